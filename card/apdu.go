@@ -3,6 +3,7 @@ package card
 import (
 	"crypto/ecdsa"
 	"errors"
+	"fmt"
 
 	"github.com/GridPlus/keycard-go"
 	"github.com/GridPlus/keycard-go/apdu"
@@ -38,6 +39,8 @@ const (
 	InsReceiveInvoice     = 0x55
 	InsGetAvailableMemory = 0x99
 	InsMineNativePhonon   = 0x41
+	InsGetResponse        = 0xC0
+	InsGetStatus          = 0xF2
 
 	// tags
 	TagSelectAppInfo           = 0xA4
@@ -46,6 +49,8 @@ const (
 	TagAppVersion              = 0x02
 	TagPairingSlots            = 0x03
 	TagAppCapability           = 0x8D
+	TagAppStatus               = 0xA3
+	TagPINTries                = 0x02
 
 	TagPhononKeyCollection = 0x40
 	TagKeyIndex            = 0x41
@@ -82,7 +87,9 @@ const (
 	TagInvoiceID = 0x96
 
 	//extended tags
-	TagChainID = 0x20
+	TagChainID          = 0x20
+	TagPhononProvenance = 0x22
+	TagPhononCreatedAt  = 0x23
 
 	//ISO7816 Standard Responses
 	SW_APPLET_SELECT_FAILED           = 0x6999
@@ -105,6 +112,8 @@ const (
 	SW_RECORD_NOT_FOUND               = 0x6A83
 	SW_SECURE_MESSAGING_NOT_SUPPORTED = 0x6882
 	SW_SECURITY_STATUS_NOT_SATISFIED  = 0x6982
+	SW_SELECTED_FILE_DEACTIVATED      = 0x6283
+	SW_SELECTED_FILE_TERMINATED       = 0x6285
 	SW_UNKNOWN                        = 0x6F00
 	SW_WARNING_STATE_UNCHANGED        = 0x6200
 	SW_WRONG_DATA                     = 0x6A80
@@ -171,6 +180,22 @@ func NewCommandVerifyPIN(pin string) *Command {
 	}
 }
 
+// NewCommandGetStatus requests the applet status, which includes the number of PIN attempts remaining
+func NewCommandGetStatus() *Command {
+	return &Command{
+		ApduCmd: apdu.NewCommand(
+			globalplatform.ClaGp,
+			InsGetStatus,
+			0,
+			0,
+			[]byte{},
+		),
+		PossibleErrs: CmdErrTable{
+			SW_INCORRECT_P1P2: errors.New("undefined status requested"),
+		},
+	}
+}
+
 func NewCommandChangePIN(pin string) *Command {
 	return &Command{
 		ApduCmd: apdu.NewCommand(
@@ -454,14 +479,44 @@ func NewCommandInstallCert(data []byte) *Command {
 	}
 }
 
+// DefaultAppletAID is the AID of the production phonon applet.
 // put here to be next to the select applet command function
-var phononAID = []byte{0xA0, 0x00, 0x00, 0x08, 0x20, 0x00, 0x03, 0x01}
+var DefaultAppletAID = []byte{0xA0, 0x00, 0x00, 0x08, 0x20, 0x00, 0x03, 0x01}
+
+var ErrAppletNotFound = errors.New("no applet with the requested AID installed on card")
+
+// ErrAppletVersionUnknown is returned by GetAppletVersion until an initialized applet has been selected
+var ErrAppletVersionUnknown = errors.New("applet version unknown")
+
+// AID is the application identifier of an applet installed on a card
+type AID []byte
+
+// KnownAppletAIDs are the AIDs phonon applets are installed under, which ListApplets looks for by default
+var KnownAppletAIDs = []AID{DefaultAppletAID}
+
+func (aid AID) String() string {
+	return fmt.Sprintf("% X", []byte(aid))
+}
+
+// ErrAppletLocked and ErrAppletTerminated are returned by SELECT when the applet is installed but has been disabled
+var (
+	ErrAppletLocked     = errors.New("phonon applet is locked")
+	ErrAppletTerminated = errors.New("phonon applet is terminated")
+)
 
 func NewCommandSelectPhononApplet() *Command {
+	return NewCommandSelectApplet(DefaultAppletAID)
+}
+
+// NewCommandSelectApplet selects the applet with the given AID, such as a development build of the phonon applet
+func NewCommandSelectApplet(aid []byte) *Command {
 	return &Command{
-		ApduCmd: globalplatform.NewCommandSelect(phononAID),
-		// no errors known
-		PossibleErrs: CmdErrTable{},
+		ApduCmd: globalplatform.NewCommandSelect(aid),
+		PossibleErrs: CmdErrTable{
+			SW_FILE_NOT_FOUND:            ErrAppletNotFound,
+			SW_SELECTED_FILE_DEACTIVATED: ErrAppletLocked,
+			SW_SELECTED_FILE_TERMINATED:  ErrAppletTerminated,
+		},
 	}
 }
 
@@ -1,26 +1,103 @@
 package card
 
 import (
+	"context"
+
 	"github.com/GridPlus/keycard-go/io"
 	"github.com/GridPlus/phonon-client/model"
 	"github.com/GridPlus/phonon-client/usb"
 )
 
-func Connect(readerIndex int) (*PhononCommandSet, error) {
-	scard, err := usb.ConnectUSBReader(readerIndex)
+// ErrReaderTimeout is returned when the PC/SC service doesn't answer before the context's deadline
+var ErrReaderTimeout = usb.ErrReaderTimeout
+
+// ErrNoCard is wrapped by the error returned when connecting to a reader without a card in it
+var ErrNoCard = usb.ErrNoCard
+
+// ListReaders returns the names of the attached card readers, in the order used for reader indices
+func ListReaders(ctx context.Context) ([]string, error) {
+	return usb.ListReaders(ctx)
+}
+
+// CardEvent is a reader being attached or detached, or a card being inserted or removed, reported by WatchReaders
+type CardEvent = usb.CardEvent
+
+// CardEventType is the kind of change reported by a CardEvent
+type CardEventType = usb.CardEventType
+
+const (
+	ReaderAdded   = usb.ReaderAdded
+	ReaderRemoved = usb.ReaderRemoved
+	CardInserted  = usb.CardInserted
+	CardRemoved   = usb.CardRemoved
+)
+
+// WatchReaders reports readers and cards coming and going until ctx is done, starting with those already attached.
+// See usb.WatchReaders.
+func WatchReaders(ctx context.Context) (<-chan CardEvent, error) {
+	return usb.WatchReaders(ctx)
+}
+
+// Option configures a PhononCommandSet created by Connect
+type Option func(*PhononCommandSet)
+
+// WithAppletAID selects the applet with the given AID instead of the production phonon applet,
+// such as a development build installed on a simulator. Select returns ErrAppletNotFound if it isn't installed.
+func WithAppletAID(aid []byte) Option {
+	return func(cs *PhononCommandSet) {
+		cs.SetAppletAID(aid)
+	}
+}
+
+// Connect connects to the card in the reader at readerIndex, waiting at most usb.DefaultReaderTimeout for PC/SC
+func Connect(readerIndex int, opts ...Option) (*PhononCommandSet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), usb.DefaultReaderTimeout)
+	defer cancel()
+	return ConnectContext(ctx, readerIndex, opts...)
+}
+
+// ConnectContext connects to the card in the reader at readerIndex, returning ErrReaderTimeout
+// if establishing the PC/SC context and listing readers doesn't finish before ctx's deadline.
+// If there is no such reader, or no card in it, the *usb.ReaderError returned lists the attached readers.
+func ConnectContext(ctx context.Context, readerIndex int, opts ...Option) (*PhononCommandSet, error) {
+	scard, err := usb.ConnectUSBReaderContext(ctx, readerIndex)
 	if err != nil {
 		return nil, err
 	}
-	cs := NewPhononCommandSet(io.NewNormalChannel(scard))
-	return cs, nil
+	return newCommandSet(scard, opts), nil
+}
+
+// ConnectToReader connects to the card in the reader with the given name, as returned by ListReaders,
+// waiting at most usb.DefaultReaderTimeout for PC/SC
+func ConnectToReader(name string, opts ...Option) (*PhononCommandSet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), usb.DefaultReaderTimeout)
+	defer cancel()
+	return ConnectToReaderContext(ctx, name, opts...)
+}
+
+// ConnectToReaderContext is ConnectToReader with reader enumeration bounded by ctx
+func ConnectToReaderContext(ctx context.Context, name string, opts ...Option) (*PhononCommandSet, error) {
+	scard, err := usb.ConnectUSBReaderByNameContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return newCommandSet(scard, opts), nil
+}
+
+func newCommandSet(t io.Transmitter, opts []Option) *PhononCommandSet {
+	cs := NewPhononCommandSet(io.NewNormalChannel(t))
+	for _, opt := range opts {
+		opt(cs)
+	}
+	return cs
 }
 
 /*QuickSecureConnection is a convenience function which establishes a connection to the card attached
 to the readerIndex given and immediately attempts to open a secure channel with it.
 Equivalent to running SELECT, PAIR, OPEN_SECURE_CHANNEL.
 Does not handle the details of uninitialized cards*/
-func QuickSecureConnection(readerIndex int, isStatic bool) (cs model.PhononCard, err error) {
-	baseCS, err := Connect(readerIndex)
+func QuickSecureConnection(readerIndex int, isStatic bool, opts ...Option) (cs model.PhononCard, err error) {
+	baseCS, err := Connect(readerIndex, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -186,6 +186,7 @@ func parseDestroyPhononResponse(resp []byte) (privKey *ecdsa.PrivateKey, err err
 	if err != nil {
 		return nil, err
 	}
+	defer util.ZeroBytes(rawPrivKey)
 
 	privKey, err = util.ParseECCPrivKey(rawPrivKey)
 	if err != nil {
@@ -257,6 +258,66 @@ func parseSelectResponse(resp []byte) (instanceUID []byte, cardPubKey *ecdsa.Pub
 	return instanceUID, cardPubKey, cardInitialized, nil
 }
 
+/*
+parseCardInfo reads the lifecycle state, applet version and pairing slot counts from a SELECT response.
+The pairing slots value starts with the number of free slots, and applets reporting their capacity
+follow it with the total number of slots. Counts missing from the response are left unknown.
+An applet that answers with application info is initialized, while one without a PIN answers with only its public key.
+*/
+func parseCardInfo(resp []byte) model.CardInfo {
+	info := model.CardInfo{
+		TotalPairingSlots: model.UnknownPairingSlots,
+		UsedPairingSlots:  model.UnknownPairingSlots,
+		FreePairingSlots:  model.UnknownPairingSlots,
+		Version:           model.UnknownAppletVersion,
+	}
+	if len(resp) == 0 {
+		return info
+	}
+	if resp[0] != TagSelectAppInfo {
+		//an uninitialized applet answers with only its public key
+		info.Lifecycle = model.LifecyclePersonalization
+		return info
+	}
+	info.Lifecycle = model.LifecycleActive
+	collection, err := tlv.ParseTLVPacket(resp, TagSelectAppInfo)
+	if err != nil {
+		log.Debug("unable to parse application info from select response. err: ", err)
+		return info
+	}
+	version, err := collection.FindTag(TagAppVersion)
+	if err == nil && len(version) == 2 {
+		info.Version = model.AppletVersion{Major: int(version[0]), Minor: int(version[1])}
+	}
+	slots, err := collection.FindTag(TagPairingSlots)
+	if err != nil || len(slots) == 0 {
+		return info
+	}
+	info.FreePairingSlots = int(slots[0])
+	if len(slots) >= 2 && slots[1] >= slots[0] {
+		info.TotalPairingSlots = int(slots[1])
+		info.UsedPairingSlots = int(slots[1] - slots[0])
+	}
+	return info
+}
+
+// parsePINTries reads the number of PIN attempts remaining from the applet status returned by GET_STATUS
+func parsePINTries(resp []byte) (int, error) {
+	collection, err := tlv.ParseTLVPacket(resp, TagAppStatus)
+	if err != nil {
+		return 0, err
+	}
+	//the PUK tries follow under the same tag
+	tries, err := collection.FindTag(TagPINTries)
+	if err != nil {
+		return 0, err
+	}
+	if len(tries) != 1 {
+		return 0, errors.New("invalid PIN tries length")
+	}
+	return int(tries[0]), nil
+}
+
 func ParseIdentifyCardResponse(resp []byte) (cardPubKey *ecdsa.PublicKey, sig *util.ECDSASignature, err error) {
 	correctLength := 67
 	if len(resp) < correctLength {
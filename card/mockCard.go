@@ -2,7 +2,6 @@ package card
 
 import (
 	"crypto/ecdsa"
-	"crypto/rand"
 	"crypto/sha256"
 	"crypto/sha512"
 	"errors"
@@ -31,6 +30,7 @@ type MockCard struct {
 	deletedPhonons  []int
 	pin             string
 	pinVerified     bool
+	pinFailures     int
 	sc              SecureChannel
 	receiveList     []*ecdsa.PublicKey
 	identityKey     *ecdsa.PrivateKey
@@ -43,6 +43,7 @@ type MockCard struct {
 	friendlyName    string
 	mintLimit       int
 	mintRate        int
+	instanceUID     []byte
 }
 
 type MockPhonon struct {
@@ -153,7 +154,7 @@ func NewMockCard(isInitialized bool, isStatic bool) (*MockCard, error) {
 	var identityPrivKey *ecdsa.PrivateKey
 	var err error
 	if !isStatic {
-		identityPrivKey, err = ethcrypto.GenerateKey()
+		identityPrivKey, err = ecdsa.GenerateKey(ethcrypto.S256(), util.Rand())
 		if err != nil {
 			return nil, err
 		}
@@ -190,19 +191,38 @@ func NewMockCard(isInitialized bool, isStatic bool) (*MockCard, error) {
 }
 
 func (c *MockCard) Select() (instanceUID []byte, cardPubKey *ecdsa.PublicKey, cardInitialized bool, err error) {
-	instanceUID = util.RandomKey(16)
+	if c.instanceUID == nil {
+		c.instanceUID = util.RandomKey(16)
+	}
 
-	privKey, _ := ethcrypto.GenerateKey()
+	privKey, _ := ecdsa.GenerateKey(ethcrypto.S256(), util.Rand())
 	cardPubKey = &privKey.PublicKey
 
 	if c.pin == "" {
 		cardInitialized = false
 	} else {
 		cardInitialized = true
+		//like the applet, only report the UID fixed at install once initialized
+		instanceUID = c.instanceUID
 	}
 	return instanceUID, cardPubKey, cardInitialized, nil
 }
 
+// CardInfo reports unknown pairing slot counts, since the mock has no pairing slots
+func (c *MockCard) CardInfo() model.CardInfo {
+	info := parseCardInfo(nil)
+	info.Lifecycle = model.LifecyclePersonalization
+	if c.pin != "" {
+		info.Lifecycle = model.LifecycleActive
+	}
+	return info
+}
+
+// SecureChannelInfo reports a closed, unpaired channel, since the mock has no secure channel
+func (c *MockCard) SecureChannelInfo() model.SecureChannelInfo {
+	return model.SecureChannelInfo{}
+}
+
 //PIN functions
 func validatePIN(pin string) error {
 	if len(pin) != 6 {
@@ -227,18 +247,30 @@ func (c *MockCard) Init(pin string) error {
 	return nil
 }
 
+// mockPINTries is the number of incorrect PIN attempts the mock accepts before blocking its PIN, as on the applet
+const mockPINTries = 3
+
 func (c *MockCard) VerifyPIN(pin string) error {
 	if c.pin == "" {
 		return errors.New("pin not initialized")
 	}
+	if c.pinFailures >= mockPINTries {
+		return ErrPINBlocked
+	}
 	if pin != c.pin {
 		c.pinVerified = false
-		return errors.New("pin did not match")
+		c.pinFailures++
+		return checkVerifyPINErrors(uint16(0x63C0 + mockPINTries - c.pinFailures))
 	}
+	c.pinFailures = 0
 	c.pinVerified = true
 	return nil
 }
 
+func (c *MockCard) GetPINStatus() (remainingTries int, err error) {
+	return mockPINTries - c.pinFailures, nil
+}
+
 func (c *MockCard) ChangePIN(pin string) error {
 	if !c.pinVerified {
 		return errors.New("pin not verified")
@@ -252,7 +284,7 @@ func (c *MockCard) ChangePIN(pin string) error {
 }
 
 func (c *MockCard) IdentifyCard(nonce []byte) (cardPubKey *ecdsa.PublicKey, cardSig *util.ECDSASignature, err error) {
-	rawCardSig, err := ecdsa.SignASN1(rand.Reader, c.identityKey, nonce)
+	rawCardSig, err := ecdsa.SignASN1(util.Rand(), c.identityKey, nonce)
 	if err != nil {
 		return c.IdentityPubKey, nil, err
 	}
@@ -419,7 +451,7 @@ func (c *MockCard) CardPair(initCardPairingData []byte) (cardPairingData []byte,
 	//private key corresponding to the public key which established this channel's foundational ECDH secret
 	cryptogram := sha256.Sum256(append(sessionKey[0:], aesIV...))
 	c.scPairData.cryptogram = cryptogram[0:]
-	receiverSig, err := ecdsa.SignASN1(rand.Reader, c.identityKey, cryptogram[0:])
+	receiverSig, err := ecdsa.SignASN1(util.Rand(), c.identityKey, cryptogram[0:])
 	if err != nil {
 		return nil, err
 	}
@@ -506,7 +538,7 @@ func (c *MockCard) CardPair2(cardPairData []byte) (cardPair2Data []byte, err err
 	if !valid {
 		return nil, errors.New("counterparty cryptogram signature invalid")
 	}
-	senderSig, err := ecdsa.SignASN1(rand.Reader, c.identityKey, cryptogram[0:])
+	senderSig, err := ecdsa.SignASN1(util.Rand(), c.identityKey, cryptogram[0:])
 	if err != nil {
 		return nil, err
 	}
@@ -542,8 +574,15 @@ func (c *MockCard) FinalizeCardPair(cardPair2Data []byte) (err error) {
 	return nil
 }
 
-func (c *MockCard) Pair() (*cert.CardCertificate, error) {
+func (c *MockCard) Pair() (*model.PairingResult, error) {
 	//omitted since mockCard does not actually need to establish a secure channel
+	return &model.PairingResult{Cert: &c.IdentityCert}, nil
+}
+
+func (c *MockCard) GetCertificate() (*cert.CardCertificate, error) {
+	if c.IdentityCert.PubKey == nil {
+		return &cert.CardCertificate{}, errors.New("no certificate loaded")
+	}
 	return &c.IdentityCert, nil
 }
 
@@ -558,7 +597,7 @@ func (c *MockCard) CreatePhonon(curveType model.CurveType) (keyIndex model.Phono
 		deleted: false,
 	}
 	// generate key
-	private, err := ecdsa.GenerateKey(ethcrypto.S256(), rand.Reader)
+	private, err := ecdsa.GenerateKey(ethcrypto.S256(), util.Rand())
 	if err != nil {
 		return 0, nil, err
 	}
@@ -566,7 +605,7 @@ func (c *MockCard) CreatePhonon(curveType model.CurveType) (keyIndex model.Phono
 	if err != nil {
 		return 0, nil, err
 	}
-	newp.PrivateKey = private.D.Bytes()
+	newp.PrivateKey = ethcrypto.FromECDSA(private)
 	newp.CurveType = curveType
 	//add it in the correct place
 	index := c.addPhonon(&newp)
@@ -587,6 +626,8 @@ func (c *MockCard) SetDescriptor(phonon *model.Phonon) error {
 	storedPhonon.Denomination = phonon.Denomination
 	storedPhonon.ChainID = phonon.ChainID
 	storedPhonon.ExtendedSchemaVersion = phonon.ExtendedSchemaVersion
+	storedPhonon.Provenance = phonon.Provenance
+	storedPhonon.CreatedAt = phonon.CreatedAt
 
 	return nil
 }
@@ -602,13 +643,19 @@ func (c *MockCard) ListPhonons(currencyType model.CurrencyType, lessThanValue ui
 		if !phonon.deleted &&
 			(currencyType == 0x00 || phonon.CurrencyType == currencyType) &&
 			(greaterThanValue == 0 || phonon.Denomination.Value().Cmp(new(big.Int).SetUint64(greaterThanValue)) == 1) &&
-			(lessThanValue == 0 || phonon.Denomination.Value().Cmp(new(big.Int).SetUint64(greaterThanValue)) == -1) {
+			(lessThanValue == 0 || phonon.Denomination.Value().Cmp(new(big.Int).SetUint64(lessThanValue)) == -1) {
 			ret = append(ret, &phonon.Phonon)
 		}
 	}
 	return ret, nil
 }
 
+//ListPhononsPage returns every matching phonon in a single page, as the mock has no response size limit
+func (c *MockCard) ListPhononsPage(currencyType model.CurrencyType, lessThanValue uint64, greaterThanValue uint64, continues bool) (phonons []*model.Phonon, more bool, err error) {
+	phonons, err = c.ListPhonons(currencyType, lessThanValue, greaterThanValue, continues)
+	return phonons, false, err
+}
+
 func (c *MockCard) GetPhononPubKey(keyIndex model.PhononKeyIndex, crv model.CurveType) (pubkey model.PhononPubKey, err error) {
 	index := int(keyIndex)
 	if index > len(c.Phonons) || c.Phonons[index].deleted {
@@ -788,8 +835,8 @@ func (c *MockCard) ReceivePhonons(transaction []byte) (err error) {
 		phonons = append(phonons, phonon)
 	}
 	//Store all received phonons
-	for _, p := range phonons {
-		c.addPhonon(&p)
+	for i := range phonons {
+		c.addPhonon(&phonons[i])
 	}
 
 	return nil
@@ -877,13 +924,16 @@ func (c *MockCard) GetAvailableMemory() (int, int, int, error) {
 
 func (c *MockCard) MineNativePhonon(difficulty uint8) (model.PhononKeyIndex, []byte, error) {
 	buf := make([]byte, 32)
-	rand.Reader.Read(buf)
+	err := util.ReadRandom(buf)
+	if err != nil {
+		return 0, nil, err
+	}
 	fmt.Printf("generated salt for native private key: % X\n", string(buf))
 	pubKey := DeriveNativePhononPubKey(buf)
 	if !correctDifficulty(pubKey.Hash, int(difficulty)) {
 		return model.PhononKeyIndex(0), nil, ErrMiningFailed
 	}
-	r, s, err := ecdsa.Sign(rand.Reader, c.identityKey, pubKey.Bytes())
+	r, s, err := ecdsa.Sign(util.Rand(), c.identityKey, pubKey.Bytes())
 	if err != nil {
 		return 0, nil, err
 	}
@@ -897,6 +947,7 @@ func (c *MockCard) MineNativePhonon(difficulty uint8) (model.PhononKeyIndex, []b
 			PubKey:      pubKey,
 			CurveType:   model.NativeCurve,
 			ExtendedTLV: []tlv.TLV{sigTLV},
+			Provenance:  model.ProvenanceMined,
 		},
 		buf,
 		false,
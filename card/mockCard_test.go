@@ -1,8 +1,11 @@
 package card
 
 import (
-	"github.com/GridPlus/phonon-client/cert"
 	"testing"
+	"time"
+
+	"github.com/GridPlus/phonon-client/cert"
+	"github.com/GridPlus/phonon-client/model"
 )
 
 func TestCardPair(t *testing.T) {
@@ -37,3 +40,78 @@ func TestCardPair(t *testing.T) {
 	}
 
 }
+
+func TestProvenanceSurvivesTransfer(t *testing.T) {
+	mock, err := NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mock.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyIndex, _, err := mock.CreatePhonon(model.Secp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mock.SetDescriptor(&model.Phonon{KeyIndex: keyIndex, CurrencyType: model.Bitcoin, Provenance: model.ProvenanceImported})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transferTLV, err := mock.Phonons[keyIndex].Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, err := decodePhononTLV(transferTLV.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.Provenance != model.ProvenanceImported {
+		t.Errorf("expected provenance %v after transfer, got %v", model.ProvenanceImported, received.Provenance)
+	}
+
+	//native phonons are mined by the card without a provenance descriptor
+	native := MockPhonon{Phonon: model.Phonon{CurveType: model.NativeCurve}, PrivateKey: []byte("salt")}
+	transferTLV, err = native.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, err = decodePhononTLV(transferTLV.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.Provenance != model.ProvenanceMined {
+		t.Errorf("expected native phonon to be reported as mined, got %v", received.Provenance)
+	}
+}
+
+func TestCreatedAtSurvivesTransfer(t *testing.T) {
+	createdAt := time.Date(2026, time.March, 14, 15, 9, 26, 0, time.UTC)
+	p := MockPhonon{Phonon: model.Phonon{CurrencyType: model.Bitcoin, CreatedAt: createdAt}, PrivateKey: append(make([]byte, 31), 1)}
+	transferTLV, err := p.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, err := decodePhononTLV(transferTLV.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !received.CreatedAt.Equal(createdAt) {
+		t.Errorf("expected creation time %v after transfer, got %v", createdAt, received.CreatedAt)
+	}
+
+	//phonons from before creation times were recorded carry none
+	legacy := MockPhonon{Phonon: model.Phonon{CurrencyType: model.Bitcoin}, PrivateKey: append(make([]byte, 31), 1)}
+	transferTLV, err = legacy.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	received, err = decodePhononTLV(transferTLV.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !received.CreatedAt.IsZero() {
+		t.Errorf("expected no creation time, got %v", received.CreatedAt)
+	}
+}
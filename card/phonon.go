@@ -3,6 +3,7 @@ package card
 import (
 	"encoding/binary"
 	"errors"
+	"time"
 
 	"github.com/GridPlus/phonon-client/model"
 	"github.com/GridPlus/phonon-client/tlv"
@@ -55,6 +56,22 @@ func TLVEncodePhononDescriptor(p *model.Phonon) ([]byte, error) {
 		return nil, err
 	}
 	p.ExtendedTLV = append(p.ExtendedTLV, chainIDTLV)
+	if p.Provenance != model.ProvenanceUnknown {
+		provenanceTLV, err := tlv.NewTLV(TagPhononProvenance, []byte{byte(p.Provenance)})
+		if err != nil {
+			return nil, err
+		}
+		p.ExtendedTLV = append(p.ExtendedTLV, provenanceTLV)
+	}
+	if !p.CreatedAt.IsZero() {
+		createdAtBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(createdAtBytes, uint64(p.CreatedAt.Unix()))
+		createdAtTLV, err := tlv.NewTLV(TagPhononCreatedAt, createdAtBytes)
+		if err != nil {
+			return nil, err
+		}
+		p.ExtendedTLV = append(p.ExtendedTLV, createdAtTLV)
+	}
 
 	phononTLV := append(schemaVersionTLV.Encode(), extendedSchemaVersionTLV.Encode()...)
 	phononTLV = append(phononTLV, denomBaseTLV.Encode()...)
@@ -165,6 +182,16 @@ func TLVDecodePublicPhononFields(phononTLV tlv.TLVCollection) (*model.Phonon, er
 				phonon.ChainID = int(entry.Value[0])
 			}
 		}
+		if entry.Tag == TagPhononProvenance && len(entry.Value) == 1 {
+			phonon.Provenance = model.PhononProvenance(entry.Value[0])
+		}
+		if entry.Tag == TagPhononCreatedAt && len(entry.Value) == 8 {
+			phonon.CreatedAt = time.Unix(int64(binary.BigEndian.Uint64(entry.Value)), 0).UTC()
+		}
+	}
+	//cards mine native phonons without writing a descriptor
+	if phonon.Provenance == model.ProvenanceUnknown && phonon.CurveType == model.NativeCurve {
+		phonon.Provenance = model.ProvenanceMined
 	}
 	return phonon, nil
 }
@@ -1,12 +1,12 @@
 package card
 
 import (
+	"bytes"
 	"crypto/ecdsa"
-	"crypto/rand"
 	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 
@@ -39,6 +39,7 @@ var (
 	ErrKeyIndexInvalid   = errors.New("key index out of valid range")
 	ErrOutOfMemory       = errors.New("card out of memory")
 	ErrPINNotEntered     = errors.New("valid PIN required")
+	ErrPINBlocked        = errors.New("PIN blocked after too many incorrect attempts")
 	ErrUnknown           = errors.New("unknown error")
 )
 
@@ -51,6 +52,8 @@ type PhononCommandSet struct {
 	ApplicationInfo *types.ApplicationInfo
 	PairingInfo     *types.PairingInfo
 	PhononCACert    []byte
+	appletAID       []byte
+	info            model.CardInfo
 }
 
 func NewPhononCommandSet(c types.Channel) *PhononCommandSet {
@@ -82,14 +85,31 @@ func NewPhononCommandSet(c types.Channel) *PhononCommandSet {
 		Level:     log.DebugLevel,
 	}
 
+	appletAID := DefaultAppletAID
+	if conf.AppletAID != "" {
+		appletAID, err = hex.DecodeString(conf.AppletAID)
+		if err != nil {
+			log.Error("configured applet AID is not valid hex, using default. err: ", err)
+			appletAID = DefaultAppletAID
+		}
+	}
+	//fetch responses too large for a single APDU before they reach the command set or secure channel
+	c = newChainingChannel(c)
 	return &PhononCommandSet{
 		c:               c,
 		sc:              NewSecureChannel(c),
 		ApplicationInfo: &types.ApplicationInfo{},
 		PhononCACert:    cert.SelectCACertByName(conf.Certificate),
+		appletAID:       appletAID,
+		info:            parseCardInfo(nil),
 	}
 }
 
+// SetAppletAID changes the AID sent by Select, for targeting applets other than the production phonon applet
+func (cs *PhononCommandSet) SetAppletAID(aid []byte) {
+	cs.appletAID = aid
+}
+
 func (cs PhononCommandSet) Send(cmd *Command) (*apdu.Response, error) {
 	//Log commands to apdu log
 	//Log APDUs in debugger format to file
@@ -108,13 +128,20 @@ func (cs PhononCommandSet) Send(cmd *Command) (*apdu.Response, error) {
 
 //Selects the phonon applet for further usage
 func (cs *PhononCommandSet) Select() (instanceUID []byte, cardPubKey *ecdsa.PublicKey, cardInitialized bool, err error) {
-	cmd := NewCommandSelectPhononApplet()
+	cmd := NewCommandSelectApplet(cs.appletAID)
 	cmd.ApduCmd.SetLe(0)
 
 	log.Debug("sending SELECT apdu")
 	resp, err := cs.Send(cmd)
 	if err != nil {
 		log.Error("could not send select command. err: ", err)
+		cs.info = parseCardInfo(nil)
+		switch err {
+		case ErrAppletLocked:
+			cs.info.Lifecycle = model.LifecycleLocked
+		case ErrAppletTerminated:
+			cs.info.Lifecycle = model.LifecycleTerminated
+		}
 		return nil, nil, false, err
 	}
 
@@ -127,6 +154,7 @@ func (cs *PhononCommandSet) Select() (instanceUID []byte, cardPubKey *ecdsa.Publ
 		log.Error("error parsing select response. err: ", err)
 		return nil, nil, false, err
 	}
+	cs.info = parseCardInfo(resp.Data)
 
 	//Generate secure channel secrets using card's public key
 	secretsErr := cs.sc.GenerateSecret(ethcrypto.FromECDSAPub(cardPubKey))
@@ -139,16 +167,151 @@ func (cs *PhononCommandSet) Select() (instanceUID []byte, cardPubKey *ecdsa.Publ
 	return instanceUID, cardPubKey, cardInitialized, nil
 }
 
-func (cs *PhononCommandSet) Pair() (*cert.CardCertificate, error) {
+/*
+ListApplets returns which of candidates are installed on the card, such as different applet versions,
+by selecting each in turn. Without candidates it looks for KnownAppletAIDs and the AID set with SetAppletAID.
+Phonon applets don't report their AID when selected, so applets under AIDs that aren't asked for aren't found.
+Locked and terminated applets are listed as installed although they can't be used.
+The last applet tried is left selected, so SelectAID or Select must be called before any other command.
+*/
+func (cs *PhononCommandSet) ListApplets(candidates ...AID) ([]AID, error) {
+	if len(candidates) == 0 {
+		candidates = append(candidates, KnownAppletAIDs...)
+		if !containsAID(candidates, cs.appletAID) {
+			candidates = append(candidates, cs.appletAID)
+		}
+	}
+	var aids []AID
+	for _, aid := range candidates {
+		_, err := cs.Send(NewCommandSelectApplet(aid))
+		if err == ErrAppletNotFound {
+			continue
+		}
+		if err != nil && err != ErrAppletLocked && err != ErrAppletTerminated {
+			log.Error("could not select applet. err: ", err)
+			return nil, err
+		}
+		aids = append(aids, aid)
+	}
+	return aids, nil
+}
+
+func containsAID(aids []AID, aid []byte) bool {
+	for _, a := range aids {
+		if bytes.Equal(a, aid) {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectAID selects the applet with the given AID, such as one returned by ListApplets, which is then used by Select.
+// It returns ErrAppletNotFound if no applet with that AID is installed, leaving the previous AID in use.
+func (cs *PhononCommandSet) SelectAID(aid []byte) error {
+	return cs.selectAID(aid, cs.Select)
+}
+
+func (cs *PhononCommandSet) selectAID(aid []byte, selectApplet func() ([]byte, *ecdsa.PublicKey, bool, error)) error {
+	previous := cs.appletAID
+	cs.appletAID = aid
+	_, _, _, err := selectApplet()
+	if err != nil {
+		cs.appletAID = previous
+	}
+	return err
+}
+
+// GetAppletVersion returns the applet version reported when it was last selected,
+// or ErrAppletVersionUnknown if it hasn't been selected or isn't initialized
+func (cs *PhononCommandSet) GetAppletVersion() (model.AppletVersion, error) {
+	if cs.info.Version == model.UnknownAppletVersion {
+		return cs.info.Version, ErrAppletVersionUnknown
+	}
+	return cs.info.Version, nil
+}
+
+// CardInfo returns the applet information parsed from the last SELECT response
+func (cs *PhononCommandSet) CardInfo() model.CardInfo {
+	return cs.info
+}
+
+// SecureChannelInfo describes the current secure channel and the pairing slot it was opened with
+func (cs *PhononCommandSet) SecureChannelInfo() model.SecureChannelInfo {
+	info := model.SecureChannelInfo{
+		Open:        cs.sc.IsOpen(),
+		CipherSuite: SecureChannelCipherSuite,
+	}
+	if cs.PairingInfo != nil {
+		info.Paired = true
+		info.PairingSlot = cs.PairingInfo.Index
+	}
+	return info
+}
+
+func (cs *PhononCommandSet) Pair() (*model.PairingResult, error) {
 	log.Debug("sending PAIR command")
+	salt, cardCert, secretHash, err := cs.pairStep1()
+	if err != nil {
+		return &model.PairingResult{}, err
+	}
+	cryptogram := sha256.Sum256(append(salt, secretHash...))
+
+	log.Debug("sending PAIR step 2 cmd")
+	cmd := NewCommandPairStep2(cryptogram)
+	resp, err := cs.Send(cmd)
+	if err != nil {
+		log.Error("error sending pair step 2 command. err: ", err)
+		return &model.PairingResult{}, err
+	}
+
+	err = checkPairingErrors(2, resp.Sw)
+	if err != nil {
+		return &model.PairingResult{}, err
+	}
+	pairStep2Resp, err := gridplus.ParsePairStep2Response(resp.Data)
+	if err != nil {
+		log.Error("could not parse pair step 2 response. err: ", err)
+		return &model.PairingResult{}, err
+	}
+	log.Debugf("pairStep2Resp: % X", pairStep2Resp)
+
+	//Derive Pairing Key
+	pairingKey := sha256.Sum256(append(pairStep2Resp.Salt, secretHash...))
+	log.Debugf("derived pairing key: % X", pairingKey)
+
+	//Store pairing info for use in OpenSecureChannel
+	cs.setPairingInfo(pairingKey[0:], pairStep2Resp.PairingIdx)
+
+	log.Debug("pairing succeeded")
+	return cs.pairingResult(cardCert), nil
+}
+
+//GetCertificate reads and validates the card's certificate using only the first, unauthenticated step of PAIR.
+//The pairing is never completed, so no pairing slot is consumed and no secure channel is required.
+func (cs *PhononCommandSet) GetCertificate() (*cert.CardCertificate, error) {
+	log.Debug("requesting card certificate")
+	_, cardCert, _, err := cs.pairStep1()
+	if err != nil {
+		return &cert.CardCertificate{}, err
+	}
+	return &cardCert, nil
+}
+
+//pairStep1 exchanges a fresh pairing key with the card, validating its certificate and
+//its signature over the salted ECDH secret
+func (cs *PhononCommandSet) pairStep1() (salt []byte, cardCert cert.CardCertificate, secretHash []byte, err error) {
 	//Generate random salt and keypair
 	clientSalt := make([]byte, 32)
-	rand.Read(clientSalt)
+	err = util.ReadRandom(clientSalt)
+	if err != nil {
+		log.Error("unable to generate pairing salt. err: ", err)
+		return nil, cardCert, nil, err
+	}
 
-	pairingPrivKey, err := ethcrypto.GenerateKey()
+	pairingPrivKey, err := ecdsa.GenerateKey(ethcrypto.S256(), util.Rand())
 	if err != nil {
 		log.Error("unable to generate pairing keypair. err: ", err)
-		return &cert.CardCertificate{}, err
+		return nil, cardCert, nil, err
 	}
 	pairingPubKey := pairingPrivKey.PublicKey
 
@@ -158,28 +321,28 @@ func (cs *PhononCommandSet) Pair() (*cert.CardCertificate, error) {
 	resp, err := cs.Send(cmd)
 	if err != nil {
 		log.Error("unable to send Pair Step 1 command. err: ", err)
-		return &cert.CardCertificate{}, err
+		return nil, cardCert, nil, err
 	}
 	err = checkPairingErrors(1, resp.Sw)
 	if err != nil {
-		return &cert.CardCertificate{}, err
+		return nil, cardCert, nil, err
 	}
 
 	salt, cardCert, signature, err := ParsePairStep1Response(resp.Data)
 	if err != nil {
 		log.Error("could not parse pair step 1 response. err: ", err)
-		return &cert.CardCertificate{}, err
+		return nil, cardCert, nil, err
 	}
 
 	cardCertPubKey, err := util.ParseECCPubKey(cardCert.PubKey)
 	if err != nil {
-		return &cert.CardCertificate{}, err
+		return nil, cardCert, nil, err
 	}
 	//Validate card's certificate has valid GridPlus signature
 	err = cert.ValidateCardCertificate(cardCert, cs.PhononCACert)
 	if err != nil {
 		log.Error("unable to verify card certificate signature")
-		return &cert.CardCertificate{}, err
+		return nil, cardCert, nil, err
 	}
 	log.Debug("certificate signature valid")
 
@@ -187,51 +350,22 @@ func (cs *PhononCommandSet) Pair() (*cert.CardCertificate, error) {
 	log.Debug("certificate public key valid: ", pubKeyValid)
 	if !pubKeyValid {
 		log.Error("card pubkey invalid")
-		return &cert.CardCertificate{}, errors.New("certificate pubkey invalid")
+		return nil, cardCert, nil, errors.New("certificate pubkey invalid")
 	}
 
 	//challenge message test
 	ecdhSecret := crypto.GenerateECDHSharedSecret(pairingPrivKey, cardCertPubKey)
 
 	secretHashArray := sha256.Sum256(append(clientSalt, ecdhSecret...))
-	secretHash := secretHashArray[0:]
+	secretHash = secretHashArray[0:]
 
 	//validate that card created valid signature over same salted and hashed ecdh secret
 	valid := ecdsa.VerifyASN1(cardCertPubKey, secretHash, signature)
 	if !valid {
 		log.Error("ecdsa sig not valid")
-		return &cert.CardCertificate{}, errors.New("could not verify shared secret challenge")
-	}
-	cryptogram := sha256.Sum256(append(salt, secretHash...))
-
-	log.Debug("sending PAIR step 2 cmd")
-	cmd = NewCommandPairStep2(cryptogram)
-	resp, err = cs.Send(cmd)
-	if err != nil {
-		log.Error("error sending pair step 2 command. err: ", err)
-		return &cert.CardCertificate{}, err
-	}
-
-	err = checkPairingErrors(2, resp.Sw)
-	if err != nil {
-		return &cert.CardCertificate{}, err
-	}
-	pairStep2Resp, err := gridplus.ParsePairStep2Response(resp.Data)
-	if err != nil {
-		log.Error("could not parse pair step 2 response. err: ", err)
-		return &cert.CardCertificate{}, err
+		return nil, cardCert, nil, errors.New("could not verify shared secret challenge")
 	}
-	log.Debugf("pairStep2Resp: % X", pairStep2Resp)
-
-	//Derive Pairing Key
-	pairingKey := sha256.Sum256(append(pairStep2Resp.Salt, secretHash...))
-	log.Debugf("derived pairing key: % X", pairingKey)
-
-	//Store pairing info for use in OpenSecureChannel
-	cs.setPairingInfo(pairingKey[0:], pairStep2Resp.PairingIdx)
-
-	log.Debug("pairing succeeded")
-	return &cardCert, nil
+	return salt, cardCert, secretHash, nil
 }
 
 //checkPairingErrors takes a pairing step, either 1 or 2, and the SW value of the response to return appropriate error messages
@@ -274,6 +408,16 @@ func (cs *PhononCommandSet) setPairingInfo(key []byte, index int) {
 	}
 }
 
+//pairingResult describes the pairing just stored in PairingInfo
+func (cs *PhononCommandSet) pairingResult(cardCert cert.CardCertificate) *model.PairingResult {
+	id := sha256.Sum256(cs.PairingInfo.Key)
+	return &model.PairingResult{
+		Cert: &cardCert,
+		Slot: cs.PairingInfo.Index,
+		ID:   id[:],
+	}
+}
+
 func (cs *PhononCommandSet) Unpair(index uint8) error {
 	log.Debug("sending UNPAIR command")
 	cmd := NewCommandUnpair(index)
@@ -307,7 +451,7 @@ func (cs *PhononCommandSet) OpenSecureChannel() error {
 func (cs *PhononCommandSet) mutualAuthenticate() error {
 	log.Debug("sending MUTUAL_AUTH command")
 	data := make([]byte, 32)
-	if _, err := rand.Read(data); err != nil {
+	if err := util.ReadRandom(data); err != nil {
 		return err
 	}
 
@@ -418,29 +562,57 @@ func (cs *PhononCommandSet) IdentifyCard(nonce []byte) (cardPubKey *ecdsa.Public
 	return cardPubKey, cardSig, nil
 }
 
+// WrongPINError is returned by VerifyPIN when the PIN is incorrect but the card still accepts further attempts
+type WrongPINError struct {
+	TriesRemaining int
+}
+
+func (e *WrongPINError) Error() string {
+	return fmt.Sprintf("incorrect pin, %d tries remaining", e.TriesRemaining)
+}
+
+// VerifyPIN unlocks the card with its PIN. An incorrect PIN fails with a *WrongPINError holding the number of
+// attempts left, and once none are left with ErrPINBlocked.
 func (cs *PhononCommandSet) VerifyPIN(pin string) error {
 	log.Debug("sending VERIFY_PIN command")
 	cmd := NewCommandVerifyPIN(pin)
 	resp, err := cs.sc.Send(cmd)
-	if err != nil {
-		log.Error("could not send VERIFY_PIN command", err)
-		return err
+	if resp != nil {
+		//the status word reporting the tries remaining isn't in the command's error table
+		if pinErr := checkVerifyPINErrors(resp.Sw); pinErr != nil {
+			log.Error("error verifying pin: ", pinErr)
+			return pinErr
+		}
 	}
-	triesRemaining, err := checkVerifyPINErrors(resp.Sw)
 	if err != nil {
-		log.Error("error verifying pin: ", err)
-		log.Error("triesRemaining: ", triesRemaining)
+		log.Error("could not send VERIFY_PIN command", err)
 		return err
 	}
 	return nil
 }
 
-func checkVerifyPINErrors(status uint16) (triesRemaining int, err error) {
+func checkVerifyPINErrors(status uint16) error {
 	if status >= 0x63C0 && status < 0x63D0 {
-		triesRemaining = int(status - 0x63C0)
-		return triesRemaining, errors.New("incorrect pin")
+		triesRemaining := int(status - 0x63C0)
+		if triesRemaining == 0 {
+			return ErrPINBlocked
+		}
+		return &WrongPINError{TriesRemaining: triesRemaining}
 	}
-	return 0, nil
+	return nil
+}
+
+// GetPINStatus returns the number of incorrect PIN attempts the card accepts before it blocks the PIN
+func (cs *PhononCommandSet) GetPINStatus() (remainingTries int, err error) {
+	log.Debug("sending GET_STATUS command")
+	cmd := NewCommandGetStatus()
+	resp, err := cs.sc.Send(cmd)
+	err = cs.checkOK(resp, err)
+	if err != nil {
+		log.Error("could not get applet status: ", err)
+		return 0, err
+	}
+	return parsePINTries(resp.Data)
 }
 
 func (cs *PhononCommandSet) ChangePIN(pin string) error {
@@ -514,10 +686,27 @@ func (cs *PhononCommandSet) SetDescriptor(p *model.Phonon) error {
 //Set lessThanValue or greaterThanValue to 0 to ignore the parameter. Returned phonons omit the public key to reduce data transmission
 //After processing, the list client should send GET_PHONON_PUB_KEY to retrieve the corresponding pubkeys if necessary.
 func (cs *PhononCommandSet) ListPhonons(currencyType model.CurrencyType, lessThanValue uint64, greaterThanValue uint64, continuation bool) ([]*model.Phonon, error) {
+	var phonons []*model.Phonon
+	for {
+		page, more, err := cs.ListPhononsPage(currencyType, lessThanValue, greaterThanValue, continuation)
+		if err != nil {
+			return nil, err
+		}
+		phonons = append(phonons, page...)
+		if !more {
+			return phonons, nil
+		}
+		continuation = true
+	}
+}
+
+//ListPhononsPage sends a single LIST_PHONONS command, returning the phonons in the card's response
+//and whether more remain to be read by a following call with continuation set
+func (cs *PhononCommandSet) ListPhononsPage(currencyType model.CurrencyType, lessThanValue uint64, greaterThanValue uint64, continuation bool) (phonons []*model.Phonon, more bool, err error) {
 	log.Debug("sending LIST_PHONONS command")
 	p2, cmdData, err := encodeListPhononsData(currencyType, lessThanValue, greaterThanValue)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	var p1 byte
@@ -530,35 +719,27 @@ func (cs *PhononCommandSet) ListPhonons(currencyType model.CurrencyType, lessTha
 	resp, err := cs.sc.Send(cmd)
 	if err != nil && err != ErrDefault {
 		log.Error("error in sending listPhonons. err: ", err)
-		return nil, err
+		return nil, false, err
 	}
 
 	err = checkPhononTableErrors(resp.Sw)
 	if err != nil {
 		log.Error("phonon table error detected: ", err)
-		return nil, err
+		return nil, false, err
 	}
 
-	continuation, err = checkContinuation(resp.Sw)
+	more, err = checkContinuation(resp.Sw)
 	if err != nil {
 		log.Error("error detected while checking for list continuation. err: ", err)
-		return nil, err
+		return nil, false, err
 	}
 
-	phonons, err := parseListPhononsResponse(resp.Data)
+	phonons, err = parseListPhononsResponse(resp.Data)
 	if err != nil {
 		log.Error("could not parse list phonons response: ", err)
-		return nil, err
-	}
-	if continuation {
-		extendedPhonons, err := cs.ListPhonons(currencyType, lessThanValue, greaterThanValue, continuation)
-		if err != nil {
-			log.Error("could not read extended phonons list: ", err)
-			return nil, err
-		}
-		phonons = append(phonons, extendedPhonons...)
+		return nil, false, err
 	}
-	return phonons, nil
+	return phonons, more, nil
 }
 
 //Generally checks status, including extended responses
@@ -616,6 +797,8 @@ func (cs *PhononCommandSet) DestroyPhonon(keyIndex model.PhononKeyIndex) (privKe
 		return nil, err
 	}
 	privKey, err = parseDestroyPhononResponse(resp.Data)
+	//the decrypted response holds the private key, which is only kept in privKey
+	util.ZeroBytes(resp.Data)
 	if err != nil {
 		return nil, err
 	}
@@ -746,6 +929,19 @@ func (cs *PhononCommandSet) InitCardPairing(receiverCert cert.CardCertificate) (
 	return resp.Data, nil
 }
 
+//InitPairingCertificate returns the initiating card's certificate from the response to INIT_CARD_PAIRING
+func InitPairingCertificate(initPairingData []byte) (cert.CardCertificate, error) {
+	collection, err := tlv.ParseTLVPacket(initPairingData)
+	if err != nil {
+		return cert.CardCertificate{}, err
+	}
+	rawCert, err := collection.FindTag(TagCardCertificate)
+	if err != nil {
+		return cert.CardCertificate{}, err
+	}
+	return cert.ParseRawCardCertificate(rawCert)
+}
+
 //CardPair takes the response from initCardPairing and passes it to the counterparty card
 //for the next step of pairing
 func (cs *PhononCommandSet) CardPair(initPairingData []byte) (cardPairData []byte, err error) {
@@ -794,13 +990,10 @@ func (cs *PhononCommandSet) FinalizeCardPair(cardPair2Data []byte) (err error) {
 
 func (cs *PhononCommandSet) InstallCertificate(signKeyFunc func([]byte) ([]byte, error)) (err error) {
 	nonce := make([]byte, 32)
-	n, err := io.ReadFull(rand.Reader, nonce)
+	err = util.ReadRandom(nonce)
 	if err != nil {
 		return fmt.Errorf("unable to retrieve random challenge for card: %s", err.Error())
 	}
-	if n != 32 {
-		return fmt.Errorf("unable to read 32 bytes for challenge to card")
-	}
 
 	// Send Challenge to card
 	cardPubKey, sig, err := cs.IdentifyCard(nonce)
@@ -1,10 +1,15 @@
 package card
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"math/big"
 	"testing"
 
+	"github.com/GridPlus/keycard-go/apdu"
+	"github.com/GridPlus/keycard-go/globalplatform"
+	"github.com/GridPlus/keycard-go/hexutils"
 	"github.com/GridPlus/keycard-go/io"
 	"github.com/GridPlus/phonon-client/model"
 	"github.com/GridPlus/phonon-client/usb"
@@ -50,6 +55,173 @@ func TestSelect(t *testing.T) {
 	log.Debugf("cardPubKey: % X", cardPubKey)
 }
 
+func TestSelectAppletNotFound(t *testing.T) {
+	sc := &scriptedChannel{responses: []*apdu.Response{response(nil, SW_FILE_NOT_FOUND)}}
+	cs := NewPhononCommandSet(sc)
+	devAID := []byte{0xA0, 0x00, 0x00, 0x08, 0x20, 0x00, 0x03, 0xFF}
+	WithAppletAID(devAID)(cs)
+
+	_, _, _, err := cs.Select()
+	if err != ErrAppletNotFound {
+		t.Errorf("expected ErrAppletNotFound, got %v", err)
+	}
+	if len(sc.sent) != 1 || !bytes.Equal(sc.sent[0].Data, devAID) {
+		t.Errorf("expected SELECT for % X, sent %v", devAID, sc.sent)
+	}
+}
+
+func TestListApplets(t *testing.T) {
+	devAID := []byte{0xA0, 0x00, 0x00, 0x08, 0x20, 0x00, 0x03, 0x02}
+	//an initialized applet answers with its application info, an uninitialized one with only its public key
+	sc := &scriptedChannel{responses: []*apdu.Response{
+		response([]byte{TagSelectAppInfo, 0x02, TagAppVersion, 0x00}, 0x9000),
+		response([]byte{TagCardSecureChannelPubKey, 0x01, 0x04}, 0x9000),
+	}}
+	cs := NewPhononCommandSet(sc)
+	WithAppletAID(devAID)(cs)
+
+	aids, err := cs.ListApplets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []AID{DefaultAppletAID, devAID}
+	if !cmp.Equal(aids, expected) {
+		t.Errorf("expected applets %v, got %v", expected, aids)
+	}
+	for i, cmd := range sc.sent {
+		if cmd.Ins != globalplatform.InsSelect || !bytes.Equal(cmd.Data, expected[i]) {
+			t.Errorf("unexpected command %d: INS %X data % X", i, cmd.Ins, cmd.Data)
+		}
+	}
+
+	lockedAID := []byte{0xA0, 0x00, 0x00, 0x08, 0x20, 0x00, 0x03, 0x03}
+	cs = NewPhononCommandSet(&scriptedChannel{responses: []*apdu.Response{
+		response(nil, SW_FILE_NOT_FOUND),
+		response(nil, SW_SELECTED_FILE_DEACTIVATED),
+	}})
+	aids, err = cs.ListApplets(devAID, lockedAID)
+	if err != nil || !cmp.Equal(aids, []AID{lockedAID}) {
+		t.Errorf("expected only the locked applet to be listed, got %v, %v", aids, err)
+	}
+}
+
+func TestSelectAIDNotFound(t *testing.T) {
+	cs := NewPhononCommandSet(&scriptedChannel{responses: []*apdu.Response{response(nil, SW_FILE_NOT_FOUND)}})
+	err := cs.SelectAID([]byte{0xA0, 0x00, 0x00, 0x08, 0x20, 0x00, 0x03, 0xFF})
+	if err != ErrAppletNotFound {
+		t.Errorf("expected ErrAppletNotFound, got %v", err)
+	}
+	if !bytes.Equal(cs.appletAID, DefaultAppletAID) {
+		t.Errorf("expected the default AID to stay in use, got % X", cs.appletAID)
+	}
+}
+
+func TestSelectCardInfo(t *testing.T) {
+	selectResponse := func(slots ...byte) []byte {
+		//application info captured from an initialized card, which reports only its free slots
+		resp := hexutils.HexToBytes("A4 5F 8F 10 48 C5 33 14 41 93 28 CF DA 80 9D CB BB F2 AC A9 80 41 04 FC D8 88 73 52 BA 5D C1 F4 4E AA F9 BD A1 63 FB C0 66 1E B6 5C 78 9A FE 5F D8 14 D6 C1 66 EB CB B9 C7 C6 0E 65 66 AF 4E 3C 85 75 95 01 42 6F 53 85 E0 42 A3 37 47 B3 D9 33 2E DA 74 86 B0 5E 1F 02 02 00 01")
+		resp = append(resp, TagPairingSlots, byte(len(slots)))
+		resp = append(resp, slots...)
+		resp = append(resp, TagAppCapability, 0x01, 0x07)
+		resp[1] = byte(len(resp) - 2)
+		return resp
+	}
+	unknown := model.UnknownPairingSlots
+	version := model.AppletVersion{Major: 0, Minor: 1}
+	selects := []struct {
+		resp     []byte
+		expected model.CardInfo
+	}{
+		{selectResponse(0x02, 0x05), model.CardInfo{TotalPairingSlots: 5, UsedPairingSlots: 3, FreePairingSlots: 2, Lifecycle: model.LifecycleActive, Version: version}},
+		{selectResponse(0x00), model.CardInfo{TotalPairingSlots: unknown, UsedPairingSlots: unknown, FreePairingSlots: 0, Lifecycle: model.LifecycleActive, Version: version}},
+	}
+	for _, s := range selects {
+		sc := &scriptedChannel{responses: []*apdu.Response{response(s.resp, 0x9000)}}
+		cs := NewPhononCommandSet(sc)
+		if cs.CardInfo() != parseCardInfo(nil) {
+			t.Errorf("expected unknown slot counts before select, got %+v", cs.CardInfo())
+		}
+		_, err := cs.GetAppletVersion()
+		if err != ErrAppletVersionUnknown {
+			t.Errorf("expected %v before select, got %v", ErrAppletVersionUnknown, err)
+		}
+		_, _, initialized, err := cs.Select()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !initialized || cs.CardInfo() != s.expected {
+			t.Errorf("expected %+v, got %+v", s.expected, cs.CardInfo())
+		}
+		v, err := cs.GetAppletVersion()
+		if err != nil || v != version || !v.AtLeast(0, 1) || v.AtLeast(0, 2) {
+			t.Errorf("expected applet version %v, got %v, %v", version, v, err)
+		}
+	}
+}
+
+func TestSelectDisabledApplet(t *testing.T) {
+	disabled := map[uint16]struct {
+		err   error
+		state model.LifecycleState
+	}{
+		SW_SELECTED_FILE_DEACTIVATED: {ErrAppletLocked, model.LifecycleLocked},
+		SW_SELECTED_FILE_TERMINATED:  {ErrAppletTerminated, model.LifecycleTerminated},
+	}
+	for sw, expected := range disabled {
+		cs := NewPhononCommandSet(&scriptedChannel{responses: []*apdu.Response{response(nil, sw)}})
+		_, _, _, err := cs.Select()
+		if err != expected.err {
+			t.Errorf("expected %v for sw % X, got %v", expected.err, sw, err)
+		}
+		if cs.CardInfo().Lifecycle != expected.state {
+			t.Errorf("expected the applet to be reported %v, got %v", expected.state, cs.CardInfo().Lifecycle)
+		}
+	}
+}
+
+func TestPINErrors(t *testing.T) {
+	var wrongPIN *WrongPINError
+	err := checkVerifyPINErrors(0x63C2)
+	if !errors.As(err, &wrongPIN) || wrongPIN.TriesRemaining != 2 {
+		t.Errorf("expected a wrong PIN with 2 tries remaining, got %v", err)
+	}
+	if err := checkVerifyPINErrors(0x63C0); err != ErrPINBlocked {
+		t.Errorf("expected %v, got %v", ErrPINBlocked, err)
+	}
+	if err := checkVerifyPINErrors(0x9000); err != nil {
+		t.Errorf("expected success, got %v", err)
+	}
+	//PIN tries, PUK tries and key initialized
+	tries, err := parsePINTries([]byte{TagAppStatus, 0x09, 0x02, 0x01, 0x03, 0x02, 0x01, 0x05, 0x01, 0x01, 0xFF})
+	if err != nil || tries != 3 {
+		t.Errorf("expected 3 PIN tries, got %v, %v", tries, err)
+	}
+}
+
+func TestSecureChannelInfo(t *testing.T) {
+	cs := NewPhononCommandSet(&scriptedChannel{})
+	info := cs.SecureChannelInfo()
+	expected := model.SecureChannelInfo{CipherSuite: SecureChannelCipherSuite}
+	if info != expected {
+		t.Errorf("expected %+v before pairing, got %+v", expected, info)
+	}
+
+	cs.setPairingInfo(bytes.Repeat([]byte{0x01}, 32), 3)
+	cs.sc.Init(make([]byte, 16), make([]byte, 32), make([]byte, 32))
+	info = cs.SecureChannelInfo()
+	expected = model.SecureChannelInfo{Open: true, Paired: true, PairingSlot: 3, CipherSuite: SecureChannelCipherSuite}
+	if info != expected {
+		t.Errorf("expected %+v once open, got %+v", expected, info)
+	}
+	cs.sc.Reset()
+	if cs.SecureChannelInfo().Open {
+		t.Error("expected the channel to be reported closed after reset")
+	}
+	if !NewStaticPhononCommandSet(cs).SecureChannelInfo().Static {
+		t.Error("expected the static command set to report its static keys")
+	}
+}
+
 //PAIR
 //OPEN_SECURE_CHANNEL
 //MUTUAL_AUTH
@@ -0,0 +1,55 @@
+package card
+
+import (
+	"errors"
+
+	"github.com/GridPlus/keycard-go/apdu"
+	"github.com/GridPlus/keycard-go/types"
+)
+
+// GET RESPONSE is an interindustry command regardless of the class of the command it continues
+const claISO7816 = 0x00
+
+// maxChainedResponses bounds GET RESPONSE requests for a single command in case a card never finishes
+const maxChainedResponses = 64
+
+var ErrResponseChainTooLong = errors.New("card kept signalling more response data")
+
+/*
+chainingChannel handles responses larger than a single APDU.
+When the card answers with SW1 0x61 it holds SW2 more bytes (0 meaning 256), which are fetched
+with GET RESPONSE and appended until the card returns a final status word.
+The caller only sees the concatenated data and that final status.
+*/
+type chainingChannel struct {
+	c types.Channel
+}
+
+func newChainingChannel(c types.Channel) types.Channel {
+	if _, ok := c.(*chainingChannel); ok {
+		return c
+	}
+	return &chainingChannel{c: c}
+}
+
+func (cc *chainingChannel) Send(cmd *apdu.Command) (*apdu.Response, error) {
+	resp, err := cc.c.Send(cmd)
+	if err != nil {
+		return resp, err
+	}
+	data := resp.Data
+	for i := 0; resp.Sw1 == SW_BYTES_REMAINING_00>>8; i++ {
+		if i == maxChainedResponses {
+			return nil, ErrResponseChainTooLong
+		}
+		getResponse := apdu.NewCommand(claISO7816, InsGetResponse, 0, 0, nil)
+		getResponse.SetLe(resp.Sw2)
+		resp, err = cc.c.Send(getResponse)
+		if err != nil {
+			return resp, err
+		}
+		data = append(data, resp.Data...)
+	}
+	resp.Data = data
+	return resp, nil
+}
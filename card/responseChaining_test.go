@@ -0,0 +1,66 @@
+package card
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/GridPlus/keycard-go/apdu"
+)
+
+// scriptedChannel answers each command with the next scripted response, recording what was sent
+type scriptedChannel struct {
+	responses []*apdu.Response
+	sent      []*apdu.Command
+}
+
+func (sc *scriptedChannel) Send(cmd *apdu.Command) (*apdu.Response, error) {
+	sc.sent = append(sc.sent, cmd)
+	resp := sc.responses[0]
+	sc.responses = sc.responses[1:]
+	return resp, nil
+}
+
+func response(data []byte, sw uint16) *apdu.Response {
+	return &apdu.Response{Data: data, Sw1: uint8(sw >> 8), Sw2: uint8(sw), Sw: sw}
+}
+
+func TestChainedResponse(t *testing.T) {
+	first := bytes.Repeat([]byte{0x01}, 256)
+	second := bytes.Repeat([]byte{0x02}, 256)
+	third := bytes.Repeat([]byte{0x03}, 20)
+	sc := &scriptedChannel{responses: []*apdu.Response{
+		response(first, 0x6100),
+		response(second, 0x6114),
+		response(third, 0x9000),
+	}}
+
+	resp, err := newChainingChannel(sc).Send(apdu.NewCommand(0x80, InsListPhonons, 0, 0, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := append(append(append([]byte{}, first...), second...), third...)
+	if !bytes.Equal(resp.Data, expected) || resp.Sw != 0x9000 {
+		t.Errorf("expected %d bytes with status 9000, got %d bytes with status %X", len(expected), len(resp.Data), resp.Sw)
+	}
+	if len(sc.sent) != 3 {
+		t.Fatalf("expected 2 GET RESPONSE commands after the original, got %d commands", len(sc.sent))
+	}
+	for i, le := range []uint8{0x00, 0x14} {
+		cmd := sc.sent[i+1]
+		_, cmdLe := cmd.Le()
+		if cmd.Ins != InsGetResponse || cmdLe != le {
+			t.Errorf("GET RESPONSE %d: expected ins %X le %X, got ins %X le %X", i, InsGetResponse, le, cmd.Ins, cmdLe)
+		}
+	}
+}
+
+func TestUnchainedResponse(t *testing.T) {
+	sc := &scriptedChannel{responses: []*apdu.Response{response([]byte{0xAA}, 0x6A82)}}
+	resp, err := newChainingChannel(sc).Send(apdu.NewCommand(0x80, InsListPhonons, 0, 0, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sc.sent) != 1 || resp.Sw != 0x6A82 {
+		t.Errorf("expected a single command passing through status 6A82, got %d commands and status %X", len(sc.sent), resp.Sw)
+	}
+}
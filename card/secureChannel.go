@@ -11,12 +11,16 @@ import (
 	"github.com/GridPlus/keycard-go/globalplatform"
 	"github.com/GridPlus/keycard-go/hexutils"
 	"github.com/GridPlus/keycard-go/types"
+	"github.com/GridPlus/phonon-client/util"
 	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 	log "github.com/sirupsen/logrus"
 )
 
 var ErrInvalidResponseMAC = errors.New("invalid response MAC")
 
+// SecureChannelCipherSuite names the key agreement, encryption and MAC used by every secure channel
+const SecureChannelCipherSuite = "ECDH-secp256k1 AES-256-CBC AES-256-CBC-MAC"
+
 type SecureChannel struct {
 	c         types.Channel
 	open      bool
@@ -34,7 +38,7 @@ func NewSecureChannel(c types.Channel) *SecureChannel {
 }
 
 func (sc *SecureChannel) GenerateSecret(cardPubKeyData []byte) error {
-	key, err := ethcrypto.GenerateKey()
+	key, err := ecdsa.GenerateKey(ethcrypto.S256(), util.Rand())
 	if err != nil {
 		return err
 	}
@@ -83,6 +87,11 @@ func (sc *SecureChannel) Init(iv, encKey, macKey []byte) {
 	sc.open = true
 }
 
+// IsOpen reports whether commands are currently being encrypted and MACed for the card
+func (sc *SecureChannel) IsOpen() bool {
+	return sc.open
+}
+
 func (sc *SecureChannel) Secret() []byte {
 	return sc.secret
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/GridPlus/keycard-go/crypto"
 	"github.com/GridPlus/keycard-go/gridplus"
 	"github.com/GridPlus/phonon-client/cert"
+	"github.com/GridPlus/phonon-client/model"
 	"github.com/GridPlus/phonon-client/util"
 
 	ethcrypto "github.com/ethereum/go-ethereum/crypto"
@@ -37,8 +38,13 @@ func staticBytes(length int) []byte {
 	return bytes
 }
 
+// SelectAID selects the applet with the given AID using the static pairing keys, see PhononCommandSet.SelectAID
+func (cs *StaticPhononCommandSet) SelectAID(aid []byte) error {
+	return cs.selectAID(aid, cs.Select)
+}
+
 func (cs *StaticPhononCommandSet) Select() (instanceUID []byte, cardPubKey *ecdsa.PublicKey, cardInitialized bool, err error) {
-	cmd := NewCommandSelectPhononApplet()
+	cmd := NewCommandSelectApplet(cs.appletAID)
 	cmd.ApduCmd.SetLe(0)
 
 	log.Debug("sending static SELECT command")
@@ -56,6 +62,7 @@ func (cs *StaticPhononCommandSet) Select() (instanceUID []byte, cardPubKey *ecds
 		log.Error("error parsing select response. err: ", err)
 		return nil, nil, false, err
 	}
+	cs.info = parseCardInfo(resp.Data)
 
 	//Generate secure channel secrets using card's public key
 	secretsErr := cs.sc.GenerateStaticSecret(ethcrypto.FromECDSAPub(cardPubKey))
@@ -68,7 +75,13 @@ func (cs *StaticPhononCommandSet) Select() (instanceUID []byte, cardPubKey *ecds
 	return instanceUID, cardPubKey, cardInitialized, nil
 }
 
-func (cs *StaticPhononCommandSet) Pair() (*cert.CardCertificate, error) {
+func (cs *StaticPhononCommandSet) SecureChannelInfo() model.SecureChannelInfo {
+	info := cs.PhononCommandSet.SecureChannelInfo()
+	info.Static = true
+	return info
+}
+
+func (cs *StaticPhononCommandSet) Pair() (*model.PairingResult, error) {
 	log.Debug("sending static PAIR command")
 	//Generate static salt
 	clientSalt := staticBytes(32)
@@ -80,7 +93,7 @@ func (cs *StaticPhononCommandSet) Pair() (*cert.CardCertificate, error) {
 	pairingPrivKey, err := ecdsa.GenerateKey(ethcrypto.S256(), r)
 	if err != nil {
 		log.Error("unable to generate pairing keypair. err: ", err)
-		return &cert.CardCertificate{}, err
+		return &model.PairingResult{}, err
 	}
 	pairingPubKey := pairingPrivKey.PublicKey
 
@@ -90,28 +103,28 @@ func (cs *StaticPhononCommandSet) Pair() (*cert.CardCertificate, error) {
 	resp, err := cs.Send(cmd)
 	if err != nil {
 		log.Error("unable to send Pair Step 1 command. err: ", err)
-		return &cert.CardCertificate{}, err
+		return &model.PairingResult{}, err
 	}
 	err = checkPairingErrors(1, resp.Sw)
 	if err != nil {
-		return &cert.CardCertificate{}, err
+		return &model.PairingResult{}, err
 	}
 
 	salt, cardCert, signature, err := ParsePairStep1Response(resp.Data)
 	if err != nil {
 		log.Error("could not parse pair step 1 response. err: ", err)
-		return &cert.CardCertificate{}, err
+		return &model.PairingResult{}, err
 	}
 
 	cardCertPubKey, err := util.ParseECCPubKey(cardCert.PubKey)
 	if err != nil {
-		return &cert.CardCertificate{}, err
+		return &model.PairingResult{}, err
 	}
 	//Validate card's certificate has valid GridPlus signature
 	err = cert.ValidateCardCertificate(cardCert, gridplus.SafecardDevCAPubKey)
 	if err != nil {
 		log.Error("unable to verify card certificate.")
-		return &cert.CardCertificate{}, err
+		return &model.PairingResult{}, err
 	}
 	log.Debug("certificate signature valid")
 
@@ -119,7 +132,7 @@ func (cs *StaticPhononCommandSet) Pair() (*cert.CardCertificate, error) {
 	log.Debug("certificate public key valid: ", pubKeyValid)
 	if !pubKeyValid {
 		log.Error("card pubkey invalid")
-		return &cert.CardCertificate{}, err
+		return &model.PairingResult{}, err
 	}
 
 	//challenge message test
@@ -132,7 +145,7 @@ func (cs *StaticPhononCommandSet) Pair() (*cert.CardCertificate, error) {
 	valid := ecdsa.VerifyASN1(cardCertPubKey, secretHash, signature)
 	if !valid {
 		log.Error("ecdsa sig not valid")
-		return &cert.CardCertificate{}, errors.New("could not verify shared secret challenge")
+		return &model.PairingResult{}, errors.New("could not verify shared secret challenge")
 	}
 	cryptogram := sha256.Sum256(append(salt, secretHash...))
 
@@ -141,17 +154,17 @@ func (cs *StaticPhononCommandSet) Pair() (*cert.CardCertificate, error) {
 	resp, err = cs.Send(cmd)
 	if err != nil {
 		log.Error("error sending pair step 2 command. err: ", err)
-		return &cert.CardCertificate{}, err
+		return &model.PairingResult{}, err
 	}
 
 	err = checkPairingErrors(2, resp.Sw)
 	if err != nil {
-		return &cert.CardCertificate{}, err
+		return &model.PairingResult{}, err
 	}
 	pairStep2Resp, err := gridplus.ParsePairStep2Response(resp.Data)
 	if err != nil {
 		log.Error("could not parse pair step 2 response. err: ", err)
-		return &cert.CardCertificate{}, err
+		return &model.PairingResult{}, err
 	}
 	log.Debugf("pairStep2Resp: % X", pairStep2Resp)
 
@@ -163,7 +176,7 @@ func (cs *StaticPhononCommandSet) Pair() (*cert.CardCertificate, error) {
 	cs.setPairingInfo(pairingKey[0:], pairStep2Resp.PairingIdx)
 
 	log.Debug("pairing succeeded")
-	return &cardCert, nil
+	return cs.pairingResult(cardCert), nil
 }
 
 func (cs *StaticPhononCommandSet) OpenSecureChannel() error {
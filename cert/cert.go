@@ -4,6 +4,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"math/big"
@@ -84,24 +85,29 @@ var ErrInvalidCert = errors.New("certificate signature was invalid")
 //Accepts a safecard certificate and validates it against the provided CA PubKey
 //Safecard CA's provided by SafecardProdCAPubKey or SafecardDevCAPubKey for the respective environments
 func ValidateCardCertificate(cert CardCertificate, CAPubKey []byte) error {
-	//Hash of cert excepting signature, certType, and certLen
-	certBytes := cert.Digest()
-	certHash := sha256.Sum256(certBytes)
-
 	CApubKey, err := util.ParseECCPubKey(CAPubKey)
 	if err != nil {
 		log.Error("could not parse CAPubKey: ", err)
 		return err
 	}
 	log.Debug("certificate authority pubkey was valid")
+	return cert.Verify(CApubKey)
+}
+
+// Verify checks the certificate's signature over the card's public key and permissions was made by the CA with caPubKey,
+// such as PhononAlphaCAPubKey for issued cards. It returns ErrInvalidCert if it wasn't or the signature is malformed.
+func (cert CardCertificate) Verify(caPubKey *ecdsa.PublicKey) error {
+	if caPubKey == nil {
+		return errors.New("no certificate authority key to verify certificate with")
+	}
 	signature, err := util.ParseECDSASignature(cert.Sig)
 	if err != nil {
 		log.Error("could not parse cert signature: ", err)
-		return err
+		return fmt.Errorf("%w: %v", ErrInvalidCert, err)
 	}
-
-	valid := ecdsa.Verify(CApubKey, certHash[0:], signature.R, signature.S)
-	if !valid {
+	//Hash of cert excepting signature, certType, and certLen
+	certHash := sha256.Sum256(cert.Digest())
+	if !ecdsa.Verify(caPubKey, certHash[:], signature.R, signature.S) {
 		return ErrInvalidCert
 	}
 	return nil
@@ -189,36 +195,91 @@ func GetSignerWithPrivateKey(privKey ecdsa.PrivateKey) func([]byte) ([]byte, err
 	}
 }
 
+// Certificate format versions, identified by the first byte of the raw certificate
+const (
+	// CertVersion1 is the original format with a single byte certificate length
+	CertVersion1 byte = 0x30
+	// CertVersion2 widens the certificate length to two bytes so larger keys and signatures fit
+	CertVersion2 byte = 0x31
+	// CurrentCertVersion is written when serializing certificates of unknown version
+	CurrentCertVersion = CertVersion1
+)
+
+var ErrUnsupportedCertVersion = errors.New("unsupported card certificate version")
+
+//Parses a raw certificate, dispatching on the version byte so older and newer cards interoperate
 func ParseRawCardCertificate(cardCertificateRaw []byte) (cert CardCertificate, err error) {
+	if len(cardCertificateRaw) < 1 {
+		return CardCertificate{}, errors.New("card certificate empty")
+	}
+	switch cardCertificateRaw[0] {
+	case CertVersion1:
+		return parseCardCertificateV1(cardCertificateRaw)
+	case CertVersion2:
+		return parseCardCertificateV2(cardCertificateRaw)
+	default:
+		log.Debugf("unsupported certificate version % X", cardCertificateRaw[0])
+		return CardCertificate{}, ErrUnsupportedCertVersion
+	}
+}
+
+//certType (1) | certLen (1) | permType | permLen | permissions | pubKeyType | pubKeyLen | pubKey | sig
+func parseCardCertificateV1(cardCertificateRaw []byte) (cert CardCertificate, err error) {
 	if len(cardCertificateRaw) < 4 {
 		return CardCertificate{}, errors.New("card certificate length too short to read permissions length")
 	}
 	cert.Permissions.certType = cardCertificateRaw[0]
 	cert.Permissions.certLen = cardCertificateRaw[1]
-	cert.Permissions.permType = cardCertificateRaw[2]
-	cert.Permissions.permLen = cardCertificateRaw[3]
+	if cert.Permissions.certLen == 0 {
+		log.Debugf("invalid certificate found: % X", cardCertificateRaw)
+		return CardCertificate{}, errors.New("card certificate invalid")
+	}
+	return parseCertificateBody(cert, cardCertificateRaw, 2, int(cert.Permissions.certLen))
+}
 
-	if cert.Permissions.certLen == 0 || cert.Permissions.permLen == 0 {
+//certType (1) | certLen (2, big endian) | permType | permLen | permissions | pubKeyType | pubKeyLen | pubKey | sig
+func parseCardCertificateV2(cardCertificateRaw []byte) (cert CardCertificate, err error) {
+	if len(cardCertificateRaw) < 5 {
+		return CardCertificate{}, errors.New("card certificate length too short to read permissions length")
+	}
+	cert.Permissions.certType = cardCertificateRaw[0]
+	certLength := int(binary.BigEndian.Uint16(cardCertificateRaw[1:3]))
+	if certLength == 0 {
+		log.Debugf("invalid certificate found: % X", cardCertificateRaw)
+		return CardCertificate{}, errors.New("card certificate invalid")
+	}
+	return parseCertificateBody(cert, cardCertificateRaw, 3, certLength)
+}
+
+//parses the version independent permissions, pubkey and signature starting at offset
+func parseCertificateBody(cert CardCertificate, cardCertificateRaw []byte, offset int, certLength int) (CardCertificate, error) {
+	raw := cardCertificateRaw[offset:]
+	if len(raw) < 2 {
+		return CardCertificate{}, errors.New("card certificate length too short to read permissions length")
+	}
+	cert.Permissions.permType = raw[0]
+	cert.Permissions.permLen = raw[1]
+
+	if cert.Permissions.permLen == 0 {
 		log.Debugf("invalid certificate found: % X", cardCertificateRaw)
 		return CardCertificate{}, errors.New("card certificate invalid")
 	}
 	permsLen := int(cert.Permissions.permLen)
-	if len(cardCertificateRaw) < 5+permsLen {
+	if len(raw) < 4+permsLen {
 		return CardCertificate{}, errors.New("card certificate too short to read full permissions block")
 	}
-	cert.Permissions.permissions = cardCertificateRaw[4 : 4+permsLen]
-	cert.Permissions.pubKeyType = cardCertificateRaw[4+permsLen]
-	cert.Permissions.pubKeyLen = cardCertificateRaw[5+permsLen]
+	cert.Permissions.permissions = raw[2 : 2+permsLen]
+	cert.Permissions.pubKeyType = raw[2+permsLen]
+	cert.Permissions.pubKeyLen = raw[3+permsLen]
 	pubKeyLen := int(cert.Permissions.pubKeyLen)
-	certLength := int(cert.Permissions.certLen)
-	if len(cardCertificateRaw) < certLength {
+	if len(cardCertificateRaw) < certLength || certLength < offset+4+permsLen+pubKeyLen {
 		return CardCertificate{}, errors.New("card certificate was incorrect length")
 	}
-	if len(cardCertificateRaw) < 6+permsLen+pubKeyLen {
+	if len(raw) < 4+permsLen+pubKeyLen {
 		return CardCertificate{}, errors.New("card certificate incorrect length")
 	}
-	cert.PubKey = cardCertificateRaw[6+int(cert.Permissions.permLen) : 6+permsLen+pubKeyLen]
-	cert.Sig = cardCertificateRaw[6+permsLen+pubKeyLen : certLength]
+	cert.PubKey = raw[4+permsLen : 4+permsLen+pubKeyLen]
+	cert.Sig = raw[4+permsLen+pubKeyLen : certLength-offset]
 
 	return cert, nil
 }
@@ -239,21 +300,34 @@ func (cert CardCertificate) Digest() []byte {
 }
 
 //Serialize the full certificate, including the cert type and length
-//which are unused in the certificate signature
+//which are unused in the certificate signature.
+//Written in the version the certificate was parsed from, or CurrentCertVersion if it has none
 func (cert CardCertificate) Serialize() []byte {
-	bytes := []byte{
-		cert.Permissions.certType,
-		cert.Permissions.certLen,
+	body := append(cert.Digest(), cert.Sig...)
+	switch cert.Permissions.certType {
+	case 0:
+		cert.Permissions.certType = CurrentCertVersion
+		cert.Permissions.certLen = byte(2 + len(body))
+		return cert.Serialize()
+	case CertVersion2:
+		bytes := []byte{CertVersion2}
+		bytes = binary.BigEndian.AppendUint16(bytes, uint16(3+len(body)))
+		return append(bytes, body...)
+	default:
+		bytes := []byte{
+			cert.Permissions.certType,
+			cert.Permissions.certLen,
+		}
+		return append(bytes, body...)
 	}
-	bytes = append(bytes, cert.Digest()...)
-	bytes = append(bytes, cert.Sig...)
-	return bytes
 }
 
 func (cert CardCertificate) String() string {
 	return fmt.Sprintf("Permissions: % X, PubKey: % X (length: %v), Sig: % X (length %v)", cert.Permissions, cert.PubKey, len(cert.PubKey), cert.Sig, len(cert.Sig))
 }
 
+// SelectCACertByName returns the CA public key for a certificate name from the config, defaulting to the alpha CA.
+// There is no production CA key yet, so only the alpha and demo CAs can be selected.
 func SelectCACertByName(name string) []byte {
 	//Select cert based on provided certificate name
 	switch strings.ToLower(name) {
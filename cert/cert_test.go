@@ -0,0 +1,132 @@
+package cert
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/GridPlus/phonon-client/util"
+)
+
+// certificates for the same card key signed by PhononMockCAPrivKey, in each supported format
+var certFixtures = map[byte]string{
+	CertVersion1: "309102020000804104d85502049946d11d42bd5bba6de23e638b3f933a6849868da243d76df936409afb2e1b114c7934380fb62d8099055db1461839023da4b046e4f4271086fa6f4d3046022100d4e8ae378a6ad1b5be5a1dae1848dcab7b3c9f1d4aab2917d643e72a7f217a5f022100cc40ce3ba03baa6d4ed6b62957750ba53a38d6322a39db842581feecd6903e8a",
+	CertVersion2: "31009202020000804104d85502049946d11d42bd5bba6de23e638b3f933a6849868da243d76df936409afb2e1b114c7934380fb62d8099055db1461839023da4b046e4f4271086fa6f4d3046022100d4e8ae378a6ad1b5be5a1dae1848dcab7b3c9f1d4aab2917d643e72a7f217a5f022100cc40ce3ba03baa6d4ed6b62957750ba53a38d6322a39db842581feecd6903e8a",
+}
+
+func TestParseVersionedCertificates(t *testing.T) {
+	var pubKeys [][]byte
+	for version, fixture := range certFixtures {
+		raw, _ := hex.DecodeString(fixture)
+		c, err := ParseRawCardCertificate(raw)
+		if err != nil {
+			t.Fatalf("unable to parse version % X certificate: %v", version, err)
+		}
+		err = ValidateCardCertificate(c, PhononMockCAPubKey)
+		if err != nil {
+			t.Errorf("version % X certificate did not validate: %v", version, err)
+		}
+		if !bytes.Equal(c.Serialize(), raw) {
+			t.Errorf("version % X certificate did not serialize back to its original bytes", version)
+		}
+		pubKeys = append(pubKeys, c.PubKey)
+	}
+	if !bytes.Equal(pubKeys[0], pubKeys[1]) {
+		t.Error("certificate versions disagree on the card public key")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	raw, _ := hex.DecodeString(certFixtures[CurrentCertVersion])
+	caPubKey, err := util.ParseECCPubKey(PhononMockCAPubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCAPubKey, err := util.ParseECCPubKey(PhononDemoCAPubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := ParseRawCardCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = valid.Verify(caPubKey)
+	if err != nil {
+		t.Fatal("valid certificate did not verify: ", err)
+	}
+	err = valid.Verify(otherCAPubKey)
+	if err != ErrInvalidCert {
+		t.Errorf("expected %v for another CA, got %v", ErrInvalidCert, err)
+	}
+
+	tamperedKey, _ := ParseRawCardCertificate(raw)
+	tamperedKey.PubKey = append([]byte{}, valid.PubKey...)
+	tamperedKey.PubKey[10] ^= 0x01
+	tamperedSig, _ := ParseRawCardCertificate(raw)
+	tamperedSig.Sig = append([]byte{}, valid.Sig...)
+	tamperedSig.Sig[len(tamperedSig.Sig)-1] ^= 0x01
+	truncatedSig, _ := ParseRawCardCertificate(raw)
+	truncatedSig.Sig = valid.Sig[:10]
+	for name, c := range map[string]CardCertificate{"public key": tamperedKey, "signature": tamperedSig, "truncated signature": truncatedSig} {
+		err = c.Verify(caPubKey)
+		if !errors.Is(err, ErrInvalidCert) {
+			t.Errorf("expected %v with a tampered %v, got %v", ErrInvalidCert, name, err)
+		}
+	}
+}
+
+func TestUnsupportedCertVersion(t *testing.T) {
+	raw, _ := hex.DecodeString(certFixtures[CertVersion1])
+	raw[0] = 0x3F
+	_, err := ParseRawCardCertificate(raw)
+	if err != ErrUnsupportedCertVersion {
+		t.Errorf("expected %v, got %v", ErrUnsupportedCertVersion, err)
+	}
+}
+
+func TestSerializeWritesCurrentVersion(t *testing.T) {
+	raw, _ := hex.DecodeString(certFixtures[CertVersion1])
+	c, err := ParseRawCardCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Permissions.certType = 0
+	c.Permissions.certLen = 0
+	if !bytes.Equal(c.Serialize(), raw) {
+		t.Errorf("expected certificate without a version to serialize as version % X", CurrentCertVersion)
+	}
+}
+
+func TestPEMRoundTrip(t *testing.T) {
+	for version, fixture := range certFixtures {
+		raw, _ := hex.DecodeString(fixture)
+		c, err := ParseRawCardCertificate(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := c.ToPEM()
+		if err != nil {
+			t.Fatalf("unable to encode version % X certificate: %v", version, err)
+		}
+		decoded, err := FromPEM(encoded)
+		if err != nil {
+			t.Fatalf("unable to decode version % X certificate: %v", version, err)
+		}
+		if !bytes.Equal(decoded.Serialize(), raw) {
+			t.Errorf("version % X certificate changed in a PEM round trip", version)
+		}
+		if !bytes.Equal(decoded.PubKey, c.PubKey) {
+			t.Errorf("version % X certificate public key changed in a PEM round trip", version)
+		}
+	}
+
+	_, err := FromPEM([]byte("-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n"))
+	if !errors.Is(err, ErrInvalidPEM) {
+		t.Errorf("expected %v for a foreign block type, got %v", ErrInvalidPEM, err)
+	}
+	_, err = FromPEM([]byte("not pem"))
+	if err != ErrInvalidPEM {
+		t.Errorf("expected %v for non PEM data, got %v", ErrInvalidPEM, err)
+	}
+}
@@ -0,0 +1,42 @@
+package cert
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// PEMBlockType labels a card certificate's PEM block. The block holds the raw certificate exactly as read from the card
+const PEMBlockType = "PHONON CARD CERTIFICATE"
+
+var ErrInvalidPEM = errors.New("data is not a PEM encoded card certificate")
+
+//ToPEM encodes the certificate as a PEM block so it can be handed to tools outside the client for verification.
+//The block's bytes are the serialized certificate, so the signature still covers them unchanged
+func (cert CardCertificate) ToPEM() ([]byte, error) {
+	if len(cert.PubKey) == 0 {
+		return nil, errors.New("card certificate has no public key")
+	}
+	var buf bytes.Buffer
+	err := pem.Encode(&buf, &pem.Block{
+		Type:  PEMBlockType,
+		Bytes: cert.Serialize(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//FromPEM parses a card certificate from the first PEM block in data, which must be labelled PEMBlockType
+func FromPEM(data []byte) (CardCertificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return CardCertificate{}, ErrInvalidPEM
+	}
+	if block.Type != PEMBlockType {
+		return CardCertificate{}, fmt.Errorf("%w: found block type %q", ErrInvalidPEM, block.Type)
+	}
+	return ParseRawCardCertificate(block.Bytes)
+}
@@ -0,0 +1,177 @@
+package cert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/GridPlus/phonon-client/util"
+)
+
+var ErrCertificateRevoked = errors.New("card certificate has been revoked")
+var ErrInvalidRevocationList = errors.New("revocation list signature was invalid")
+var ErrStaleRevocationList = errors.New("revocation list is older than the last one accepted")
+
+const revocationListDomain = "phonon card revocation list"
+
+// maxRevocationListSize bounds a fetched revocation list
+const maxRevocationListSize = 1 << 20
+
+/*
+RevocationList is a set of revoked card IDs, such as cards reported stolen or known to be compromised.
+A certificate is revoked if the card ID of its public key, as returned by util.CardIDFromPubKey, is on the list.
+
+Lists can be built locally with NewRevocationList, or published by a certificate authority as a signed list
+and loaded with ParseRevocationList or FetchRevocationList, which reject lists not signed by the CA.
+Each published list carries a signed sequence number the CA increases with every list it publishes, so an older
+list, from before a card was revoked, can't be passed off as current. Loading fails with ErrStaleRevocationList
+if the list's sequence number is lower than that of the last list accepted.
+*/
+type RevocationList struct {
+	revoked  map[string]bool
+	sequence uint64 //sequence number of the published list, 0 for lists built locally
+	mtex     sync.RWMutex
+}
+
+// signedRevocationList is the published form of a revocation list, signed by the CA over its digest
+type signedRevocationList struct {
+	Revoked   []string `json:"revoked"`
+	Sequence  uint64   `json:"sequence"`
+	Signature string   `json:"signature"` //hex encoded ASN.1 ECDSA signature
+}
+
+func NewRevocationList(cardIDs []string) *RevocationList {
+	r := &RevocationList{
+		revoked: make(map[string]bool, len(cardIDs)),
+	}
+	for _, id := range cardIDs {
+		r.revoked[strings.ToLower(id)] = true
+	}
+	return r
+}
+
+// Sequence returns the sequence number the list was published with, or 0 if it was built locally
+func (r *RevocationList) Sequence() uint64 {
+	return r.sequence
+}
+
+// Revoke adds a card ID to the list
+func (r *RevocationList) Revoke(cardID string) {
+	r.mtex.Lock()
+	defer r.mtex.Unlock()
+	r.revoked[strings.ToLower(cardID)] = true
+}
+
+// IsRevoked reports whether the card ID is on the list
+func (r *RevocationList) IsRevoked(cardID string) bool {
+	r.mtex.RLock()
+	defer r.mtex.RUnlock()
+	return r.revoked[strings.ToLower(cardID)]
+}
+
+// Check returns ErrCertificateRevoked if the certificate's card is on the list
+func (r *RevocationList) Check(cert CardCertificate) error {
+	pubKey, err := util.ParseECCPubKey(cert.PubKey)
+	if err != nil {
+		return err
+	}
+	cardID := util.CardIDFromPubKey(pubKey)
+	if r.IsRevoked(cardID) {
+		return fmt.Errorf("%w: card %s", ErrCertificateRevoked, cardID)
+	}
+	return nil
+}
+
+// revocationListDigest is the hash signed by the CA, covering the sequence number and every card ID in sorted order
+func revocationListDigest(sequence uint64, cardIDs []string) []byte {
+	sorted := make([]string, len(cardIDs))
+	for i, id := range cardIDs {
+		sorted[i] = strings.ToLower(id)
+	}
+	sort.Strings(sorted)
+	h := sha256.New()
+	h.Write([]byte(revocationListDomain))
+	binary.Write(h, binary.BigEndian, sequence)
+	for _, id := range sorted {
+		h.Write([]byte(id))
+		h.Write([]byte{'\n'})
+	}
+	return h.Sum(nil)
+}
+
+// SignRevocationList produces a published revocation list, signing with the key supplied in the signKeyFunc as for certificates.
+// sequence must be higher than that of every list published before it.
+func SignRevocationList(cardIDs []string, sequence uint64, signKeyFunc func([]byte) ([]byte, error)) ([]byte, error) {
+	sig, err := signKeyFunc(revocationListDigest(sequence, cardIDs))
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign revocation list: %s", err.Error())
+	}
+	return json.Marshal(signedRevocationList{
+		Revoked:   cardIDs,
+		Sequence:  sequence,
+		Signature: hex.EncodeToString(sig),
+	})
+}
+
+// ParseRevocationList loads a published revocation list, checking it was signed by the CA and is no older than last,
+// the last list accepted. last may be nil if no list has been accepted yet.
+func ParseRevocationList(data []byte, CAPubKey []byte, last *RevocationList) (*RevocationList, error) {
+	var signed signedRevocationList
+	err := json.Unmarshal(data, &signed)
+	if err != nil {
+		return nil, err
+	}
+	CApubKey, err := util.ParseECCPubKey(CAPubKey)
+	if err != nil {
+		return nil, err
+	}
+	sigBytes, err := hex.DecodeString(signed.Signature)
+	if err != nil {
+		return nil, ErrInvalidRevocationList
+	}
+	signature, err := util.ParseECDSASignature(sigBytes)
+	if err != nil {
+		return nil, ErrInvalidRevocationList
+	}
+	digest := sha256.Sum256(revocationListDigest(signed.Sequence, signed.Revoked))
+	if !ecdsa.Verify(CApubKey, digest[:], signature.R, signature.S) {
+		return nil, ErrInvalidRevocationList
+	}
+	if last != nil && signed.Sequence < last.Sequence() {
+		return nil, fmt.Errorf("%w: sequence %d, last accepted %d", ErrStaleRevocationList, signed.Sequence, last.Sequence())
+	}
+	list := NewRevocationList(signed.Revoked)
+	list.sequence = signed.Sequence
+	return list, nil
+}
+
+// FetchRevocationList downloads and parses a published revocation list, see ParseRevocationList
+func FetchRevocationList(ctx context.Context, url string, CAPubKey []byte, last *RevocationList) (*RevocationList, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch revocation list: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRevocationListSize))
+	if err != nil {
+		return nil, err
+	}
+	return ParseRevocationList(data, CAPubKey, last)
+}
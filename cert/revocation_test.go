@@ -0,0 +1,96 @@
+package cert
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/GridPlus/phonon-client/util"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestRevocationList(t *testing.T) {
+	caKey, err := ethcrypto.ToECDSA(PhononMockCAPrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedKey, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	goodKey, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedID := util.CardIDFromPubKey(&revokedKey.PublicKey)
+
+	published, err := SignRevocationList([]string{revokedID, "00112233aabbccdd"}, 2, GetSignerWithPrivateKey(*caKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := ParseRevocationList(published, PhononMockCAPubKey, nil)
+	if err != nil {
+		t.Fatal("unable to parse signed revocation list: ", err)
+	}
+	err = list.Check(CardCertificate{PubKey: ethcrypto.FromECDSAPub(&revokedKey.PublicKey)})
+	if !errors.Is(err, ErrCertificateRevoked) {
+		t.Errorf("expected revoked card to be rejected, got %v", err)
+	}
+	err = list.Check(CardCertificate{PubKey: ethcrypto.FromECDSAPub(&goodKey.PublicKey)})
+	if err != nil {
+		t.Errorf("expected card not on the list to pass, got %v", err)
+	}
+
+	_, err = ParseRevocationList(published, PhononDemoCAPubKey, nil)
+	if err != ErrInvalidRevocationList {
+		t.Errorf("expected list signed by another CA to be rejected, got %v", err)
+	}
+	tampered, err := SignRevocationList([]string{revokedID}, 2, GetSignerWithPrivateKey(*caKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered = []byte(string(tampered[:len(`{"revoked":[`)]) + `"ffffffffffffffff",` + string(tampered[len(`{"revoked":[`):]))
+	_, err = ParseRevocationList(tampered, PhononMockCAPubKey, nil)
+	if err != ErrInvalidRevocationList {
+		t.Errorf("expected altered list to be rejected, got %v", err)
+	}
+}
+
+func TestStaleRevocationList(t *testing.T) {
+	caKey, err := ethcrypto.ToECDSA(PhononMockCAPrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	older, err := SignRevocationList(nil, 1, GetSignerWithPrivateKey(*caKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := SignRevocationList([]string{"00112233aabbccdd"}, 2, GetSignerWithPrivateKey(*caKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := ParseRevocationList(current, PhononMockCAPubKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Sequence() != 2 {
+		t.Errorf("expected the list's sequence number to be kept, got %v", last.Sequence())
+	}
+
+	//a list published before the card was revoked can't replace the current one
+	_, err = ParseRevocationList(older, PhononMockCAPubKey, last)
+	if !errors.Is(err, ErrStaleRevocationList) {
+		t.Errorf("expected an older list to be rejected, got %v", err)
+	}
+	_, err = ParseRevocationList(current, PhononMockCAPubKey, last)
+	if err != nil {
+		t.Errorf("expected the same list to be accepted again, got %v", err)
+	}
+
+	//the sequence number is signed, so it can't be raised on an older list
+	replayed := []byte(strings.Replace(string(older), `"sequence":1`, `"sequence":3`, 1))
+	_, err = ParseRevocationList(replayed, PhononMockCAPubKey, last)
+	if err != ErrInvalidRevocationList {
+		t.Errorf("expected an older list with a raised sequence number to be rejected, got %v", err)
+	}
+}
@@ -15,4 +15,5 @@ type ChainService interface {
 	DeriveAddress(p *model.Phonon) (address string, err error)
 	CheckRedeemable(p *model.Phonon, redeemAddress string) (err error)
 	RedeemPhonon(p *model.Phonon, privKey *ecdsa.PrivateKey, redeemAddress string) (transactionData string, err error)
+	EstimateRedeemFee(p *model.Phonon) (RedeemEstimate, error)
 }
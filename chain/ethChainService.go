@@ -254,6 +254,27 @@ func (eth *EthChainService) CheckRedeemable(p *model.Phonon, redeemAddress strin
 	return nil
 }
 
+//EstimateRedeemFee prices a redeem at the node's suggested gas price. Sending the whole balance of a secp256k1 key
+//is a plain transfer, which always uses the service's gas limit
+func (eth *EthChainService) EstimateRedeemFee(p *model.Phonon) (estimate RedeemEstimate, err error) {
+	if p.Address == "" {
+		p.Address, err = eth.DeriveAddress(p)
+		if err != nil {
+			log.Error("unable to derive source address for redemption: ", err)
+			return RedeemEstimate{}, err
+		}
+	}
+	err = eth.dialRPCNode(p.ChainID)
+	if err != nil {
+		return RedeemEstimate{}, err
+	}
+	_, onChainBalance, suggestedGasPrice, err := eth.fetchPreTransactionInfo(context.Background(), common.HexToAddress(p.Address))
+	if err != nil {
+		return RedeemEstimate{}, err
+	}
+	return newRedeemEstimate(onChainBalance, suggestedGasPrice, eth.gasLimit), nil
+}
+
 func (eth *EthChainService) checkRedeemValue(balance *big.Int, gasPrice *big.Int) (positive bool, redeemValue *big.Int) {
 	redeemValue = eth.calcRedemptionValue(balance, gasPrice)
 	log.Debug("transaction redemption value is: ", redeemValue)
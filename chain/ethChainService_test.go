@@ -143,3 +143,42 @@ func TestEthChainServiceRedeem(t *testing.T) {
 	//Check for correct balance output
 	t.Log("resultBalance was: ", resultBalance)
 }
+
+func TestEthEstimateRedeemFee(t *testing.T) {
+	sim, err := getSimEVM()
+	if err != nil {
+		t.Fatal("unable to start EVM simulator")
+	}
+	eth, err := NewEthChainService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testChainID := 1337
+	eth.cl = sim
+	eth.clChainID = testChainID
+
+	senderPrivKey, _ := crypto.GenerateKey()
+	pubKey, err := model.NewPhononPubKey(crypto.FromECDSAPub(&senderPrivKey.PublicKey), model.Secp256k1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &model.Phonon{PubKey: pubKey, CurrencyType: model.Ethereum, ChainID: testChainID}
+	estimate, err := eth.EstimateRedeemFee(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gasPrice, err := sim.SuggestGasPrice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedFee := new(big.Int).Mul(gasPrice, big.NewInt(21000))
+	if estimate.Fee.Cmp(expectedFee) != 0 || estimate.Size != 21000 {
+		t.Errorf("expected a fee of %v for 21000 gas, got %v for %v", expectedFee, estimate.Fee, estimate.Size)
+	}
+	if estimate.Value.Sign() != 0 || estimate.Net.Cmp(new(big.Int).Neg(expectedFee)) != 0 {
+		t.Errorf("expected an unfunded phonon to net the negative fee, got %v", estimate.Net)
+	}
+	if !estimate.FeeExceeds(100) {
+		t.Error("expected any fee to exceed the value of an unfunded phonon")
+	}
+}
@@ -0,0 +1,35 @@
+package chain
+
+import (
+	"math/big"
+)
+
+/*
+RedeemEstimate is the expected cost of redeeming a phonon, in the base units of its currency.
+Size is the estimated size of the redeem transaction in the units FeeRate is charged per, such as gas for ethereum.
+Net is the amount the redeem address should receive, which is negative when the fee exceeds the phonon's value.
+*/
+type RedeemEstimate struct {
+	Value   *big.Int
+	FeeRate *big.Int
+	Size    uint64
+	Fee     *big.Int
+	Net     *big.Int
+}
+
+func newRedeemEstimate(value *big.Int, feeRate *big.Int, size uint64) RedeemEstimate {
+	fee := new(big.Int).Mul(feeRate, new(big.Int).SetUint64(size))
+	return RedeemEstimate{
+		Value:   value,
+		FeeRate: feeRate,
+		Size:    size,
+		Fee:     fee,
+		Net:     new(big.Int).Sub(value, fee),
+	}
+}
+
+// FeeExceeds reports whether the fee is more than percent of the phonon's value. Any fee exceeds a worthless phonon's value
+func (e RedeemEstimate) FeeExceeds(percent uint) bool {
+	limit := new(big.Int).Mul(e.Value, new(big.Int).SetUint64(uint64(percent)))
+	return new(big.Int).Mul(e.Fee, big.NewInt(100)).Cmp(limit) > 0
+}
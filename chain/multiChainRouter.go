@@ -52,6 +52,14 @@ func (mcr *MultiChainRouter) RedeemPhonon(p *model.Phonon, privKey *ecdsa.Privat
 	return chain.RedeemPhonon(p, privKey, redeemAddress)
 }
 
+func (mcr *MultiChainRouter) EstimateRedeemFee(p *model.Phonon) (RedeemEstimate, error) {
+	chain, ok := mcr.chainServices[p.CurrencyType]
+	if !ok {
+		return RedeemEstimate{}, ErrCurrencyTypeUnsupported
+	}
+	return chain.EstimateRedeemFee(p)
+}
+
 func (mcr *MultiChainRouter) CheckRedeemable(p *model.Phonon, redeemAddress string) (err error) {
 	chain, ok := mcr.chainServices[p.CurrencyType]
 	if !ok {
@@ -16,12 +16,19 @@ limitations under the License.
 package cmd
 
 import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"os"
+	"strings"
+
 	remote "github.com/GridPlus/phonon-client/remote/v1/server"
+	"github.com/GridPlus/phonon-client/util"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 var (
-	certificate, key, port string
+	certificate, key, port, identityKeyFile string
 )
 
 // JumpBoxCmd represents the JumpBox command
@@ -34,13 +41,35 @@ Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 	Run: func(_ *cobra.Command, _ []string) {
+		if identityKeyFile != "" {
+			identityKey, err := loadIdentityKey(identityKeyFile)
+			if err != nil {
+				log.Fatal("unable to load server identity key: ", err)
+			}
+			log.Info("authenticating to clients as ", util.ECCPubKeyToHexString(&identityKey.PublicKey))
+			remote.SetIdentityKey(identityKey)
+		}
 		remote.StartServer(port, certificate, key)
 	},
 }
 
+//loadIdentityKey reads a hex encoded secp256k1 private key from a file
+func loadIdentityKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	return util.ParseECCPrivKey(raw)
+}
+
 func init() {
 	rootCmd.AddCommand(JumpBoxCmd)
 	JumpBoxCmd.Flags().StringVarP(&port, "port", "p", "8080", "port for clients to connect on")
 	JumpBoxCmd.Flags().StringVarP(&certificate, "cert", "c", "", "SSL certificate")
 	JumpBoxCmd.Flags().StringVarP(&key, "key", "k", "", "SSL key")
+	JumpBoxCmd.Flags().StringVar(&identityKeyFile, "identity", "", "file holding the hex encoded private key the server signs client challenges with")
 }
@@ -56,7 +56,11 @@ func identifyCard() {
 	fmt.Println("received pubkey from select:\n", hex.Dump(ethcrypto.FromECDSAPub(selectCardPubKey)))
 
 	nonce := make([]byte, 32)
-	rand.Read(nonce)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		fmt.Println("unable to generate challenge for card: ", err)
+		return
+	}
 	cardPubKey, cardSig, err := cs.IdentifyCard(nonce)
 	if err != nil {
 		fmt.Println("error identifying card: ", err)
@@ -1,11 +1,14 @@
 package config
 
 import (
+	"crypto/ecdsa"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"runtime"
 
 	"github.com/GridPlus/phonon-client/hooks"
+	"github.com/GridPlus/phonon-client/util"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
@@ -13,6 +16,9 @@ import (
 type Config struct {
 	//PhononCommandSet
 	Certificate string //string ID to select a certificate
+	AppletAID   string //hex encoded AID of the applet to select, defaults to the production phonon applet
+	//hex encoded public key jump servers must prove they hold when connecting, unset to trust any server
+	JumpServerKey string
 	// log exporting
 	TelemetryKey string
 }
@@ -25,6 +31,18 @@ func DefaultConfig() Config {
 	return conf
 }
 
+// ParseJumpServerKey returns the configured jump server public key, or nil if none is set
+func (c Config) ParseJumpServerKey() (*ecdsa.PublicKey, error) {
+	if c.JumpServerKey == "" {
+		return nil, nil
+	}
+	raw, err := hex.DecodeString(c.JumpServerKey)
+	if err != nil {
+		return nil, err
+	}
+	return util.ParseECCPubKey(raw)
+}
+
 func LoadConfig() (config Config, err error) {
 	// SetDefaultConfig()
 	switch runtime.GOOS {
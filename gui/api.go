@@ -2,6 +2,7 @@ package gui
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"embed"
 	"encoding/json"
 	"fmt"
@@ -46,8 +47,9 @@ var manifest []byte
 var swagger embed.FS
 
 type apiSession struct {
-	t            *orchestrator.PhononTerminal
-	telemetryKey string
+	t             *orchestrator.PhononTerminal
+	telemetryKey  string
+	jumpServerKey *ecdsa.PublicKey
 }
 
 func Server(port string, certFile string, keyFile string, mock bool) {
@@ -59,7 +61,11 @@ func Server(port string, certFile string, keyFile string, mock bool) {
 	if err != nil {
 		log.Fatal("Unable to load configuration")
 	}
-	session := apiSession{orchestrator.NewPhononTerminal(), conf.TelemetryKey}
+	jumpServerKey, err := conf.ParseJumpServerKey()
+	if err != nil {
+		log.Fatal("unable to parse configured jump server key: ", err)
+	}
+	session := apiSession{orchestrator.NewPhononTerminal(), conf.TelemetryKey, jumpServerKey}
 	//initialize cache map
 	if mock {
 		//Start server with a mock and ignore actual cards
@@ -95,6 +101,7 @@ func Server(port string, certFile string, keyFile string, mock bool) {
 	r.HandleFunc("/cards/{sessionID}/listPhonons", session.listPhonons)
 	r.HandleFunc("/cards/{sessionID}/phonon/{PhononIndex}/setDescriptor", session.setDescriptor)
 	r.HandleFunc("/cards/{sessionID}/phonon/send", session.send)
+	r.HandleFunc("/cards/{sessionID}/phonon/send/dryRun", session.dryRunSend)
 	r.HandleFunc("/cards/{sessionID}/phonon/create", session.createPhonon)
 	r.HandleFunc("/cards/{sessionID}/phonon/redeem", session.redeemPhonons)
 	r.HandleFunc("/cards/{sessionID}/phonon/{PhononIndex}/export", session.exportPhonon)
@@ -563,7 +570,12 @@ func (apiSession apiSession) init(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
-	if sess.IsInitialized() {
+	initialized, err := sess.RefreshInitialized()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if initialized {
 		http.Error(w, "card is already initialized", http.StatusBadRequest)
 		return
 	}
@@ -641,6 +653,9 @@ func (apiSession apiSession) ConnectRemote(w http.ResponseWriter, r *http.Reques
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if apiSession.jumpServerKey != nil {
+		sess.SetJumpServerKey(apiSession.jumpServerKey)
+	}
 	err = sess.ConnectToRemoteProvider(ConnectionReq.URL)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -849,6 +864,32 @@ func (apiSession apiSession) send(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (apiSession apiSession) dryRunSend(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sess, err := apiSession.sessionFromMuxVars(vars)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	type dryRunResp struct {
+		Success bool
+		Error   string
+	}
+	resp := &dryRunResp{Success: true}
+	err = sess.DryRunTransfer()
+	if err != nil {
+		resp.Success = false
+		resp.Error = err.Error()
+	}
+	enc := json.NewEncoder(w)
+	err = enc.Encode(resp)
+	if err != nil {
+		log.Error("unable to encode outgoing dry run response")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 func (apiSession apiSession) exportPhonon(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	sess, err := apiSession.sessionFromMuxVars(vars)
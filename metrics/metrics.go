@@ -0,0 +1,76 @@
+/*
+Package metrics lets operators observe the validator and remote packages without those packages
+depending on a metrics library. Instrumented code reports through the Metrics set with SetMetrics,
+which does nothing until an operator installs an adapter for Prometheus or any other system.
+*/
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Metric names reported by the instrumented packages, following Prometheus naming conventions
+const (
+	ValidatorRequests        = "phonon_validator_requests_total"           //labels: backend
+	ValidatorRequestErrors   = "phonon_validator_request_errors_total"     //labels: backend
+	ValidatorRequestDuration = "phonon_validator_request_duration_seconds" //labels: backend
+	RemoteConnectionsOpened  = "phonon_remote_connections_opened_total"
+	RemoteConnectionsClosed  = "phonon_remote_connections_closed_total"
+	RemoteMessages           = "phonon_remote_messages_total"             //labels: message
+	RemoteMessageDuration    = "phonon_remote_message_duration_seconds"   //labels: message
+	RemoteHandshakeDuration  = "phonon_remote_handshake_duration_seconds" //labels: stage, result
+	RemoteTransfers          = "phonon_remote_transfers_total"            //labels: direction, result
+)
+
+/*
+Metrics receives measurements from instrumented packages.
+Implementations must be safe for concurrent use. labels may be nil and must not be retained or modified.
+*/
+type Metrics interface {
+	IncCounter(name string, labels map[string]string)
+	ObserveHistogram(name string, value float64, labels map[string]string)
+}
+
+type noop struct{}
+
+func (noop) IncCounter(string, map[string]string)                {}
+func (noop) ObserveHistogram(string, float64, map[string]string) {}
+
+// holder keeps the stored type constant, which atomic.Value requires
+type holder struct {
+	m Metrics
+}
+
+var current atomic.Value
+
+func init() {
+	current.Store(holder{noop{}})
+}
+
+// SetMetrics installs m for all instrumented packages. A nil m restores the no-op default.
+func SetMetrics(m Metrics) {
+	if m == nil {
+		m = noop{}
+	}
+	current.Store(holder{m})
+}
+
+func get() Metrics {
+	return current.Load().(holder).m
+}
+
+// Inc increments the named counter
+func Inc(name string, labels map[string]string) {
+	get().IncCounter(name, labels)
+}
+
+// Observe records value in the named histogram
+func Observe(name string, value float64, labels map[string]string) {
+	get().ObserveHistogram(name, value, labels)
+}
+
+// ObserveSince records the seconds elapsed since start in the named histogram
+func ObserveSince(name string, start time.Time, labels map[string]string) {
+	Observe(name, time.Since(start).Seconds(), labels)
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mtex         sync.Mutex
+	counters     map[string]int
+	observations map[string][]float64
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		counters:     make(map[string]int),
+		observations: make(map[string][]float64),
+	}
+}
+
+func (r *recorder) IncCounter(name string, labels map[string]string) {
+	r.mtex.Lock()
+	defer r.mtex.Unlock()
+	r.counters[name+labels["backend"]]++
+}
+
+func (r *recorder) ObserveHistogram(name string, value float64, labels map[string]string) {
+	r.mtex.Lock()
+	defer r.mtex.Unlock()
+	r.observations[name] = append(r.observations[name], value)
+}
+
+func TestSetMetrics(t *testing.T) {
+	//the default no-op must be safe to report to
+	Inc(ValidatorRequests, nil)
+
+	r := newRecorder()
+	SetMetrics(r)
+	defer SetMetrics(nil)
+
+	labels := map[string]string{"backend": "bcoin"}
+	Inc(ValidatorRequests, labels)
+	Inc(ValidatorRequests, labels)
+	ObserveSince(ValidatorRequestDuration, time.Now().Add(-time.Second), labels)
+	if r.counters[ValidatorRequests+"bcoin"] != 2 {
+		t.Errorf("expected 2 requests counted, got %d", r.counters[ValidatorRequests+"bcoin"])
+	}
+	durations := r.observations[ValidatorRequestDuration]
+	if len(durations) != 1 || durations[0] < 1 {
+		t.Errorf("unexpected request durations: %v", durations)
+	}
+
+	SetMetrics(nil)
+	Inc(ValidatorRequests, labels)
+	if r.counters[ValidatorRequests+"bcoin"] != 2 {
+		t.Error("expected metrics to stop being reported after SetMetrics(nil)")
+	}
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var ErrInvalidAmount = errors.New("invalid amount")
+var ErrAmountTooPrecise = errors.New("amount is more precise than the currency's smallest unit")
+var ErrAmountOutOfRange = errors.New("amount out of range")
+var ErrUnitsUnknown = errors.New("currency has no known units")
+
+// currencyUnits holds the ticker and the number of decimal places of the smallest unit of each currency
+var currencyUnits = map[CurrencyType]struct {
+	ticker   string
+	decimals int
+}{
+	Bitcoin:  {"BTC", 8},
+	Ethereum: {"ETH", 18},
+}
+
+/*
+ParseAmount converts a human readable amount such as "0.01", "0.01 BTC" or "1.5 ETH" into
+the currency's smallest unit, satoshis for bitcoin and wei for ethereum.
+The amount is parsed as a decimal string so no floating point rounding can occur. Amounts with
+more decimal places than the currency supports, negative amounts, and a ticker that doesn't match
+the currency are rejected.
+*/
+func ParseAmount(s string, currency CurrencyType) (int64, error) {
+	units, ok := currencyUnits[currency]
+	if !ok {
+		return 0, ErrUnitsUnknown
+	}
+	fields := strings.Fields(s)
+	switch {
+	case len(fields) == 2:
+		if !strings.EqualFold(fields[1], units.ticker) {
+			return 0, fmt.Errorf("%w: %s amount given for %s", ErrInvalidAmount, fields[1], units.ticker)
+		}
+	case len(fields) != 1:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	whole, frac, _ := strings.Cut(fields[0], ".")
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, fields[0])
+	}
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > units.decimals {
+		return 0, fmt.Errorf("%w: %s has %d decimal places", ErrAmountTooPrecise, units.ticker, units.decimals)
+	}
+	frac += strings.Repeat("0", units.decimals-len(frac))
+
+	value, ok := new(big.Int).SetString(whole+frac, 10)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, fields[0])
+	}
+	if !value.IsInt64() {
+		return 0, fmt.Errorf("%w: %s", ErrAmountOutOfRange, fields[0])
+	}
+	return value.Int64(), nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
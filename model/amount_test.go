@@ -0,0 +1,56 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	valid := []struct {
+		amount   string
+		currency CurrencyType
+		expected int64
+	}{
+		{"0.01 BTC", Bitcoin, 1000000},
+		{"0.01", Bitcoin, 1000000},
+		{"21 btc", Bitcoin, 2100000000},
+		{"0.00000001 BTC", Bitcoin, 1},
+		{"0.100000000", Bitcoin, 10000000},
+		{".5", Bitcoin, 50000000},
+		{"1.5 ETH", Ethereum, 1500000000000000000},
+		{"0.000000000000000001", Ethereum, 1},
+		{"0.29", Ethereum, 290000000000000000},
+	}
+	for _, v := range valid {
+		value, err := ParseAmount(v.amount, v.currency)
+		if err != nil {
+			t.Errorf("unable to parse %q: %v", v.amount, err)
+			continue
+		}
+		if value != v.expected {
+			t.Errorf("%q: expected %d, got %d", v.amount, v.expected, value)
+		}
+	}
+
+	invalid := []struct {
+		amount   string
+		currency CurrencyType
+		err      error
+	}{
+		{"0.000000001 BTC", Bitcoin, ErrAmountTooPrecise},
+		{"1.5 ETH", Bitcoin, ErrInvalidAmount},
+		{"-1", Bitcoin, ErrInvalidAmount},
+		{"1e3", Bitcoin, ErrInvalidAmount},
+		{".", Bitcoin, ErrInvalidAmount},
+		{"", Bitcoin, ErrInvalidAmount},
+		{"1 2 BTC", Bitcoin, ErrInvalidAmount},
+		{"10 ETH", Ethereum, ErrAmountOutOfRange},
+		{"1", Native, ErrUnitsUnknown},
+	}
+	for _, v := range invalid {
+		_, err := ParseAmount(v.amount, v.currency)
+		if !errors.Is(err, v.err) {
+			t.Errorf("%q: expected %v, got %v", v.amount, v.err, err)
+		}
+	}
+}
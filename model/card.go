@@ -2,23 +2,124 @@ package model
 
 import (
 	"crypto/ecdsa"
+	"fmt"
 
 	"github.com/GridPlus/phonon-client/cert"
 	"github.com/GridPlus/phonon-client/util"
 )
 
+// PairingResult describes a completed terminal to card pairing.
+// Slot is the card's pairing slot holding the pairing and ID identifies the pairing key
+// without revealing it, so callers can keep track of their pairings and free slots with UNPAIR.
+type PairingResult struct {
+	Cert *cert.CardCertificate
+	Slot int
+	ID   []byte
+}
+
+// UnknownPairingSlots is reported for pairing slot counts the applet doesn't include in its SELECT response
+const UnknownPairingSlots = -1
+
+/*
+CardInfo describes the applet as reported in its most recent SELECT response.
+Applets report how many pairing slots are free, and versions that also report their total number of slots
+let the used slots be shown. Counts that weren't reported, including every count on an uninitialized card,
+are UnknownPairingSlots. Lifecycle is also kept when SELECT fails because the applet is locked or terminated.
+Version is UnknownAppletVersion unless the applet is initialized, since only initialized applets report it.
+*/
+type CardInfo struct {
+	TotalPairingSlots int
+	UsedPairingSlots  int
+	FreePairingSlots  int
+	Lifecycle         LifecycleState
+	Version           AppletVersion
+}
+
+// AppletVersion is the version of the phonon applet, for enabling features that only newer applets support
+type AppletVersion struct {
+	Major int
+	Minor int
+}
+
+// UnknownAppletVersion is reported for applets that haven't reported their version
+var UnknownAppletVersion = AppletVersion{Major: -1, Minor: -1}
+
+func (v AppletVersion) String() string {
+	if v == UnknownAppletVersion {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// AtLeast reports whether v is major.minor or newer. An unknown version is never at least any version.
+func (v AppletVersion) AtLeast(major int, minor int) bool {
+	if v == UnknownAppletVersion {
+		return false
+	}
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+// LifecycleState is the state of the applet, as determined from its response to SELECT
+type LifecycleState int
+
+const (
+	// LifecycleUnknown means the applet hasn't been selected
+	LifecycleUnknown LifecycleState = iota
+	// LifecyclePersonalization means the applet is installed but has no PIN, so it must be initialized before use
+	LifecyclePersonalization
+	// LifecycleActive means the applet is initialized and ready for use
+	LifecycleActive
+	// LifecycleLocked means the applet has been locked and refuses every command until the issuer unlocks it
+	LifecycleLocked
+	// LifecycleTerminated means the applet has been permanently disabled
+	LifecycleTerminated
+)
+
+func (l LifecycleState) String() string {
+	switch l {
+	case LifecyclePersonalization:
+		return "personalization"
+	case LifecycleActive:
+		return "active"
+	case LifecycleLocked:
+		return "locked"
+	case LifecycleTerminated:
+		return "terminated"
+	default:
+		return "unknown"
+	}
+}
+
+/*
+SecureChannelInfo describes the terminal to card secure channel without exposing any of its keys.
+The applet supports a single cipher suite, so CipherSuite is fixed rather than negotiated.
+Static is set when the channel was derived from the insecure static keys used for debugging with a simulator.
+*/
+type SecureChannelInfo struct {
+	Open        bool
+	Paired      bool
+	PairingSlot int
+	CipherSuite string
+	Static      bool
+}
+
 type PhononCard interface {
 	Select() (instanceUID []byte, cardPubKey *ecdsa.PublicKey, cardInitialized bool, err error)
-	Pair() (*cert.CardCertificate, error)
+	CardInfo() CardInfo
+	SecureChannelInfo() SecureChannelInfo
+	Pair() (*PairingResult, error)
+	GetCertificate() (*cert.CardCertificate, error)
 	OpenSecureChannel() error
 	OpenSecureConnection() error
 	Init(pin string) error
 	IdentifyCard(nonce []byte) (cardPubKey *ecdsa.PublicKey, cardSig *util.ECDSASignature, err error)
 	VerifyPIN(pin string) error
 	ChangePIN(pin string) error
+	GetPINStatus() (remainingTries int, err error)
 	CreatePhonon(curveType CurveType) (keyIndex PhononKeyIndex, pubKey PhononPubKey, err error)
 	SetDescriptor(phonon *Phonon) error
 	ListPhonons(currencyType CurrencyType, lessThanValue uint64, greaterThanValue uint64, continuation bool) ([]*Phonon, error)
+	ListPhononsPage(currencyType CurrencyType, lessThanValue uint64, greaterThanValue uint64, continuation bool) (phonons []*Phonon, more bool, err error)
 	GetPhononPubKey(keyIndex PhononKeyIndex, crv CurveType) (pubkey PhononPubKey, err error)
 	DestroyPhonon(keyIndex PhononKeyIndex) (privKey *ecdsa.PrivateKey, err error)
 	SendPhonons(keyIndices []PhononKeyIndex, extendedRequest bool) (transferPhononPackets []byte, err error)
@@ -13,6 +13,7 @@ type CounterpartyPhononCard interface {
 	GenerateInvoice() (invoiceData []byte, err error)
 	ReceiveInvoice(invoiceData []byte) error
 	VerifyPaired() error
+	DryRunTransfer() error
 	PairingStatus() RemotePairingStatus
 	ConnectToCard(string) error
 }
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"time"
 
 	"github.com/GridPlus/phonon-client/tlv"
 	"github.com/GridPlus/phonon-client/util"
@@ -31,6 +32,9 @@ type Phonon struct {
 	ExtendedTLV           tlv.TLVList
 	Address               string //chain specific attribute not stored on card
 	AddressType           uint8  //chain specific address type identifier
+	Tag                   string //user supplied label, kept by the client rather than stored on card
+	Provenance            PhononProvenance
+	CreatedAt             time.Time //set at deposit and stored in the extended schema, zero for older phonons
 }
 
 func (p *Phonon) String() string {
@@ -47,6 +51,21 @@ func (p *Phonon) String() string {
 		p.ExtendedTLV)
 }
 
+// CreatedWithin reports whether the phonon was created in the window from after to before, inclusive.
+// A zero bound leaves that side of the window open, and phonons without a creation time match any window.
+func (p *Phonon) CreatedWithin(after time.Time, before time.Time) bool {
+	if p.CreatedAt.IsZero() {
+		return true
+	}
+	if !after.IsZero() && p.CreatedAt.Before(after) {
+		return false
+	}
+	if !before.IsZero() && p.CreatedAt.After(before) {
+		return false
+	}
+	return true
+}
+
 //Phonon data structured for display to the user and use in frontends
 type PhononJSON struct {
 	KeyIndex              PhononKeyIndex
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+var phononCSVHeader = []string{"index", "currency", "value", "pubkey", "address", "tag", "validation"}
+
+const (
+	ValidationStatusValid     = "valid"
+	ValidationStatusInvalid   = "invalid"
+	ValidationStatusError     = "error"
+	ValidationStatusUnchecked = "unchecked"
+)
+
+/*
+PhononCSVWriter writes a phonon inventory as CSV for spreadsheets and bookkeeping.
+Rows are written to the underlying writer as they are added so large inventories
+don't need to be held in memory. Fields containing commas, quotes or newlines,
+such as tags, are quoted following RFC 4180.
+
+DeriveAddress and Validate are optional hooks so callers can fill in the address
+and validation columns with whichever chain service and validator they have configured.
+*/
+type PhononCSVWriter struct {
+	DeriveAddress func(p *Phonon) (string, error)
+	Validate      func(p *Phonon) (bool, error)
+
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func NewPhononCSVWriter(w io.Writer) *PhononCSVWriter {
+	return &PhononCSVWriter{
+		w: csv.NewWriter(w),
+	}
+}
+
+// Write adds a row for the phonon, writing the header first if this is the first row
+func (cw *PhononCSVWriter) Write(p *Phonon) error {
+	err := cw.writeHeader()
+	if err != nil {
+		return err
+	}
+	address := p.Address
+	if address == "" && cw.DeriveAddress != nil {
+		address, err = cw.DeriveAddress(p)
+		if err != nil {
+			return fmt.Errorf("unable to derive address for phonon %v: %w", p.KeyIndex, err)
+		}
+	}
+	var pubKey string
+	if p.PubKey != nil {
+		pubKey = p.PubKey.String()
+	}
+	return cw.w.Write([]string{
+		fmt.Sprint(p.KeyIndex),
+		p.CurrencyType.String(),
+		p.Denomination.String(),
+		pubKey,
+		address,
+		p.Tag,
+		cw.validationStatus(p),
+	})
+}
+
+func (cw *PhononCSVWriter) writeHeader() error {
+	if cw.headerWritten {
+		return nil
+	}
+	cw.headerWritten = true
+	return cw.w.Write(phononCSVHeader)
+}
+
+// validation failures are recorded in the row rather than aborting the export
+func (cw *PhononCSVWriter) validationStatus(p *Phonon) string {
+	if cw.Validate == nil {
+		return ValidationStatusUnchecked
+	}
+	valid, err := cw.Validate(p)
+	switch {
+	case err != nil:
+		return ValidationStatusError
+	case valid:
+		return ValidationStatusValid
+	default:
+		return ValidationStatusInvalid
+	}
+}
+
+// Flush writes any buffered rows to the underlying writer
+func (cw *PhononCSVWriter) Flush() error {
+	//an empty inventory still gets a header
+	err := cw.writeHeader()
+	if err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// WritePhononsCSV writes the phonons to w as CSV using the optional address and validation hooks
+func WritePhononsCSV(w io.Writer, phonons []Phonon, deriveAddress func(p *Phonon) (string, error), validate func(p *Phonon) (bool, error)) error {
+	cw := NewPhononCSVWriter(w)
+	cw.DeriveAddress = deriveAddress
+	cw.Validate = validate
+	for i := range phonons {
+		err := cw.Write(&phonons[i])
+		if err != nil {
+			return err
+		}
+	}
+	return cw.Flush()
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"testing"
+)
+
+func TestWritePhononsCSV(t *testing.T) {
+	phonons := []Phonon{
+		{KeyIndex: 1, CurrencyType: Bitcoin, Denomination: Denomination{Base: 5, Exponent: 2}, Address: "bc1known", Tag: "gift, for \"Alice\""},
+		{KeyIndex: 2, CurrencyType: Ethereum, Denomination: Denomination{Base: 1, Exponent: 0}},
+	}
+	deriveAddress := func(p *Phonon) (string, error) {
+		return "derived", nil
+	}
+	validate := func(p *Phonon) (bool, error) {
+		if p.KeyIndex == 2 {
+			return false, errors.New("backend down")
+		}
+		return true, nil
+	}
+
+	var buf bytes.Buffer
+	err := WritePhononsCSV(&buf, phonons, deriveAddress, validate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal("output is not valid csv: ", err)
+	}
+	expected := [][]string{
+		phononCSVHeader,
+		{"1", "Bitcoin", "500", "", "bc1known", "gift, for \"Alice\"", ValidationStatusValid},
+		{"2", "Ethereum", "1", "", "derived", "", ValidationStatusError},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, got %d", len(expected), len(records))
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if records[i][j] != expected[i][j] {
+				t.Errorf("record %d column %s: expected %q, got %q", i, phononCSVHeader[j], expected[i][j], records[i][j])
+			}
+		}
+	}
+}
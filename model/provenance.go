@@ -0,0 +1,32 @@
+package model
+
+import "fmt"
+
+/*
+PhononProvenance records how a phonon's value first came onto a card. It is stored in the
+phonon's extended schema, so it travels with the phonon through every transfer and lets a
+receiver decide how much to trust it, for example by revalidating imported phonons on chain.
+*/
+type PhononProvenance uint8
+
+const (
+	ProvenanceUnknown   PhononProvenance = iota //created before provenance was recorded
+	ProvenanceDeposited                         //funded on chain through a deposit
+	ProvenanceMined                             //natively mined on a card
+	ProvenanceImported                          //restored from a backup or imported from outside a card
+)
+
+func (p PhononProvenance) String() string {
+	switch p {
+	case ProvenanceUnknown:
+		return "unknown"
+	case ProvenanceDeposited:
+		return "deposited"
+	case ProvenanceMined:
+		return "mined"
+	case ProvenanceImported:
+		return "imported"
+	default:
+		return fmt.Sprintf("PhononProvenance(%d)", uint8(p))
+	}
+}
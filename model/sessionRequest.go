@@ -92,6 +92,44 @@ type ResponseReceivePhonons struct {
 	Err error
 }
 
+type RequestDryRunTransfer struct {
+	Ret chan ResponseDryRunTransfer
+}
+
+func (*RequestDryRunTransfer) GetName() string {
+	return "RequestDryRunTransfer"
+}
+
+type ResponseDryRunTransfer struct {
+	Err error
+}
+
+type RequestGenerateInvoice struct {
+	Ret chan ResponseGenerateInvoice
+}
+
+func (*RequestGenerateInvoice) GetName() string {
+	return "RequestGenerateInvoice"
+}
+
+type ResponseGenerateInvoice struct {
+	Err     error
+	Payload []byte
+}
+
+type RequestReceiveInvoice struct {
+	Ret     chan ResponseReceiveInvoice
+	Payload []byte
+}
+
+func (*RequestReceiveInvoice) GetName() string {
+	return "RequestReceiveInvoice"
+}
+
+type ResponseReceiveInvoice struct {
+	Err error
+}
+
 type RequestGetName struct {
 	Ret chan ResponseGetName
 }
@@ -130,3 +168,17 @@ func (*RequestSetPaired) GetName() string {
 type ResponseSetPaired struct {
 	Err error
 }
+
+type RequestResumePairing struct {
+	Ret   chan ResponseResumePairing
+	Card  CounterpartyPhononCard
+	Token []byte
+}
+
+func (*RequestResumePairing) GetName() string {
+	return "RequestResumePairing"
+}
+
+type ResponseResumePairing struct {
+	Err error
+}
@@ -0,0 +1,75 @@
+package orchestrator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+// DepositRequest describes one phonon to be created by DepositBatch
+type DepositRequest struct {
+	CurrencyType model.CurrencyType
+	Denomination model.Denomination
+}
+
+// DepositBatchError is returned by DepositBatch when it stops partway through a batch.
+// Index is the position in the batch of the deposit that failed, none of the deposits from it onwards were created.
+type DepositBatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *DepositBatchError) Error() string {
+	return fmt.Sprintf("deposit %v of batch failed: %v", e.Index, e.Err)
+}
+
+func (e *DepositBatchError) Unwrap() error {
+	return e.Err
+}
+
+/*
+DepositBatch creates a phonon for each deposit, holding the card for the whole batch so the phonons are created
+back to back over the open secure channel. The phonons are returned with their public keys and addresses,
+ready to be funded on chain and confirmed with FinalizeDepositPhonons, which sets their descriptors.
+
+If a deposit fails the phonons created before it are returned along with a *DepositBatchError holding the
+index of the failed deposit, so the caller can fund or clean up the partial batch.
+*/
+func (s *Session) DepositBatch(deposits []DepositRequest) ([]*model.Phonon, error) {
+	if !s.verified() {
+		return nil, s.unverifiedErr()
+	}
+	s.ElementUsageMtex.Lock()
+	defer s.ElementUsageMtex.Unlock()
+
+	phonons := make([]*model.Phonon, 0, len(deposits))
+	for i, deposit := range deposits {
+		p := &model.Phonon{
+			CurveType:    model.Secp256k1,
+			Denomination: deposit.Denomination,
+			CurrencyType: deposit.CurrencyType,
+			Provenance:   model.ProvenanceDeposited,
+			//the descriptor stores whole seconds
+			CreatedAt: time.Now().UTC().Truncate(time.Second),
+		}
+		var err error
+		p.KeyIndex, p.PubKey, err = s.createPhonon()
+		if err != nil {
+			s.logger.Error("failed to create phonon for batch deposit: ", err)
+			return phonons, &DepositBatchError{Index: i, Err: err}
+		}
+		p.Address, err = s.chainSrv.DeriveAddress(p)
+		if err != nil {
+			s.logger.Error("failed to derive address for batch deposit: ", err)
+			//the phonon can't be funded without an address, so don't leave it occupying a slot
+			_, destroyErr := s.cs.DestroyPhonon(p.KeyIndex)
+			if destroyErr == nil {
+				delete(s.cache, p.KeyIndex)
+			}
+			return phonons, &DepositBatchError{Index: i, Err: err}
+		}
+		phonons = append(phonons, p)
+	}
+	return phonons, nil
+}
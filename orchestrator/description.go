@@ -0,0 +1,142 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+// MaxDescriptionLength is the longest description, in bytes, that can be attached to a phonon
+const MaxDescriptionLength = 64
+
+var ErrDescriptionTooLong = errors.New("phonon description too long")
+var ErrDescriptionInvalid = errors.New("phonon description is not valid UTF-8")
+
+/*
+DescriptionStore keeps the descriptions set with SetPhononDescription, keyed by the phonon's public key.
+The applet has no field for a label, and a descriptor can't be rewritten once the phonon holds value,
+so descriptions are kept off the card.
+*/
+type DescriptionStore interface {
+	Description(pubKey string) (desc string, ok bool)
+	SetDescription(pubKey string, desc string) error
+}
+
+// WithDescriptionStore keeps phonon descriptions in store, such as a FileDescriptionStore so they outlast the session.
+// Without it descriptions are kept in memory for the life of the session.
+func WithDescriptionStore(store DescriptionStore) Option {
+	return func(s *Session) {
+		s.descriptions = store
+	}
+}
+
+/*
+SetPhononDescription labels the phonon at keyIndex with desc, which is listed as its Tag by ListPhonons and
+the methods built on it. It fails with ErrDescriptionTooLong if desc is longer than
+MaxDescriptionLength bytes, and an empty desc clears the label.
+*/
+func (s *Session) SetPhononDescription(keyIndex model.PhononKeyIndex, desc string) error {
+	if len(desc) > MaxDescriptionLength {
+		return ErrDescriptionTooLong
+	}
+	if !utf8.ValidString(desc) {
+		return ErrDescriptionInvalid
+	}
+	p, err := s.GetPhonon(keyIndex)
+	if err != nil {
+		return err
+	}
+	if s.descriptions == nil {
+		s.descriptions = make(memoryDescriptionStore)
+	}
+	err = s.descriptions.SetDescription(p.PubKey.String(), desc)
+	if err != nil {
+		return err
+	}
+	s.cache[keyIndex].p.Tag = desc
+	return nil
+}
+
+// applyDescriptions fills in the tags of phonons listed from the card with their stored descriptions.
+// Descriptions are found by public key, which the card doesn't list, so each phonon's key is fetched once.
+func (s *Session) applyDescriptions(phonons []*model.Phonon) {
+	if s.descriptions == nil {
+		return
+	}
+	for _, p := range phonons {
+		if p.PubKey == nil {
+			pubKey, err := s.cs.GetPhononPubKey(p.KeyIndex, p.CurveType)
+			if err != nil {
+				s.logger.Error("unable to look up phonon description: ", err)
+				continue
+			}
+			s.addPubKeyToCache(p.KeyIndex, pubKey)
+			p.PubKey = pubKey
+		}
+		desc, ok := s.descriptions.Description(p.PubKey.String())
+		if ok {
+			p.Tag = desc
+		}
+	}
+}
+
+type memoryDescriptionStore map[string]string
+
+func (m memoryDescriptionStore) Description(pubKey string) (string, bool) {
+	desc, ok := m[pubKey]
+	return desc, ok
+}
+
+func (m memoryDescriptionStore) SetDescription(pubKey string, desc string) error {
+	m[pubKey] = desc
+	return nil
+}
+
+// FileDescriptionStore is a DescriptionStore saving descriptions to a JSON file after every change
+type FileDescriptionStore struct {
+	path         string
+	descriptions map[string]string
+	mtex         sync.Mutex
+}
+
+// NewFileDescriptionStore loads the descriptions saved at path, which is created on the first change if it doesn't exist
+func NewFileDescriptionStore(path string) (*FileDescriptionStore, error) {
+	store := &FileDescriptionStore{
+		path:         path,
+		descriptions: make(map[string]string),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &store.descriptions)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (f *FileDescriptionStore) Description(pubKey string) (string, bool) {
+	f.mtex.Lock()
+	defer f.mtex.Unlock()
+	desc, ok := f.descriptions[pubKey]
+	return desc, ok
+}
+
+func (f *FileDescriptionStore) SetDescription(pubKey string, desc string) error {
+	f.mtex.Lock()
+	defer f.mtex.Unlock()
+	f.descriptions[pubKey] = desc
+	data, err := json.Marshal(f.descriptions)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, data, 0600)
+}
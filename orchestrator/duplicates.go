@@ -0,0 +1,68 @@
+package orchestrator
+
+import (
+	"context"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+/*
+removeDuplicatePhonons destroys each phonon received since before whose public key the card already held,
+including keys repeated within the same transfer, and returns the indices it removed. The copies share a
+private key, so destroying the duplicate slot only frees it and leaves the asset on the card exactly once.
+
+Reading a key from the card takes a command per phonon and the check runs before the transfer is acknowledged,
+so only the received phonons' keys are read, and they are compared with the keys of the phonons already on the
+card that this session has cached. A copy of a phonon whose key the session never read isn't found. Phonons this
+session received have their keys cached, so a retransmission of an earlier transfer is. Reading stops when ctx is done.
+
+The transfer packet is encrypted for the card, so duplicates can only be found after the card has stored them.
+By then the transfer is accepted, so a retransmission is acknowledged like any other transfer and the removed
+copies are only reported in ReceiveResult.Duplicates.
+*/
+func (s *Session) removeDuplicatePhonons(ctx context.Context, before map[model.PhononKeyIndex]bool) ([]model.PhononKeyIndex, error) {
+	phonons, err := s.ListPhonons(0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(phonons))
+	var received []*model.Phonon
+	for _, p := range phonons {
+		if before[p.KeyIndex] {
+			cached, ok := s.cache[p.KeyIndex]
+			if ok && cached.p.PubKey != nil {
+				seen[cached.p.PubKey.String()] = true
+			}
+			continue
+		}
+		err = ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+		phonon, err := s.GetPhonon(p.KeyIndex)
+		if err != nil {
+			return nil, err
+		}
+		received = append(received, phonon)
+	}
+
+	var duplicates []model.PhononKeyIndex
+	for _, p := range received {
+		key := p.PubKey.String()
+		if seen[key] {
+			duplicates = append(duplicates, p.KeyIndex)
+			continue
+		}
+		seen[key] = true
+	}
+	for _, keyIndex := range duplicates {
+		_, err = s.destroyPhonon(keyIndex)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(duplicates) > 0 {
+		s.logger.Infof("removed copies of phonons already on the card at indices %v", duplicates)
+	}
+	return duplicates, nil
+}
@@ -0,0 +1,74 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/GridPlus/phonon-client/card"
+	"github.com/GridPlus/phonon-client/model"
+)
+
+// keyCountingCard counts the phonon keys read from the card. It stands in for a received transfer by creating a phonon
+type keyCountingCard struct {
+	*card.MockCard
+	keyReads int
+}
+
+func (c *keyCountingCard) GetPhononPubKey(keyIndex model.PhononKeyIndex, crv model.CurveType) (model.PhononPubKey, error) {
+	c.keyReads++
+	return c.MockCard.GetPhononPubKey(keyIndex, crv)
+}
+
+// ListPhonons leaves out the phonons' keys, which the card doesn't list
+func (c *keyCountingCard) ListPhonons(currencyType model.CurrencyType, lessThanValue uint64, greaterThanValue uint64, continues bool) ([]*model.Phonon, error) {
+	phonons, err := c.MockCard.ListPhonons(currencyType, lessThanValue, greaterThanValue, continues)
+	listed := make([]*model.Phonon, 0, len(phonons))
+	for _, p := range phonons {
+		withoutKey := *p
+		withoutKey.PubKey = nil
+		listed = append(listed, &withoutKey)
+	}
+	return listed, err
+}
+
+func (c *keyCountingCard) ReceivePhonons(transfer []byte) error {
+	keyIndex, _, err := c.MockCard.CreatePhonon(model.Secp256k1)
+	if err != nil {
+		return err
+	}
+	return c.MockCard.SetDescriptor(&model.Phonon{KeyIndex: keyIndex, CurrencyType: model.Ethereum})
+}
+
+func TestDuplicateCheckReadsReceivedKeysOnly(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counting := &keyCountingCard{MockCard: mock}
+	sess, err := NewSession(counting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//phonons already on the card whose keys the session has never read
+	for i := 0; i < 10; i++ {
+		err = counting.ReceivePhonons(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counting.keyReads = 0
+	result, err := sess.ReceivePhononsChecked(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Duplicates) != 0 {
+		t.Errorf("expected no duplicates, got %v", result.Duplicates)
+	}
+	if counting.keyReads != 1 {
+		t.Errorf("expected only the received phonon's key to be read, read %v keys", counting.keyReads)
+	}
+}
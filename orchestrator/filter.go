@@ -0,0 +1,95 @@
+package orchestrator
+
+import (
+	"math/big"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+// ValidationFilter selects phonons by whether they have been confirmed on chain during the session
+type ValidationFilter int
+
+const (
+	// AnyValidation matches phonons whether or not they have been validated
+	AnyValidation ValidationFilter = iota
+	// OnlyValidated matches phonons found backed by ReceivePhonons validation or UnbackedPhonons
+	OnlyValidated
+	// OnlyUnvalidated matches phonons that haven't been confirmed on chain, or were found unbacked
+	OnlyUnvalidated
+)
+
+// PhononFilter constrains the phonons returned by ListPhononsFiltered. The zero value matches every phonon.
+type PhononFilter struct {
+	CurrencyType model.CurrencyType //zero matches any currency
+	MinValue     *big.Int           //smallest denomination matched, nil for no minimum
+	MaxValue     *big.Int           //largest denomination matched, nil for no maximum
+	Validation   ValidationFilter
+}
+
+func (f PhononFilter) matches(p *model.Phonon, validated bool) bool {
+	if f.CurrencyType != 0 && p.CurrencyType != f.CurrencyType {
+		return false
+	}
+	value := p.Denomination.Value()
+	if f.MinValue != nil && value.Cmp(f.MinValue) < 0 {
+		return false
+	}
+	if f.MaxValue != nil && value.Cmp(f.MaxValue) > 0 {
+		return false
+	}
+	switch f.Validation {
+	case OnlyValidated:
+		return validated
+	case OnlyUnvalidated:
+		return !validated
+	}
+	return true
+}
+
+// cardValueBounds converts the filter's inclusive value bounds to the exclusive bounds of the card's list filter.
+// A bound that doesn't fit in 64 bits is left zero, which the card treats as no bound.
+func (f PhononFilter) cardValueBounds() (lessThanValue uint64, greaterThanValue uint64) {
+	if f.MaxValue != nil {
+		bound := new(big.Int).Add(f.MaxValue, big.NewInt(1))
+		if bound.IsUint64() {
+			lessThanValue = bound.Uint64()
+		}
+	}
+	if f.MinValue != nil && f.MinValue.Sign() > 0 {
+		bound := new(big.Int).Sub(f.MinValue, big.NewInt(1))
+		if bound.IsUint64() {
+			greaterThanValue = bound.Uint64()
+		}
+	}
+	return lessThanValue, greaterThanValue
+}
+
+/*
+ListPhononsFiltered returns the phonons on the card matching filter. Value bounds are inclusive and compared
+against each phonon's full denomination. The currency and any bounds that fit in 64 bits are passed to the
+card's own list filter, and the rest of the filter is applied to the phonons the card returns.
+*/
+func (s *Session) ListPhononsFiltered(filter PhononFilter) ([]*model.Phonon, error) {
+	lessThanValue, greaterThanValue := filter.cardValueBounds()
+	phonons, err := s.ListPhonons(filter.CurrencyType, lessThanValue, greaterThanValue)
+	if err != nil {
+		return nil, err
+	}
+	ret := []*model.Phonon{}
+	for _, p := range phonons {
+		if filter.matches(p, s.cache[p.KeyIndex].validated) {
+			ret = append(ret, p)
+		}
+	}
+	return ret, nil
+}
+
+// setValidated records whether the phonon at keyIndex was found backed on chain
+func (s *Session) setValidated(keyIndex model.PhononKeyIndex, validated bool) {
+	cached, ok := s.cache[keyIndex]
+	if !ok {
+		return
+	}
+	cached.validated = validated
+	s.cache[keyIndex] = cached
+}
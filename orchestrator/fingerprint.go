@@ -0,0 +1,53 @@
+package orchestrator
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/GridPlus/phonon-client/util"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+var ErrCardMismatch = errors.New("card does not match the expected fingerprint")
+var ErrCardIdentityInvalid = errors.New("card did not sign the identify challenge with its identity key")
+
+const cardFingerprintDomain = "phonon card fingerprint"
+
+/*
+CardFingerprint returns a fingerprint of the card's identity public key so an application tied
+to one card can store it and detect when a different card has been inserted.
+
+The applet exports neither its seed nor a master public key or xpub derived from it, so this
+identifies the card rather than its seed. The fingerprint is a domain separated sha256 of the
+identity key, and the card must sign a fresh nonce with that key for it to be accepted.
+No PIN is needed, so the check can run before anything else touches the card.
+*/
+func (s *Session) CardFingerprint() ([]byte, error) {
+	nonce := util.RandomKey(32)
+	pubKey, sig, err := s.IdentifyCard(nonce)
+	if err != nil {
+		return nil, err
+	}
+	if pubKey == nil || sig == nil || sig.R == nil || sig.S == nil || !ecdsa.Verify(pubKey, nonce, sig.R, sig.S) {
+		return nil, ErrCardIdentityInvalid
+	}
+	s.identityPubKey = pubKey
+	h := sha256.New()
+	h.Write([]byte(cardFingerprintDomain))
+	h.Write(ethcrypto.FromECDSAPub(pubKey))
+	return h.Sum(nil), nil
+}
+
+// CheckCardFingerprint returns ErrCardMismatch if the card's fingerprint is not the expected one
+func (s *Session) CheckCardFingerprint(expected []byte) error {
+	fingerprint, err := s.CardFingerprint()
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(fingerprint, expected) != 1 {
+		return ErrCardMismatch
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package orchestrator
+
+import (
+	"errors"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+var ErrLifecycleUnknown = errors.New("card has not reported its lifecycle state")
+
+/*
+LifecycleState returns the state of the applet as reported when it was last selected, such as whether it
+still needs a PIN. The applet is selected again when the secure channel is opened after initialization,
+but otherwise the state isn't reread, as selecting the applet would close the secure channel.
+
+A locked or terminated applet refuses to be selected, so NewSession fails with card.ErrAppletLocked or
+card.ErrAppletTerminated rather than returning a session for it.
+*/
+func (s *Session) LifecycleState() (model.LifecycleState, error) {
+	s.ElementUsageMtex.Lock()
+	defer s.ElementUsageMtex.Unlock()
+
+	state := s.cs.CardInfo().Lifecycle
+	if state == model.LifecycleUnknown {
+		return state, ErrLifecycleUnknown
+	}
+	return state, nil
+}
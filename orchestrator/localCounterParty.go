@@ -75,6 +75,14 @@ func (lcp *localCounterParty) VerifyPaired() error {
 	}
 }
 
+func (lcp *localCounterParty) DryRunTransfer() error {
+	err := lcp.VerifyPaired()
+	if err != nil {
+		return err
+	}
+	return lcp.counterSession.checkReadyToReceive()
+}
+
 func (lcp *localCounterParty) PairingStatus() model.RemotePairingStatus {
 	return lcp.pairingStatus
 }
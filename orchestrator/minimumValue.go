@@ -0,0 +1,61 @@
+package orchestrator
+
+import (
+	"math/big"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+/*
+SetMinAcceptedValue sets the smallest denomination of currency accepted from a counterparty, protecting the card's
+limited storage from being filled by transfers of dust. A nil or zero min accepts phonons of any value, which is the default.
+
+The transfer packet is encrypted for the card, so phonons can't be checked before the card commits them and a
+transfer can't be refused for carrying dust. Received phonons below the minimum are kept, since they may still
+hold value, and reported in ReceiveResult.BelowMinimum so the owner can redeem or send them on to free the slots.
+*/
+func (s *Session) SetMinAcceptedValue(currency model.CurrencyType, min *big.Int) {
+	if min == nil || min.Sign() <= 0 {
+		delete(s.minAcceptedValue, currency)
+		return
+	}
+	if s.minAcceptedValue == nil {
+		s.minAcceptedValue = make(map[model.CurrencyType]*big.Int)
+	}
+	s.minAcceptedValue[currency] = new(big.Int).Set(min)
+}
+
+// MinAcceptedValue returns the smallest denomination of currency accepted on receive, zero if any value is accepted
+func (s *Session) MinAcceptedValue(currency model.CurrencyType) *big.Int {
+	min, ok := s.minAcceptedValue[currency]
+	if !ok {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Set(min)
+}
+
+// belowMinimum returns the indices of the phonons received since before whose value is below their currency's minimum
+func (s *Session) belowMinimum(before map[model.PhononKeyIndex]bool) ([]model.PhononKeyIndex, error) {
+	if len(s.minAcceptedValue) == 0 {
+		return nil, nil
+	}
+	phonons, err := s.ListPhonons(0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	var below []model.PhononKeyIndex
+	for _, p := range phonons {
+		if before[p.KeyIndex] {
+			continue
+		}
+		min, ok := s.minAcceptedValue[p.CurrencyType]
+		if !ok || p.Denomination.Value().Cmp(min) >= 0 {
+			continue
+		}
+		below = append(below, p.KeyIndex)
+	}
+	if len(below) > 0 {
+		s.logger.Warnf("received phonons at indices %v are below the minimum accepted value", below)
+	}
+	return below, nil
+}
@@ -0,0 +1,108 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/GridPlus/phonon-client/cert"
+	"github.com/GridPlus/phonon-client/model"
+)
+
+var ErrWrongRecipient = errors.New("offline transfer is addressed to another card")
+var ErrRecipientNotPaired = errors.New("offline transfer recipient is not the card this card is paired with")
+
+/*
+OfflineTransfer carries a transfer packet between air-gapped cards in a file instead of over a connection.
+
+The packet is the one SendPhonons delivers, encrypted by the sending card for the card it is paired with.
+The cards must be paired first by carrying the InitCardPairing, CardPair and CardPair2 data between them
+the same way, without either session being reset until the transfer has been imported.
+
+An offline transfer is a one way hand off that can't be recovered. The sender's card deletes the phonons as it
+writes the transfer with WriteOfflineTransfer, so from then on the file is the only copy of them, and only the
+recipient card can import it with ImportOfflineTransfer while it is still paired with the sender. If the file is lost
+or the recipient card pairs with another card before importing it, the phonons are gone.
+
+There is no receipt. The card has no command to sign that it stored a transfer, and a signature made through
+IDENTIFY_CARD can be obtained for any digest, so it would not show the phonons arrived. Nor would a receipt
+make the transfer safer, since the sender has nothing left to recover once it is written.
+*/
+type OfflineTransfer struct {
+	SenderCert      []byte //serialized certificate of the sending card
+	RecipientPubKey []byte //identity public key of the card the packet is encrypted for
+	Packet          []byte
+}
+
+// Encode serializes the transfer for writing to a file
+func (t OfflineTransfer) Encode() ([]byte, error) {
+	return json.Marshal(t)
+}
+
+// DecodeOfflineTransfer parses a transfer file written with OfflineTransfer.Encode
+func DecodeOfflineTransfer(data []byte) (OfflineTransfer, error) {
+	var t OfflineTransfer
+	err := json.Unmarshal(data, &t)
+	return t, err
+}
+
+/*
+WriteOfflineTransfer sends the phonons at keyIndices to the recipient card this card is paired with,
+returning the transfer for delivery as a file. It fails with ErrRecipientNotPaired unless recipient is that card,
+since the packet can only be imported by the paired card. As with SendPhonons the phonons are gone from this card
+once it returns, and the transfer is the only copy of them, see OfflineTransfer.
+*/
+func (s *Session) WriteOfflineTransfer(keyIndices []model.PhononKeyIndex, recipient cert.CardCertificate) (OfflineTransfer, error) {
+	err := s.checkCanSend()
+	if err != nil {
+		return OfflineTransfer{}, err
+	}
+	if !s.verified() {
+		return OfflineTransfer{}, s.unverifiedErr()
+	}
+	senderCert, err := s.PublicIdentity()
+	if err != nil {
+		return OfflineTransfer{}, err
+	}
+	if bytes.Equal(senderCert.PubKey, recipient.PubKey) {
+		return OfflineTransfer{}, ErrSelfTransfer
+	}
+	if !bytes.Equal(s.pairingPubKey, recipient.PubKey) {
+		return OfflineTransfer{}, ErrRecipientNotPaired
+	}
+	err = s.reservePhonons(keyIndices)
+	if err != nil {
+		return OfflineTransfer{}, err
+	}
+	defer s.releasePhonons(keyIndices)
+
+	s.ElementUsageMtex.Lock()
+	defer s.ElementUsageMtex.Unlock()
+	packet, err := s.cs.SendPhonons(keyIndices, false)
+	if err != nil {
+		return OfflineTransfer{}, err
+	}
+	for _, index := range keyIndices {
+		delete(s.cache, index)
+	}
+	return OfflineTransfer{
+		SenderCert:      senderCert.Serialize(),
+		RecipientPubKey: recipient.PubKey,
+		Packet:          packet,
+	}, nil
+}
+
+// ImportOfflineTransfer receives the phonons in t onto this card, which must be the card it was written for
+func (s *Session) ImportOfflineTransfer(t OfflineTransfer) error {
+	if !s.verified() {
+		return s.unverifiedErr()
+	}
+	recipientCert, err := s.PublicIdentity()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(recipientCert.PubKey, t.RecipientPubKey) {
+		return ErrWrongRecipient
+	}
+	return s.ReceivePhonons(t.Packet)
+}
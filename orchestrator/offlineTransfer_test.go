@@ -0,0 +1,95 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/GridPlus/phonon-client/card"
+	"github.com/GridPlus/phonon-client/model"
+)
+
+func TestOfflineTransfer(t *testing.T) {
+	var sessions []*Session
+	for i := 0; i < 3; i++ {
+		mock, err := card.NewMockCard(true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess, err := NewSession(mock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.VerifyPIN("111111")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, sess)
+	}
+	sender, recipient, other := sessions[0], sessions[1], sessions[2]
+
+	//each pairing step would be carried between the cards in a file
+	recipientCert, err := recipient.GetCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	initPairingData, err := sender.InitCardPairing(*recipientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardPairData, err := recipient.CardPair(initPairingData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardPair2Data, err := sender.CardPair2(cardPairData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = recipient.FinalizeCardPair(cardPair2Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyIndex, _, err := sender.CreatePhonon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sender.SetDescriptor(&model.Phonon{KeyIndex: keyIndex, CurrencyType: model.Ethereum, Denomination: model.Denomination{Base: 1, Exponent: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCert, err := other.GetCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sender.WriteOfflineTransfer([]model.PhononKeyIndex{keyIndex}, *otherCert)
+	if err != ErrRecipientNotPaired {
+		t.Errorf("expected %v writing for an unpaired card, got %v", ErrRecipientNotPaired, err)
+	}
+	transfer, err := sender.WriteOfflineTransfer([]model.PhononKeyIndex{keyIndex}, *recipientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transferFile, err := transfer.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, err := DecodeOfflineTransfer(transferFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = other.ImportOfflineTransfer(imported)
+	if err != ErrWrongRecipient {
+		t.Errorf("expected %v importing on another card, got %v", ErrWrongRecipient, err)
+	}
+	err = recipient.ImportOfflineTransfer(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phonons, err := recipient.ListPhonons(0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(phonons) != 1 || phonons[0].Denomination.Value().Int64() != 1000 {
+		t.Errorf("expected the transferred phonon on the recipient, got %v", phonons)
+	}
+}
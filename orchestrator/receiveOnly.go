@@ -0,0 +1,36 @@
+package orchestrator
+
+import (
+	"errors"
+)
+
+var ErrReceiveOnly = errors.New("session is receive only and cannot send, redeem or export phonons")
+
+// Option configures a Session at construction
+type Option func(*Session)
+
+/*
+ReceiveOnly makes the session refuse every operation that moves phonons off the card.
+SendPhonons, DryRunTransfer, DestroyPhonon and RedeemPhonon return ErrReceiveOnly,
+while pairing, receiving, depositing and listing phonons work as usual.
+
+This is a policy enforced by the session for cards designated as deposit destinations, not by the applet.
+Anyone holding the card and its PIN can still send phonons from it through another session.
+*/
+func ReceiveOnly() Option {
+	return func(s *Session) {
+		s.receiveOnly = true
+	}
+}
+
+// IsReceiveOnly reports whether the session was constructed with ReceiveOnly
+func (s *Session) IsReceiveOnly() bool {
+	return s.receiveOnly
+}
+
+func (s *Session) checkCanSend() error {
+	if s.receiveOnly {
+		return ErrReceiveOnly
+	}
+	return nil
+}
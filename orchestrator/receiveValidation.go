@@ -0,0 +1,84 @@
+package orchestrator
+
+import (
+	"context"
+	"time"
+
+	"github.com/GridPlus/phonon-client/model"
+	"github.com/GridPlus/phonon-client/validator"
+)
+
+// receiveChecksTimeout bounds the checks made after a transfer is received, reading the received phonons' keys
+// from the card and confirming them on chain. It is kept below the sender's default PhononAck timeout so checking
+// doesn't make a stored transfer look lost to the sender.
+const receiveChecksTimeout = 8 * time.Second
+
+/*
+ReceiveResult reports the checks ReceivePhononsChecked made on a transfer once the card stored it.
+
+The transfer packet is encrypted for the card, so phonons can only be inspected after the card has accepted them,
+when the sender's card has already given them up. The checks are therefore advisory: the transfer is acknowledged
+to the sender whatever they find, and flagged phonons are kept on the card and listed here for the owner to act on.
+
+Duplicates holds the indices of received copies of keys the card already held, which were destroyed to free
+their slots since another slot holds the same key. BelowMinimum holds the indices of received phonons worth
+less than their currency's MinAcceptedValue. Unconfirmed holds the validation outcome of each received
+phonon that could not be confirmed on chain, with Err set when the validator couldn't reach a verdict.
+It is empty unless receive validation is enabled.
+*/
+type ReceiveResult struct {
+	Duplicates   []model.PhononKeyIndex
+	BelowMinimum []model.PhononKeyIndex
+	Unconfirmed  []validator.Result
+}
+
+/*
+SetReceiveValidation enables checking every received phonon against the chain with the registered validators.
+Each received public key is used to derive the phonon's addresses, which must hold funds matching its
+denomination. Phonons of a currency without a registered validator can't be confirmed and fail validation.
+Validation is advisory, failing phonons are kept and reported in ReceiveResult.Unconfirmed.
+*/
+func (s *Session) SetReceiveValidation(enabled bool) {
+	s.validateReceived = enabled
+}
+
+// phononIndices returns the key indices of every phonon currently on the card
+func (s *Session) phononIndices() (map[model.PhononKeyIndex]bool, error) {
+	phonons, err := s.ListPhonons(0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[model.PhononKeyIndex]bool, len(phonons))
+	for _, p := range phonons {
+		ret[p.KeyIndex] = true
+	}
+	return ret, nil
+}
+
+// validateReceivedPhonons validates the phonons that were not on the card before the transfer, returning those that failed
+func (s *Session) validateReceivedPhonons(ctx context.Context, before map[model.PhononKeyIndex]bool) ([]validator.Result, error) {
+	phonons, err := s.ListPhonons(0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	var received []*model.Phonon
+	for _, p := range phonons {
+		if before[p.KeyIndex] {
+			continue
+		}
+		phonon, err := s.GetPhonon(p.KeyIndex)
+		if err != nil {
+			return nil, err
+		}
+		received = append(received, phonon)
+	}
+	var invalid []validator.Result
+	for _, r := range validator.ValidateAll(ctx, received, 0) {
+		s.setValidated(r.Phonon.KeyIndex, r.Valid && r.Err == nil)
+		if !r.Valid || r.Err != nil {
+			s.logger.Errorf("received phonon %v could not be confirmed on chain. valid: %v, err: %v", r.Phonon.KeyIndex, r.Valid, r.Err)
+			invalid = append(invalid, r)
+		}
+	}
+	return invalid, nil
+}
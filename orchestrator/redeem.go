@@ -0,0 +1,73 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GridPlus/phonon-client/chain"
+	"github.com/GridPlus/phonon-client/model"
+)
+
+// DefaultRedeemFeeLimit is the percentage of a phonon's value its redeem fee may reach before RedeemToAddress requires confirmation
+const DefaultRedeemFeeLimit = 10
+
+var ErrRedeemFeeExceedsLimit = errors.New("redeem fee exceeds the limit for the phonon's value")
+
+// RedeemFeeError is returned by RedeemToAddress when the estimated fee is more than the session's limit
+// and the caller hasn't confirmed it. The phonon is left on the card.
+type RedeemFeeError struct {
+	Estimate     chain.RedeemEstimate
+	LimitPercent uint
+}
+
+func (e *RedeemFeeError) Error() string {
+	return fmt.Sprintf("redeem fee of %v is more than %d%% of the phonon's value of %v", e.Estimate.Fee, e.LimitPercent, e.Estimate.Value)
+}
+
+func (e *RedeemFeeError) Unwrap() error {
+	return ErrRedeemFeeExceedsLimit
+}
+
+// RedeemResult is the outcome of RedeemToAddress, including the fee estimate the redeem was made with
+type RedeemResult struct {
+	Estimate        chain.RedeemEstimate
+	TransactionData string
+	PrivKey         string
+}
+
+// SetRedeemFeeLimit sets the percentage of a phonon's value its redeem fee may reach before RedeemToAddress requires confirmation
+func (s *Session) SetRedeemFeeLimit(percent uint) {
+	s.redeemFeeLimit = percent
+}
+
+// EstimateRedeemFee returns the network fee for redeeming p at the chain's current fee rate, and the amount that would be received
+func (s *Session) EstimateRedeemFee(p *model.Phonon) (chain.RedeemEstimate, error) {
+	if !s.verified() {
+		return chain.RedeemEstimate{}, s.unverifiedErr()
+	}
+	return s.chainSrv.EstimateRedeemFee(p)
+}
+
+/*
+RedeemToAddress redeems p as RedeemPhonon does once the network fee has been estimated. If the fee is more than
+the session's redeem fee limit the phonon is kept and a RedeemFeeError is returned, unless confirmFee is set because
+the user has accepted the fee. The estimate is returned in either case so it can be shown to the user.
+
+The fee is estimated immediately before redeeming, so the fee actually paid may still differ slightly.
+*/
+func (s *Session) RedeemToAddress(p *model.Phonon, redeemAddress string, confirmFee bool) (RedeemResult, error) {
+	err := s.checkCanSend()
+	if err != nil {
+		return RedeemResult{}, err
+	}
+	estimate, err := s.EstimateRedeemFee(p)
+	if err != nil {
+		return RedeemResult{}, err
+	}
+	result := RedeemResult{Estimate: estimate}
+	if !confirmFee && estimate.FeeExceeds(s.redeemFeeLimit) {
+		return result, &RedeemFeeError{Estimate: estimate, LimitPercent: s.redeemFeeLimit}
+	}
+	result.TransactionData, result.PrivKey, err = s.RedeemPhonon(p, redeemAddress)
+	return result, err
+}
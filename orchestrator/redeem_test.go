@@ -0,0 +1,118 @@
+package orchestrator
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/GridPlus/phonon-client/card"
+	"github.com/GridPlus/phonon-client/chain"
+	"github.com/GridPlus/phonon-client/model"
+)
+
+// fakeChainService records redemptions instead of submitting transactions
+type fakeChainService struct {
+	redeemErr  error
+	redeemedTo []string
+	estimate   chain.RedeemEstimate
+}
+
+func (f *fakeChainService) DeriveAddress(p *model.Phonon) (string, error) {
+	return "addr-" + p.PubKey.String()[:8], nil
+}
+
+func (f *fakeChainService) CheckRedeemable(p *model.Phonon, redeemAddress string) error {
+	return nil
+}
+
+func (f *fakeChainService) EstimateRedeemFee(p *model.Phonon) (chain.RedeemEstimate, error) {
+	return f.estimate, nil
+}
+
+func (f *fakeChainService) RedeemPhonon(p *model.Phonon, privKey *ecdsa.PrivateKey, redeemAddress string) (string, error) {
+	if f.redeemErr != nil {
+		return "", f.redeemErr
+	}
+	f.redeemedTo = append(f.redeemedTo, redeemAddress)
+	return "sweeptx", nil
+}
+
+func newRedeemSession(t *testing.T, currencyType model.CurrencyType) (*Session, *fakeChainService, model.PhononKeyIndex) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainSrv := &fakeChainService{estimate: chain.RedeemEstimate{Net: big.NewInt(4837)}}
+	sess.chainSrv = chainSrv
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyIndex, _, err := sess.CreatePhonon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	denom, _ := model.NewDenomination(big.NewInt(5000))
+	err = sess.SetDescriptor(&model.Phonon{KeyIndex: keyIndex, CurrencyType: currencyType, Denomination: denom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sess, chainSrv, keyIndex
+}
+
+var testEstimate = chain.RedeemEstimate{
+	Value:   big.NewInt(5000),
+	FeeRate: big.NewInt(10),
+	Size:    21,
+	Fee:     big.NewInt(210),
+	Net:     big.NewInt(4790),
+}
+
+func TestRedeemToAddressFeeLimit(t *testing.T) {
+	sess, chainSrv, keyIndex := newRedeemSession(t, model.Ethereum)
+	p, err := sess.GetPhonon(keyIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//a fee of 210 is 4.2% of the phonon's value
+	chainSrv.estimate = testEstimate
+
+	sess.SetRedeemFeeLimit(1)
+	result, err := sess.RedeemToAddress(p, "redeem", false)
+	var feeErr *RedeemFeeError
+	if !errors.As(err, &feeErr) || !errors.Is(err, ErrRedeemFeeExceedsLimit) {
+		t.Fatalf("expected a RedeemFeeError above the limit, got %v", err)
+	}
+	if result.Estimate.Net.Int64() != 4790 || len(chainSrv.redeemedTo) != 0 {
+		t.Errorf("expected the estimate without redeeming, got %+v and redeems %v", result.Estimate, chainSrv.redeemedTo)
+	}
+	if _, err = sess.GetPhonon(keyIndex); err != nil {
+		t.Error("expected the phonon to be kept when the fee isn't confirmed: ", err)
+	}
+
+	result, err = sess.RedeemToAddress(p, "redeem", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TransactionData != "sweeptx" || result.PrivKey == "" || len(chainSrv.redeemedTo) != 1 {
+		t.Errorf("expected a confirmed redeem to go ahead, got %+v", result)
+	}
+}
+
+func TestRedeemToAddressWithinLimit(t *testing.T) {
+	sess, chainSrv, keyIndex := newRedeemSession(t, model.Ethereum)
+	p, err := sess.GetPhonon(keyIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainSrv.estimate = testEstimate
+	_, err = sess.RedeemToAddress(p, "redeem", false)
+	if err != nil {
+		t.Fatalf("expected the fee to be within the default limit, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+var ErrInvalidPhononIndex = errors.New("no phonon available at index")
+var ErrPhononReserved = errors.New("phonon already reserved by another transfer")
+
+// PhononIndexError reports the requested indices that caused a transfer to be refused.
+// Err is ErrInvalidPhononIndex or ErrPhononReserved.
+type PhononIndexError struct {
+	Err     error
+	Indices []model.PhononKeyIndex
+}
+
+func (e *PhononIndexError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Err, e.Indices)
+}
+
+func (e *PhononIndexError) Unwrap() error {
+	return e.Err
+}
+
+/*
+reservePhonons checks every index names a phonon on the card that isn't part of another transfer
+and reserves them all for the caller, who must release them when the transfer ends.
+Nothing is reserved if any index fails, so a bad request is refused before any transfer work starts.
+*/
+func (s *Session) reservePhonons(keyIndices []model.PhononKeyIndex) error {
+	phonons, err := s.ListPhonons(0, 0, 0)
+	if err != nil {
+		return err
+	}
+	available := make(map[model.PhononKeyIndex]bool, len(phonons))
+	for _, p := range phonons {
+		available[p.KeyIndex] = true
+	}
+	var invalid []model.PhononKeyIndex
+	requested := make(map[model.PhononKeyIndex]bool, len(keyIndices))
+	for _, index := range keyIndices {
+		//a phonon can't be sent twice in one transfer either
+		if !available[index] || requested[index] {
+			invalid = append(invalid, index)
+		}
+		requested[index] = true
+	}
+	if len(invalid) > 0 {
+		return &PhononIndexError{Err: ErrInvalidPhononIndex, Indices: invalid}
+	}
+
+	s.reservedMtex.Lock()
+	defer s.reservedMtex.Unlock()
+	var reserved []model.PhononKeyIndex
+	for _, index := range keyIndices {
+		if s.reserved[index] {
+			reserved = append(reserved, index)
+		}
+	}
+	if len(reserved) > 0 {
+		return &PhononIndexError{Err: ErrPhononReserved, Indices: reserved}
+	}
+	if s.reserved == nil {
+		s.reserved = make(map[model.PhononKeyIndex]bool)
+	}
+	for _, index := range keyIndices {
+		s.reserved[index] = true
+	}
+	return nil
+}
+
+func (s *Session) releasePhonons(keyIndices []model.PhononKeyIndex) {
+	s.reservedMtex.Lock()
+	for _, index := range keyIndices {
+		delete(s.reserved, index)
+	}
+	s.reservedMtex.Unlock()
+}
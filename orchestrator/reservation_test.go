@@ -0,0 +1,102 @@
+package orchestrator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/GridPlus/phonon-client/card"
+	"github.com/GridPlus/phonon-client/model"
+)
+
+func TestSendPhononsValidatesIndices(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _, err := sess.CreatePhonon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := sess.CreatePhonon()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//no counterparty is paired, so reaching the network would panic rather than return these errors
+	err = sess.SendPhonons([]model.PhononKeyIndex{first, 40, first})
+	var indexErr *PhononIndexError
+	if !errors.As(err, &indexErr) || !errors.Is(err, ErrInvalidPhononIndex) {
+		t.Fatalf("expected %v, got %v", ErrInvalidPhononIndex, err)
+	}
+	if !reflect.DeepEqual(indexErr.Indices, []model.PhononKeyIndex{40, first}) {
+		t.Errorf("unexpected invalid indices %v", indexErr.Indices)
+	}
+
+	err = sess.reservePhonons([]model.PhononKeyIndex{second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.SendPhonons([]model.PhononKeyIndex{first, second})
+	if !errors.As(err, &indexErr) || !errors.Is(err, ErrPhononReserved) {
+		t.Fatalf("expected %v, got %v", ErrPhononReserved, err)
+	}
+	if !reflect.DeepEqual(indexErr.Indices, []model.PhononKeyIndex{second}) {
+		t.Errorf("unexpected reserved indices %v", indexErr.Indices)
+	}
+	if sess.reserved[first] {
+		t.Error("refused transfer left a phonon reserved")
+	}
+}
+
+func TestDestroyReservedPhonon(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyIndex, pubKey, err := sess.CreatePhonon()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sess.reservePhonons([]model.PhononKeyIndex{keyIndex})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sess.DestroyPhonon(keyIndex)
+	if !errors.Is(err, ErrPhononReserved) {
+		t.Fatalf("expected a phonon mid transfer to be refused with %v, got %v", ErrPhononReserved, err)
+	}
+	sess.releasePhonons([]model.PhononKeyIndex{keyIndex})
+
+	privKey, err := sess.DestroyPhonon(keyIndex)
+	if err != nil {
+		t.Fatal("unable to destroy phonon: ", err)
+	}
+	if !pubKey.Equal(&model.ECCPubKey{PubKey: &privKey.PublicKey}) {
+		t.Error("destroyed phonon returned the wrong private key")
+	}
+	if sess.reserved[keyIndex] {
+		t.Error("destroying a phonon left it reserved")
+	}
+	_, err = sess.DestroyPhonon(keyIndex)
+	if !errors.Is(err, ErrInvalidPhononIndex) {
+		t.Errorf("expected a destroyed phonon to be gone, got %v", err)
+	}
+}
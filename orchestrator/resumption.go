@@ -0,0 +1,159 @@
+package orchestrator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/GridPlus/phonon-client/model"
+	"github.com/GridPlus/phonon-client/util"
+)
+
+// ResumptionTokenLifetime is how long after pairing completes a dropped connection may resume the pairing.
+// After this a fresh pairing is required.
+const ResumptionTokenLifetime = 5 * time.Minute
+
+var ErrNoResumptionToken = errors.New("no resumable card pairing")
+var ErrResumptionTokenExpired = errors.New("resumption token expired, cards must pair again")
+var ErrResumptionTokenInvalid = errors.New("resumption token does not match the last card pairing")
+var ErrResumptionUnsupported = errors.New("counterparty can't resume a card pairing")
+var ErrResumptionCardMismatch = errors.New("last card pairing was made with another card")
+
+/*
+A resumption token identifies a completed card to card pairing so a transfer interrupted
+by a dropped connection can continue over a new connection without pairing again.
+
+Both cards derive the same token from the pairing transcript, the data exchanged in
+CardPair, CardPair2 and FinalizeCardPair, so no extra message is needed to agree on it.
+When the connection is re-established the side connecting to the card sends its token,
+and the counterparty only carries on with the pairing if it matches its own. A token is rejected once ResumptionTokenLifetime has passed since pairing completed,
+and is discarded as soon as either side starts a new pairing, since the card's own
+pairing state is replaced at that point.
+
+The token alone doesn't show which card is on the other end of the new connection, so the identity key
+of the card the pairing was made with is kept alongside it. A pairing is only resumed with a counterparty
+certified with that key, since the card would otherwise encrypt transfers for a card that can't decrypt them.
+*/
+type resumptionState struct {
+	token           []byte
+	expires         time.Time
+	counterpartyKey []byte //identity key of the card the pairing was made with
+}
+
+const resumptionTokenDomain = "phonon pairing resumption"
+
+func newResumptionState(counterpartyKey []byte, initPairingData []byte, cardPairData []byte, cardPair2Data []byte) *resumptionState {
+	h := sha256.New()
+	h.Write([]byte(resumptionTokenDomain))
+	for _, data := range [][]byte{initPairingData, cardPairData, cardPair2Data} {
+		//length prefix each field so the transcript can't be shifted between them
+		binary.Write(h, binary.BigEndian, uint32(len(data)))
+		h.Write(data)
+	}
+	return &resumptionState{
+		token:           h.Sum(nil),
+		expires:         time.Now().Add(ResumptionTokenLifetime),
+		counterpartyKey: counterpartyKey,
+	}
+}
+
+func (r *resumptionState) check(token []byte, now time.Time) error {
+	if r == nil {
+		return ErrNoResumptionToken
+	}
+	if !now.Before(r.expires) {
+		return ErrResumptionTokenExpired
+	}
+	if subtle.ConstantTimeCompare(r.token, token) != 1 {
+		return ErrResumptionTokenInvalid
+	}
+	return nil
+}
+
+// checkCounterparty returns ErrResumptionCardMismatch unless remoteCard is certified with the key of the card the pairing was made with
+func (r *resumptionState) checkCounterparty(remoteCard model.CounterpartyPhononCard) error {
+	remoteCert, err := remoteCard.GetCertificate()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(r.counterpartyKey, remoteCert.PubKey) {
+		return ErrResumptionCardMismatch
+	}
+	return nil
+}
+
+// counterpartyID returns the card ID of the card the pairing was made with, or an empty string if its key is invalid
+func (r *resumptionState) counterpartyID() string {
+	key, err := util.ParseECCPubKey(r.counterpartyKey)
+	if err != nil {
+		return ""
+	}
+	return util.CardIDFromPubKey(key)
+}
+
+// pairingResumer is a counterparty that can carry on with a previous card pairing, see ResumePairing
+type pairingResumer interface {
+	ResumePairing(token []byte) error
+}
+
+/*
+ResumePairing reattaches a re-established counterparty connection to the previous card pairing instead of
+pairing again, by sending the counterparty the pairing's resumption token to check against its own.
+The pairing must have been made with the card cardID, and remoteCard must be certified with that card's key,
+otherwise it fails with ErrResumptionCardMismatch without sending the token.
+It fails with ErrResumptionUnsupported if the counterparty connection has no way to send it.
+*/
+func (s *Session) ResumePairing(remoteCard model.CounterpartyPhononCard, cardID string) error {
+	if !s.verified() {
+		return s.unverifiedErr()
+	}
+	if s.resumption == nil {
+		return ErrNoResumptionToken
+	}
+	if !time.Now().Before(s.resumption.expires) {
+		s.resumption = nil
+		return ErrResumptionTokenExpired
+	}
+	resumer, ok := remoteCard.(pairingResumer)
+	if !ok {
+		return ErrResumptionUnsupported
+	}
+	if !strings.EqualFold(s.resumption.counterpartyID(), cardID) {
+		return ErrResumptionCardMismatch
+	}
+	err := s.resumption.checkCounterparty(remoteCard)
+	if err != nil {
+		return err
+	}
+	err = resumer.ResumePairing(s.resumption.token)
+	if err != nil {
+		return err
+	}
+	s.RemoteCard = remoteCard
+	return nil
+}
+
+// acceptResumption checks the resumption token a counterparty sent with ResumePairing against the last card pairing,
+// and that the counterparty is the card that pairing was made with
+func (s *Session) acceptResumption(remoteCard model.CounterpartyPhononCard, token []byte) error {
+	if !s.verified() {
+		return s.unverifiedErr()
+	}
+	err := s.resumption.check(token, time.Now())
+	if err == ErrResumptionTokenExpired {
+		s.resumption = nil
+	}
+	if err != nil {
+		return err
+	}
+	err = s.resumption.checkCounterparty(remoteCard)
+	if err != nil {
+		return err
+	}
+	s.RemoteCard = remoteCard
+	return nil
+}
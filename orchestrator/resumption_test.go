@@ -0,0 +1,102 @@
+package orchestrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GridPlus/phonon-client/cert"
+	"github.com/GridPlus/phonon-client/util"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestResumptionToken(t *testing.T) {
+	sender := newResumptionState(nil, []byte("init"), []byte("pair"), []byte("pair2"))
+	receiver := newResumptionState(nil, []byte("init"), []byte("pair"), []byte("pair2"))
+	if err := receiver.check(sender.token, time.Now()); err != nil {
+		t.Fatal("both sides of a pairing should derive the same token: ", err)
+	}
+
+	shifted := newResumptionState(nil, []byte("ini"), []byte("tpair"), []byte("pair2"))
+	if err := receiver.check(shifted.token, time.Now()); err != ErrResumptionTokenInvalid {
+		t.Errorf("expected token from another pairing to be invalid, got %v", err)
+	}
+	if err := receiver.check(sender.token, time.Now().Add(ResumptionTokenLifetime)); err != ErrResumptionTokenExpired {
+		t.Errorf("expected stale token to be rejected, got %v", err)
+	}
+	var none *resumptionState
+	if err := none.check(sender.token, time.Now()); err != ErrNoResumptionToken {
+		t.Errorf("expected missing pairing to be reported, got %v", err)
+	}
+}
+
+// fakeResumer is a counterparty connection to the card holding key, which resumes pairings with counterparty
+type fakeResumer struct {
+	localCounterParty
+	key          []byte
+	local        []byte //key of the card on this side of the connection
+	counterparty *Session
+}
+
+func (f *fakeResumer) GetCertificate() (*cert.CardCertificate, error) {
+	return &cert.CardCertificate{PubKey: f.key}, nil
+}
+
+func (f *fakeResumer) ResumePairing(token []byte) error {
+	return f.counterparty.acceptResumption(&fakeResumer{key: f.local}, token)
+}
+
+func newCardKey(t *testing.T) ([]byte, string) {
+	key, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ethcrypto.FromECDSAPub(&key.PublicKey), util.CardIDFromPubKey(&key.PublicKey)
+}
+
+func TestResumePairing(t *testing.T) {
+	senderKey, _ := newCardKey(t)
+	receiverKey, receiverID := newCardKey(t)
+	otherKey, otherID := newCardKey(t)
+
+	sender := &Session{pinVerified: true, terminalPaired: true}
+	receiver := &Session{pinVerified: true, terminalPaired: true}
+	remoteCard := &fakeResumer{key: receiverKey, local: senderKey, counterparty: receiver}
+	if err := sender.ResumePairing(remoteCard, receiverID); err != ErrNoResumptionToken {
+		t.Errorf("expected resuming without a pairing to fail with %v, got %v", ErrNoResumptionToken, err)
+	}
+
+	sender.resumption = newResumptionState(receiverKey, []byte("init"), []byte("pair"), []byte("pair2"))
+	receiver.resumption = newResumptionState(senderKey, []byte("init"), []byte("pair"), []byte("pair2"))
+	if err := sender.ResumePairing(remoteCard, receiverID); err != nil {
+		t.Fatal("expected the counterparty to accept the token of the same pairing: ", err)
+	}
+	if sender.RemoteCard != remoteCard {
+		t.Error("expected the resumed counterparty to become the remote card")
+	}
+
+	//connecting to another card must not send it the token or treat it as paired
+	otherCard := &fakeResumer{key: otherKey, local: senderKey, counterparty: receiver}
+	if err := sender.ResumePairing(otherCard, otherID); err != ErrResumptionCardMismatch {
+		t.Errorf("expected resuming with another card to fail with %v, got %v", ErrResumptionCardMismatch, err)
+	}
+	if err := sender.ResumePairing(otherCard, receiverID); err != ErrResumptionCardMismatch {
+		t.Errorf("expected a counterparty certified with another key to fail with %v, got %v", ErrResumptionCardMismatch, err)
+	}
+	if sender.RemoteCard != remoteCard {
+		t.Error("expected a rejected resumption to leave the remote card unchanged")
+	}
+
+	//the counterparty refuses a token presented by another card
+	impostor := &fakeResumer{key: receiverKey, local: otherKey, counterparty: receiver}
+	if err := sender.ResumePairing(impostor, receiverID); err != ErrResumptionCardMismatch {
+		t.Errorf("expected the counterparty to refuse another card's resumption with %v, got %v", ErrResumptionCardMismatch, err)
+	}
+
+	receiver.resumption = newResumptionState(senderKey, []byte("init"), []byte("other"), []byte("pair2"))
+	if err := sender.ResumePairing(remoteCard, receiverID); err != ErrResumptionTokenInvalid {
+		t.Errorf("expected the counterparty to reject the token of another pairing, got %v", err)
+	}
+	if err := sender.ResumePairing(&localCounterParty{}, receiverID); err != ErrResumptionUnsupported {
+		t.Errorf("expected a counterparty without resumption to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+var ErrInsufficientFunds = errors.New("phonons on the card don't add up to the requested amount")
+
+// SelectionStrategy decides which phonons SelectPhononsForAmount spends first
+type SelectionStrategy int
+
+const (
+	// SelectLargestFirst spends the largest phonons first, sending as few phonons as possible
+	SelectLargestFirst SelectionStrategy = iota
+	// SelectSmallestFirst spends the smallest phonons first, clearing dust from the card
+	SelectSmallestFirst
+)
+
+// SetSelectionStrategy sets how SelectPhononsForAmount picks phonons. The default is SelectLargestFirst.
+func (s *Session) SetSelectionStrategy(strategy SelectionStrategy) {
+	s.selectionStrategy = strategy
+}
+
+/*
+SelectPhononsForAmount picks phonons of currency adding up to at least amount using the session's SelectionStrategy,
+returning their key indices and their total value. Phonons can't be split, so the total may exceed amount and
+the difference has to be returned as change. Phonons reserved by a transfer in progress are never selected.
+
+ErrInsufficientFunds is returned if every available phonon of currency together is worth less than amount.
+*/
+func (s *Session) SelectPhononsForAmount(currency model.CurrencyType, amount *big.Int) ([]model.PhononKeyIndex, *big.Int, error) {
+	phonons, err := s.ListPhonons(0, 0, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.reservedMtex.Lock()
+	var available []*model.Phonon
+	for _, p := range phonons {
+		if p.CurrencyType == currency && !s.reserved[p.KeyIndex] {
+			available = append(available, p)
+		}
+	}
+	s.reservedMtex.Unlock()
+
+	sort.SliceStable(available, func(i, j int) bool {
+		cmp := available[i].Denomination.Value().Cmp(available[j].Denomination.Value())
+		if s.selectionStrategy == SelectSmallestFirst {
+			return cmp < 0
+		}
+		return cmp > 0
+	})
+	total := big.NewInt(0)
+	var selected []model.PhononKeyIndex
+	for _, p := range available {
+		if total.Cmp(amount) >= 0 {
+			break
+		}
+		selected = append(selected, p.KeyIndex)
+		total.Add(total, p.Denomination.Value())
+	}
+	if total.Cmp(amount) < 0 {
+		return nil, nil, ErrInsufficientFunds
+	}
+	return selected, total, nil
+}
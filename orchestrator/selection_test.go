@@ -0,0 +1,75 @@
+package orchestrator
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/GridPlus/phonon-client/card"
+	"github.com/GridPlus/phonon-client/model"
+)
+
+func TestSelectPhononsForAmount(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indices []model.PhononKeyIndex
+	for _, d := range []model.Denomination{{Base: 1, Exponent: 3}, {Base: 5, Exponent: 3}, {Base: 2, Exponent: 3}, {Base: 1, Exponent: 4}} {
+		p := &model.Phonon{CurrencyType: model.Bitcoin, Denomination: d}
+		p.KeyIndex, _, err = sess.CreatePhonon()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.SetDescriptor(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		indices = append(indices, p.KeyIndex)
+	}
+	ether := &model.Phonon{CurrencyType: model.Ethereum, Denomination: model.Denomination{Base: 1, Exponent: 6}}
+	ether.KeyIndex, _, err = sess.CreatePhonon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.SetDescriptor(ether)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	selected, total, err := sess.SelectPhononsForAmount(model.Bitcoin, big.NewInt(12000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, []model.PhononKeyIndex{indices[3], indices[1]}) || total.Int64() != 15000 {
+		t.Errorf("expected the two largest phonons worth 15000, got %v worth %v", selected, total)
+	}
+
+	sess.SetSelectionStrategy(SelectSmallestFirst)
+	selected, total, err = sess.SelectPhononsForAmount(model.Bitcoin, big.NewInt(2500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, []model.PhononKeyIndex{indices[0], indices[2]}) || total.Int64() != 3000 {
+		t.Errorf("expected the two smallest phonons worth 3000, got %v worth %v", selected, total)
+	}
+
+	//reserved phonons are part of another transfer
+	err = sess.reservePhonons([]model.PhononKeyIndex{indices[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.releasePhonons([]model.PhononKeyIndex{indices[0]})
+	_, _, err = sess.SelectPhononsForAmount(model.Bitcoin, big.NewInt(18000))
+	if err != ErrInsufficientFunds {
+		t.Errorf("expected %v, got %v", ErrInsufficientFunds, err)
+	}
+}
@@ -0,0 +1,87 @@
+package orchestrator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/GridPlus/phonon-client/card"
+	"github.com/GridPlus/phonon-client/model"
+)
+
+// lossyCounterParty fails to acknowledge the first transfer delivered to it without storing it
+type lossyCounterParty struct {
+	localCounterParty
+	lost bool
+}
+
+var errDeliveryLost = errors.New("delivery lost")
+
+func (l *lossyCounterParty) ReceivePhonons(phononTransfer []byte) error {
+	if !l.lost {
+		l.lost = true
+		return errDeliveryLost
+	}
+	return l.localCounterParty.ReceivePhonons(phononTransfer)
+}
+
+func TestSendPhononsUndelivered(t *testing.T) {
+	var sessions []*Session
+	for i := 0; i < 2; i++ {
+		mock, err := card.NewMockCard(true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess, err := NewSession(mock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.VerifyPIN("111111")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, sess)
+	}
+	sender, receiver := sessions[0], sessions[1]
+	receiverCert, err := receiver.GetCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	initPairingData, err := sender.InitCardPairing(*receiverCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardPairData, err := receiver.CardPair(initPairingData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardPair2Data, err := sender.CardPair2(cardPairData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = receiver.FinalizeCardPair(cardPair2Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender.RemoteCard = &lossyCounterParty{localCounterParty: localCounterParty{counterSession: receiver, pairingStatus: model.StatusPaired}}
+
+	keyIndex, _, err := sender.CreatePhonon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sender.SendPhonons([]model.PhononKeyIndex{keyIndex})
+	var undelivered *UndeliveredTransferError
+	if !errors.As(err, &undelivered) || !errors.Is(err, errDeliveryLost) {
+		t.Fatalf("expected an UndeliveredTransferError, got %v", err)
+	}
+	err = sender.RedeliverPhonons(undelivered.Packet)
+	if err != nil {
+		t.Fatal("unable to redeliver the transfer packet: ", err)
+	}
+	phonons, err := receiver.ListPhonons(0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(phonons) != 1 {
+		t.Errorf("expected the redelivered phonon on the receiver, got %v", phonons)
+	}
+}
@@ -0,0 +1,25 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrSerialNumberUnavailable = errors.New("card does not report a serial number")
+
+/*
+SerialNumber returns the instance UID the applet reports when it is selected, as upper case hex.
+The UID is generated once when the applet is installed on a card, so it stays the same for the life of
+the physical card and can be matched against asset records. Unlike the card ID it isn't derived from the
+certificate, and reading it needs neither the PIN nor the secure channel.
+
+The UID is taken from the SELECT made when the session starts or initializes the card, since selecting the
+applet again would close the secure channel. The applet only reports its UID once initialized, so
+ErrSerialNumberUnavailable is returned for a card without a PIN.
+*/
+func (s *Session) SerialNumber() (string, error) {
+	if len(s.instanceUID) == 0 {
+		return "", ErrSerialNumberUnavailable
+	}
+	return fmt.Sprintf("%X", s.instanceUID), nil
+}
@@ -1,13 +1,16 @@
 package orchestrator
 
 import (
+	"context"
 	"crypto/ecdsa"
-	"crypto/rand"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/big"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -45,8 +48,30 @@ type Session struct {
 	cancelMiningChan      chan struct{}
 	isMiningActive        bool
 	mutexedMiningReport   mutexedMiningReport
+	resumption            *resumptionState
+	pendingPairTranscript [][]byte
+	pairingPubKey         []byte                        //identity key of the card this card is being or was last paired with
+	reserved              map[model.PhononKeyIndex]bool //phonons being sent by an in progress transfer
+	reservedMtex          sync.Mutex
+	revocations           *cert.RevocationList
+	validateReceived      bool
+	receiveOnly           bool
+	minAcceptedValue      map[model.CurrencyType]*big.Int
+	redeemFeeLimit        uint
+	jumpServerKey         *ecdsa.PublicKey //key the jump server must authenticate with in ConnectToRemoteProvider
+	jumpServerTLS         []remote.Option  //TLS verification options for ConnectToRemoteProvider, none skips verification
+	caPubKey              []byte           //CA counterparty certificates must be issued by, remote.DefaultCAPubKey if nil
+	selectionStrategy     SelectionStrategy
+	descriptions          DescriptionStore //descriptions set with SetPhononDescription, nil until one is set
+	instanceUID           []byte
 	// cachePopulated indicates if all of the phonons present on the card have been cached. This is currently only set when listphonons is called with the values to list all phonons on the card.
 	cachePopulated bool
+	// cacheGeneration is incremented whenever cachePopulated is cleared, so a listing made across several
+	// locks of ElementUsageMtex can tell whether the cache was invalidated before it completed.
+	cacheGeneration uint64
+	// listings is incremented whenever the card starts a new listing or receives phonons, either of which
+	// resets the card's listing cursor, so a paged listing can tell whether its next page still follows on.
+	listings uint64
 }
 
 const (
@@ -75,6 +100,7 @@ type miningStatusReport struct {
 type cachedPhonon struct {
 	pubkeyCached bool
 	infoCached   bool
+	validated    bool //confirmed on chain during this session
 	p            *model.Phonon
 }
 
@@ -84,10 +110,15 @@ var ErrCardNotPairedToCard = errors.New("card not paired with any other card")
 var ErrNameCannotBeEmpty = errors.New("requested name cannot be empty")
 var ErrMiningNotActive = errors.New("no active mining operation")
 var ErrMiningReportNotAvailable = errors.New("could not find mining status report")
+var ErrSelfTransfer = errors.New("cannot send phonons to the card they are sent from")
+var ErrPhononNotFound = errors.New("no phonon at requested index")
+
+// ErrCardNotInitialized is returned by seed dependent methods while the card has no PIN set, and therefore no seed
+var ErrCardNotInitialized = card.ErrCardUninitialized
 
 // Creates a new card session, automatically connecting if the card is already initialized with a PIN
 // The next step is to run VerifyPIN to gain access to the secure commands on the card
-func NewSession(storage model.PhononCard) (s *Session, err error) {
+func NewSession(storage model.PhononCard, opts ...Option) (s *Session, err error) {
 	chainSrv, err := chain.NewMultiChainRouter()
 	if err != nil {
 		return nil, err
@@ -111,11 +142,18 @@ func NewSession(storage model.PhononCard) (s *Session, err error) {
 		isMiningActive:        false,
 		mutexedMiningReport:   mutexedMiningReport{m: make(map[string]miningStatusReport), mtex: &sync.Mutex{}},
 		cache:                 make(map[model.PhononKeyIndex]cachedPhonon),
+		redeemFeeLimit:        DefaultRedeemFeeLimit,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.caPubKey == nil {
+		s.caPubKey = cardCAPubKey(storage)
 	}
 	s.logger = log.WithField("cardID", s.GetCardId())
 
 	s.ElementUsageMtex.Lock()
-	_, _, s.pinInitialized, err = s.cs.Select()
+	s.instanceUID, _, s.pinInitialized, err = s.cs.Select()
 	s.ElementUsageMtex.Unlock()
 	if err != nil {
 		log.Error("cannot select card for new session: ", err)
@@ -167,7 +205,7 @@ func (s *Session) SetPaired(status bool) {}
 
 func generateId() (string, error) {
 	buffer := make([]byte, 16)
-	_, err := rand.Read(buffer)
+	err := util.ReadRandom(buffer)
 
 	if err != nil {
 		return "", err
@@ -178,7 +216,7 @@ func generateId() (string, error) {
 
 func (s *Session) GetMiningReport(attemptId string) (miningStatusReport, error) {
 	if !s.verified() {
-		return miningStatusReport{}, card.ErrPINNotEntered
+		return miningStatusReport{}, s.unverifiedErr()
 	}
 
 	return s.mutexedMiningReport.getMiningStatus(attemptId)
@@ -186,7 +224,7 @@ func (s *Session) GetMiningReport(attemptId string) (miningStatusReport, error)
 
 func (s *Session) ListMiningReports() (map[string]miningStatusReport, error) {
 	if !s.verified() {
-		return nil, card.ErrPINNotEntered
+		return nil, s.unverifiedErr()
 	}
 
 	if len(s.mutexedMiningReport.m) > 0 {
@@ -198,7 +236,7 @@ func (s *Session) ListMiningReports() (map[string]miningStatusReport, error) {
 
 func (s *Session) CancelMiningRequest() error {
 	if !s.verified() {
-		return card.ErrPINNotEntered
+		return s.unverifiedErr()
 	}
 
 	if s.isMiningActive {
@@ -296,7 +334,7 @@ func (s *Session) initMiningAttempt(id string, difficulty uint8) {
 
 func (s *Session) MineNativePhonon(difficulty uint8) (string, error) {
 	if !s.verified() {
-		return "", card.ErrPINNotEntered
+		return "", s.unverifiedErr()
 	}
 
 	id, err := generateId()
@@ -342,7 +380,7 @@ func (s *Session) GetName() (string, error) {
 
 func (s *Session) SetName(name string) error {
 	if !s.verified() {
-		return card.ErrPINNotEntered
+		return s.unverifiedErr()
 	}
 	if name == "" {
 		return ErrNameCannotBeEmpty
@@ -363,6 +401,31 @@ func (s *Session) GetCertificate() (*cert.CardCertificate, error) {
 	return &cert.CardCertificate{}, errors.New("certificate not cached by session yet")
 }
 
+// PublicIdentity returns the card's certificate without requiring a PIN or an open secure channel,
+// making it possible to identify a card as soon as it is selected.
+func (s *Session) PublicIdentity() (*cert.CardCertificate, error) {
+	if s.Cert != nil {
+		return s.Cert, nil
+	}
+	s.ElementUsageMtex.Lock()
+	defer s.ElementUsageMtex.Unlock()
+	return s.cs.GetCertificate()
+}
+
+// CardInfo returns the pairing slot counts and applet version the applet reported when it was last selected,
+// so a UI can show how many slots are used and warn before they run out or when the applet is outdated
+func (s *Session) CardInfo() model.CardInfo {
+	return s.cs.CardInfo()
+}
+
+// SecureChannelInfo describes the secure channel to the card, for diagnosing commands the card rejects.
+// No session keys are included.
+func (s *Session) SecureChannelInfo() model.SecureChannelInfo {
+	s.ElementUsageMtex.Lock()
+	defer s.ElementUsageMtex.Unlock()
+	return s.cs.SecureChannelInfo()
+}
+
 func (s *Session) IsUnlocked() bool {
 	return s.pinVerified
 }
@@ -371,10 +434,38 @@ func (s *Session) IsPairedToTerminal() bool {
 	return s.terminalPaired
 }
 
+// IsInitialized reports whether the card has been initialized with a PIN, as last seen by Select or Init.
+// Use RefreshInitialized to check a card that may have been initialized elsewhere.
 func (s *Session) IsInitialized() bool {
+	s.ElementUsageMtex.Lock()
+	defer s.ElementUsageMtex.Unlock()
 	return s.pinInitialized
 }
 
+// RefreshInitialized selects the applet again to refresh whether the card has been initialized with a PIN
+func (s *Session) RefreshInitialized() (bool, error) {
+	s.ElementUsageMtex.Lock()
+	defer s.ElementUsageMtex.Unlock()
+	if s.pinInitialized {
+		return true, nil
+	}
+	instanceUID, _, initialized, err := s.cs.Select()
+	if err != nil {
+		return false, err
+	}
+	s.pinInitialized = initialized
+	s.instanceUID = instanceUID
+	return initialized, nil
+}
+
+// unverifiedErr explains why the session isn't verified, distinguishing a card without a seed from a locked one
+func (s *Session) unverifiedErr() error {
+	if !s.pinInitialized {
+		return ErrCardNotInitialized
+	}
+	return card.ErrPINNotEntered
+}
+
 func (s *Session) IsPairedToCard() bool {
 	return s.RemoteCard != nil
 }
@@ -383,11 +474,11 @@ func (s *Session) IsPairedToCard() bool {
 func (s *Session) Connect() error {
 	s.ElementUsageMtex.Lock()
 	defer s.ElementUsageMtex.Unlock()
-	cert, err := s.cs.Pair()
+	pairing, err := s.cs.Pair()
 	if err != nil {
 		return err
 	}
-	s.Cert = cert
+	s.Cert = pairing.Cert
 	s.identityPubKey, _ = util.ParseECCPubKey(s.Cert.PubKey)
 	err = s.cs.OpenSecureChannel()
 	if err != nil {
@@ -406,11 +497,17 @@ func (s *Session) Init(pin string) error {
 
 	s.ElementUsageMtex.Lock()
 	err := s.cs.Init(pin)
+	if err == nil {
+		//the applet only reports its UID once initialized, and no secure channel is open yet for the SELECT to close
+		s.instanceUID, _, _, err = s.cs.Select()
+	}
+	if err == nil {
+		s.pinInitialized = true
+	}
 	s.ElementUsageMtex.Unlock()
 	if err != nil {
 		return err
 	}
-	s.pinInitialized = true
 	//Open new secure connection now that card is initialized
 
 	err = s.Connect()
@@ -422,26 +519,46 @@ func (s *Session) Init(pin string) error {
 	return nil
 }
 
+// VerifyPIN unlocks the card's secure commands. An incorrect PIN fails with a *card.WrongPINError holding the
+// number of attempts left, or card.ErrPINBlocked once the card refuses any more, and locks the card again.
 func (s *Session) VerifyPIN(pin string) error {
 	s.ElementUsageMtex.Lock()
 	defer s.ElementUsageMtex.Unlock()
 
+	return s.verifyPIN(pin)
+}
+
+func (s *Session) verifyPIN(pin string) error {
 	err := s.cs.VerifyPIN(pin)
 	if err != nil {
+		s.pinVerified = false
 		return err
 	}
 	s.pinVerified = true
 	return nil
 }
 
-func (s *Session) ChangePIN(pin string) error {
+// ChangePIN replaces the card's PIN with newPIN once oldPIN is verified, failing as VerifyPIN does if it isn't
+func (s *Session) ChangePIN(oldPIN string, newPIN string) error {
 	s.ElementUsageMtex.Lock()
 	defer s.ElementUsageMtex.Unlock()
 
-	if !s.pinVerified {
-		return errors.New("card locked, cannot change pin")
+	err := s.verifyPIN(oldPIN)
+	if err != nil {
+		return err
 	}
-	return s.cs.ChangePIN(pin)
+	return s.cs.ChangePIN(newPIN)
+}
+
+// PINStatus returns the number of incorrect PIN attempts the card accepts before it blocks the PIN
+func (s *Session) PINStatus() (remainingTries int, err error) {
+	if !s.pinInitialized {
+		return 0, ErrCardNotInitialized
+	}
+	s.ElementUsageMtex.Lock()
+	defer s.ElementUsageMtex.Unlock()
+
+	return s.cs.GetPINStatus()
 }
 
 func (s *Session) verified() bool {
@@ -453,10 +570,15 @@ func (s *Session) verified() bool {
 
 func (s *Session) CreatePhonon() (keyIndex model.PhononKeyIndex, pubkey model.PhononPubKey, err error) {
 	if !s.verified() {
-		return 0, nil, card.ErrPINNotEntered
+		return 0, nil, s.unverifiedErr()
 	}
 	s.ElementUsageMtex.Lock()
 	defer s.ElementUsageMtex.Unlock()
+	return s.createPhonon()
+}
+
+// createPhonon creates a secp256k1 phonon and caches its key. The caller must hold ElementUsageMtex.
+func (s *Session) createPhonon() (keyIndex model.PhononKeyIndex, pubkey model.PhononPubKey, err error) {
 	index, pubkey, err := s.cs.CreatePhonon(model.Secp256k1)
 	if err == nil {
 		s.cache[index] = cachedPhonon{
@@ -474,7 +596,7 @@ func (s *Session) CreatePhonon() (keyIndex model.PhononKeyIndex, pubkey model.Ph
 
 func (s *Session) SetDescriptor(p *model.Phonon) error {
 	if !s.verified() {
-		return card.ErrPINNotEntered
+		return s.unverifiedErr()
 	}
 	s.ElementUsageMtex.Lock()
 	defer s.ElementUsageMtex.Unlock()
@@ -487,7 +609,7 @@ func (s *Session) SetDescriptor(p *model.Phonon) error {
 
 func (s *Session) ListPhonons(currencyType model.CurrencyType, lessThanValue uint64, greaterThanValue uint64) ([]*model.Phonon, error) {
 	if !s.verified() {
-		return nil, card.ErrPINNotEntered
+		return nil, s.unverifiedErr()
 	}
 	if s.cachePopulated {
 		ret := []*model.Phonon{}
@@ -499,11 +621,13 @@ func (s *Session) ListPhonons(currencyType model.CurrencyType, lessThanValue uin
 	s.ElementUsageMtex.Lock()
 	defer s.ElementUsageMtex.Unlock()
 
+	s.listings++
 	phonons, err := s.cs.ListPhonons(currencyType, lessThanValue, greaterThanValue, false)
 	// add listed phonons to the cache
 	for _, phonon := range phonons {
 		s.addInfoToCache(phonon)
 	}
+	s.applyDescriptions(phonons)
 
 	if currencyType == 0 && lessThanValue == 0 && greaterThanValue == 0 {
 		//all phonons were listed, therefore each one can be accounted for in the cache
@@ -512,9 +636,75 @@ func (s *Session) ListPhonons(currencyType model.CurrencyType, lessThanValue uin
 	return phonons, err
 }
 
+// GetPhonon returns the phonon at keyIndex, or ErrPhononNotFound if the slot is empty.
+// The card has no command to read a single descriptor, so phonons are served from the session's cache,
+// which is filled by a single full listing the first time an uncached phonon is requested.
+func (s *Session) GetPhonon(keyIndex model.PhononKeyIndex) (*model.Phonon, error) {
+	if !s.verified() {
+		return nil, s.unverifiedErr()
+	}
+	cached, ok := s.cache[keyIndex]
+	if !ok || !cached.infoCached {
+		if s.cachePopulated {
+			return nil, ErrPhononNotFound
+		}
+		_, err := s.ListPhonons(0, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		cached, ok = s.cache[keyIndex]
+		if !ok {
+			return nil, ErrPhononNotFound
+		}
+	}
+	phonon := *cached.p
+	if phonon.PubKey == nil {
+		pubKey, err := s.GetPhononPubKey(keyIndex, phonon.CurveType)
+		if err != nil {
+			return nil, err
+		}
+		phonon.PubKey = pubKey
+	}
+	return &phonon, nil
+}
+
+// FindPhononsByTag returns the phonons whose tag contains substring, ignoring case.
+// The card can't search tags itself, so every phonon is listed once and filtered here.
+func (s *Session) FindPhononsByTag(substring string) ([]model.Phonon, error) {
+	phonons, err := s.ListPhonons(0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	substring = strings.ToLower(substring)
+	ret := []model.Phonon{}
+	for _, p := range phonons {
+		if p.Tag != "" && strings.Contains(strings.ToLower(p.Tag), substring) {
+			ret = append(ret, *p)
+		}
+	}
+	return ret, nil
+}
+
+// ListPhononsCreatedBetween returns the phonons created in the window from after to before, inclusive.
+// A zero bound leaves that side of the window open. Phonons deposited before creation times were recorded
+// have none and are always included, since they can't be ruled out.
+func (s *Session) ListPhononsCreatedBetween(after time.Time, before time.Time) ([]model.Phonon, error) {
+	phonons, err := s.ListPhonons(0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	ret := []model.Phonon{}
+	for _, p := range phonons {
+		if p.CreatedWithin(after, before) {
+			ret = append(ret, *p)
+		}
+	}
+	return ret, nil
+}
+
 func (s *Session) GetPhononPubKey(keyIndex model.PhononKeyIndex, crv model.CurveType) (pubkey model.PhononPubKey, err error) {
 	if !s.verified() {
-		return nil, card.ErrPINNotEntered
+		return nil, s.unverifiedErr()
 	}
 	s.ElementUsageMtex.Lock()
 	defer s.ElementUsageMtex.Unlock()
@@ -526,9 +716,25 @@ func (s *Session) GetPhononPubKey(keyIndex model.PhononKeyIndex, crv model.Curve
 	return k, err
 }
 
+// DestroyPhonon deletes a phonon from the card and returns its private key, so the asset it holds can be spent.
+// A phonon being sent by a transfer in progress can't be destroyed and fails with ErrPhononReserved.
 func (s *Session) DestroyPhonon(keyIndex model.PhononKeyIndex) (privKey *ecdsa.PrivateKey, err error) {
+	err = s.checkCanSend()
+	if err != nil {
+		return nil, err
+	}
+	err = s.reservePhonons([]model.PhononKeyIndex{keyIndex})
+	if err != nil {
+		return nil, err
+	}
+	defer s.releasePhonons([]model.PhononKeyIndex{keyIndex})
+	return s.destroyPhonon(keyIndex)
+}
+
+// destroyPhonon destroys a phonon regardless of receive only mode, for cleaning up keys that were never funded
+func (s *Session) destroyPhonon(keyIndex model.PhononKeyIndex) (privKey *ecdsa.PrivateKey, err error) {
 	if !s.verified() {
-		return nil, card.ErrPINNotEntered
+		return nil, s.unverifiedErr()
 	}
 	s.ElementUsageMtex.Lock()
 	defer s.ElementUsageMtex.Unlock()
@@ -549,27 +755,61 @@ func (s *Session) IdentifyCard(nonce []byte) (cardPubKey *ecdsa.PublicKey, cardS
 
 func (s *Session) InitCardPairing(receiverCert cert.CardCertificate) ([]byte, error) {
 	if !s.verified() {
-		return nil, card.ErrPINNotEntered
+		return nil, s.unverifiedErr()
 	}
 	s.ElementUsageMtex.Lock()
 	defer s.ElementUsageMtex.Unlock()
 
+	err := s.checkRevoked(receiverCert)
+	if err != nil {
+		return nil, err
+	}
+	s.resumption = nil
+	s.pairingPubKey = receiverCert.PubKey
 	return s.cs.InitCardPairing(receiverCert)
 }
 
 func (s *Session) CardPair(initPairingData []byte) ([]byte, error) {
 	if !s.verified() {
-		return nil, card.ErrPINNotEntered
+		return nil, s.unverifiedErr()
 	}
 	s.ElementUsageMtex.Lock()
 	defer s.ElementUsageMtex.Unlock()
 
-	return s.cs.CardPair(initPairingData)
+	senderCert, err := card.InitPairingCertificate(initPairingData)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkRevoked(senderCert)
+	if err != nil {
+		return nil, err
+	}
+	s.resumption = nil
+	s.pairingPubKey = senderCert.PubKey
+	cardPairData, err := s.cs.CardPair(initPairingData)
+	if err != nil {
+		return nil, err
+	}
+	s.pendingPairTranscript = [][]byte{initPairingData, cardPairData}
+	return cardPairData, nil
+}
+
+// SetRevocationList makes card pairing fail with cert.ErrCertificateRevoked when the counterparty's
+// certificate is on the list. Passing nil disables the check.
+func (s *Session) SetRevocationList(revocations *cert.RevocationList) {
+	s.revocations = revocations
+}
+
+func (s *Session) checkRevoked(counterpartyCert cert.CardCertificate) error {
+	if s.revocations == nil {
+		return nil
+	}
+	return s.revocations.Check(counterpartyCert)
 }
 
 func (s *Session) CardPair2(cardPairData []byte) (cardPair2Data []byte, err error) {
 	if !s.verified() {
-		return nil, card.ErrPINNotEntered
+		return nil, s.unverifiedErr()
 	}
 	s.ElementUsageMtex.Lock()
 	defer s.ElementUsageMtex.Unlock()
@@ -584,7 +824,7 @@ func (s *Session) CardPair2(cardPairData []byte) (cardPair2Data []byte, err erro
 
 func (s *Session) FinalizeCardPair(cardPair2Data []byte) error {
 	if !s.verified() {
-		return card.ErrPINNotEntered
+		return s.unverifiedErr()
 	}
 	s.ElementUsageMtex.Lock()
 	defer s.ElementUsageMtex.Unlock()
@@ -593,16 +833,55 @@ func (s *Session) FinalizeCardPair(cardPair2Data []byte) error {
 	if err != nil {
 		return err
 	}
+	if len(s.pendingPairTranscript) == 2 {
+		s.resumption = newResumptionState(s.pairingPubKey, s.pendingPairTranscript[0], s.pendingPairTranscript[1], cardPair2Data)
+	}
+	s.pendingPairTranscript = nil
 	return nil
 }
 
+// UndeliveredTransferError is returned by SendPhonons when the card made the transfer packet but the counterparty
+// didn't acknowledge it. The phonons are already gone from this card, so Packet must be kept and delivered again.
+type UndeliveredTransferError struct {
+	Packet []byte
+	Err    error
+}
+
+func (e *UndeliveredTransferError) Error() string {
+	return fmt.Sprintf("transfer packet was not delivered: %v", e.Err)
+}
+
+func (e *UndeliveredTransferError) Unwrap() error {
+	return e.Err
+}
+
+/*
+SendPhonons transfers the phonons at keyIndices to the paired counterparty card.
+The card removes the phonons as soon as it produces the encrypted transfer packet, and the counterparty's card
+accepts that packet whenever it is delivered. A transfer is therefore final once the packet has been made:
+it can't carry an expiry or be refunded to this card. A delivery failure after that point is returned as an
+UndeliveredTransferError carrying the packet, which must be delivered again with RedeliverPhonons.
+*/
 func (s *Session) SendPhonons(keyIndices []model.PhononKeyIndex) error {
 	log.Debug("Sending phonons")
+	err := s.checkCanSend()
+	if err != nil {
+		return err
+	}
 	if !s.verified() && s.RemoteCard != nil {
 		return ErrCardNotPairedToCard
 	}
+	err = s.reservePhonons(keyIndices)
+	if err != nil {
+		return err
+	}
+	defer s.releasePhonons(keyIndices)
+	err = s.checkNotSelfTransfer()
+	if err != nil {
+		return err
+	}
 	log.Debug("verifying pairing")
-	err := s.RemoteCard.VerifyPaired()
+	err = s.RemoteCard.VerifyPaired()
 	if err != nil {
 		return err
 	}
@@ -613,22 +892,131 @@ func (s *Session) SendPhonons(keyIndices []model.PhononKeyIndex) error {
 	if err != nil {
 		return err
 	}
+	for _, index := range keyIndices {
+		delete(s.cache, index)
+	}
 	err = s.RemoteCard.ReceivePhonons(phononTransferPacket)
 	if err != nil {
 		log.Debug("error receiving phonons on remote")
-		return err
+		return &UndeliveredTransferError{Packet: phononTransferPacket, Err: err}
 	}
 	fmt.Println("unlockingMutex")
-	for _, index := range keyIndices {
-		delete(s.cache, index)
+	return nil
+}
+
+// RedeliverPhonons delivers the packet of an UndeliveredTransferError to the paired counterparty again
+func (s *Session) RedeliverPhonons(phononTransferPacket []byte) error {
+	if s.RemoteCard == nil {
+		return ErrCardNotPairedToCard
+	}
+	return s.RemoteCard.ReceivePhonons(phononTransferPacket)
+}
+
+// checkNotSelfTransfer compares the counterparty's card ID from its certificate against this card's ID
+func (s *Session) checkNotSelfTransfer() error {
+	remoteCert, err := s.RemoteCard.GetCertificate()
+	if err != nil {
+		return err
+	}
+	remoteKey, err := util.ParseECCPubKey(remoteCert.PubKey)
+	if err != nil {
+		return err
+	}
+	if util.CardIDFromPubKey(remoteKey) == s.GetCardId() {
+		return ErrSelfTransfer
 	}
 	return nil
 }
 
+/*
+DryRunTransfer exercises the full path to the paired counterparty without moving any phonons.
+It checks the counterparty is not this card, verifies the pairing in both directions,
+and asks the counterparty to acknowledge it is ready to receive.
+No phonons are reserved, sent or deleted, so it is safe to run before committing funds.
+A nil error means a real transfer to this counterparty should go through.
+*/
+func (s *Session) DryRunTransfer() error {
+	err := s.checkCanSend()
+	if err != nil {
+		return err
+	}
+	if !s.verified() {
+		return s.unverifiedErr()
+	}
+	if s.RemoteCard == nil {
+		return ErrCardNotPairedToCard
+	}
+	err = s.checkNotSelfTransfer()
+	if err != nil {
+		return err
+	}
+	err = s.RemoteCard.VerifyPaired()
+	if err != nil {
+		return fmt.Errorf("dry run failed verifying pairing: %w", err)
+	}
+	err = s.RemoteCard.DryRunTransfer()
+	if err != nil {
+		return fmt.Errorf("dry run failed awaiting counterparty acknowledgement: %w", err)
+	}
+	return nil
+}
+
+// checkReadyToReceive answers a counterparty's dry run, reporting whether this session could accept phonons
+func (s *Session) checkReadyToReceive() error {
+	if !s.verified() {
+		return s.unverifiedErr()
+	}
+	if s.RemoteCard == nil {
+		return ErrCardNotPairedToCard
+	}
+	return nil
+}
+
+// ReceivePhonons stores a transfer packet on the card as ReceivePhononsChecked does, discarding what the checks found
 func (s *Session) ReceivePhonons(phononTransferPacket []byte) error {
+	_, err := s.ReceivePhononsChecked(phononTransferPacket)
+	return err
+}
+
+/*
+ReceivePhononsChecked stores a transfer packet on the card and then checks the phonons it carried.
+It only fails when the card refuses the packet. Once the card has stored the phonons the transfer is
+accepted whatever the checks find, see ReceiveResult.
+*/
+func (s *Session) ReceivePhononsChecked(phononTransferPacket []byte) (ReceiveResult, error) {
 	if !s.verified() && s.RemoteCard != nil {
-		return ErrCardNotPairedToCard
+		return ReceiveResult{}, ErrCardNotPairedToCard
+	}
+	before, err := s.phononIndices()
+	if err != nil {
+		return ReceiveResult{}, err
+	}
+	err = s.receivePhonons(phononTransferPacket)
+	if err != nil {
+		return ReceiveResult{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), receiveChecksTimeout)
+	defer cancel()
+	var result ReceiveResult
+	result.Duplicates, err = s.removeDuplicatePhonons(ctx, before)
+	if err != nil {
+		s.logger.Error("unable to remove duplicate received phonons: ", err)
+	}
+	result.BelowMinimum, err = s.belowMinimum(before)
+	if err != nil {
+		s.logger.Error("unable to check received phonons against the minimum accepted value: ", err)
+	}
+	if !s.validateReceived {
+		return result, nil
 	}
+	result.Unconfirmed, err = s.validateReceivedPhonons(ctx, before)
+	if err != nil {
+		s.logger.Error("unable to validate received phonons: ", err)
+	}
+	return result, nil
+}
+
+func (s *Session) receivePhonons(phononTransferPacket []byte) error {
 	s.ElementUsageMtex.Lock()
 	defer s.ElementUsageMtex.Unlock()
 
@@ -638,6 +1026,8 @@ func (s *Session) ReceivePhonons(phononTransferPacket []byte) error {
 	}
 	//invalidate the cache now that new phonons have been received
 	s.cachePopulated = false
+	s.cacheGeneration++
+	s.listings++
 	return nil
 }
 
@@ -671,7 +1061,15 @@ func (s *Session) ConnectToRemoteProvider(RemoteURL string) error {
 		return fmt.Errorf("unable to parse url for card connection: %s", err.Error())
 	}
 	log.Info("connecting")
-	remConn, err := remote.Connect(s.remoteMessageChan, fmt.Sprintf("https://%s/phonon", u.Host), true)
+	var opts []remote.Option
+	if s.jumpServerKey != nil {
+		opts = append(opts, remote.WithServerKey(s.jumpServerKey))
+	}
+	if s.caPubKey != nil {
+		opts = append(opts, remote.WithCAPubKey(s.caPubKey))
+	}
+	opts = append(opts, s.jumpServerTLS...)
+	remConn, err := remote.Connect(s.remoteMessageChan, fmt.Sprintf("https://%s/phonon", u.Host), len(s.jumpServerTLS) == 0, opts...)
 	if err != nil {
 		return fmt.Errorf("unable to connect to remote session: %s", err.Error())
 	}
@@ -679,6 +1077,46 @@ func (s *Session) ConnectToRemoteProvider(RemoteURL string) error {
 	return nil
 }
 
+// WithCAPubKey sets the CA counterparty certificates must be issued by when pairing through ConnectToRemoteProvider.
+// By default it is the CA the card was configured with, see cert.SelectCACertByName.
+func WithCAPubKey(caPubKey []byte) Option {
+	return func(s *Session) {
+		s.caPubKey = caPubKey
+	}
+}
+
+// cardCAPubKey returns the CA the card's command set was configured with, or nil if it has none
+func cardCAPubKey(storage model.PhononCard) []byte {
+	switch cs := storage.(type) {
+	case *card.PhononCommandSet:
+		return cs.PhononCACert
+	case *card.StaticPhononCommandSet:
+		return cs.PhononCACert
+	}
+	return nil
+}
+
+// SetJumpServerKey requires jump servers connected to with ConnectToRemoteProvider to prove they hold the private key for pubKey.
+// Unless SetJumpServerTLS is also called TLS verification is skipped, so without a key any server at the URL is trusted.
+func (s *Session) SetJumpServerKey(pubKey *ecdsa.PublicKey) {
+	s.jumpServerKey = pubKey
+}
+
+/*
+SetJumpServerTLS verifies the TLS certificate of jump servers connected to with ConnectToRemoteProvider,
+against config and, if fingerprint isn't nil, the pinned SHA-256 hash of the leaf certificate.
+See remote.WithTLSConfig and remote.WithServerCertFingerprint. Passing nil for both skips verification again.
+*/
+func (s *Session) SetJumpServerTLS(config *tls.Config, fingerprint []byte) {
+	s.jumpServerTLS = nil
+	if config != nil {
+		s.jumpServerTLS = append(s.jumpServerTLS, remote.WithTLSConfig(config))
+	}
+	if fingerprint != nil {
+		s.jumpServerTLS = append(s.jumpServerTLS, remote.WithServerCertFingerprint(fingerprint))
+	}
+}
+
 func (s *Session) RemoteConnectionStatus() model.RemotePairingStatus {
 	if s.RemoteCard == nil {
 		return model.StatusUnconnected
@@ -707,6 +1145,12 @@ func (s *Session) ConnectToCounterparty(cardID string) error {
 		//we shouldn't get this far and still receive this error
 		return err
 	}
+	//reconnecting to the card of a recent pairing carries on with that pairing
+	err = s.ResumePairing(s.RemoteCard, cardID)
+	if err == nil {
+		return nil
+	}
+	log.Debug("unable to resume card pairing, pairing again: ", err)
 	err = s.PairWithRemoteCard(s.RemoteCard)
 	return err
 
@@ -735,37 +1179,26 @@ func (s *Session) PairWithRemoteCard(remoteCard model.CounterpartyPhononCard) er
 	if err != nil {
 		return err
 	}
+	s.resumption = newResumptionState(remoteCert.PubKey, initPairingData, cardPairData, cardPair2Data)
 	s.RemoteCard = remoteCard
 	return nil
 }
 
 /*
-InitDepositPhonons takes a currencyType and a map of denominations to quantity,
-Creates the required phonons, deposits them using the configured service for the asset
-and upon success sets their descriptors
+InitDepositPhonons takes a currencyType and a list of denominations,
+creates a phonon for each of them with DepositBatch and returns them ready to be funded on chain.
+Their descriptors are set once the deposits are confirmed with FinalizeDepositPhonons.
 */
 func (s *Session) InitDepositPhonons(currencyType model.CurrencyType, denoms []*model.Denomination) (phonons []*model.Phonon, err error) {
 	log.Debugf("running InitDepositPhonons with data: %v, %v\n", currencyType, denoms)
-	if !s.verified() {
-		return nil, card.ErrPINNotEntered
-	}
+	deposits := make([]DepositRequest, 0, len(denoms))
 	for _, denom := range denoms {
-		p := &model.Phonon{}
-		p.KeyIndex, p.PubKey, err = s.CreatePhonon()
-		log.Debug("ran CreatePhonons in InitDepositLoop")
-		if err != nil {
-			log.Error("failed to create phonon for deposit: ", err)
-			return nil, err
-		}
-		p.Denomination = *denom
-		p.CurrencyType = currencyType
-		p.Address, err = s.chainSrv.DeriveAddress(p)
-		if err != nil {
-			log.Error("failed to derive address for phonon deposit: ", err)
-			return nil, err
-		}
-
-		phonons = append(phonons, p)
+		deposits = append(deposits, DepositRequest{CurrencyType: currencyType, Denomination: *denom})
+	}
+	phonons, err = s.DepositBatch(deposits)
+	if err != nil {
+		log.Error("failed to create phonons for deposit: ", err)
+		return nil, err
 	}
 	return phonons, nil
 }
@@ -780,7 +1213,7 @@ type DepositConfirmation struct {
 func (s *Session) FinalizeDepositPhonons(confirmations []DepositConfirmation) ([]DepositConfirmation, error) {
 	log.Debug("running finalizeDepositPhonon")
 	if !s.verified() {
-		return nil, card.ErrPINNotEntered
+		return nil, s.unverifiedErr()
 	}
 	var lastErr error
 	for _, v := range confirmations {
@@ -804,7 +1237,7 @@ func (s *Session) FinalizeDepositPhonon(dc DepositConfirmation) error {
 			return err
 		}
 	} else {
-		_, err := s.DestroyPhonon(dc.Phonon.KeyIndex)
+		_, err := s.destroyPhonon(dc.Phonon.KeyIndex)
 		if err != nil {
 			log.Error("unable to clean up deposit failure by destroying phonon: ", dc.Phonon)
 		}
@@ -865,6 +1298,30 @@ func (s *Session) handleRequest(r model.SessionRequest) {
 		var resp model.ResponseReceivePhonons
 		resp.Err = s.ReceivePhonons(req.Payload)
 		req.Ret <- resp
+	case "RequestDryRunTransfer":
+		req, ok := r.(*model.RequestDryRunTransfer)
+		if !ok {
+			panic("this shouldn't happen.")
+		}
+		var resp model.ResponseDryRunTransfer
+		resp.Err = s.checkReadyToReceive()
+		req.Ret <- resp
+	case "RequestGenerateInvoice":
+		req, ok := r.(*model.RequestGenerateInvoice)
+		if !ok {
+			panic("this shouldn't happen.")
+		}
+		var resp model.ResponseGenerateInvoice
+		resp.Payload, resp.Err = s.GenerateInvoice()
+		req.Ret <- resp
+	case "RequestReceiveInvoice":
+		req, ok := r.(*model.RequestReceiveInvoice)
+		if !ok {
+			panic("this shouldn't happen.")
+		}
+		var resp model.ResponseReceiveInvoice
+		resp.Err = s.ReceiveInvoice(req.Payload)
+		req.Ret <- resp
 	case "RequestGetName":
 		req, ok := r.(*model.RequestGetName)
 		if !ok {
@@ -893,6 +1350,14 @@ func (s *Session) handleRequest(r model.SessionRequest) {
 		s.SetPaired(req.Status)
 		resp.Err = nil
 		req.Ret <- resp
+	case "RequestResumePairing":
+		req, ok := r.(*model.RequestResumePairing)
+		if !ok {
+			panic("this shouldn't happen.")
+		}
+		var resp model.ResponseResumePairing
+		resp.Err = s.acceptResumption(req.Card, req.Token)
+		req.Ret <- resp
 	}
 }
 
@@ -900,15 +1365,30 @@ func (s *Session) handleRequest(r model.SessionRequest) {
 RedeemPhonon takes a phonon and a redemptionAddress as an asset specific address string (usually hex encoded)
 and submits a transaction to the asset's chain in order to transfer it to another address
 In case the on chain transfer fails, returns the private key as a fallback so that access to the asset is not lost
+A phonon being sent by a transfer in progress can't be redeemed and fails with ErrPhononReserved
 */
 func (s *Session) RedeemPhonon(p *model.Phonon, redeemAddress string) (transactionData string, privKeyString string, err error) {
+	err = s.checkCanSend()
+	if err != nil {
+		return "", "", err
+	}
+	err = s.reservePhonons([]model.PhononKeyIndex{p.KeyIndex})
+	if err != nil {
+		return "", "", err
+	}
+	defer s.releasePhonons([]model.PhononKeyIndex{p.KeyIndex})
+	return s.redeemPhonon(p, redeemAddress)
+}
+
+// redeemPhonon redeems a phonon already reserved by the caller
+func (s *Session) redeemPhonon(p *model.Phonon, redeemAddress string) (transactionData string, privKeyString string, err error) {
 	err = s.chainSrv.CheckRedeemable(p, redeemAddress)
 	if err != nil {
 		return "", "", err
 	}
 
 	//Retrieve phonon private key.
-	privKey, err := s.DestroyPhonon(p.KeyIndex)
+	privKey, err := s.destroyPhonon(p.KeyIndex)
 	if err != nil {
 		return "", "", err
 	}
@@ -960,6 +1440,7 @@ func (s *Session) addInfoToCache(p *model.Phonon) {
 			p:            p,
 			pubkeyCached: cached.pubkeyCached,
 			infoCached:   true,
+			validated:    cached.validated,
 		}
 		s.cache[p.KeyIndex].p.PubKey = cachedPubKey
 	}
@@ -1,11 +1,23 @@
 package orchestrator_test
 
 import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/GridPlus/phonon-client/card"
+	"github.com/GridPlus/phonon-client/cert"
+	"github.com/GridPlus/phonon-client/chain"
 	"github.com/GridPlus/phonon-client/model"
 	"github.com/GridPlus/phonon-client/orchestrator"
 	"github.com/GridPlus/phonon-client/remote/v1/server"
+	"github.com/GridPlus/phonon-client/validator"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -25,6 +37,47 @@ func TestDepositPhonons(t *testing.T) {
 }
 */
 
+func TestDepositBatch(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	denom := model.Denomination{Base: 1, Exponent: 3}
+	phonons, err := sess.DepositBatch([]orchestrator.DepositRequest{
+		{CurrencyType: model.Ethereum, Denomination: denom},
+		{CurrencyType: model.Ethereum, Denomination: denom},
+		{CurrencyType: model.Bitcoin, Denomination: denom},
+		{CurrencyType: model.Ethereum, Denomination: denom},
+	})
+	var batchErr *orchestrator.DepositBatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 2 || !errors.Is(err, chain.ErrCurrencyTypeUnsupported) {
+		t.Fatalf("expected the bitcoin deposit to fail at index 2, got %v", err)
+	}
+	if len(phonons) != 2 {
+		t.Fatalf("expected the 2 phonons created before the failure, got %v", len(phonons))
+	}
+	for _, p := range phonons {
+		if p.PubKey == nil || p.Address == "" {
+			t.Errorf("expected phonon %v to have a public key and address", p.KeyIndex)
+		}
+	}
+	listed, err := sess.ListPhonons(0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 {
+		t.Errorf("expected the failed deposit not to be left on the card, found %v phonons", len(listed))
+	}
+}
+
 func TestE2EJumpboxSendPhonon(t *testing.T) {
 	log.SetLevel(log.DebugLevel)
 	//todo: fix this
@@ -106,3 +159,1067 @@ func TestE2ELocalSendPhonon(t *testing.T) {
 	}
 
 }
+
+func TestSendPhononsToSelf(t *testing.T) {
+	term := orchestrator.NewPhononTerminal()
+	mockID, err := term.GenerateMock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := term.SessionFromID(mockID)
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.ConnectToLocalProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.RemoteCard.ConnectToCard(mockID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyIndex, _, err := sess.CreatePhonon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.SendPhonons([]model.PhononKeyIndex{keyIndex})
+	if err != orchestrator.ErrSelfTransfer {
+		t.Errorf("expected self transfer error, got %v", err)
+	}
+}
+
+func TestDryRunTransferToSelf(t *testing.T) {
+	term := orchestrator.NewPhononTerminal()
+	mockID, err := term.GenerateMock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := term.SessionFromID(mockID)
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.DryRunTransfer()
+	if err != orchestrator.ErrCardNotPairedToCard {
+		t.Errorf("expected not paired error before connecting, got %v", err)
+	}
+	err = sess.ConnectToLocalProvider()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.RemoteCard.ConnectToCard(mockID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.DryRunTransfer()
+	if err != orchestrator.ErrSelfTransfer {
+		t.Errorf("expected self transfer error, got %v", err)
+	}
+}
+
+// denominationValidator accepts phonons of a single denomination, standing in for an on chain check
+type denominationValidator struct {
+	backed model.Denomination
+}
+
+func (v denominationValidator) Validate(p *model.Phonon) (bool, error) {
+	if p.PubKey == nil {
+		return false, validator.ErrMissingPubKey
+	}
+	return p.Denomination == v.backed, nil
+}
+
+func TestReceiveValidation(t *testing.T) {
+	var mocks []*card.MockCard
+	var sessions []*orchestrator.Session
+	for i := 0; i < 2; i++ {
+		mock, err := card.NewMockCard(true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess, err := orchestrator.NewSession(mock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.VerifyPIN("111111")
+		if err != nil {
+			t.Fatal(err)
+		}
+		mocks = append(mocks, mock)
+		sessions = append(sessions, sess)
+	}
+	sender, receiver := sessions[0], sessions[1]
+	receiverCert, err := receiver.GetCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	initPairingData, err := sender.InitCardPairing(*receiverCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardPairData, err := receiver.CardPair(initPairingData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardPair2Data, err := sender.CardPair2(cardPairData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = receiver.FinalizeCardPair(cardPair2Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backed := model.Denomination{Base: 1, Exponent: 3}
+	unbacked := model.Denomination{Base: 5, Exponent: 3}
+	var indices []model.PhononKeyIndex
+	for _, denom := range []model.Denomination{backed, unbacked} {
+		keyIndex, _, err := sender.CreatePhonon()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sender.SetDescriptor(&model.Phonon{KeyIndex: keyIndex, CurrencyType: model.Bitcoin, Denomination: denom})
+		if err != nil {
+			t.Fatal(err)
+		}
+		indices = append(indices, keyIndex)
+	}
+	validator.Register(model.Bitcoin, denominationValidator{backed: backed})
+	defer validator.Unregister(model.Bitcoin)
+
+	receiver.SetReceiveValidation(true)
+	packet, err := mocks[0].SendPhonons(indices, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := receiver.ReceivePhononsChecked(packet)
+	if err != nil {
+		t.Fatal("expected the stored transfer to be accepted, got ", err)
+	}
+	if len(result.Unconfirmed) != 1 || result.Unconfirmed[0].Phonon.Denomination != unbacked {
+		t.Errorf("expected only the unbacked phonon to fail, got %+v", result.Unconfirmed)
+	}
+	//the card has already accepted the transfer so both phonons remain for inspection
+	phonons, err := receiver.ListPhonons(0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(phonons) != 2 {
+		t.Errorf("expected 2 phonons on receiver, got %d", len(phonons))
+	}
+}
+
+func TestUnbackedPhonons(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backed := model.Denomination{Base: 1, Exponent: 3}
+	unbacked := model.Denomination{Base: 5, Exponent: 3}
+	phonons := []*model.Phonon{
+		{CurrencyType: model.Bitcoin, Denomination: backed},
+		{CurrencyType: model.Bitcoin, Denomination: unbacked},
+		{CurrencyType: model.Ethereum, Denomination: backed},
+	}
+	for _, p := range phonons {
+		p.KeyIndex, _, err = sess.CreatePhonon()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.SetDescriptor(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	validator.Register(model.Bitcoin, denominationValidator{backed: backed})
+	defer validator.Unregister(model.Bitcoin)
+
+	found, err := sess.UnbackedPhonons(context.Background())
+	if err != validator.ErrNoValidator {
+		t.Errorf("expected the ethereum phonon to be unchecked with %v, got %v", validator.ErrNoValidator, err)
+	}
+	if len(found) != 1 || found[0].Phonon.KeyIndex != phonons[1].KeyIndex || found[0].Shortfall.Int64() != 5000 {
+		t.Errorf("expected only the unbacked bitcoin phonon, got %+v", found)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = sess.UnbackedPhonons(ctx)
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestListPhononsFiltered(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	small := model.Denomination{Base: 1, Exponent: 3}
+	large := model.Denomination{Base: 5, Exponent: 6}
+	phonons := []*model.Phonon{
+		{CurrencyType: model.Bitcoin, Denomination: small},
+		{CurrencyType: model.Bitcoin, Denomination: large},
+		{CurrencyType: model.Ethereum, Denomination: large},
+	}
+	for _, p := range phonons {
+		p.KeyIndex, _, err = sess.CreatePhonon()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.SetDescriptor(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	validator.Register(model.Bitcoin, denominationValidator{backed: small})
+	defer validator.Unregister(model.Bitcoin)
+	_, err = sess.UnbackedPhonons(context.Background())
+	if err != validator.ErrNoValidator {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		filter   orchestrator.PhononFilter
+		expected []model.PhononKeyIndex
+	}{
+		{"everything", orchestrator.PhononFilter{}, []model.PhononKeyIndex{phonons[0].KeyIndex, phonons[1].KeyIndex, phonons[2].KeyIndex}},
+		{"currency", orchestrator.PhononFilter{CurrencyType: model.Bitcoin}, []model.PhononKeyIndex{phonons[0].KeyIndex, phonons[1].KeyIndex}},
+		{"minimum", orchestrator.PhononFilter{CurrencyType: model.Bitcoin, MinValue: big.NewInt(5000000)}, []model.PhononKeyIndex{phonons[1].KeyIndex}},
+		{"maximum", orchestrator.PhononFilter{MaxValue: big.NewInt(1000)}, []model.PhononKeyIndex{phonons[0].KeyIndex}},
+		{"empty range", orchestrator.PhononFilter{MinValue: big.NewInt(1001), MaxValue: big.NewInt(4999999)}, nil},
+		{"validated", orchestrator.PhononFilter{Validation: orchestrator.OnlyValidated}, []model.PhononKeyIndex{phonons[0].KeyIndex}},
+		{"unvalidated", orchestrator.PhononFilter{CurrencyType: model.Bitcoin, Validation: orchestrator.OnlyUnvalidated}, []model.PhononKeyIndex{phonons[1].KeyIndex}},
+	}
+	for _, test := range tests {
+		found, err := sess.ListPhononsFiltered(test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var indices []model.PhononKeyIndex
+		for _, p := range found {
+			indices = append(indices, p.KeyIndex)
+		}
+		sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+		if !reflect.DeepEqual(indices, test.expected) {
+			t.Errorf("%v: expected phonons %v, got %v", test.name, test.expected, indices)
+		}
+	}
+}
+
+// listRecordingCard records the filter of each phonon listing it is asked for
+type listRecordingCard struct {
+	*card.MockCard
+	listed [][3]uint64
+}
+
+func (c *listRecordingCard) ListPhonons(currencyType model.CurrencyType, lessThanValue uint64, greaterThanValue uint64, continues bool) ([]*model.Phonon, error) {
+	c.listed = append(c.listed, [3]uint64{uint64(currencyType), lessThanValue, greaterThanValue})
+	return c.MockCard.ListPhonons(currencyType, lessThanValue, greaterThanValue, continues)
+}
+
+func TestListPhononsFilteredOnCard(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &listRecordingCard{MockCard: mock}
+	sess, err := orchestrator.NewSession(recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	huge := new(big.Int).Lsh(big.NewInt(1), 70)
+	filters := []orchestrator.PhononFilter{
+		{CurrencyType: model.Bitcoin, MinValue: big.NewInt(1000), MaxValue: big.NewInt(5000)},
+		{CurrencyType: model.Ethereum, MinValue: huge, MaxValue: huge},
+	}
+	for _, filter := range filters {
+		_, err = sess.ListPhononsFiltered(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	//the card's bounds are exclusive, and bounds too large for it are left to the host
+	expected := [][3]uint64{{uint64(model.Bitcoin), 5001, 999}, {uint64(model.Ethereum), 0, 0}}
+	if !reflect.DeepEqual(recorder.listed, expected) {
+		t.Errorf("expected card list filters %v, got %v", expected, recorder.listed)
+	}
+}
+
+func TestReceiveOnlySession(t *testing.T) {
+	senderCard, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := orchestrator.NewSession(senderCard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiverCard, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver, err := orchestrator.NewSession(receiverCard, orchestrator.ReceiveOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender.IsReceiveOnly() || !receiver.IsReceiveOnly() {
+		t.Fatal("expected only the receiver to be receive only")
+	}
+	for _, sess := range []*orchestrator.Session{sender, receiver} {
+		err = sess.VerifyPIN("111111")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	receiverCert, err := receiver.GetCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	initPairingData, err := sender.InitCardPairing(*receiverCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardPairData, err := receiver.CardPair(initPairingData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardPair2Data, err := sender.CardPair2(cardPairData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = receiver.FinalizeCardPair(cardPair2Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyIndex, _, err := sender.CreatePhonon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet, err := senderCard.SendPhonons([]model.PhononKeyIndex{keyIndex}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = receiver.ReceivePhonons(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phonons, err := receiver.ListPhonons(0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(phonons) != 1 {
+		t.Fatalf("expected the receive only card to hold 1 phonon, got %d", len(phonons))
+	}
+	received := phonons[0]
+
+	err = receiver.SendPhonons([]model.PhononKeyIndex{received.KeyIndex})
+	if err != orchestrator.ErrReceiveOnly {
+		t.Errorf("expected SendPhonons to be refused, got %v", err)
+	}
+	_, err = receiver.DestroyPhonon(received.KeyIndex)
+	if err != orchestrator.ErrReceiveOnly {
+		t.Errorf("expected DestroyPhonon to be refused, got %v", err)
+	}
+	_, _, err = receiver.RedeemPhonon(received, "address")
+	if err != orchestrator.ErrReceiveOnly {
+		t.Errorf("expected RedeemPhonon to be refused, got %v", err)
+	}
+
+	//deposits that are never confirmed are still cleaned up
+	deposits, err := receiver.InitDepositPhonons(model.Ethereum, []*model.Denomination{{Base: 1, Exponent: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = receiver.FinalizeDepositPhonon(orchestrator.DepositConfirmation{Phonon: deposits[0], ConfirmedOnChain: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	phonons, err = receiver.ListPhonons(0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(phonons) != 1 {
+		t.Errorf("expected the unconfirmed deposit to be destroyed, %d phonons remain", len(phonons))
+	}
+}
+
+func TestReceiveDuplicatePhonons(t *testing.T) {
+	var mocks []*card.MockCard
+	var sessions []*orchestrator.Session
+	for i := 0; i < 2; i++ {
+		mock, err := card.NewMockCard(true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess, err := orchestrator.NewSession(mock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.VerifyPIN("111111")
+		if err != nil {
+			t.Fatal(err)
+		}
+		mocks = append(mocks, mock)
+		sessions = append(sessions, sess)
+	}
+	sender, receiver := sessions[0], sessions[1]
+	receiverCert, err := receiver.GetCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	initPairingData, err := sender.InitCardPairing(*receiverCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardPairData, err := receiver.CardPair(initPairingData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardPair2Data, err := sender.CardPair2(cardPairData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = receiver.FinalizeCardPair(cardPair2Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	countPhonons := func() int {
+		phonons, err := receiver.ListPhonons(0, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(phonons)
+	}
+
+	first, _, err := sender.CreatePhonon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet, err := mocks[0].SendPhonons([]model.PhononKeyIndex{first}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = receiver.ReceivePhonons(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a transfer repeating a phonon is accepted, keeping a single copy and reporting the other
+	second, _, err := sender.CreatePhonon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet, err = mocks[0].SendPhonons([]model.PhononKeyIndex{second, second}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := receiver.ReceivePhononsChecked(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Duplicates) != 1 {
+		t.Errorf("expected the repeated copy to be reported, got %v", result.Duplicates)
+	}
+	if n := countPhonons(); n != 2 {
+		t.Errorf("expected 2 phonons after removing the repeated copy, got %d", n)
+	}
+}
+
+func TestReceiveBelowMinimum(t *testing.T) {
+	var mocks []*card.MockCard
+	var sessions []*orchestrator.Session
+	for i := 0; i < 2; i++ {
+		mock, err := card.NewMockCard(true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess, err := orchestrator.NewSession(mock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.VerifyPIN("111111")
+		if err != nil {
+			t.Fatal(err)
+		}
+		mocks = append(mocks, mock)
+		sessions = append(sessions, sess)
+	}
+	sender, receiver := sessions[0], sessions[1]
+	receiverCert, err := receiver.GetCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	initPairingData, err := sender.InitCardPairing(*receiverCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardPairData, err := receiver.CardPair(initPairingData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardPair2Data, err := sender.CardPair2(cardPairData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = receiver.FinalizeCardPair(cardPair2Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if receiver.MinAcceptedValue(model.Ethereum).Sign() != 0 {
+		t.Error("expected phonons of any value to be accepted by default")
+	}
+	receiver.SetMinAcceptedValue(model.Ethereum, big.NewInt(1000))
+
+	var keyIndices []model.PhononKeyIndex
+	for _, value := range []int64{100, 5000} {
+		keyIndex, _, err := sender.CreatePhonon()
+		if err != nil {
+			t.Fatal(err)
+		}
+		denomination, err := model.NewDenomination(big.NewInt(value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sender.SetDescriptor(&model.Phonon{
+			KeyIndex:     keyIndex,
+			CurrencyType: model.Ethereum,
+			Denomination: denomination,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyIndices = append(keyIndices, keyIndex)
+	}
+	packet, err := mocks[0].SendPhonons(keyIndices, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := receiver.ReceivePhononsChecked(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phonons, err := receiver.ListPhonons(0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the dust can't be refused once the card has stored it, so it is kept and reported
+	if len(phonons) != 2 {
+		t.Errorf("expected both received phonons to be kept, got %v", phonons)
+	}
+	if len(result.BelowMinimum) != 1 {
+		t.Fatalf("expected the phonon below the minimum to be reported, got %v", result.BelowMinimum)
+	}
+	dust, err := receiver.GetPhonon(result.BelowMinimum[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dust.Denomination.Value().Int64() != 100 {
+		t.Errorf("expected the 100 phonon to be reported, got %v", dust.Denomination)
+	}
+}
+
+func TestPublicIdentityWithoutSecureChannel(t *testing.T) {
+	mock, err := card.NewMockCard(false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mock.InstallCertificate(cert.SignWithDemoKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.IsPairedToTerminal() {
+		t.Fatal("uninitialized card should not have opened a secure channel")
+	}
+	identity, err := sess.PublicIdentity()
+	if err != nil {
+		t.Fatal("unable to read public identity: ", err)
+	}
+	if !reflect.DeepEqual(*identity, mock.IdentityCert) {
+		t.Errorf("expected identity %v, got %v", mock.IdentityCert, identity)
+	}
+}
+
+func TestUninitializedCard(t *testing.T) {
+	mock, err := card.NewMockCard(false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.IsInitialized() {
+		t.Fatal("expected card to be uninitialized")
+	}
+	initialized, err := sess.RefreshInitialized()
+	if err != nil || initialized {
+		t.Fatalf("expected refreshed card to be uninitialized, got %v, %v", initialized, err)
+	}
+	_, err = sess.ListPhonons(0, 0, 0)
+	if !errors.Is(err, orchestrator.ErrCardNotInitialized) {
+		t.Errorf("expected %v listing phonons, got %v", orchestrator.ErrCardNotInitialized, err)
+	}
+	_, _, err = sess.CreatePhonon()
+	if !errors.Is(err, orchestrator.ErrCardNotInitialized) {
+		t.Errorf("expected %v creating phonon, got %v", orchestrator.ErrCardNotInitialized, err)
+	}
+
+	err = sess.Init("111111")
+	if err != nil {
+		t.Fatal("unable to initialize card: ", err)
+	}
+	if !sess.IsInitialized() {
+		t.Fatal("expected card to be initialized")
+	}
+}
+
+func TestPINManagement(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.ChangePIN("111111", "222222")
+	if err != nil {
+		t.Fatal("unable to change PIN: ", err)
+	}
+	var wrongPIN *card.WrongPINError
+	err = sess.VerifyPIN("111111")
+	if !errors.As(err, &wrongPIN) || wrongPIN.TriesRemaining != 2 {
+		t.Fatalf("expected the old PIN to be rejected with 2 tries remaining, got %v", err)
+	}
+	tries, err := sess.PINStatus()
+	if err != nil || tries != 2 {
+		t.Errorf("expected 2 tries remaining, got %v, %v", tries, err)
+	}
+	err = sess.VerifyPIN("222222")
+	if err != nil {
+		t.Fatal("unable to verify new PIN: ", err)
+	}
+	tries, _ = sess.PINStatus()
+	if tries != 3 {
+		t.Errorf("expected tries to be reset by a correct PIN, got %v", tries)
+	}
+	for i := 0; i < 3; i++ {
+		err = sess.ChangePIN("333333", "444444")
+	}
+	if err != card.ErrPINBlocked {
+		t.Errorf("expected %v after running out of tries, got %v", card.ErrPINBlocked, err)
+	}
+	err = sess.VerifyPIN("222222")
+	if err != card.ErrPINBlocked {
+		t.Errorf("expected a blocked PIN to refuse the correct PIN, got %v", err)
+	}
+}
+
+func TestFindPhononsByTag(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := []string{"Birthday gift", "savings", "", "gift for Bob"}
+	for _, tag := range tags {
+		keyIndex, _, err := sess.CreatePhonon()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.SetDescriptor(&model.Phonon{KeyIndex: keyIndex, CurrencyType: model.Ethereum})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.SetPhononDescription(keyIndex, tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := sess.FindPhononsByTag("GIFT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 {
+		t.Fatalf("expected 2 phonons tagged gift, got %d", len(found))
+	}
+	for _, p := range found {
+		if p.Tag != tags[0] && p.Tag != tags[3] {
+			t.Errorf("unexpected phonon with tag %q at index %v", p.Tag, p.KeyIndex)
+		}
+	}
+}
+
+func TestPhononDescription(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "descriptions.json")
+	store, err := orchestrator.NewFileDescriptionStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock, orchestrator.WithDescriptionStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyIndex, pubKey, err := sess.CreatePhonon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.SetDescriptor(&model.Phonon{KeyIndex: keyIndex, CurrencyType: model.Ethereum})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sess.SetPhononDescription(keyIndex, strings.Repeat("a", orchestrator.MaxDescriptionLength+1))
+	if err != orchestrator.ErrDescriptionTooLong {
+		t.Errorf("expected %v, got %v", orchestrator.ErrDescriptionTooLong, err)
+	}
+	err = sess.SetPhononDescription(keyIndex, "rent ☂")
+	if err != nil {
+		t.Fatal("unable to set description: ", err)
+	}
+	phonons, err := sess.ListPhonons(0, 0, 0)
+	if err != nil || len(phonons) != 1 || phonons[0].Tag != "rent ☂" {
+		t.Fatalf("expected the description to be listed as the tag, got %v, %v", phonons, err)
+	}
+
+	reloaded, err := orchestrator.NewFileDescriptionStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, ok := reloaded.Description(pubKey.String())
+	if !ok || desc != "rent ☂" {
+		t.Errorf("expected the description to be saved, got %q", desc)
+	}
+}
+
+func TestListPhononsCreatedBetween(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	march := time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC)
+	april := time.Date(2026, time.April, 10, 0, 0, 0, 0, time.UTC)
+	tagged := map[string]time.Time{"march": march, "april": april, "legacy": {}}
+	for tag, createdAt := range tagged {
+		keyIndex, _, err := sess.CreatePhonon()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.SetDescriptor(&model.Phonon{KeyIndex: keyIndex, CurrencyType: model.Bitcoin, CreatedAt: createdAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.SetPhononDescription(keyIndex, tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := sess.ListPhononsCreatedBetween(time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := map[string]bool{}
+	for _, p := range found {
+		tags[p.Tag] = true
+	}
+	if len(found) != 2 || !tags["april"] || !tags["legacy"] {
+		t.Errorf("expected the april and legacy phonons, got %v", tags)
+	}
+}
+
+func TestStreamPhonons(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := map[model.PhononKeyIndex]bool{}
+	for i := 0; i < 3; i++ {
+		keyIndex, _, err := sess.CreatePhonon()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.SetDescriptor(&model.Phonon{KeyIndex: keyIndex, CurrencyType: model.Ethereum})
+		if err != nil {
+			t.Fatal(err)
+		}
+		created[keyIndex] = true
+	}
+
+	phonons, errs := sess.StreamPhonons(context.Background())
+	streamed := map[model.PhononKeyIndex]bool{}
+	for p := range phonons {
+		streamed[p.KeyIndex] = true
+	}
+	err = <-errs
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(streamed, created) {
+		t.Errorf("expected every phonon to be streamed, got %v", streamed)
+	}
+
+	//a scan cancelled after the first phonon stops sending
+	ctx, cancel := context.WithCancel(context.Background())
+	phonons, errs = sess.StreamPhonons(ctx)
+	<-phonons
+	cancel()
+	err = <-errs
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if _, ok := <-phonons; ok {
+		t.Error("expected the phonon channel to be closed after cancellation")
+	}
+}
+
+func TestGetPhonon(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indices []model.PhononKeyIndex
+	for _, tag := range []string{"first", "second"} {
+		keyIndex, _, err := sess.CreatePhonon()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.SetDescriptor(&model.Phonon{KeyIndex: keyIndex, CurrencyType: model.Ethereum})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.SetPhononDescription(keyIndex, tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		indices = append(indices, keyIndex)
+	}
+
+	p, err := sess.GetPhonon(indices[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.KeyIndex != indices[1] || p.Tag != "second" || p.CurrencyType != model.Ethereum {
+		t.Errorf("unexpected phonon returned: %+v", p)
+	}
+	if p.PubKey == nil {
+		t.Error("expected phonon pubkey to be populated")
+	}
+
+	_, err = sess.DestroyPhonon(indices[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sess.GetPhonon(indices[0])
+	if !errors.Is(err, orchestrator.ErrPhononNotFound) {
+		t.Errorf("expected ErrPhononNotFound for destroyed phonon, got %v", err)
+	}
+	_, err = sess.GetPhonon(indices[1] + 10)
+	if !errors.Is(err, orchestrator.ErrPhononNotFound) {
+		t.Errorf("expected ErrPhononNotFound for empty slot, got %v", err)
+	}
+}
+
+func TestSerialNumber(t *testing.T) {
+	mock, err := card.NewMockCard(false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sess.SerialNumber()
+	if err != orchestrator.ErrSerialNumberUnavailable {
+		t.Errorf("expected %v before initialization, got %v", orchestrator.ErrSerialNumberUnavailable, err)
+	}
+	err = sess.Init("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := sess.SerialNumber()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serial) != 32 {
+		t.Errorf("expected a 16 byte hex serial, got %q", serial)
+	}
+
+	//the serial belongs to the card, so a new session reads the same one without unlocking it
+	other, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSerial, err := other.SerialNumber()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherSerial != serial {
+		t.Errorf("expected serial %s from a new session, got %s", serial, otherSerial)
+	}
+}
+
+func TestLifecycleState(t *testing.T) {
+	mock, err := card.NewMockCard(false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := sess.LifecycleState()
+	if err != nil || state != model.LifecyclePersonalization {
+		t.Errorf("expected %v before initialization, got %v, %v", model.LifecyclePersonalization, state, err)
+	}
+	err = sess.Init("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err = sess.LifecycleState()
+	if err != nil || state != model.LifecycleActive {
+		t.Errorf("expected %v once initialized, got %v, %v", model.LifecycleActive, state, err)
+	}
+}
+
+func TestCardFingerprint(t *testing.T) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := orchestrator.NewSession(mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, err := sess.CardFingerprint()
+	if err != nil {
+		t.Fatal("unable to fingerprint card: ", err)
+	}
+	err = sess.CheckCardFingerprint(fingerprint)
+	if err != nil {
+		t.Error("card does not match its own fingerprint: ", err)
+	}
+
+	otherMock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := orchestrator.NewSession(otherMock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = other.CheckCardFingerprint(fingerprint)
+	if err != orchestrator.ErrCardMismatch {
+		t.Errorf("expected %v for a different card, got %v", orchestrator.ErrCardMismatch, err)
+	}
+}
+
+func TestPairingWithRevokedCard(t *testing.T) {
+	var sessions []*orchestrator.Session
+	for i := 0; i < 2; i++ {
+		mock, err := card.NewMockCard(true, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess, err := orchestrator.NewSession(mock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.VerifyPIN("111111")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, sess)
+	}
+	sender, receiver := sessions[0], sessions[1]
+	receiverCert, err := receiver.GetCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender.SetRevocationList(cert.NewRevocationList([]string{receiver.GetCardId()}))
+	_, err = sender.InitCardPairing(*receiverCert)
+	if !errors.Is(err, cert.ErrCertificateRevoked) {
+		t.Errorf("expected pairing with a revoked card to fail, got %v", err)
+	}
+
+	sender.SetRevocationList(nil)
+	initPairingData, err := sender.InitCardPairing(*receiverCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver.SetRevocationList(cert.NewRevocationList([]string{sender.GetCardId()}))
+	_, err = receiver.CardPair(initPairingData)
+	if !errors.Is(err, cert.ErrCertificateRevoked) {
+		t.Errorf("expected pairing from a revoked card to fail, got %v", err)
+	}
+}
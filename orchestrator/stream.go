@@ -0,0 +1,124 @@
+package orchestrator
+
+import (
+	"context"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+/*
+StreamPhonons lists every phonon on the card, sending each one as soon as the card's response containing it
+has been read and decoded rather than once the whole listing is complete. The card returns its phonons in pages,
+and ctx is checked between reads so a scan of a large card can be abandoned part way through.
+
+Other operations on the card can run between pages. If one of them resets the card's listing, the scan starts
+over and skips the phonons it has already sent, so each phonon is sent once.
+
+The phonon channel is closed once the scan ends. At most one error, ctx.Err() if the scan was cancelled,
+is sent on the error channel before it is also closed.
+Listed phonons are cached as in ListPhonons, and a cached listing is streamed without reading the card.
+*/
+func (s *Session) StreamPhonons(ctx context.Context) (<-chan model.Phonon, <-chan error) {
+	phonons := make(chan model.Phonon)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(phonons)
+		err := s.streamPhonons(ctx, phonons)
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return phonons, errs
+}
+
+func (s *Session) streamPhonons(ctx context.Context, out chan<- model.Phonon) error {
+	if !s.verified() {
+		return s.unverifiedErr()
+	}
+	send := func(p *model.Phonon) error {
+		select {
+		case out <- *p:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if s.cachePopulated {
+		cached, err := s.ListPhonons(0, 0, 0)
+		if err != nil {
+			return err
+		}
+		for _, p := range cached {
+			err = send(p)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var scan phononScan
+	sent := make(map[model.PhononKeyIndex]bool)
+	for {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+		page, more, err := s.listPhononsPage(&scan)
+		if err != nil {
+			return err
+		}
+		for _, p := range page {
+			//descriptors the card returned but which couldn't be parsed are logged and skipped,
+			//as are phonons already sent before the scan started over
+			if p == nil || sent[p.KeyIndex] {
+				continue
+			}
+			sent[p.KeyIndex] = true
+			err = send(p)
+			if err != nil {
+				return err
+			}
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// phononScan is the state of a paged listing of the card kept between pages, while ElementUsageMtex is released
+type phononScan struct {
+	started    bool
+	listing    uint64 //the session's listings when the scan's first page was read
+	generation uint64 //the session's cacheGeneration when the scan's first page was read
+}
+
+/*
+listPhononsPage reads the next page of all phonons from the card, adding them to the cache.
+If the card's listing was reset since the last page the scan starts over from the first page.
+Once the last page is read the cache is marked populated, unless it was invalidated during the scan.
+*/
+func (s *Session) listPhononsPage(scan *phononScan) ([]*model.Phonon, bool, error) {
+	s.ElementUsageMtex.Lock()
+	defer s.ElementUsageMtex.Unlock()
+
+	continuation := scan.started && scan.listing == s.listings
+	if !continuation {
+		s.listings++
+		*scan = phononScan{started: true, listing: s.listings, generation: s.cacheGeneration}
+	}
+	page, more, err := s.cs.ListPhononsPage(0, 0, 0, continuation)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, p := range page {
+		if p != nil {
+			s.addInfoToCache(p)
+		}
+	}
+	if !more && scan.generation == s.cacheGeneration {
+		s.cachePopulated = true
+	}
+	return page, more, nil
+}
@@ -0,0 +1,144 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GridPlus/phonon-client/card"
+	"github.com/GridPlus/phonon-client/model"
+)
+
+// pagedCard lists the mock's phonons a page at a time, keeping a cursor that is reset like the card's
+type pagedCard struct {
+	*card.MockCard
+	pageSize int
+	cursor   int
+}
+
+func (c *pagedCard) ListPhonons(currencyType model.CurrencyType, lessThanValue uint64, greaterThanValue uint64, continues bool) ([]*model.Phonon, error) {
+	c.cursor = 0
+	return c.MockCard.ListPhonons(currencyType, lessThanValue, greaterThanValue, continues)
+}
+
+func (c *pagedCard) ListPhononsPage(currencyType model.CurrencyType, lessThanValue uint64, greaterThanValue uint64, continues bool) ([]*model.Phonon, bool, error) {
+	if !continues {
+		c.cursor = 0
+	}
+	phonons, err := c.MockCard.ListPhonons(currencyType, lessThanValue, greaterThanValue, continues)
+	if err != nil {
+		return nil, false, err
+	}
+	end := c.cursor + c.pageSize
+	if end > len(phonons) {
+		end = len(phonons)
+	}
+	page := phonons[c.cursor:end]
+	c.cursor = end
+	return page, end < len(phonons), nil
+}
+
+// ReceivePhonons stands in for a received transfer by creating a phonon
+func (c *pagedCard) ReceivePhonons(transfer []byte) error {
+	c.cursor = 0
+	keyIndex, _, err := c.MockCard.CreatePhonon(model.Secp256k1)
+	if err != nil {
+		return err
+	}
+	return c.MockCard.SetDescriptor(&model.Phonon{KeyIndex: keyIndex, CurrencyType: model.Bitcoin})
+}
+
+func newPagedSession(t *testing.T) (*Session, map[model.PhononKeyIndex]bool) {
+	mock, err := card.NewMockCard(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := NewSession(&pagedCard{MockCard: mock, pageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sess.VerifyPIN("111111")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := map[model.PhononKeyIndex]bool{}
+	for i := 0; i < 5; i++ {
+		keyIndex, _, err := sess.CreatePhonon()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = sess.SetDescriptor(&model.Phonon{KeyIndex: keyIndex, CurrencyType: model.Ethereum})
+		if err != nil {
+			t.Fatal(err)
+		}
+		created[keyIndex] = true
+	}
+	return sess, created
+}
+
+// streamInterrupted streams every phonon, calling interrupt once the first has arrived,
+// while the scan is waiting to send the rest of the first page
+func streamInterrupted(t *testing.T, sess *Session, interrupt func()) map[model.PhononKeyIndex]int {
+	phonons, errs := sess.StreamPhonons(context.Background())
+	streamed := map[model.PhononKeyIndex]int{}
+	for p := range phonons {
+		if len(streamed) == 0 {
+			interrupt()
+		}
+		streamed[p.KeyIndex]++
+	}
+	err := <-errs
+	if err != nil {
+		t.Fatal(err)
+	}
+	return streamed
+}
+
+func TestStreamPhononsInterleavedListing(t *testing.T) {
+	sess, created := newPagedSession(t)
+	streamed := streamInterrupted(t, sess, func() {
+		_, err := sess.ListPhonons(model.Ethereum, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(streamed) != len(created) {
+		t.Errorf("expected all %v phonons to be streamed, got %v", len(created), streamed)
+	}
+	for keyIndex, count := range streamed {
+		if !created[keyIndex] || count != 1 {
+			t.Errorf("expected phonon %v to be streamed once, got %v", keyIndex, count)
+		}
+	}
+}
+
+func TestStreamPhononsInterleavedReceive(t *testing.T) {
+	sess, created := newPagedSession(t)
+	streamed := streamInterrupted(t, sess, func() {
+		err := sess.receivePhonons(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(streamed) != len(created)+1 {
+		t.Errorf("expected the received phonon to be streamed with the rest, got %v", streamed)
+	}
+	for keyIndex, count := range streamed {
+		if count != 1 {
+			t.Errorf("expected phonon %v to be streamed once, got %v", keyIndex, count)
+		}
+	}
+	//the received phonon is served from the cache the scan populated
+	phonons, err := sess.ListPhonons(0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range phonons {
+		_, err = sess.GetPhonon(p.KeyIndex)
+		if err != nil {
+			t.Errorf("unable to get phonon %v after the scan: %v", p.KeyIndex, err)
+		}
+	}
+	if len(phonons) != len(created)+1 {
+		t.Errorf("expected the cache to hold the received phonon, got %v phonons", len(phonons))
+	}
+}
@@ -0,0 +1,56 @@
+package orchestrator
+
+import (
+	"context"
+
+	"github.com/GridPlus/phonon-client/model"
+	"github.com/GridPlus/phonon-client/validator"
+)
+
+/*
+UnbackedPhonons checks every phonon on the card against the chain with the registered validators and returns
+those whose backing is missing or less than their denomination, each with the amount it is short by.
+
+Phonons that couldn't be checked, such as those of a currency without a validator, aren't counted as unbacked.
+The unbacked phonons found among the rest are returned along with the first error, so a single unreachable
+backend doesn't hide the result for other currencies. Once ctx is done no further phonons are checked and
+ctx.Err() is returned.
+*/
+func (s *Session) UnbackedPhonons(ctx context.Context) ([]validator.Backing, error) {
+	listed, err := s.ListPhonons(0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	phonons := make([]*model.Phonon, 0, len(listed))
+	for _, p := range listed {
+		err = ctx.Err()
+		if err != nil {
+			return nil, err
+		}
+		//listing doesn't include public keys, which the validators derive addresses from
+		phonon, err := s.GetPhonon(p.KeyIndex)
+		if err != nil {
+			return nil, err
+		}
+		phonons = append(phonons, phonon)
+	}
+
+	var unbacked []validator.Backing
+	var firstErr error
+	for _, b := range validator.CheckBackingAll(ctx, phonons, 0) {
+		if b.Err != nil {
+			if firstErr == nil {
+				firstErr = b.Err
+			}
+			continue
+		}
+		s.setValidated(b.Phonon.KeyIndex, b.Backed())
+		if !b.Backed() {
+			unbacked = append(unbacked, b)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return unbacked, firstErr
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/GridPlus/phonon-client/model"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrRetriesExhausted = errors.New("gave up reconnecting after maximum attempts")
+
+// BackoffPolicy controls how long to wait between reconnection attempts.
+// Unset delays and multiplier are taken from DefaultBackoffPolicy.
+type BackoffPolicy struct {
+	InitialDelay time.Duration
+	Multiplier   float64
+	MaxDelay     time.Duration
+	MaxAttempts  int     //0 retries until the context is cancelled
+	Jitter       float64 //fraction of each delay to randomize by, between 0 and 1
+}
+
+// DefaultBackoffPolicy suits an interactive client: quick first retry, capped at 30 seconds between attempts
+var DefaultBackoffPolicy = BackoffPolicy{
+	InitialDelay: 500 * time.Millisecond,
+	Multiplier:   2,
+	MaxDelay:     30 * time.Second,
+	MaxAttempts:  10,
+	Jitter:       0.2,
+}
+
+// withDefaults returns p with its unset delays and multiplier taken from DefaultBackoffPolicy
+func (p BackoffPolicy) withDefaults() BackoffPolicy {
+	p.InitialDelay = orDefault(p.InitialDelay, DefaultBackoffPolicy.InitialDelay)
+	p.MaxDelay = orDefault(p.MaxDelay, DefaultBackoffPolicy.MaxDelay)
+	if p.Multiplier <= 0 {
+		p.Multiplier = DefaultBackoffPolicy.Multiplier
+	}
+	return p
+}
+
+// Delay returns how long to wait before the given retry, counting from zero
+func (p BackoffPolicy) Delay(retry int) time.Duration {
+	p = p.withDefaults()
+	delay := float64(p.InitialDelay)
+	for i := 0; i < retry && delay < float64(p.MaxDelay); i++ {
+		delay *= p.Multiplier
+	}
+	if delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// retry calls attempt until it succeeds, the policy's attempts run out, or ctx is cancelled
+func (p BackoffPolicy) retry(ctx context.Context, attempt func() error) error {
+	var err error
+	for i := 0; p.MaxAttempts == 0 || i < p.MaxAttempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(p.Delay(i - 1)):
+			}
+		}
+		err = attempt()
+		if err == nil {
+			return nil
+		}
+		log.Debugf("connection attempt %d failed: %v", i+1, err)
+	}
+	return fmt.Errorf("%w: %v", ErrRetriesExhausted, err)
+}
+
+// ConnectWithBackoff calls Connect, retrying failed attempts according to policy
+func ConnectWithBackoff(ctx context.Context, policy BackoffPolicy, sessReqChan chan model.SessionRequest, url string, ignoreTLS bool, opts ...Option) (*RemoteConnection, error) {
+	var conn *RemoteConnection
+	err := policy.retry(ctx, func() error {
+		var err error
+		conn, err = Connect(sessReqChan, url, ignoreTLS, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	p := BackoffPolicy{
+		InitialDelay: 100 * time.Millisecond,
+		Multiplier:   3,
+		MaxDelay:     time.Second,
+	}
+	expected := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second, time.Second}
+	for i, d := range expected {
+		if p.Delay(i) != d {
+			t.Errorf("retry %d: expected delay %v, got %v", i, d, p.Delay(i))
+		}
+	}
+
+	//unset fields fall back to the defaults rather than disabling the backoff
+	unset := BackoffPolicy{InitialDelay: 100 * time.Millisecond}
+	if unset.Delay(0) != 100*time.Millisecond || unset.Delay(1) != 200*time.Millisecond || unset.Delay(20) != DefaultBackoffPolicy.MaxDelay {
+		t.Errorf("expected the default multiplier and cap, got %v, %v and %v", unset.Delay(0), unset.Delay(1), unset.Delay(20))
+	}
+	if (BackoffPolicy{}).Delay(0) != DefaultBackoffPolicy.InitialDelay {
+		t.Errorf("expected the default initial delay, got %v", BackoffPolicy{}.Delay(0))
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Delay(0)
+		if d < 50*time.Millisecond || d > 150*time.Millisecond {
+			t.Fatalf("jittered delay %v outside of expected range", d)
+		}
+	}
+}
+
+func TestBackoffRetry(t *testing.T) {
+	p := BackoffPolicy{InitialDelay: time.Millisecond, Multiplier: 1, MaxAttempts: 3}
+	attempts := 0
+	errFailed := errors.New("failed")
+	err := p.retry(context.Background(), func() error {
+		attempts++
+		return errFailed
+	})
+	if !errors.Is(err, ErrRetriesExhausted) || attempts != 3 {
+		t.Errorf("expected %v after 3 attempts, got %v after %d", ErrRetriesExhausted, err, attempts)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p = BackoffPolicy{InitialDelay: time.Hour}
+	attempts = 0
+	err = p.retry(ctx, func() error {
+		attempts++
+		cancel()
+		return errFailed
+	})
+	if err != context.Canceled || attempts != 1 {
+		t.Errorf("expected cancellation after 1 attempt, got %v after %d", err, attempts)
+	}
+}
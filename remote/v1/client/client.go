@@ -1,19 +1,21 @@
 package client
 
 import (
-	"bytes"
 	"context"
 	"crypto/ecdsa"
-	"crypto/rand"
 	"crypto/tls"
 	"encoding/gob"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/GridPlus/phonon-client/card"
 	"github.com/GridPlus/phonon-client/cert"
+	"github.com/GridPlus/phonon-client/metrics"
 	"github.com/GridPlus/phonon-client/model"
 	v1 "github.com/GridPlus/phonon-client/remote/v1"
 	"github.com/GridPlus/phonon-client/util"
@@ -24,30 +26,115 @@ import (
 
 type RemoteConnection struct {
 	conn                     *h2conn.Conn
-	out                      *gob.Encoder
-	in                       *gob.Decoder
+	out                      v1.Encoder
+	connCancel               context.CancelFunc //aborts the request carrying conn, unblocking reads from it
+	connLost                 chan struct{}      //closed once conn stops delivering messages
+	connMtex                 sync.Mutex         //guards conn, connCancel, connLost and out, which are replaced on reconnection
+	ctx                      context.Context    //cancelled by Close
+	cancel                   context.CancelFunc
+	url                      string
+	transport                *http2.Transport //shared by every dial so Close can release its connections
+	maxMessageSize           uint64
 	remoteCertificate        *cert.CardCertificate
 	localCertificate         *cert.CardCertificate
 	sessionRequestChan       chan model.SessionRequest
 	identifiedWithServerChan chan bool
 	identifiedWithServer     bool
-	counterpartyNonce        [32]byte
+	caPubKey                 []byte //counterparty certificates must be issued by this CA, DefaultCAPubKey if nil
+	counterpartyNonce        []byte
+	identifyNonceSize        int
 	verified                 bool
-	connectedToCardChan      chan bool
-	verifyPairedChan         chan string
 
-	//card pairing message channels
-	remoteCertificateChan    chan cert.CardCertificate
-	remoteIdentityChan       chan []byte
-	cardPair1DataChan        chan []byte
-	finalizeCardPairDataChan chan []byte
-	pairingStatus            model.RemotePairingStatus
-	logger                   *log.Entry
+	remoteIdentityChan chan []byte
+	pairingStatus      model.RemotePairingStatus
+	logger             *log.Entry
 
-	phononAckChan chan bool
+	// compression is set once the server agrees to compress payloads in its HelloAck
+	compression atomic.Bool
+	// codec is the wire format offered when dialing, and wireCodec is set to it if the server agrees, nil for gob
+	codec        v1.Codec
+	wireCodec    v1.Codec
+	helloAckChan chan v1.Hello
+	// requireSchema fails the handshake unless the server reports a matching schema fingerprint
+	requireSchema bool
+	// serverKey is the public key the server must sign the Hello challenge with, if set
+	serverKey *ecdsa.PublicKey
+
+	// waiters holds each request currently awaiting a response, keyed by response message name and request ID.
+	// Requests register before sending so that a response processed before the request starts waiting is not lost.
+	waiters     map[waiterKey]waiter
+	waitersMtex sync.Mutex
+	// lastRequestID is the ID given to the most recent request, so any number of requests can be outstanding
+	lastRequestID atomic.Uint64
+	// outMtex serializes writes to out, since requests may be sent concurrently
+	outMtex sync.Mutex
+
+	// cardID and connectedAt identify the connection in Status snapshots
+	cardID      string
+	connectedAt time.Time
+
+	// listener receives the outcome of each incoming transfer while Listen is running
+	listener     chan ReceiveEvent
+	listenerMtex sync.Mutex
+	// timeouts bounds the wait for each response, zero fields use DefaultTimeouts
+	timeouts Timeouts
+	// events receives progress reports for Events
+	events chan RemoteEvent
+	// readErr receives the error ending the current connection's incoming messages
+	readErr chan error
+	// reconnect is the policy for dialing the jump server again after the connection drops, nil to give up at once
+	reconnect *BackoffPolicy
+	// onDisconnect is called each time the connection drops
+	onDisconnect     func(error)
+	onDisconnectMtex sync.Mutex
+	// stopKeepalive is closed to stop the keepalive started by StartKeepalive, nil if none is running
+	stopKeepalive     chan struct{}
+	stopKeepaliveMtex sync.Mutex
+	// done is closed once the connection stops handling incoming messages
+	done chan struct{}
+}
+
+type waiter struct {
+	ch    chan v1.Message
+	since time.Time
+}
+
+type waiterKey struct {
+	name string
+	id   uint64
 }
 
 var ErrTimeout = errors.New("Timeout")
+var ErrCertificateUnavailable = errors.New("local card certificate unavailable")
+var ErrIdentifyFailed = errors.New("unable to identify local card")
+var ErrNotConnectedToCard = errors.New("not connected to a card or already paired")
+var ErrCardPairFailed = errors.New("unable to complete card pair 1")
+var ErrDuplicateConnection = errors.New("card connected to the jump server from another session")
+var ErrCounterpartyCertInvalid = errors.New("counterparty certificate not issued by trusted CA")
+var ErrIdentityUnverified = errors.New("counterparty failed identity challenge")
+
+// DefaultCAPubKey is the CA counterparty certificates must be issued by unless overridden with WithCAPubKey.
+// It is the alpha CA, which issued the cards in circulation. No production root CA key is published yet,
+// so there is none to default to.
+var DefaultCAPubKey = cert.PhononAlphaCAPubKey
+var ErrNonceTooShort = fmt.Errorf("identify challenge must be at least %d bytes", MinIdentifyNonceSize)
+
+const (
+	// DefaultIdentifyNonceSize is the length of the challenge sent in Identify unless set with WithIdentifyNonceSize
+	DefaultIdentifyNonceSize = 32
+	// MinIdentifyNonceSize is the shortest challenge accepted, below which a signed challenge could be guessed and replayed
+	MinIdentifyNonceSize = 16
+)
+
+// PhononRejectedError is returned by ReceivePhonons when the counterparty refuses the transfer
+type PhononRejectedError struct {
+	Reason  v1.RejectReason
+	Message string
+}
+
+func (e *PhononRejectedError) Error() string {
+	return fmt.Sprintf("counterparty rejected phonons (%s): %s", e.Reason, e.Message)
+}
 
 // Requests into the card session
 func (c *RemoteConnection) getLocalCertificate() (*cert.CardCertificate, error) {
@@ -55,7 +142,10 @@ func (c *RemoteConnection) getLocalCertificate() (*cert.CardCertificate, error)
 		Ret: make(chan model.ResponseCertificate),
 	}
 	c.logger.Debug("Requesting local card certificate")
-	c.sessionRequestChan <- req
+	err := c.toSession(req)
+	if err != nil {
+		return &cert.CardCertificate{}, err
+	}
 	ret := <-req.Ret
 	if ret.Err != nil {
 		return &cert.CardCertificate{}, ret.Err
@@ -69,7 +159,10 @@ func (c *RemoteConnection) requestIdentifyCard(payload []byte) (*ecdsa.PublicKey
 		Nonce: payload,
 	}
 	c.logger.Debug("Requesting Identify card")
-	c.sessionRequestChan <- req
+	err := c.toSession(req)
+	if err != nil {
+		return nil, nil, err
+	}
 	ret := <-req.Ret
 	return ret.PubKey, ret.Sig, ret.Err
 }
@@ -80,7 +173,10 @@ func (c *RemoteConnection) requestCardPair1(payload []byte) ([]byte, error) {
 		Payload: payload,
 	}
 	c.logger.Debug("Requesting card pair 1")
-	c.sessionRequestChan <- req
+	err := c.toSession(req)
+	if err != nil {
+		return nil, err
+	}
 	ret := <-req.Ret
 	return ret.Payload, ret.Err
 }
@@ -91,7 +187,10 @@ func (c *RemoteConnection) requestFinalizeCardPair(payload []byte) error {
 		Payload: payload,
 	}
 	c.logger.Debug("Requesting finalize card pair")
-	c.sessionRequestChan <- req
+	err := c.toSession(req)
+	if err != nil {
+		return err
+	}
 	ret := <-req.Ret
 	return ret.Err
 }
@@ -102,7 +201,10 @@ func (c *RemoteConnection) requestReceivePhonons(payload []byte) error {
 		Payload: payload,
 	}
 	c.logger.Debug("Requesting Receive Phonons")
-	c.sessionRequestChan <- req
+	err := c.toSession(req)
+	if err != nil {
+		return err
+	}
 	ret := <-req.Ret
 	return ret.Err
 }
@@ -112,57 +214,165 @@ func (c *RemoteConnection) requestGetName() (string, error) {
 		Ret: make(chan model.ResponseGetName),
 	}
 	c.logger.Debug("Requesting Name")
-	c.sessionRequestChan <- req
+	err := c.toSession(req)
+	if err != nil {
+		return "", err
+	}
 	ret := <-req.Ret
 	return ret.Name, ret.Err
 }
 
+// toSession passes req to the card session, unless the connection is closed first
+func (c *RemoteConnection) toSession(req model.SessionRequest) error {
+	select {
+	case c.sessionRequestChan <- req:
+		return nil
+	case <-c.ctx.Done():
+		return ErrConnectionClosed
+	}
+}
+
 func (c *RemoteConnection) requestPairWithRemote(card model.CounterpartyPhononCard) error {
 	req := &model.RequestPairWithRemote{
 		Ret:  make(chan model.ResponsePairWithRemote),
 		Card: card,
 	}
 	c.logger.Debug("Requesting pairing")
-	c.sessionRequestChan <- req
+	err := c.toSession(req)
+	if err != nil {
+		return err
+	}
 	ret := <-req.Ret
 	return ret.Err
 }
 
-func Connect(sessReqChan chan model.SessionRequest, url string, ignoreTLS bool) (*RemoteConnection, error) {
-	d := &h2conn.Client{
-		Client: &http.Client{
-			Transport: &http2.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: ignoreTLS}},
-		},
+// Option configures a RemoteConnection created by Connect
+type Option func(*connectOptions)
+
+type connectOptions struct {
+	maxMessageSize        uint64
+	identifyNonceSize     int
+	requireSchema         bool
+	serverKey             *ecdsa.PublicKey
+	reconnect             *BackoffPolicy
+	timeouts              Timeouts
+	tlsConfig             *tls.Config
+	serverCertFingerprint []byte
+	caPubKey              []byte
+	codec                 v1.Codec
+}
+
+// WithMaxMessageSize sets the largest message accepted from the jump server.
+// A larger message is rejected with ErrMessageTooLarge before it is buffered and the connection is closed.
+func WithMaxMessageSize(size uint64) Option {
+	return func(o *connectOptions) {
+		o.maxMessageSize = size
 	}
+}
 
-	conn, resp, err := d.Connect(context.Background(), url) //url)
-	if err != nil {
-		return nil, fmt.Errorf("unable to connect to remote server %e,", err)
+/*
+WithCodec offers the jump server codec as the wire format in place of gob, such as v1.CBORCodec so that
+the connection can be read by peers not written in Go. The codec is offered in the v1.CodecHeader when dialing,
+and messages and their payloads use gob unless the server echoes it.
+Messages decoded by a codec other than gob are bounded by the codec rather than WithMaxMessageSize.
+*/
+func WithCodec(codec v1.Codec) Option {
+	return func(o *connectOptions) {
+		o.codec = codec
 	}
-	if resp.StatusCode != http.StatusOK {
-		log.Error("received bad status from jumpbox. err: ", resp.Status)
+}
+
+// WithIdentifyNonceSize sets the length of the challenge the counterparty card signs in Identify.
+// Sizes below MinIdentifyNonceSize are rejected by Connect with ErrNonceTooShort.
+// The current phonon applet only signs 32 byte challenges, so this is for applets with other requirements.
+func WithIdentifyNonceSize(size int) Option {
+	return func(o *connectOptions) {
+		o.identifyNonceSize = size
+	}
+}
+
+// WithRequiredSchema fails Connect with v1.ErrSchemaMismatch unless the jump server reports the same message schema.
+// Without it, a server too old to report its schema is trusted to match.
+func WithRequiredSchema() Option {
+	return func(o *connectOptions) {
+		o.requireSchema = true
+	}
+}
+
+// WithServerKey fails Connect with v1.ErrServerAuthFailed unless the jump server signs a challenge with the given key,
+// bound to the TLS connection. This authenticates the server even when TLS verification is skipped, and the card
+// only identifies itself to the server once it has.
+func WithServerKey(pubKey *ecdsa.PublicKey) Option {
+	return func(o *connectOptions) {
+		o.serverKey = pubKey
 	}
+}
+
+// WithCAPubKey sets the CA whose certificates GetCertificate and Identify accept from the counterparty, see cert.SelectCACertByName
+func WithCAPubKey(caPubKey []byte) Option {
+	return func(o *connectOptions) {
+		o.caPubKey = caPubKey
+	}
+}
+
+/*
+Connect dials the jump server at url and identifies the local card with it.
 
-	client := &RemoteConnection{
-		conn:                     conn,
-		out:                      gob.NewEncoder(conn),
-		in:                       gob.NewDecoder(conn),
+The server's certificate is verified against the system roots unless WithTLSConfig or WithServerCertFingerprint
+say otherwise. ignoreTLS skips verification entirely, which lets anyone able to intercept the connection pose
+as the jump server, so it is only for local development and can't be combined with those options.
+*/
+func Connect(sessReqChan chan model.SessionRequest, url string, ignoreTLS bool, opts ...Option) (client *RemoteConnection, err error) {
+	defer observeHandshake(handshakeServer, time.Now(), &err)
+	options := connectOptions{
+		maxMessageSize:    DefaultMaxMessageSize,
+		identifyNonceSize: DefaultIdentifyNonceSize,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.identifyNonceSize < MinIdentifyNonceSize {
+		return nil, ErrNonceTooShort
+	}
+	if options.codec == nil {
+		options.codec = v1.GobCodec
+	}
+	tlsConfig, err := options.clientTLSConfig(ignoreTLS)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		if err != nil {
+			cancel()
+		}
+	}()
+	client = &RemoteConnection{
+		ctx:                      ctx,
+		cancel:                   cancel,
+		url:                      url,
+		transport:                &http2.Transport{TLSClientConfig: tlsConfig},
+		maxMessageSize:           options.maxMessageSize,
 		remoteCertificate:        nil,
 		localCertificate:         nil,
 		sessionRequestChan:       sessReqChan,
 		identifiedWithServerChan: make(chan bool, 1),
 		identifiedWithServer:     false,
-		counterpartyNonce:        [32]byte{},
+		identifyNonceSize:        options.identifyNonceSize,
 		verified:                 false,
-		connectedToCardChan:      make(chan bool, 1),
-		verifyPairedChan:         make(chan string),
-		remoteCertificateChan:    make(chan cert.CardCertificate, 1),
 		remoteIdentityChan:       make(chan []byte, 1),
-		cardPair1DataChan:        make(chan []byte, 1),
-		finalizeCardPairDataChan: make(chan []byte, 1),
 		pairingStatus:            model.StatusUnconnected,
 		logger:                   log.WithField("cardID", "unknown"),
-		phononAckChan:            make(chan bool, 1),
+		waiters:                  make(map[waiterKey]waiter),
+		helloAckChan:             make(chan v1.Hello, 1),
+		codec:                    options.codec,
+		requireSchema:            options.requireSchema,
+		serverKey:                options.serverKey,
+		caPubKey:                 options.caPubKey,
+		reconnect:                options.reconnect,
+		events:                   make(chan RemoteEvent, eventBuffer),
+		timeouts:                 options.timeouts,
+		done:                     make(chan struct{}),
 	}
 
 	name, err := client.requestGetName()
@@ -170,50 +380,313 @@ func Connect(sessReqChan chan model.SessionRequest, url string, ignoreTLS bool)
 		return nil, err
 	}
 	client.logger = log.WithField("cardID", name)
-	//First send the client cert to kick off connection validation
-	client.logger.Debugf("certificate: % X", client.localCertificate)
+	client.cardID = name
 	client.localCertificate, err = client.getLocalCertificate()
 	if err != nil {
 		client.logger.Error("could not fetch certificate from card: ", err)
 		return nil, err
 	}
 	client.logger.Debug("client has crt: ", client.localCertificate)
-	msg := v1.Message{
+
+	err = client.establish()
+	if err != nil {
+		return nil, err
+	}
+	go client.HandleIncoming()
+	client.pairingStatus = model.StatusConnectedToBridge
+	client.connectedAt = time.Now()
+	register(client)
+	metrics.Inc(metrics.RemoteConnectionsOpened, nil)
+	return client, nil
+}
+
+/*
+establish dials the jump server, identifies the local card with it and negotiates features. Incoming messages
+are read in the background from then on, and the error that ends them is sent on readErr.
+The connection is closed again if any step fails.
+*/
+func (c *RemoteConnection) establish() error {
+	d := &h2conn.Client{
+		Client: &http.Client{
+			Transport: c.transport,
+		},
+	}
+	offerCodec := c.codec != nil && c.codec != v1.GobCodec
+	if offerCodec {
+		d.Header = http.Header{v1.CodecHeader: []string{c.codec.Name()}}
+	}
+	//closing an h2conn.Conn only ends the request body, so reads are unblocked by cancelling the request
+	connCtx, connCancel := context.WithCancel(c.ctx)
+	conn, resp, err := d.Connect(connCtx, c.url)
+	if err != nil {
+		connCancel()
+		return fmt.Errorf("unable to connect to remote server %e,", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Error("received bad status from jumpbox. err: ", resp.Status)
+	}
+	c.emit(EventConnected, nil)
+	closeConn := func() {
+		conn.Close()
+		connCancel()
+	}
+	var wireCodec v1.Codec
+	if offerCodec && resp.Header.Get(v1.CodecHeader) == c.codec.Name() {
+		wireCodec = c.codec
+	} else if offerCodec {
+		c.logger.Debugf("server did not agree to the %v codec, continuing with gob", c.codec.Name())
+	}
+	var in v1.Decoder
+	var frames *frameLimitReader
+	if wireCodec != nil {
+		in = wireCodec.NewDecoder(conn)
+	} else {
+		frames = newFrameLimitReader(conn, c.maxMessageSize)
+		in = gob.NewDecoder(frames)
+	}
+	readErr := make(chan error, 1)
+	lost := make(chan struct{})
+	c.connMtex.Lock()
+	if c.ctx.Err() != nil {
+		//Close was called while dialing
+		c.connMtex.Unlock()
+		closeConn()
+		return ErrConnectionClosed
+	}
+	c.conn = conn
+	c.connCancel = connCancel
+	c.connLost = lost
+	c.out = gob.NewEncoder(conn)
+	if wireCodec != nil {
+		c.out = wireCodec.NewEncoder(conn)
+	}
+	c.wireCodec = wireCodec
+	c.compression.Store(false)
+	c.connMtex.Unlock()
+	c.readErr = readErr
+	c.identifiedWithServer = false
+	//drop signals left over from a previous connection
+	select {
+	case <-c.identifiedWithServerChan:
+	default:
+	}
+	select {
+	case <-c.helloAckChan:
+	default:
+	}
+
+	go c.readMessages(conn, in, frames, lost, readErr)
+	//the card only identifies itself to a server that has proven its identity
+	if c.serverKey != nil {
+		err = c.authenticateServer(resp.TLS, readErr)
+		if err != nil {
+			c.logger.Error("unable to authenticate jump server: ", err)
+			closeConn()
+			return err
+		}
+	}
+	//send the client cert to kick off connection validation
+	err = c.encode(&v1.Message{
 		Name:    v1.ResponseCertificate,
-		Payload: client.localCertificate.Serialize(),
+		Payload: c.localCertificate.Serialize(),
+	})
+	if err != nil {
+		c.logger.Error("unable to send cert to jump server. err: ", err)
+		closeConn()
+		return err
 	}
-	err = client.out.Encode(msg)
+
+	select {
+	case <-c.identifiedWithServerChan:
+	case err = <-readErr:
+		closeConn()
+		return err
+	case <-time.After(c.timeouts.withDefaults().Identify):
+		closeConn()
+		return fmt.Errorf("verification with server timed out")
+	}
+
+	err = c.negotiateFeatures()
 	if err != nil {
-		client.logger.Error("unable to send cert to jump server. err: ", err)
-		return nil, err
+		c.logger.Error("unable to complete hello with jump server: ", err)
+		closeConn()
+		return err
 	}
+	return nil
+}
 
-	go client.HandleIncoming()
+// helloTimeout is how long to wait for a HelloAck before assuming the server predates the Hello handshake
+const helloTimeout = 2 * time.Second
 
+// negotiateFeatures offers compression to the server and checks both sides share a message schema.
+// Nothing else may be sent until the HelloAck arrives since the server expects compressed payloads from then on.
+func (c *RemoteConnection) negotiateFeatures() error {
+	hello := v1.Hello{Compression: true, SchemaFingerprint: v1.SchemaFingerprint()}
+	payload, err := c.payloadCodec().Marshal(hello)
+	if err != nil {
+		c.logger.Error("unable to encode hello: ", err)
+		return err
+	}
+	err = c.encode(&v1.Message{Name: v1.MessageHello, Payload: payload})
+	if err != nil {
+		c.logger.Error("unable to send hello: ", err)
+		return err
+	}
 	select {
-	case <-client.identifiedWithServerChan:
-	case <-time.After(time.Second * 10):
-		return nil, fmt.Errorf("verification with server timed out")
+	case agreed := <-c.helloAckChan:
+		return c.checkSchema(agreed)
+	case <-time.After(helloTimeout):
+		c.logger.Debug("server did not acknowledge hello, continuing without compression")
+		return c.checkSchema(v1.Hello{})
 	}
+}
 
-	client.pairingStatus = model.StatusConnectedToBridge
-	return client, nil
+/*
+authenticateServer challenges the jump server to sign a fresh challenge with the configured server key,
+bound to the TLS connection described by state. A server relaying the signature of another over a separate
+TLS connection, or one without the key, fails with v1.ErrServerAuthFailed.
+*/
+func (c *RemoteConnection) authenticateServer(state *tls.ConnectionState, readErr <-chan error) error {
+	binding, err := v1.TLSBinding(state)
+	if err != nil {
+		return err
+	}
+	challenge := make([]byte, v1.ServerChallengeSize)
+	err = util.ReadRandom(challenge)
+	if err != nil {
+		return err
+	}
+	id := c.newRequestID()
+	resp := c.await(v1.ResponseServerAuth, id)
+	defer c.stopAwaiting(v1.ResponseServerAuth, id)
+	err = c.sendRequest(id, v1.RequestServerAuth, challenge)
+	if err != nil {
+		return err
+	}
+	select {
+	case msg := <-resp:
+		return c.checkServerSignature(challenge, binding, msg.Payload)
+	case err = <-readErr:
+		return err
+	case <-time.After(c.timeouts.withDefaults().Identify):
+		return ErrTimeout
+	}
+}
+
+// checkServerSignature returns v1.ErrServerAuthFailed unless sig is the configured server key's signature
+// over challenge and the TLS binding
+func (c *RemoteConnection) checkServerSignature(challenge []byte, binding []byte, sig []byte) error {
+	err := v1.VerifyServerChallenge(c.serverKey, challenge, binding, sig)
+	if err != nil {
+		return fmt.Errorf("%w: expected key %s", err, util.ECCPubKeyToHexString(c.serverKey))
+	}
+	c.logger.Debug("authenticated jump server")
+	return nil
+}
+
+// checkSchema returns v1.ErrSchemaMismatch if the server's HelloAck reports a different schema,
+// or no schema when one is required
+func (c *RemoteConnection) checkSchema(agreed v1.Hello) error {
+	if !agreed.SchemaMatches() || (c.requireSchema && len(agreed.SchemaFingerprint) == 0) {
+		return fmt.Errorf("%w: server fingerprint % X", v1.ErrSchemaMismatch, agreed.SchemaFingerprint)
+	}
+	c.logger.Debug("negotiated compression: ", agreed.Compression)
+	return nil
+}
+
+func (c *RemoteConnection) processHelloAck(msg v1.Message) {
+	var agreed v1.Hello
+	err := c.payloadCodec().Unmarshal(msg.Payload, &agreed)
+	if err != nil {
+		c.logger.Error("unable to decode hello ack: ", err)
+		return
+	}
+	//a server with another schema agrees to nothing, but don't rely on it
+	c.compression.Store(agreed.Compression && agreed.SchemaMatches())
+	select {
+	case c.helloAckChan <- agreed:
+	default:
+	}
 }
 
+/*
+HandleIncoming runs until the connection to the jump server is lost for good. Each time the connection drops
+the OnDisconnect callback is called, and if reconnection is enabled with WithReconnect the server is dialed
+again and the local card identified anew. Once the connection can't be restored it is marked closed and Closed fires.
+*/
 func (c *RemoteConnection) HandleIncoming() {
+	for {
+		err := <-c.readErr
+		c.notifyDisconnect(err)
+		if c.ctx.Err() != nil {
+			break
+		}
+		c.emit(EventError, err)
+		if c.reconnect == nil {
+			break
+		}
+		//the jump server forgets the counterparty along with the connection, so pairing must be redone
+		c.pairingStatus = model.StatusUnconnected
+		err = c.reconnect.retry(c.ctx, c.establish)
+		if err != nil {
+			c.logger.Error("unable to reconnect to jump server: ", err)
+			break
+		}
+		c.logger.Info("reconnected to jump server")
+		c.pairingStatus = model.StatusConnectedToBridge
+		c.connectedAt = time.Now()
+	}
+	c.pairingStatus = model.StatusUnconnected
+	unregister(c)
+	metrics.Inc(metrics.RemoteConnectionsClosed, nil)
+	close(c.done)
+}
+
+/*
+readMessages processes messages decoded by in until the connection fails, then closes lost and sends the error on readErr.
+With gob, in reads from frames, and a message that can't be decoded is logged and skipped, since frames delivers
+messages whole and the next one can still be read. A type definition that can't be decoded leaves the decoder unable
+to read the values that follow it, so the connection is closed and ErrCorruptStream is sent instead.
+
+Other codecs read from conn directly and frames is nil. Messages they report as v1.ErrMalformedMessage are skipped.
+*/
+func (c *RemoteConnection) readMessages(conn io.ReadCloser, in v1.Decoder, frames *frameLimitReader, lost chan<- struct{}, readErr chan<- error) {
+	framed := frames != nil
 	var err error
-	message := v1.Message{}
-	err = c.in.Decode(&message)
-	for err == nil {
+	for {
+		message := v1.Message{}
+		err = in.Decode(&message)
+		if framed && frames.err != nil {
+			err = frames.err
+			break
+		}
+		if framed && err != nil && frames.typeDefinition {
+			err = fmt.Errorf("%w: %v", ErrCorruptStream, err)
+			break
+		}
+		if !framed && err != nil && !errors.Is(err, v1.ErrMalformedMessage) {
+			break
+		}
+		if err == nil && c.compression.Load() {
+			message.Payload, err = v1.DecompressPayload(message.Payload)
+		}
+		if err != nil {
+			c.logger.Error("skipping malformed message: ", err)
+			continue
+		}
 		c.process(message)
-		message = v1.Message{}
-		err = c.in.Decode(&message)
 	}
 	c.logger.Printf("Error decoding message: %s", err.Error())
-	c.pairingStatus = model.StatusUnconnected
+	if errors.Is(err, ErrMessageTooLarge) || errors.Is(err, ErrCorruptStream) {
+		//the rest of the stream can't be decoded once a message is skipped
+		conn.Close()
+	}
+	close(lost)
+	readErr <- err
 }
 
 func (c *RemoteConnection) process(msg v1.Message) {
+	defer observeMessage(msg.Name, time.Now())
 	c.logger.Debug(fmt.Sprintf("processing %s message", msg.Name))
 	switch msg.Name {
 	case v1.RequestCertificate:
@@ -226,35 +699,111 @@ func (c *RemoteConnection) process(msg v1.Message) {
 		c.processIdentify(msg)
 	case v1.MessageError:
 		c.logger.Error(string(msg.Payload))
+		c.emit(EventError, errors.New(string(msg.Payload)))
 	case v1.MessageIdentifiedWithServer:
 		c.identifiedWithServerChan <- true
 		c.identifiedWithServer = true
+		c.emit(EventIdentifiedWithServer, nil)
+	case v1.MessageHelloAck:
+		c.processHelloAck(msg)
+	case v1.ResponsePing, v1.ResponseServerAuth:
+		c.deliver(msg)
 	case v1.MessageConnectedToCard:
 		c.processConnectedToCard(msg)
 		// Card pairing requests and responses
 	case v1.RequestCardPair1:
 		c.processCardPair1(msg)
 	case v1.ResponseCardPair1:
-		c.cardPair1DataChan <- msg.Payload
+		c.deliver(msg)
 	case v1.RequestFinalizeCardPair:
 		c.processFinalizeCardPair(msg)
 	case v1.ResponseFinalizeCardPair:
-		c.finalizeCardPairDataChan <- msg.Payload
-	case v1.MessagePhononAck:
-		c.phononAckChan <- true
+		c.deliver(msg)
+	case v1.MessagePhononAck, v1.MessagePhononReject:
+		c.deliver(msg)
 	case v1.RequestReceivePhonon:
 		c.processReceivePhonons(msg)
 	case v1.RequestVerifyPaired:
 		c.processRequestVerifyPaired(msg)
 	case v1.MessageDisconnected:
 		c.disconnect()
+	case v1.MessageDuplicateConnection:
+		c.processDuplicateConnection(msg)
 	case v1.RequestDisconnectFromCard:
 		c.disconnectFromCard()
 	case v1.ResponseVerifyPaired:
-		if c.verifyPairedChan != nil {
-			c.verifyPairedChan <- string(msg.Payload)
+		c.deliver(msg)
+	case v1.RequestDryRunTransfer:
+		c.processDryRunTransfer(msg)
+	case v1.ResponseDryRunTransfer:
+		c.deliver(msg)
+	case v1.RequestGenerateInvoice:
+		c.processGenerateInvoice(msg)
+	case v1.RequestReceiveInvoice:
+		c.processReceiveInvoice(msg)
+	case v1.ResponseGenerateInvoice, v1.ResponseReceiveInvoice:
+		c.deliver(msg)
+	case v1.RequestResumePairing:
+		c.processResumePairing(msg)
+	case v1.ResponseResumePairing:
+		c.deliver(msg)
+	}
+}
+
+// newRequestID returns an ID for a request that no other request on this connection uses
+func (c *RemoteConnection) newRequestID() uint64 {
+	return c.lastRequestID.Add(1)
+}
+
+// await registers interest in the message with the given name answering request id
+// and must be called before sending the request
+func (c *RemoteConnection) await(messageName string, id uint64) chan v1.Message {
+	ch := make(chan v1.Message, 1)
+	c.waitersMtex.Lock()
+	c.waiters[waiterKey{messageName, id}] = waiter{ch: ch, since: time.Now()}
+	c.waitersMtex.Unlock()
+	return ch
+}
+
+// stopAwaiting removes a registration made by await, if it is still pending
+func (c *RemoteConnection) stopAwaiting(messageName string, id uint64) {
+	c.waitersMtex.Lock()
+	delete(c.waiters, waiterKey{messageName, id})
+	c.waitersMtex.Unlock()
+}
+
+// deliver hands a response to the request waiting on it. Responses nobody is waiting for are dropped.
+func (c *RemoteConnection) deliver(msg v1.Message) bool {
+	ch, ok := c.takeWaiter(msg.Name, msg.ID)
+	if !ok {
+		c.logger.Debugf("dropping unexpected %s message", msg.Name)
+		return false
+	}
+	ch <- msg
+	return true
+}
+
+/*
+takeWaiter removes and returns the channel of the request waiting on messageName with the given ID.
+Peers that predate request IDs answer with ID zero, which is given to the longest waiting request
+for messageName, as they answer requests in the order they were sent.
+*/
+func (c *RemoteConnection) takeWaiter(messageName string, id uint64) (chan v1.Message, bool) {
+	c.waitersMtex.Lock()
+	defer c.waitersMtex.Unlock()
+	key := waiterKey{messageName, id}
+	w, ok := c.waiters[key]
+	if id == 0 && !ok {
+		for k, candidate := range c.waiters {
+			if k.name == messageName && (!ok || candidate.since.Before(w.since)) {
+				key, w, ok = k, candidate, true
+			}
 		}
 	}
+	if ok {
+		delete(c.waiters, key)
+	}
+	return w.ch, ok
 }
 
 /////
@@ -269,56 +818,121 @@ func (c *RemoteConnection) processConnectedToCard(msg v1.Message) {
 		return
 	}
 	c.remoteCertificate = &counterpartyCert
-	c.connectedToCardChan <- true
 	c.pairingStatus = model.StatusConnectedToCard
+	c.emit(EventConnectedToCard, nil)
+	c.deliver(msg)
 
 }
 
 func (c *RemoteConnection) sendCertificate(msg v1.Message) {
-	c.logger.Debug("caching counterparty certificate")
-	c.sendMessage(v1.ResponseCertificate, c.localCertificate.Serialize())
+	if c.localCertificate == nil || c.localCertificate.PubKey == nil {
+		c.logger.Error("Cert doesn't exist")
+		c.sendError(ErrCertificateUnavailable)
+		return
+	}
+	c.sendReply(msg, v1.ResponseCertificate, c.localCertificate.Serialize())
 }
 
 func (c *RemoteConnection) sendIdentify(msg v1.Message) {
 	_, sig, err := c.requestIdentifyCard(msg.Payload)
 	if err != nil {
 		c.logger.Error("Issue identifying local card", err.Error())
+		c.sendError(ErrIdentifyFailed)
 		return
 	}
-	payload := []byte{}
-	buf := bytes.NewBuffer(payload)
-	enc := gob.NewEncoder(buf)
-	enc.Encode(sig)
-	c.sendMessage(v1.ResponseIdentify, buf.Bytes())
+	if sig == nil {
+		c.logger.Error("local card returned no identify signature")
+		c.sendError(ErrIdentifyFailed)
+		return
+	}
+	payload, err := c.payloadCodec().Marshal(sig)
+	if err != nil {
+		c.logger.Error("unable to encode identify signature: ", err)
+		c.sendError(ErrIdentifyFailed)
+		return
+	}
+	c.sendReply(msg, v1.ResponseIdentify, payload)
 }
 
+// processIdentify checks the counterparty's answer to Identify, releasing Identify once it is verified.
+// An answer that fails verification is only logged, leaving Identify to time out.
 func (c *RemoteConnection) processIdentify(msg v1.Message) {
-	key, sig, err := card.ParseIdentifyCardResponse(msg.Payload)
+	err := c.verifyIdentity(msg.Payload)
 	if err != nil {
-		c.logger.Error("Issue parsing identify card response", err.Error())
+		c.logger.Error("Unable to verify card challenge: ", err)
 		return
 	}
-	if !ecdsa.Verify(key, c.counterpartyNonce[:], sig.R, sig.S) {
-		c.logger.Error("Unable to verify card challenge")
-		return
-	} else {
-		c.verified = true
-		return
+	c.verified = true
+	select {
+	case c.remoteIdentityChan <- msg.Payload:
+	default:
+	}
+}
+
+// verifyCounterpartyCertificate returns ErrCounterpartyCertInvalid unless remoteCert was issued by the trusted CA
+func (c *RemoteConnection) verifyCounterpartyCertificate(remoteCert *cert.CardCertificate) error {
+	caPubKey := c.caPubKey
+	if caPubKey == nil {
+		caPubKey = DefaultCAPubKey
+	}
+	key, err := util.ParseECCPubKey(caPubKey)
+	if err != nil {
+		return err
+	}
+	err = remoteCert.Verify(key)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCounterpartyCertInvalid, err)
+	}
+	return nil
+}
+
+/*
+verifyIdentity checks payload is the counterparty card's signature over the challenge sent by Identify.
+The signing key is taken from the counterparty's certificate, which must be issued by the trusted CA,
+never from the response itself, so a server relaying the exchange can't substitute its own key.
+*/
+func (c *RemoteConnection) verifyIdentity(payload []byte) error {
+	if len(c.counterpartyNonce) == 0 {
+		return fmt.Errorf("%w: no challenge outstanding", ErrIdentityUnverified)
+	}
+	if c.remoteCertificate == nil {
+		return fmt.Errorf("%w: counterparty certificate unknown", ErrIdentityUnverified)
+	}
+	err := c.verifyCounterpartyCertificate(c.remoteCertificate)
+	if err != nil {
+		return err
+	}
+	key, err := util.ParseECCPubKey(c.remoteCertificate.PubKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCounterpartyCertInvalid, err)
+	}
+	var sig util.ECDSASignature
+	err = c.payloadCodec().Unmarshal(payload, &sig)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIdentityUnverified, err)
 	}
+	if sig.R == nil || sig.S == nil || !ecdsa.Verify(key, c.counterpartyNonce, sig.R, sig.S) {
+		return ErrIdentityUnverified
+	}
+	return nil
 }
 
 func (c *RemoteConnection) processCardPair1(msg v1.Message) {
 	if c.pairingStatus != model.StatusConnectedToCard {
 		c.logger.Error("Card either not connected to a card or already paired")
+		c.sendError(ErrNotConnectedToCard)
 		return
 	}
+	c.emit(EventCardPairStarted, nil)
 	cardPairData, err := c.requestCardPair1(msg.Payload)
 	if err != nil {
 		c.logger.Error("error with card pair 1", err.Error())
+		c.emit(EventError, err)
+		c.sendError(ErrCardPairFailed)
 		return
 	}
 	c.pairingStatus = model.StatusCardPair1Complete
-	c.sendMessage(v1.ResponseCardPair1, cardPairData)
+	c.sendReply(msg, v1.ResponseCardPair1, cardPairData)
 
 }
 
@@ -330,11 +944,13 @@ func (c *RemoteConnection) processFinalizeCardPair(msg v1.Message) {
 	err := c.requestFinalizeCardPair(msg.Payload)
 	if err != nil {
 		c.logger.Error("Error finalizing Card Pair", err.Error())
-		c.sendMessage(v1.ResponseFinalizeCardPair, []byte(err.Error()))
+		c.emit(EventError, err)
+		c.sendReply(msg, v1.ResponseFinalizeCardPair, []byte(err.Error()))
 		return
 	}
-	c.sendMessage(v1.ResponseFinalizeCardPair, []byte{})
+	c.sendReply(msg, v1.ResponseFinalizeCardPair, []byte{})
 	c.pairingStatus = model.StatusPaired
+	c.emit(EventCardPairFinalized, nil)
 	//c.finalizeCardPairErrorChan <- err
 }
 
@@ -343,9 +959,34 @@ func (c *RemoteConnection) processReceivePhonons(msg v1.Message) {
 	err := c.requestReceivePhonons(msg.Payload)
 	if err != nil {
 		c.logger.Error(err.Error())
+		countTransfer(transferReceived, resultRejected)
+		c.emit(EventError, err)
+		c.rejectPhonons(msg, err)
+		c.notifyListener(err)
+		return
+	}
+	countTransfer(transferReceived, resultAccepted)
+	c.emit(EventPhononsReceived, nil)
+	c.sendReply(msg, v1.MessagePhononAck, []byte{})
+	//only once the sender has its answer, so a listener closing the connection doesn't drop it
+	c.notifyListener(nil)
+}
+
+// rejectPhonons tells the sender of req why the transfer was refused
+func (c *RemoteConnection) rejectPhonons(req v1.Message, err error) {
+	reject := v1.PhononReject{
+		Reason:  v1.RejectReasonUnspecified,
+		Message: err.Error(),
+	}
+	if errors.Is(err, card.ErrPhononTableFull) || errors.Is(err, card.ErrOutOfMemory) {
+		reject.Reason = v1.RejectReasonInsufficientStorage
+	}
+	payload, err := reject.Encode(c.payloadCodec())
+	if err != nil {
+		c.logger.Error("unable to encode phonon rejection: ", err)
 		return
 	}
-	c.sendMessage(v1.MessagePhononAck, []byte{})
+	c.sendReply(req, v1.MessagePhononReject, payload)
 }
 
 // ProcessProvideCertificate is for adding a remote card's certificate to the remote portion of the struct
@@ -356,21 +997,47 @@ func (c *RemoteConnection) receiveCertificate(msg v1.Message) {
 		return
 	}
 	c.logger.Debug("Remote Certificate received")
-	c.remoteCertificateChan <- remoteCert
+	c.remoteCertificate = &remoteCert
+	c.deliver(msg)
 }
 
 /////
 // Below are the methods that satisfy the interface for remote counterparty
 /////
+// Identify challenges the counterparty card to sign a random nonce with its identity key.
+// It fails with ErrCounterpartyCertInvalid unless the counterparty's certificate was issued by the trusted CA,
+// see WithCAPubKey. An answer that doesn't match the certified key is never accepted, so Identify fails with ErrTimeout.
 func (c *RemoteConnection) Identify() error {
-	var nonce [32]byte
-	rand.Read(nonce[:])
+	size := c.identifyNonceSize
+	if size == 0 {
+		size = DefaultIdentifyNonceSize
+	}
+	if size < MinIdentifyNonceSize {
+		return ErrNonceTooShort
+	}
+	nonce := make([]byte, size)
+	//a predictable challenge would let a recorded identify response be replayed
+	err := util.ReadRandom(nonce)
+	if err != nil {
+		return fmt.Errorf("unable to generate identify challenge: %w", err)
+	}
+	//the answer is checked against the key in the counterparty's certificate
+	_, err = c.GetCertificate()
+	if err != nil {
+		return err
+	}
+	c.verified = false
 	c.counterpartyNonce = nonce
-	c.sendMessage(v1.RequestIdentify, nonce[:])
+	err = c.sendRequest(c.newRequestID(), v1.RequestIdentify, nonce)
+	if err != nil {
+		return err
+	}
 	select {
 	case <-c.remoteIdentityChan:
 		return nil
-	case <-time.After(10 * time.Second):
+	case <-c.lost():
+		return ErrConnectionLost
+	case <-time.After(c.timeouts.withDefaults().Identify):
 		return ErrTimeout
 
 	}
@@ -378,11 +1045,21 @@ func (c *RemoteConnection) Identify() error {
 
 func (c *RemoteConnection) CardPair(initPairingData []byte) (cardPairData []byte, err error) {
 	c.logger.Debug("card pair initiated")
-	c.sendMessage(v1.RequestCardPair1, initPairingData)
+	id := c.newRequestID()
+	resp := c.await(v1.ResponseCardPair1, id)
+	defer c.stopAwaiting(v1.ResponseCardPair1, id)
+	err = c.sendRequest(id, v1.RequestCardPair1, initPairingData)
+	if err != nil {
+		return []byte{}, err
+	}
+	c.emit(EventCardPairStarted, nil)
 	select {
-	case cardPairData := <-c.cardPair1DataChan:
-		return cardPairData, nil
-	case <-time.After(10 * time.Second):
+	case msg := <-resp:
+		return msg.Payload, nil
+	case <-c.lost():
+		return []byte{}, ErrConnectionLost
+	case <-time.After(c.timeouts.withDefaults().CardPair):
+		c.emit(EventError, ErrTimeout)
 		return []byte{}, ErrTimeout
 	}
 }
@@ -393,17 +1070,29 @@ func (c *RemoteConnection) CardPair2(cardPairData []byte) (cardPairData2 []byte,
 }
 
 func (c *RemoteConnection) FinalizeCardPair(cardPair2Data []byte) error {
-	c.sendMessage(v1.RequestFinalizeCardPair, cardPair2Data)
+	id := c.newRequestID()
+	resp := c.await(v1.ResponseFinalizeCardPair, id)
+	defer c.stopAwaiting(v1.ResponseFinalizeCardPair, id)
+	err := c.sendRequest(id, v1.RequestFinalizeCardPair, cardPair2Data)
+	if err != nil {
+		return err
+	}
 	if !(c.pairingStatus == model.StatusPaired) {
 		select {
-		case errorbytes := <-c.finalizeCardPairDataChan:
+		case msg := <-resp:
 			var err error
-			if len(errorbytes) > 0 {
-				return errors.New(string(errorbytes))
+			if len(msg.Payload) > 0 {
+				err = errors.New(string(msg.Payload))
+				c.emit(EventError, err)
+				return err
 			} else {
+				c.emit(EventCardPairFinalized, nil)
 				return err
 			}
-		case <-time.After(10 * time.Second):
+		case <-c.lost():
+			return ErrConnectionLost
+		case <-time.After(c.timeouts.withDefaults().Finalize):
+			c.emit(EventError, ErrTimeout)
 			return ErrTimeout
 		}
 	}
@@ -411,14 +1100,23 @@ func (c *RemoteConnection) FinalizeCardPair(cardPair2Data []byte) error {
 	return nil
 }
 
+// GetCertificate returns the counterparty card's certificate, or ErrCounterpartyCertInvalid if it wasn't issued
+// by the trusted CA, see WithCAPubKey
 func (c *RemoteConnection) GetCertificate() (*cert.CardCertificate, error) {
 	if c.remoteCertificate == nil {
 		c.logger.Debug("remote certificate not cached, requesting it")
-		c.sendMessage(v1.RequestCertificate, []byte{})
+		id := c.newRequestID()
+		resp := c.await(v1.ResponseCertificate, id)
+		defer c.stopAwaiting(v1.ResponseCertificate, id)
+		err := c.sendRequest(id, v1.RequestCertificate, []byte{})
+		if err != nil {
+			return nil, err
+		}
 		select {
-		case cert := <-c.remoteCertificateChan:
-			c.remoteCertificate = &cert
-		case <-time.After(10 * time.Second):
+		case <-resp:
+		case <-c.lost():
+			return nil, ErrConnectionLost
+		case <-time.After(c.timeouts.withDefaults().Certificate):
 			c.logger.Debug("Certificate request timed out")
 			return nil, ErrTimeout
 		}
@@ -426,20 +1124,36 @@ func (c *RemoteConnection) GetCertificate() (*cert.CardCertificate, error) {
 	} else {
 		c.logger.Debugf("returning cached remote certificate: % X", c.remoteCertificate.Serialize())
 	}
+	//the certificate is relayed by the jump server, so it's only trusted once checked against the CA
+	err := c.verifyCounterpartyCertificate(c.remoteCertificate)
+	if err != nil {
+		return nil, err
+	}
 	return c.remoteCertificate, nil
 }
 
-func (c *RemoteConnection) ConnectToCard(cardID string) error {
+func (c *RemoteConnection) ConnectToCard(cardID string) (err error) {
+	defer observeHandshake(handshakeCard, time.Now(), &err)
 	c.logger.Info("sending requestConnectCard2Card message")
-	c.sendMessage(v1.RequestConnectCard2Card, []byte(cardID))
-	var err error
+	id := c.newRequestID()
+	resp := c.await(v1.MessageConnectedToCard, id)
+	defer c.stopAwaiting(v1.MessageConnectedToCard, id)
+	err = c.sendRequest(id, v1.RequestConnectCard2Card, []byte(cardID))
+	if err != nil {
+		return err
+	}
 	select {
-	case <-time.After(10 * time.Second):
+	case <-c.lost():
+		return ErrConnectionLost
+	case <-time.After(c.timeouts.withDefaults().ConnectToCard):
 		c.logger.Error("Connection Timed out Waiting for peer")
-		c.conn.Close()
+		c.Close()
 		err = ErrTimeout
 		return err
-	case <-c.connectedToCardChan:
+	case msg := <-resp:
+		if msg.Name == v1.MessageDuplicateConnection {
+			return ErrDuplicateConnection
+		}
 		c.pairingStatus = model.StatusConnectedToCard
 		err = nil
 	}
@@ -450,56 +1164,134 @@ func (c *RemoteConnection) ConnectToCard(cardID string) error {
 	return nil
 }
 
+/*
+ReceivePhonons sends a transfer packet to the counterparty and waits for it to be accepted or rejected.
+The packet for the whole batch goes out as a single message, so a transfer costs one round trip however many
+phonons it carries. The connection to the jump server is an ordered, reliable stream, so the packet is never
+split into chunks and there is no acknowledgment window to tune. The largest batch is bounded by the
+counterparty's maximum message size, see WithMaxMessageSize.
+
+With no chunks there is nothing to verify piecewise, so the packet carries no Merkle root over its phonons.
+Its contents are encrypted by the sending card for the paired card, so neither client can read the descriptors
+to hash them, and the receiving card checks the packet as a whole when it is stored.
+*/
 func (c *RemoteConnection) ReceivePhonons(PhononTransfer []byte) error {
-	c.sendMessage(v1.RequestReceivePhonon, PhononTransfer)
+	id := c.newRequestID()
+	resp := c.await(v1.MessagePhononAck, id)
+	defer c.stopAwaiting(v1.MessagePhononAck, id)
+	reject := c.await(v1.MessagePhononReject, id)
+	defer c.stopAwaiting(v1.MessagePhononReject, id)
+	err := c.sendRequest(id, v1.RequestReceivePhonon, PhononTransfer)
+	if err != nil {
+		return err
+	}
 	select {
-	case <-time.After(10 * time.Second):
+	case <-c.lost():
+		return ErrConnectionLost
+	case <-time.After(c.timeouts.withDefaults().PhononAck):
 		c.logger.Error("unable to verify remote recipt of phonons")
+		countTransfer(transferSent, resultTimeout)
 		return ErrTimeout
-	case <-c.phononAckChan:
+	case <-resp:
+		countTransfer(transferSent, resultAccepted)
 		return nil
+	case msg := <-reject:
+		countTransfer(transferSent, resultRejected)
+		r, err := v1.DecodePhononReject(c.payloadCodec(), msg.Payload)
+		if err != nil {
+			c.logger.Error("unable to decode phonon rejection: ", err)
+			return &PhononRejectedError{Reason: v1.RejectReasonUnspecified}
+		}
+		return &PhononRejectedError{Reason: r.Reason, Message: r.Message}
 	}
 }
 
-func (c *RemoteConnection) GenerateInvoice() (invoiceData []byte, err error) {
-	// todo:
-	return
+// Utility functions
+func (c *RemoteConnection) sendMessage(messageName string, messagePayload []byte) error {
+	return c.sendRequest(0, messageName, messagePayload)
 }
 
-func (c *RemoteConnection) ReceiveInvoice(invoiceData []byte) error {
-	// todo:
-	return nil
+// sendReply answers req, echoing its ID so the counterparty can match the response to its request
+func (c *RemoteConnection) sendReply(req v1.Message, messageName string, messagePayload []byte) error {
+	return c.sendRequest(req.ID, messageName, messagePayload)
 }
 
-// Utility functions
-func (c *RemoteConnection) sendMessage(messageName string, messagePayload []byte) {
+// sendRequest sends a message carrying the given request ID
+func (c *RemoteConnection) sendRequest(id uint64, messageName string, messagePayload []byte) error {
 	c.logger.Debug(messageName, string(messagePayload))
 
 	tosend := &v1.Message{
 		Name:    messageName,
 		Payload: messagePayload,
+		ID:      id,
+	}
+	err := c.encode(tosend)
+	if err != nil {
+		c.logger.Errorf("unable to send %s message: %v", messageName, err)
+	}
+	return err
+}
+
+// payloadCodec returns the codec payloads are encoded with on the current connection
+func (c *RemoteConnection) payloadCodec() v1.Codec {
+	c.connMtex.Lock()
+	defer c.connMtex.Unlock()
+	if c.wireCodec != nil {
+		return c.wireCodec
 	}
-	c.out.Encode(tosend)
+	return v1.GobCodec
+}
+
+// encode sends a message to the server, compressing the payload if negotiated.
+// It returns ErrConnectionClosed once the connection has been closed with Close.
+func (c *RemoteConnection) encode(msg *v1.Message) error {
+	if c.ctx.Err() != nil {
+		return ErrConnectionClosed
+	}
+	if c.compression.Load() {
+		payload, err := v1.CompressPayload(msg.Payload)
+		if err != nil {
+			return err
+		}
+		msg = &v1.Message{Name: msg.Name, Payload: payload, ID: msg.ID}
+	}
+	c.connMtex.Lock()
+	out := c.out
+	c.connMtex.Unlock()
+	c.outMtex.Lock()
+	defer c.outMtex.Unlock()
+	return out.Encode(msg)
+}
+
+// sendError reports a failure to handle a request back to the counterparty
+func (c *RemoteConnection) sendError(err error) {
+	c.sendMessage(v1.MessageError, []byte(err.Error()))
 }
 
 func (c *RemoteConnection) VerifyPaired() error {
 	tosend := &v1.Message{
 		Name:    v1.RequestVerifyPaired,
 		Payload: []byte(""),
+		ID:      c.newRequestID(),
+	}
+	resp := c.await(v1.ResponseVerifyPaired, tosend.ID)
+	defer c.stopAwaiting(v1.ResponseVerifyPaired, tosend.ID)
+	err := c.encode(tosend)
+	if err != nil {
+		return err
 	}
-	c.verifyPairedChan = make(chan string)
-	c.out.Encode(tosend)
 
 	var connectedCardID string
 
 	select {
-	case connectedCardID = <-c.verifyPairedChan:
-	case <-time.After(10 * time.Second):
+	case msg := <-resp:
+		connectedCardID = string(msg.Payload)
+	case <-c.lost():
+		return ErrConnectionLost
+	case <-time.After(c.timeouts.withDefaults().CardPair):
 		return fmt.Errorf("counterparty card not paired to this card")
 	}
-	c.verifyPairedChan = nil
 
-	var err error
 	connectedID, err := c.requestGetName()
 	if err != nil {
 		return err
@@ -515,6 +1307,7 @@ func (c *RemoteConnection) VerifyPaired() error {
 func (c *RemoteConnection) processRequestVerifyPaired(msg v1.Message) {
 	tosend := &v1.Message{
 		Name: v1.ResponseVerifyPaired,
+		ID:   msg.ID,
 	}
 	if c.pairingStatus == model.StatusPaired {
 		if c.remoteCertificate == nil || c.remoteCertificate.PubKey == nil {
@@ -529,13 +1322,24 @@ func (c *RemoteConnection) processRequestVerifyPaired(msg v1.Message) {
 		msg := util.CardIDFromPubKey(key)
 		tosend.Payload = []byte(msg)
 	}
-	c.out.Encode(tosend)
+	c.encode(tosend)
 }
 
 func (c *RemoteConnection) PairingStatus() model.RemotePairingStatus {
 	return c.pairingStatus
 }
 
+// processDuplicateConnection handles the server handing this card's slot to a newer connection,
+// failing a pending ConnectToCard rather than leaving it to time out
+func (c *RemoteConnection) processDuplicateConnection(msg v1.Message) {
+	c.logger.Error("connection replaced by another session for this card: ", string(msg.Payload))
+	c.pairingStatus = model.StatusUnconnected
+	ch, ok := c.takeWaiter(v1.MessageConnectedToCard, msg.ID)
+	if ok {
+		ch <- msg
+	}
+}
+
 func (c *RemoteConnection) disconnect() {
 	c.pairingStatus = model.StatusUnconnected
 }
@@ -543,3 +1347,36 @@ func (c *RemoteConnection) disconnect() {
 func (c *RemoteConnection) disconnectFromCard() {
 	c.pairingStatus = model.StatusConnectedToBridge
 }
+
+// lost returns a channel closed once the current connection to the jump server stops delivering messages
+func (c *RemoteConnection) lost() <-chan struct{} {
+	c.connMtex.Lock()
+	defer c.connMtex.Unlock()
+	return c.connLost
+}
+
+// closeConn drops the current connection to the jump server, which is redialed if reconnection is enabled
+func (c *RemoteConnection) closeConn() error {
+	c.connMtex.Lock()
+	defer c.connMtex.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	c.connCancel()
+	return c.conn.Close()
+}
+
+/*
+Close disconnects from the jump server without reconnecting and waits for incoming messages to stop being handled.
+Requests from the counterparty still waiting on the local session are abandoned, and sending anything
+afterwards fails with ErrConnectionClosed. Closing more than once has no further effect.
+*/
+func (c *RemoteConnection) Close() error {
+	c.cancel()
+	err := c.closeConn()
+	<-c.done
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
+	}
+	return err
+}
@@ -0,0 +1,1100 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/gob"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GridPlus/phonon-client/card"
+	"github.com/GridPlus/phonon-client/cert"
+	"github.com/GridPlus/phonon-client/model"
+	v1 "github.com/GridPlus/phonon-client/remote/v1"
+	"github.com/GridPlus/phonon-client/util"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/posener/h2conn"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+)
+
+// loopback decodes every message the client sends and answers it synchronously,
+// so responses are processed before sendMessage even returns to the request method
+type loopback struct {
+	buf     bytes.Buffer
+	dec     *gob.Decoder
+	c       *RemoteConnection
+	respond func(v1.Message) *v1.Message
+}
+
+func (l *loopback) Write(p []byte) (int, error) {
+	n, err := l.buf.Write(p)
+	if err != nil {
+		return n, err
+	}
+	var msg v1.Message
+	err = l.dec.Decode(&msg)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// only a type definition was written so far
+		return n, nil
+	}
+	if err != nil {
+		return n, err
+	}
+	if resp := l.respond(msg); resp != nil {
+		l.c.process(*resp)
+	}
+	return n, nil
+}
+
+func newLoopbackConnection(respond func(v1.Message) *v1.Message) *RemoteConnection {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &RemoteConnection{
+		ctx:                ctx,
+		cancel:             cancel,
+		remoteIdentityChan: make(chan []byte, 1),
+		pairingStatus:      model.StatusConnectedToCard,
+		logger:             log.WithField("cardID", "test"),
+		waiters:            make(map[waiterKey]waiter),
+	}
+	l := &loopback{c: c, respond: respond}
+	l.dec = gob.NewDecoder(&l.buf)
+	c.out = gob.NewEncoder(l)
+	return c
+}
+
+func TestFastResponseIsNotLost(t *testing.T) {
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+		switch msg.Name {
+		case v1.RequestCardPair1:
+			return &v1.Message{Name: v1.ResponseCardPair1, Payload: []byte("pairData")}
+		case v1.RequestReceivePhonon:
+			return &v1.Message{Name: v1.MessagePhononAck}
+		case v1.RequestFinalizeCardPair:
+			return &v1.Message{Name: v1.ResponseFinalizeCardPair}
+		}
+		return nil
+	})
+
+	cardPairData, err := c.CardPair([]byte("init"))
+	if err != nil {
+		t.Fatal("card pair response was lost: ", err)
+	}
+	if string(cardPairData) != "pairData" {
+		t.Errorf("unexpected card pair data: %s", cardPairData)
+	}
+	err = c.FinalizeCardPair([]byte("pair2"))
+	if err != nil {
+		t.Fatal("finalize card pair response was lost: ", err)
+	}
+	err = c.ReceivePhonons([]byte("transfer"))
+	if err != nil {
+		t.Fatal("phonon ack was lost: ", err)
+	}
+}
+
+func TestUnexpectedResponseIsDropped(t *testing.T) {
+	c := newLoopbackConnection(func(v1.Message) *v1.Message { return nil })
+	if c.deliver(v1.Message{Name: v1.MessagePhononAck}) {
+		t.Error("expected unsolicited ack to be dropped")
+	}
+	if len(c.waiters) != 0 {
+		t.Error("unsolicited message registered a waiter")
+	}
+}
+
+func TestConcurrentRequestsAreMatchedByID(t *testing.T) {
+	requests := make(chan v1.Message, 2)
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+		requests <- msg
+		return nil
+	})
+	type result struct {
+		sent     string
+		received string
+		err      error
+	}
+	results := make(chan result, 2)
+	for _, data := range []string{"first", "second"} {
+		go func(data string) {
+			resp, err := c.CardPair([]byte(data))
+			results <- result{data, string(resp), err}
+		}(data)
+	}
+	first, second := <-requests, <-requests
+	if first.ID == 0 || first.ID == second.ID {
+		t.Fatalf("expected distinct request IDs, got %d and %d", first.ID, second.ID)
+	}
+	//answer in the opposite order to the requests
+	for _, req := range []v1.Message{second, first} {
+		c.process(v1.Message{Name: v1.ResponseCardPair1, Payload: append([]byte("paired "), req.Payload...), ID: req.ID})
+	}
+	for i := 0; i < 2; i++ {
+		r := <-results
+		if r.err != nil {
+			t.Fatal("card pair failed: ", r.err)
+		}
+		if r.received != "paired "+r.sent {
+			t.Errorf("request %q got the response %q", r.sent, r.received)
+		}
+	}
+}
+
+func TestHandlersReportErrors(t *testing.T) {
+	var sent []v1.Message
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+		sent = append(sent, msg)
+		return nil
+	})
+
+	c.sendCertificate(v1.Message{Name: v1.RequestCertificate})
+	c.pairingStatus = model.StatusPaired
+	c.processCardPair1(v1.Message{Name: v1.RequestCardPair1})
+
+	expected := []string{ErrCertificateUnavailable.Error(), ErrNotConnectedToCard.Error()}
+	if len(sent) != len(expected) {
+		t.Fatalf("expected %d error messages, got %d", len(expected), len(sent))
+	}
+	for i, msg := range sent {
+		if msg.Name != v1.MessageError || string(msg.Payload) != expected[i] {
+			t.Errorf("expected error message %q, got %s: %q", expected[i], msg.Name, msg.Payload)
+		}
+	}
+}
+
+func TestHelloAckEnablesCompression(t *testing.T) {
+	var sent []v1.Message
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+		sent = append(sent, msg)
+		return nil
+	})
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v1.Hello{Compression: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.process(v1.Message{Name: v1.MessageHelloAck, Payload: buf.Bytes()})
+	if !c.compression.Load() {
+		t.Fatal("expected compression to be enabled by the hello ack")
+	}
+
+	payload := bytes.Repeat([]byte("transfer"), 100)
+	c.sendMessage(v1.RequestReceivePhonon, payload)
+	if len(sent) != 1 {
+		t.Fatalf("expected 1 message sent, got %d", len(sent))
+	}
+	decompressed, err := v1.DecompressPayload(sent[0].Payload)
+	if err != nil {
+		t.Fatal("payload was not compressed: ", err)
+	}
+	if !bytes.Equal(decompressed, payload) {
+		t.Error("payload did not survive compression")
+	}
+}
+
+func TestHelloAckWithCodec(t *testing.T) {
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message { return nil })
+	c.helloAckChan = make(chan v1.Hello, 1)
+	c.wireCodec = v1.CBORCodec
+	payload, err := v1.CBORCodec.Marshal(v1.Hello{Compression: true, SchemaFingerprint: v1.SchemaFingerprint()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.process(v1.Message{Name: v1.MessageHelloAck, Payload: payload})
+	if !c.compression.Load() {
+		t.Error("expected a cbor encoded HelloAck to be decoded with the connection's codec")
+	}
+}
+
+func TestHelloAckSchemaMismatch(t *testing.T) {
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message { return nil })
+	c.helloAckChan = make(chan v1.Hello, 1)
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v1.Hello{Compression: true, SchemaFingerprint: []byte{0x01}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.process(v1.Message{Name: v1.MessageHelloAck, Payload: buf.Bytes()})
+	if c.compression.Load() {
+		t.Error("expected compression to stay disabled with a server using another schema")
+	}
+	err = c.checkSchema(<-c.helloAckChan)
+	if !errors.Is(err, v1.ErrSchemaMismatch) {
+		t.Errorf("expected %v, got %v", v1.ErrSchemaMismatch, err)
+	}
+
+	err = c.checkSchema(v1.Hello{})
+	if err != nil {
+		t.Errorf("expected a server not reporting its schema to be accepted, got %v", err)
+	}
+	c.requireSchema = true
+	err = c.checkSchema(v1.Hello{})
+	if !errors.Is(err, v1.ErrSchemaMismatch) {
+		t.Errorf("expected %v when a schema is required, got %v", v1.ErrSchemaMismatch, err)
+	}
+	err = c.checkSchema(v1.Hello{SchemaFingerprint: v1.SchemaFingerprint()})
+	if err != nil {
+		t.Errorf("expected a matching schema to be accepted, got %v", err)
+	}
+}
+
+func TestServerSignature(t *testing.T) {
+	key, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message { return nil })
+	c.serverKey = &key.PublicKey
+	challenge := []byte("a challenge the client generated")
+	binding := []byte("keying material of the client's TLS connection")
+	sig, err := v1.SignServerChallenge(key, challenge, binding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.checkServerSignature(challenge, binding, sig)
+	if err != nil {
+		t.Errorf("expected the server's signature to be accepted, got %v", err)
+	}
+	err = c.checkServerSignature(challenge, binding, nil)
+	if !errors.Is(err, v1.ErrServerAuthFailed) {
+		t.Errorf("expected %v from a server not signing the challenge, got %v", v1.ErrServerAuthFailed, err)
+	}
+	err = c.checkServerSignature(challenge, []byte("keying material of a relay's TLS connection"), sig)
+	if !errors.Is(err, v1.ErrServerAuthFailed) {
+		t.Errorf("expected %v from a relayed signature, got %v", v1.ErrServerAuthFailed, err)
+	}
+	rogue, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err = v1.SignServerChallenge(rogue, challenge, binding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.checkServerSignature(challenge, binding, sig)
+	if !errors.Is(err, v1.ErrServerAuthFailed) {
+		t.Errorf("expected %v from a server signing with another key, got %v", v1.ErrServerAuthFailed, err)
+	}
+}
+
+func TestRejectedPhononsReturnReason(t *testing.T) {
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+		if msg.Name != v1.RequestReceivePhonon {
+			return nil
+		}
+		payload, err := v1.PhononReject{Reason: v1.RejectReasonInsufficientStorage, Message: "phonon table full"}.Encode(v1.GobCodec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &v1.Message{Name: v1.MessagePhononReject, Payload: payload}
+	})
+
+	err := c.ReceivePhonons([]byte("transfer"))
+	var rejected *PhononRejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("expected a PhononRejectedError, got %v", err)
+	}
+	if rejected.Reason != v1.RejectReasonInsufficientStorage || rejected.Message != "phonon table full" {
+		t.Errorf("unexpected rejection: %+v", rejected)
+	}
+}
+
+func TestDryRunTransfer(t *testing.T) {
+	var reason string
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+		if msg.Name != v1.RequestDryRunTransfer {
+			return nil
+		}
+		return &v1.Message{Name: v1.ResponseDryRunTransfer, Payload: []byte(reason)}
+	})
+
+	err := c.DryRunTransfer()
+	if err != nil {
+		t.Fatal("expected dry run to succeed, got ", err)
+	}
+	reason = "not paired"
+	err = c.DryRunTransfer()
+	if !errors.Is(err, ErrCounterpartyNotReady) {
+		t.Errorf("expected ErrCounterpartyNotReady, got %v", err)
+	}
+}
+
+func TestDryRunTransferRefusedWhenUnpaired(t *testing.T) {
+	var answer *v1.Message
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+		if msg.Name == v1.ResponseDryRunTransfer {
+			answer = &msg
+		}
+		return nil
+	})
+
+	c.process(v1.Message{Name: v1.RequestDryRunTransfer})
+	if answer == nil {
+		t.Fatal("expected a dry run response")
+	}
+	if len(answer.Payload) == 0 {
+		t.Error("expected unpaired connection to refuse the dry run")
+	}
+}
+
+func TestResumePairing(t *testing.T) {
+	var reason string
+	var token []byte
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+		if msg.Name != v1.RequestResumePairing {
+			return nil
+		}
+		token = msg.Payload
+		return &v1.Message{Name: v1.ResponseResumePairing, Payload: []byte(reason)}
+	})
+
+	reason = "resumption token does not match the last card pairing"
+	err := c.ResumePairing([]byte("token"))
+	if !errors.Is(err, ErrResumptionRejected) {
+		t.Errorf("expected ErrResumptionRejected, got %v", err)
+	}
+	if c.pairingStatus != model.StatusConnectedToCard {
+		t.Error("expected a rejected resumption to leave the cards unpaired")
+	}
+	reason = ""
+	err = c.ResumePairing([]byte("token"))
+	if err != nil {
+		t.Fatal("expected resumption to succeed, got ", err)
+	}
+	if string(token) != "token" || c.pairingStatus != model.StatusPaired {
+		t.Errorf("expected the token to be sent and the cards paired, sent %q with status %v", token, c.pairingStatus)
+	}
+	err = c.ResumePairing([]byte("token"))
+	if err != ErrNotConnectedToCard {
+		t.Errorf("expected resuming an already paired connection to fail, got %v", err)
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("entropy unavailable")
+}
+
+func TestIdentifyChallenge(t *testing.T) {
+	var challenge []byte
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+		if msg.Name == v1.RequestIdentify {
+			challenge = msg.Payload
+		}
+		return nil
+	})
+	c.identifyNonceSize = 48
+	caKey, err := ethcrypto.ToECDSA(cert.PhononMockCAPrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, c.remoteCertificate = newCounterpartyCard(t, caKey)
+	c.caPubKey = cert.PhononMockCAPubKey
+	c.remoteIdentityChan <- nil
+	err = c.Identify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(challenge) != 48 || bytes.Equal(challenge, make([]byte, 48)) {
+		t.Errorf("expected a random 48 byte challenge, got % X", challenge)
+	}
+
+	challenge = nil
+	restore := util.SetRandForTesting(failingReader{})
+	defer restore()
+	err = c.Identify()
+	if err == nil {
+		t.Error("expected identify to fail without entropy")
+	}
+	if challenge != nil {
+		t.Errorf("expected no challenge to be sent without entropy, sent % X", challenge)
+	}
+}
+
+func TestDuplicateConnectionFailsConnectToCard(t *testing.T) {
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+		if msg.Name == v1.RequestConnectCard2Card {
+			return &v1.Message{Name: v1.MessageDuplicateConnection}
+		}
+		return nil
+	})
+	err := c.ConnectToCard("counterparty")
+	if err != ErrDuplicateConnection {
+		t.Errorf("expected %v, got %v", ErrDuplicateConnection, err)
+	}
+	if c.PairingStatus() != model.StatusUnconnected {
+		t.Errorf("expected connection to be marked unconnected, got %v", c.PairingStatus())
+	}
+}
+
+func TestStatusReportsPendingResponses(t *testing.T) {
+	c := newLoopbackConnection(func(v1.Message) *v1.Message { return nil })
+	c.cardID = "local"
+	c.connectedAt = time.Now().Add(-time.Minute)
+	c.await(v1.ResponseCardPair1, 1)
+	register(c)
+	defer unregister(c)
+
+	var status *ConnectionStatus
+	for _, s := range Status() {
+		if s.CardID == "local" {
+			s := s
+			status = &s
+		}
+	}
+	if status == nil {
+		t.Fatal("registered connection missing from status")
+	}
+	if status.Stage != model.StatusConnectedToCard || status.ConnectedFor < time.Minute {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if len(status.PendingResponses) != 1 || status.PendingResponses[0].Message != v1.ResponseCardPair1 {
+		t.Errorf("expected a pending %s, got %+v", v1.ResponseCardPair1, status.PendingResponses)
+	}
+
+	c.stopAwaiting(v1.ResponseCardPair1, 1)
+	unregister(c)
+	for _, s := range Status() {
+		if s.CardID == "local" {
+			t.Error("unregistered connection still reported")
+		}
+	}
+}
+
+func TestListenReturnsAfterAcceptedTransfer(t *testing.T) {
+	var repliesMtex sync.Mutex
+	var replies []string
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+		if msg.Name == v1.MessagePhononAck {
+			//a slow write gives a listener notified too early time to hear of the transfer first
+			time.Sleep(50 * time.Millisecond)
+		}
+		repliesMtex.Lock()
+		defer repliesMtex.Unlock()
+		replies = append(replies, msg.Name)
+		return nil
+	})
+	c.sessionRequestChan = make(chan model.SessionRequest)
+	go func() {
+		results := []error{card.ErrPhononTableFull, nil}
+		for _, result := range results {
+			req := (<-c.sessionRequestChan).(*model.RequestReceivePhonons)
+			req.Ret <- model.ResponseReceivePhonons{Err: result}
+		}
+	}()
+
+	events := make(chan ReceiveEvent, 2)
+	listening := make(chan error, 1)
+	go func() {
+		listening <- c.Listen(context.Background(), events)
+	}()
+	for registered := false; !registered; time.Sleep(time.Millisecond) {
+		c.listenerMtex.Lock()
+		registered = c.listener != nil
+		c.listenerMtex.Unlock()
+	}
+
+	c.process(v1.Message{Name: v1.RequestReceivePhonon, Payload: []byte("too many")})
+	if event := <-events; !errors.Is(event.Err, card.ErrPhononTableFull) {
+		t.Errorf("expected refused transfer to be reported, got %v", event.Err)
+	}
+	go c.process(v1.Message{Name: v1.RequestReceivePhonon, Payload: []byte("transfer")})
+	if event := <-events; event.Err != nil {
+		t.Errorf("expected accepted transfer, got %v", event.Err)
+	}
+	//the sender must have its ack by the time the listener hears of the transfer
+	repliesMtex.Lock()
+	if len(replies) != 2 || replies[1] != v1.MessagePhononAck {
+		t.Errorf("expected the transfer to be acknowledged before it was reported, sent %v", replies)
+	}
+	repliesMtex.Unlock()
+	select {
+	case err := <-listening:
+		if err != nil {
+			t.Error("listen failed: ", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("listen did not return after an accepted transfer")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Listen(ctx, nil); err != context.Canceled {
+		t.Errorf("expected cancelled listen to return %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestDisconnectRetriesThenCloses(t *testing.T) {
+	c := newLoopbackConnection(func(v1.Message) *v1.Message { return nil })
+	//nothing listens on port 1, so every reconnection attempt is refused
+	c.url = "https://127.0.0.1:1/phonon"
+	c.transport = &http2.Transport{}
+	c.readErr = make(chan error, 1)
+	c.done = make(chan struct{})
+	c.reconnect = &BackoffPolicy{InitialDelay: time.Millisecond, Multiplier: 1, MaxAttempts: 2}
+	disconnects := make(chan error, 1)
+	c.OnDisconnect(func(err error) {
+		disconnects <- err
+	})
+	go c.HandleIncoming()
+
+	c.readErr <- io.EOF
+	select {
+	case err := <-disconnects:
+		if err != io.EOF {
+			t.Errorf("expected disconnect with %v, got %v", io.EOF, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("disconnect callback not called")
+	}
+	select {
+	case <-c.Closed():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed after reconnection attempts ran out")
+	}
+	if c.pairingStatus != model.StatusUnconnected {
+		t.Errorf("expected status %v after closing, got %v", model.StatusUnconnected, c.pairingStatus)
+	}
+}
+
+func TestConfiguredTimeout(t *testing.T) {
+	c := newLoopbackConnection(func(v1.Message) *v1.Message { return nil })
+	c.timeouts = Timeouts{CardPair: time.Millisecond}
+	start := time.Now()
+	_, err := c.CardPair([]byte("init"))
+	if err != ErrTimeout {
+		t.Errorf("expected %v from unanswered card pair, got %v", ErrTimeout, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("card pair waited %v despite 1ms timeout", elapsed)
+	}
+}
+
+func TestInvoiceRequests(t *testing.T) {
+	var received []byte
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+		var result v1.InvoiceResult
+		switch msg.Name {
+		case v1.RequestGenerateInvoice:
+			result.Invoice = []byte("invoice")
+		case v1.RequestReceiveInvoice:
+			received = msg.Payload
+			result.Error = "no invoice expected"
+		default:
+			return nil
+		}
+		payload, err := result.Encode(v1.GobCodec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &v1.Message{Name: msg.Name + "Response", Payload: payload}
+	})
+	if _, err := c.GenerateInvoice(); err != ErrNotConnectedToCard {
+		t.Errorf("expected %v before pairing, got %v", ErrNotConnectedToCard, err)
+	}
+	c.pairingStatus = model.StatusPaired
+
+	invoice, err := c.GenerateInvoice()
+	if err != nil {
+		t.Fatal("unable to generate invoice: ", err)
+	}
+	if !bytes.Equal(invoice, []byte("invoice")) {
+		t.Errorf("expected invoice %q, got %q", "invoice", invoice)
+	}
+	err = c.ReceiveInvoice([]byte("theirs"))
+	if !errors.Is(err, ErrInvoiceFailed) {
+		t.Errorf("expected %v, got %v", ErrInvoiceFailed, err)
+	}
+	if !bytes.Equal(received, []byte("theirs")) {
+		t.Errorf("expected counterparty to be sent the invoice, got %q", received)
+	}
+}
+
+func TestProcessGenerateInvoice(t *testing.T) {
+	responses := make(chan v1.Message, 1)
+	c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+		responses <- msg
+		return nil
+	})
+	c.pairingStatus = model.StatusPaired
+	c.sessionRequestChan = make(chan model.SessionRequest)
+	go func() {
+		req := (<-c.sessionRequestChan).(*model.RequestGenerateInvoice)
+		req.Ret <- model.ResponseGenerateInvoice{Payload: []byte("invoice")}
+	}()
+
+	c.process(v1.Message{Name: v1.RequestGenerateInvoice})
+	msg := <-responses
+	if msg.Name != v1.ResponseGenerateInvoice {
+		t.Fatalf("expected %s, got %s", v1.ResponseGenerateInvoice, msg.Name)
+	}
+	result, err := v1.DecodeInvoiceResult(v1.GobCodec, msg.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Error != "" || !bytes.Equal(result.Invoice, []byte("invoice")) {
+		t.Errorf("unexpected invoice result %+v", result)
+	}
+}
+
+// newFakeJumpServer identifies every client and acknowledges its hello, answering pings if answerPings is set
+// and ignoring everything else
+func newFakeJumpServer(answerPings bool) *httptest.Server {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		codec := v1.GobCodec
+		if offered, ok := v1.CodecByName(r.Header.Get(v1.CodecHeader)); ok {
+			codec = offered
+			w.Header().Set(v1.CodecHeader, codec.Name())
+		}
+		conn, err := h2conn.Accept(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		in, out := codec.NewDecoder(conn), codec.NewEncoder(conn)
+		for {
+			var msg v1.Message
+			if in.Decode(&msg) != nil {
+				return
+			}
+			switch msg.Name {
+			case v1.ResponseCertificate:
+				out.Encode(v1.Message{Name: v1.MessageIdentifiedWithServer})
+			case v1.MessageHello:
+				var offered v1.Hello
+				if codec.Unmarshal(msg.Payload, &offered) != nil {
+					return
+				}
+				payload, _ := codec.Marshal(v1.Hello{SchemaFingerprint: v1.SchemaFingerprint()})
+				out.Encode(v1.Message{Name: v1.MessageHelloAck, Payload: payload})
+			case v1.RequestPing:
+				if answerPings {
+					out.Encode(v1.Message{Name: v1.ResponsePing})
+				}
+			}
+		}
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	return srv
+}
+
+// serveSession answers the requests Connect makes of the local session, and accepts transfers, until done is closed
+func serveSession(requests chan model.SessionRequest, done chan struct{}) {
+	for {
+		select {
+		case r := <-requests:
+			switch req := r.(type) {
+			case *model.RequestGetName:
+				req.Ret <- model.ResponseGetName{Name: "test"}
+			case *model.RequestCertificate:
+				req.Ret <- model.ResponseCertificate{Payload: &cert.CardCertificate{}}
+			case *model.RequestReceivePhonons:
+				req.Ret <- model.ResponseReceivePhonons{}
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+func TestConnectWithCodec(t *testing.T) {
+	srv := newFakeJumpServer(true)
+	defer srv.Close()
+	requests := make(chan model.SessionRequest)
+	done := make(chan struct{})
+	defer close(done)
+	go serveSession(requests, done)
+
+	c, err := Connect(requests, srv.URL, true, WithCodec(v1.CBORCodec))
+	if err != nil {
+		t.Fatal("unable to connect: ", err)
+	}
+	defer c.Close()
+	if c.wireCodec != v1.CBORCodec || c.payloadCodec() != v1.CBORCodec {
+		t.Fatal("expected the connection to use cbor")
+	}
+	err = c.ping(time.Second)
+	if err != nil {
+		t.Error("expected a ping to be answered over cbor, got ", err)
+	}
+}
+
+func TestCloseReleasesGoroutines(t *testing.T) {
+	srv := newFakeJumpServer(true)
+	defer srv.Close()
+	requests := make(chan model.SessionRequest)
+	done := make(chan struct{})
+	defer close(done)
+	go serveSession(requests, done)
+
+	connectAndClose := func() {
+		c, err := Connect(requests, srv.URL, true)
+		if err != nil {
+			t.Fatal("unable to connect: ", err)
+		}
+		err = c.Close()
+		if err != nil {
+			t.Error("unable to close connection: ", err)
+		}
+		select {
+		case <-c.Closed():
+		default:
+			t.Error("expected connection to be closed once Close returns")
+		}
+	}
+	//the first connection starts goroutines the test server keeps for its lifetime
+	connectAndClose()
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		connectAndClose()
+	}
+	after := runtime.NumGoroutine()
+	for deadline := time.Now().Add(2 * time.Second); after > before && time.Now().Before(deadline); after = runtime.NumGoroutine() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after > before {
+		t.Errorf("goroutines grew from %d to %d over 10 connections", before, after)
+	}
+}
+
+func TestSendAfterClose(t *testing.T) {
+	srv := newFakeJumpServer(true)
+	defer srv.Close()
+	requests := make(chan model.SessionRequest)
+	done := make(chan struct{})
+	defer close(done)
+	go serveSession(requests, done)
+
+	c, err := Connect(requests, srv.URL, true)
+	if err != nil {
+		t.Fatal("unable to connect: ", err)
+	}
+	err = c.Close()
+	if err != nil {
+		t.Error("unable to close connection: ", err)
+	}
+	err = c.Close()
+	if err != nil {
+		t.Error("expected closing twice to succeed, got ", err)
+	}
+	err = c.sendMessage(v1.RequestNoOp, []byte{})
+	if err != ErrConnectionClosed {
+		t.Errorf("expected %v sending after close, got %v", ErrConnectionClosed, err)
+	}
+	start := time.Now()
+	err = c.ConnectToCard("counterparty")
+	if err != ErrConnectionClosed {
+		t.Errorf("expected %v connecting to a card after close, got %v", ErrConnectionClosed, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected requests on a closed connection to fail without waiting for a response")
+	}
+}
+
+type brokenWriter struct{}
+
+func (brokenWriter) Write([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestSendFailureReturnsImmediately(t *testing.T) {
+	c := newLoopbackConnection(func(v1.Message) *v1.Message { return nil })
+	c.out = gob.NewEncoder(brokenWriter{})
+	requests := map[string]func() error{
+		"Identify": c.Identify,
+		"CardPair": func() error {
+			_, err := c.CardPair([]byte("init"))
+			return err
+		},
+		"FinalizeCardPair": func() error {
+			return c.FinalizeCardPair([]byte("finalize"))
+		},
+		"GetCertificate": func() error {
+			_, err := c.GetCertificate()
+			return err
+		},
+		"ConnectToCard": func() error {
+			return c.ConnectToCard("counterparty")
+		},
+		"ReceivePhonons": func() error {
+			return c.ReceivePhonons([]byte("transfer"))
+		},
+	}
+	for name, request := range requests {
+		start := time.Now()
+		err := request()
+		if !errors.Is(err, io.ErrClosedPipe) {
+			t.Errorf("expected %s to return %v, got %v", name, io.ErrClosedPipe, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s waited %v after failing to send", name, elapsed)
+		}
+	}
+}
+
+func TestServerVerification(t *testing.T) {
+	srv := newFakeJumpServer(true)
+	defer srv.Close()
+	requests := make(chan model.SessionRequest)
+	done := make(chan struct{})
+	defer close(done)
+	go serveSession(requests, done)
+
+	fingerprint := sha256.Sum256(srv.Certificate().Raw)
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{"pinned fingerprint", []Option{WithServerCertFingerprint(fingerprint[:])}, false},
+		{"wrong fingerprint", []Option{WithServerCertFingerprint(make([]byte, sha256.Size))}, true},
+		{"trusted CA", []Option{WithTLSConfig(&tls.Config{RootCAs: roots})}, false},
+		{"system roots", nil, true},
+		{"trusted CA and pin", []Option{WithTLSConfig(&tls.Config{RootCAs: roots}), WithServerCertFingerprint(fingerprint[:])}, false},
+		{"pin and rejecting verifier", []Option{WithTLSConfig(&tls.Config{RootCAs: roots, VerifyPeerCertificate: rejectPeer}), WithServerCertFingerprint(fingerprint[:])}, true},
+	}
+	for _, test := range tests {
+		c, err := Connect(requests, srv.URL, false, test.opts...)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: expected error %v, got %v", test.name, test.wantErr, err)
+		}
+		if c != nil {
+			c.Close()
+		}
+	}
+
+	_, err := Connect(requests, srv.URL, true, WithServerCertFingerprint(fingerprint[:]))
+	if err != ErrConflictingTLSOptions {
+		t.Errorf("expected %v pinning with ignoreTLS, got %v", ErrConflictingTLSOptions, err)
+	}
+}
+
+func rejectPeer([][]byte, [][]*x509.Certificate) error {
+	return errors.New("rejected")
+}
+
+func TestEvents(t *testing.T) {
+	srv := newFakeJumpServer(true)
+	defer srv.Close()
+	requests := make(chan model.SessionRequest)
+	done := make(chan struct{})
+	defer close(done)
+	go serveSession(requests, done)
+
+	c, err := Connect(requests, srv.URL, true)
+	if err != nil {
+		t.Fatal("unable to connect: ", err)
+	}
+	defer c.Close()
+	c.process(v1.Message{Name: v1.RequestReceivePhonon, Payload: []byte("transfer")})
+	c.process(v1.Message{Name: v1.MessageError, Payload: []byte("counterparty unavailable")})
+
+	expected := []RemoteEventType{EventConnected, EventIdentifiedWithServer, EventPhononsReceived, EventError}
+	for _, eventType := range expected {
+		select {
+		case event := <-c.Events():
+			if event.Type != eventType {
+				t.Errorf("expected %v event, got %v", eventType, event.Type)
+			}
+			if eventType == EventError && (event.Err == nil || event.Err.Error() != "counterparty unavailable") {
+				t.Errorf("expected error event to carry the server's error, got %v", event.Err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %v event", eventType)
+		}
+	}
+}
+
+// newCounterpartyCard returns a card identity key with a certificate for it signed by caKey
+func newCounterpartyCard(t *testing.T, caKey *ecdsa.PrivateKey) (*ecdsa.PrivateKey, *cert.CardCertificate) {
+	cardKey, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := cert.CreateCardCertificate(&cardKey.PublicKey, cert.GetSignerWithPrivateKey(*caKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cardCert, err := cert.ParseRawCardCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cardKey, &cardCert
+}
+
+func TestIdentifyVerifiesCertificate(t *testing.T) {
+	caKey, err := ethcrypto.ToECDSA(cert.PhononMockCAPrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgerKey, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	certifiedKey, certified := newCounterpartyCard(t, caKey)
+	forgedKey, forged := newCounterpartyCard(t, forgerKey)
+
+	tests := []struct {
+		name     string
+		cert     *cert.CardCertificate
+		signer   *ecdsa.PrivateKey
+		wantErr  error
+		verified bool
+	}{
+		{"certified card", certified, certifiedKey, nil, true},
+		{"forged certificate", forged, forgedKey, ErrCounterpartyCertInvalid, false},
+		{"substituted key", certified, forgedKey, ErrTimeout, false},
+	}
+	for _, test := range tests {
+		c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+			switch msg.Name {
+			case v1.RequestCertificate:
+				return &v1.Message{Name: v1.ResponseCertificate, Payload: test.cert.Serialize()}
+			case v1.RequestIdentify:
+				r, s, err := ecdsa.Sign(rand.Reader, test.signer, msg.Payload)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var buf bytes.Buffer
+				err = gob.NewEncoder(&buf).Encode(util.ECDSASignature{R: r, S: s})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return &v1.Message{Name: v1.ResponseIdentify, Payload: buf.Bytes()}
+			}
+			return nil
+		})
+		c.caPubKey = cert.PhononMockCAPubKey
+		c.timeouts.Identify = 100 * time.Millisecond
+
+		start := time.Now()
+		err := c.Identify()
+		if !errors.Is(err, test.wantErr) || (test.wantErr == nil && err != nil) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.wantErr, err)
+		}
+		//a verified answer is reported as soon as it is checked rather than after the identify timeout
+		if elapsed := time.Since(start); test.verified && elapsed >= c.timeouts.Identify {
+			t.Errorf("%s: identify took %v", test.name, elapsed)
+		}
+		if c.verified != test.verified {
+			t.Errorf("%s: expected verified to be %v", test.name, test.verified)
+		}
+	}
+}
+
+func TestGetCertificateVerifiesIssuer(t *testing.T) {
+	caKey, err := ethcrypto.ToECDSA(cert.PhononMockCAPrivKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgerKey, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, certified := newCounterpartyCard(t, caKey)
+	_, forged := newCounterpartyCard(t, forgerKey)
+	for _, test := range []struct {
+		name    string
+		cert    *cert.CardCertificate
+		wantErr error
+	}{
+		{"certified card", certified, nil},
+		{"forged certificate", forged, ErrCounterpartyCertInvalid},
+	} {
+		c := newLoopbackConnection(func(msg v1.Message) *v1.Message {
+			if msg.Name == v1.RequestCertificate {
+				return &v1.Message{Name: v1.ResponseCertificate, Payload: test.cert.Serialize()}
+			}
+			return nil
+		})
+		c.caPubKey = cert.PhononMockCAPubKey
+		remoteCert, err := c.GetCertificate()
+		if !errors.Is(err, test.wantErr) || (test.wantErr == nil && err != nil) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.wantErr, err)
+		}
+		if test.wantErr == nil && (remoteCert == nil || !bytes.Equal(remoteCert.PubKey, test.cert.PubKey)) {
+			t.Errorf("%s: expected the counterparty certificate to be returned", test.name)
+		}
+	}
+}
+
+func TestKeepalive(t *testing.T) {
+	requests := make(chan model.SessionRequest)
+	done := make(chan struct{})
+	defer close(done)
+	go serveSession(requests, done)
+
+	srv := newFakeJumpServer(true)
+	defer srv.Close()
+	c, err := Connect(requests, srv.URL, true)
+	if err != nil {
+		t.Fatal("unable to connect: ", err)
+	}
+	if c.StartKeepalive(0) != ErrInvalidKeepaliveInterval {
+		t.Errorf("expected a zero interval to be rejected with %v", ErrInvalidKeepaliveInterval)
+	}
+	err = c.StartKeepalive(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := c.stopKeepalive
+	err = c.StartKeepalive(20 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-first:
+	default:
+		t.Error("expected the first keepalive to be stopped when it was replaced")
+	}
+	select {
+	case <-c.Closed():
+		t.Error("connection dropped although pings were answered")
+	case <-time.After(200 * time.Millisecond):
+	}
+	c.Close()
+
+	silent := newFakeJumpServer(false)
+	defer silent.Close()
+	c, err = Connect(requests, silent.URL, true)
+	if err != nil {
+		t.Fatal("unable to connect: ", err)
+	}
+	defer c.Close()
+	c.pairingStatus = model.StatusConnectedToCard
+	pending := make(chan error, 1)
+	go func() {
+		_, err := c.CardPair([]byte("init"))
+		pending <- err
+	}()
+	c.StartKeepalive(20 * time.Millisecond)
+	select {
+	case err := <-pending:
+		if err != ErrConnectionLost {
+			t.Errorf("expected pending card pair to fail with %v, got %v", ErrConnectionLost, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pending card pair not failed when pings went unanswered")
+	}
+	select {
+	case <-c.Closed():
+	case <-time.After(time.Second):
+		t.Error("expected connection to be closed when pings went unanswered")
+	}
+}
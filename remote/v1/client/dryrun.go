@@ -0,0 +1,64 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GridPlus/phonon-client/model"
+	v1 "github.com/GridPlus/phonon-client/remote/v1"
+)
+
+var ErrCounterpartyNotReady = errors.New("counterparty not ready to receive phonons")
+
+/*
+DryRunTransfer asks the counterparty to acknowledge it could receive phonons, without sending any.
+The counterparty answers with an empty payload when it is paired and unlocked,
+or with the reason it would refuse a transfer.
+Counterparties that predate dry runs ignore the request, so it fails with ErrTimeout.
+*/
+func (c *RemoteConnection) DryRunTransfer() error {
+	id := c.newRequestID()
+	resp := c.await(v1.ResponseDryRunTransfer, id)
+	defer c.stopAwaiting(v1.ResponseDryRunTransfer, id)
+	err := c.sendRequest(id, v1.RequestDryRunTransfer, []byte{})
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.lost():
+		return ErrConnectionLost
+	case <-time.After(c.timeouts.withDefaults().PhononAck):
+		return ErrTimeout
+	case msg := <-resp:
+		if len(msg.Payload) > 0 {
+			return fmt.Errorf("%w: %s", ErrCounterpartyNotReady, msg.Payload)
+		}
+		return nil
+	}
+}
+
+func (c *RemoteConnection) processDryRunTransfer(msg v1.Message) {
+	var payload []byte
+	err := c.readyToReceive()
+	if err != nil {
+		payload = []byte(err.Error())
+	}
+	c.sendReply(msg, v1.ResponseDryRunTransfer, payload)
+}
+
+func (c *RemoteConnection) readyToReceive() error {
+	if c.pairingStatus != model.StatusPaired {
+		return errors.New("not paired")
+	}
+	req := &model.RequestDryRunTransfer{
+		Ret: make(chan model.ResponseDryRunTransfer),
+	}
+	c.logger.Debug("Requesting dry run transfer check")
+	err := c.toSession(req)
+	if err != nil {
+		return err
+	}
+	ret := <-req.Ret
+	return ret.Err
+}
@@ -0,0 +1,75 @@
+package client
+
+import "time"
+
+// RemoteEventType identifies a step of connecting to the jump server and pairing with a counterparty
+type RemoteEventType int
+
+const (
+	EventConnected            RemoteEventType = iota //connected to the jump server, on first connecting and on each reconnection
+	EventIdentifiedWithServer                        //the jump server accepted the local card's certificate
+	EventConnectedToCard                             //the jump server connected this card to the counterparty
+	EventCardPairStarted                             //the first card pair step was sent or received
+	EventCardPairFinalized                           //the cards are paired
+	EventPhononsReceived                             //the local card accepted a transfer from the counterparty
+	EventError                                       //a step failed, see RemoteEvent.Err
+)
+
+func (t RemoteEventType) String() string {
+	switch t {
+	case EventConnected:
+		return "connected"
+	case EventIdentifiedWithServer:
+		return "identified with server"
+	case EventConnectedToCard:
+		return "connected to card"
+	case EventCardPairStarted:
+		return "card pair started"
+	case EventCardPairFinalized:
+		return "card pair finalized"
+	case EventPhononsReceived:
+		return "phonons received"
+	case EventError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// RemoteEvent reports progress of a RemoteConnection, for showing it in a user interface
+type RemoteEvent struct {
+	Type               RemoteEventType
+	CounterpartyCardID string //empty until connected to a card
+	Err                error
+	Time               time.Time
+}
+
+// eventBuffer is how many events are held for a slow subscriber before further events are dropped
+const eventBuffer = 32
+
+/*
+Events returns the channel the connection reports its progress on. Events are dropped rather than
+holding up the connection if the channel is full, so it should be read promptly.
+The channel is never closed; Closed signals the end of the connection.
+*/
+func (c *RemoteConnection) Events() <-chan RemoteEvent {
+	return c.events
+}
+
+// emit reports an event to the Events channel, if there is room for it
+func (c *RemoteConnection) emit(eventType RemoteEventType, err error) {
+	if c.events == nil {
+		return
+	}
+	event := RemoteEvent{
+		Type:               eventType,
+		CounterpartyCardID: c.counterpartyCardID(),
+		Err:                err,
+		Time:               time.Now(),
+	}
+	select {
+	case c.events <- event:
+	default:
+		c.logger.Debug("events channel full, dropping ", eventType, " event")
+	}
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	v1 "github.com/GridPlus/phonon-client/remote/v1"
+)
+
+// DefaultMaxMessageSize bounds a single message from the jump server unless overridden with WithMaxMessageSize.
+// Phonon transfer packets and certificates are a few kilobytes at most.
+const DefaultMaxMessageSize = 4 * 1024 * 1024
+
+var ErrMessageTooLarge = v1.ErrMessageTooLarge
+var ErrCorruptStream = errors.New("gob type definition from the jump server could not be decoded")
+
+/*
+frameLimitReader sits between the connection and the gob decoder and enforces a maximum message size.
+A gob stream is a sequence of messages each prefixed with its length, so the prefix can be checked
+before the decoder allocates a buffer for the message body.
+
+Each message is read from the connection in full before any of it is passed on, so the decoder only ever
+sees whole messages. A value that fails to decode leaves the stream at the start of the next one and
+can be skipped, while a failure reading the connection itself is kept in err. A type definition that fails
+to decode can't be skipped, since the decoder is left unable to decode the values sent with that type,
+so whether the last message read was a type definition is kept in typeDefinition.
+
+Gob encodes the length as an unsigned integer: values below 128 are a single byte, larger values are
+a byte holding the negated byte count followed by the value in big endian.
+*/
+type frameLimitReader struct {
+	r              io.Reader
+	max            uint64
+	frame          []byte //the rest of the current message, prefix included, not yet passed on to the decoder
+	typeDefinition bool   //the current message defines a type rather than carrying a value
+	err            error  //the error that ended reading from r, if any
+}
+
+func newFrameLimitReader(r io.Reader, max uint64) *frameLimitReader {
+	return &frameLimitReader{
+		r:   r,
+		max: max,
+	}
+}
+
+func (f *frameLimitReader) Read(p []byte) (int, error) {
+	if len(f.frame) == 0 {
+		if f.err != nil {
+			return 0, f.err
+		}
+		err := f.readFrame()
+		if err != nil {
+			f.err = err
+			return 0, err
+		}
+	}
+	n := copy(p, f.frame)
+	f.frame = f.frame[n:]
+	return n, nil
+}
+
+// readFrame reads the next message from r, checking its length before reading the body
+func (f *frameLimitReader) readFrame() error {
+	var first [1]byte
+	_, err := io.ReadFull(f.r, first[:])
+	if err != nil {
+		return err
+	}
+	prefix := first[:]
+	size := uint64(first[0])
+	if first[0] >= 0x80 {
+		//the byte count is the prefix negated as a byte, and a uint64 is at most 8 bytes
+		n := int(^first[0] + 1)
+		if n == 0 || n > 8 {
+			return fmt.Errorf("invalid message length prefix % X", first[0])
+		}
+		buf := make([]byte, n)
+		_, err = io.ReadFull(f.r, buf)
+		if err != nil {
+			return err
+		}
+		prefix = append(prefix, buf...)
+		size = 0
+		for _, b := range buf {
+			size = size<<8 | uint64(b)
+		}
+	}
+	if size > f.max {
+		return ErrMessageTooLarge
+	}
+	frame := make([]byte, len(prefix)+int(size))
+	copy(frame, prefix)
+	_, err = io.ReadFull(f.r, frame[len(prefix):])
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	f.frame = frame
+	f.typeDefinition = isTypeDefinition(frame[len(prefix):])
+	return nil
+}
+
+// isTypeDefinition reports whether a gob message body starts with a negative type ID, which defines that type.
+// The ID is a signed integer, encoded as an unsigned one with the sign in its lowest bit.
+func isTypeDefinition(body []byte) bool {
+	if len(body) == 0 {
+		return false
+	}
+	last := 0
+	if body[0] >= 0x80 {
+		//longer integers are prefixed with their negated byte count, followed by the bytes big endian
+		last = int(^body[0] + 1)
+		if last == 0 || last > 8 || last >= len(body) {
+			return false
+		}
+	}
+	return body[last]&1 == 1
+}
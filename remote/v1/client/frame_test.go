@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"testing"
+
+	v1 "github.com/GridPlus/phonon-client/remote/v1"
+)
+
+func TestMaxMessageSize(t *testing.T) {
+	var stream bytes.Buffer
+	enc := gob.NewEncoder(&stream)
+	for _, size := range []int{10, 1000, 100000} {
+		err := enc.Encode(v1.Message{Name: v1.RequestReceivePhonon, Payload: make([]byte, size)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := gob.NewDecoder(newFrameLimitReader(&stream, 10000))
+	for _, size := range []int{10, 1000} {
+		var msg v1.Message
+		err := dec.Decode(&msg)
+		if err != nil {
+			t.Fatalf("unable to decode %d byte message: %v", size, err)
+		}
+		if len(msg.Payload) != size {
+			t.Errorf("expected %d byte payload, got %d", size, len(msg.Payload))
+		}
+	}
+	var msg v1.Message
+	err := dec.Decode(&msg)
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("expected %v, got %v", ErrMessageTooLarge, err)
+	}
+}
+
+func TestMalformedMessageIsSkipped(t *testing.T) {
+	var stream bytes.Buffer
+	enc := gob.NewEncoder(&stream)
+	err := enc.Encode(v1.Message{Name: v1.MessageError, Payload: []byte("first")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//a well framed value of an undefined type
+	stream.Write([]byte{0x04, 0x7e, 0xff, 0xff, 0xff})
+	err = enc.Encode(v1.Message{Name: v1.ResponseCardPair1, Payload: []byte("second")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newLoopbackConnection(func(v1.Message) *v1.Message { return nil })
+	resp := c.await(v1.ResponseCardPair1, 1)
+	readErr := make(chan error, 1)
+	frames := newFrameLimitReader(&stream, DefaultMaxMessageSize)
+	c.readMessages(io.NopCloser(nil), gob.NewDecoder(frames), frames, make(chan struct{}), readErr)
+
+	select {
+	case msg := <-resp:
+		if !bytes.Equal(msg.Payload, []byte("second")) {
+			t.Errorf("expected payload %q, got %q", "second", msg.Payload)
+		}
+	default:
+		t.Error("message after the malformed one was not processed")
+	}
+	if err := <-readErr; err != io.EOF {
+		t.Errorf("expected reading to end with %v, got %v", io.EOF, err)
+	}
+}
+
+func TestCorruptTypeDefinitionIsFatal(t *testing.T) {
+	var stream bytes.Buffer
+	//a well framed definition of type 64 whose body is not a valid wire type
+	stream.Write([]byte{0x04, 0x7f, 0xff, 0xff, 0xff})
+	err := gob.NewEncoder(&stream).Encode(v1.Message{Name: v1.ResponseCardPair1, Payload: []byte("second")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newLoopbackConnection(func(v1.Message) *v1.Message { return nil })
+	resp := c.await(v1.ResponseCardPair1, 1)
+	readErr := make(chan error, 1)
+	frames := newFrameLimitReader(&stream, DefaultMaxMessageSize)
+	c.readMessages(io.NopCloser(nil), gob.NewDecoder(frames), frames, make(chan struct{}), readErr)
+
+	if err := <-readErr; !errors.Is(err, ErrCorruptStream) {
+		t.Errorf("expected reading to end with %v, got %v", ErrCorruptStream, err)
+	}
+	select {
+	case <-resp:
+		t.Error("message after the corrupt type definition was processed")
+	default:
+	}
+}
+
+func TestIsTypeDefinition(t *testing.T) {
+	cases := []struct {
+		body     []byte
+		expected bool
+	}{
+		{[]byte{0x7f, 0x01}, true},        //-64
+		{[]byte{0x7e, 0x01}, false},       //63
+		{[]byte{0xfe, 0x01, 0x03}, true},  //-130
+		{[]byte{0xfe, 0x01, 0x02}, false}, //129
+		{[]byte{0xfe, 0x01}, false},       //truncated
+		{[]byte{0xff, 0x81}, true},        //-65
+		{[]byte{0xff, 0x80}, false},       //64
+		{[]byte{0xff}, false},             //truncated
+		{[]byte{0x80, 0x01, 0x02}, false}, //byte count of 128
+		{[]byte{0xf7, 0x01, 0x02}, false}, //byte count of 9, more than a uint64 holds
+		{nil, false},
+	}
+	for _, tc := range cases {
+		if isTypeDefinition(tc.body) != tc.expected {
+			t.Errorf("expected % X to be a type definition: %v", tc.body, tc.expected)
+		}
+	}
+}
+
+func TestInvalidLengthPrefix(t *testing.T) {
+	//0x80 would negate to a byte count of 128, and 0xF7 claims 9 bytes, more than a uint64 holds
+	for _, prefix := range []byte{0x80, 0xF7} {
+		r := newFrameLimitReader(bytes.NewReader([]byte{prefix, 0x01, 0x02}), DefaultMaxMessageSize)
+		_, err := r.Read(make([]byte, 16))
+		if err == nil {
+			t.Errorf("expected length prefix % X to be rejected", prefix)
+		}
+	}
+}
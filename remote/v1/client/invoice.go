@@ -0,0 +1,108 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GridPlus/phonon-client/model"
+	v1 "github.com/GridPlus/phonon-client/remote/v1"
+)
+
+var ErrInvoiceFailed = errors.New("counterparty could not handle invoice")
+
+// GenerateInvoice asks the counterparty's card for an invoice, which the local card takes with ReceiveInvoice
+// so that its next transfer can only be received by the card that issued the invoice
+func (c *RemoteConnection) GenerateInvoice() (invoiceData []byte, err error) {
+	result, err := c.invoiceRequest(v1.RequestGenerateInvoice, v1.ResponseGenerateInvoice, []byte{})
+	if err != nil {
+		return nil, err
+	}
+	return result.Invoice, nil
+}
+
+// ReceiveInvoice passes an invoice generated by the local card to the counterparty's card
+func (c *RemoteConnection) ReceiveInvoice(invoiceData []byte) error {
+	_, err := c.invoiceRequest(v1.RequestReceiveInvoice, v1.ResponseReceiveInvoice, invoiceData)
+	return err
+}
+
+// invoiceRequest sends an invoice request to the counterparty and waits for its result
+func (c *RemoteConnection) invoiceRequest(request string, response string, payload []byte) (v1.InvoiceResult, error) {
+	if c.pairingStatus != model.StatusPaired {
+		return v1.InvoiceResult{}, ErrNotConnectedToCard
+	}
+	id := c.newRequestID()
+	resp := c.await(response, id)
+	defer c.stopAwaiting(response, id)
+	err := c.sendRequest(id, request, payload)
+	if err != nil {
+		return v1.InvoiceResult{}, err
+	}
+	select {
+	case <-c.lost():
+		return v1.InvoiceResult{}, ErrConnectionLost
+	case <-time.After(c.timeouts.withDefaults().Invoice):
+		return v1.InvoiceResult{}, ErrTimeout
+	case msg := <-resp:
+		result, err := v1.DecodeInvoiceResult(c.payloadCodec(), msg.Payload)
+		if err != nil {
+			c.logger.Error("unable to decode invoice result: ", err)
+			return v1.InvoiceResult{}, err
+		}
+		if result.Error != "" {
+			return v1.InvoiceResult{}, fmt.Errorf("%w: %s", ErrInvoiceFailed, result.Error)
+		}
+		return result, nil
+	}
+}
+
+func (c *RemoteConnection) processGenerateInvoice(msg v1.Message) {
+	var result v1.InvoiceResult
+	var err error
+	if c.pairingStatus != model.StatusPaired {
+		err = errors.New("not paired")
+	} else {
+		req := &model.RequestGenerateInvoice{
+			Ret: make(chan model.ResponseGenerateInvoice),
+		}
+		c.logger.Debug("Requesting generate invoice")
+		err = c.toSession(req)
+		if err == nil {
+			ret := <-req.Ret
+			result.Invoice, err = ret.Payload, ret.Err
+		}
+	}
+	c.sendInvoiceResult(msg, v1.ResponseGenerateInvoice, result, err)
+}
+
+func (c *RemoteConnection) processReceiveInvoice(msg v1.Message) {
+	var err error
+	if c.pairingStatus != model.StatusPaired {
+		err = errors.New("not paired")
+	} else {
+		req := &model.RequestReceiveInvoice{
+			Ret:     make(chan model.ResponseReceiveInvoice),
+			Payload: msg.Payload,
+		}
+		c.logger.Debug("Requesting receive invoice")
+		err = c.toSession(req)
+		if err == nil {
+			err = (<-req.Ret).Err
+		}
+	}
+	c.sendInvoiceResult(msg, v1.ResponseReceiveInvoice, v1.InvoiceResult{}, err)
+}
+
+func (c *RemoteConnection) sendInvoiceResult(req v1.Message, response string, result v1.InvoiceResult, err error) {
+	if err != nil {
+		c.logger.Error("unable to handle invoice: ", err)
+		result = v1.InvoiceResult{Error: err.Error()}
+	}
+	payload, err := result.Encode(c.payloadCodec())
+	if err != nil {
+		c.logger.Error("unable to encode invoice result: ", err)
+		return
+	}
+	c.sendReply(req, response, payload)
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"errors"
+	"time"
+
+	v1 "github.com/GridPlus/phonon-client/remote/v1"
+)
+
+var ErrConnectionLost = errors.New("connection to the jump server lost")
+var ErrInvalidKeepaliveInterval = errors.New("keepalive interval must be positive")
+
+/*
+StartKeepalive pings the jump server every interval so a connection silently dropped along the way, such as
+by a NAT timing it out, is noticed. If a ping isn't answered before the next is due the connection is presumed
+dead and dropped: operations waiting on it fail with ErrConnectionLost, and it is redialed if reconnection
+is enabled with WithReconnect. Pinging stops once the connection is closed.
+Calling StartKeepalive again replaces the running keepalive with one at the new interval.
+
+The jump server must answer pings, servers that predate them would have every connection dropped.
+*/
+func (c *RemoteConnection) StartKeepalive(interval time.Duration) error {
+	if interval <= 0 {
+		return ErrInvalidKeepaliveInterval
+	}
+	stop := make(chan struct{})
+	c.stopKeepaliveMtex.Lock()
+	if c.stopKeepalive != nil {
+		close(c.stopKeepalive)
+	}
+	c.stopKeepalive = stop
+	c.stopKeepaliveMtex.Unlock()
+	go c.keepalive(interval, stop)
+	return nil
+}
+
+// StopKeepalive stops pinging the jump server, if StartKeepalive was called
+func (c *RemoteConnection) StopKeepalive() {
+	c.stopKeepaliveMtex.Lock()
+	defer c.stopKeepaliveMtex.Unlock()
+	if c.stopKeepalive != nil {
+		close(c.stopKeepalive)
+		c.stopKeepalive = nil
+	}
+}
+
+func (c *RemoteConnection) keepalive(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		case <-c.done:
+			return
+		}
+		err := c.ping(interval)
+		if err == ErrTimeout {
+			c.logger.Error("jump server stopped answering pings, dropping connection")
+			c.closeConn()
+		}
+	}
+}
+
+// ping waits up to grace for the jump server to answer a ping
+func (c *RemoteConnection) ping(grace time.Duration) error {
+	id := c.newRequestID()
+	resp := c.await(v1.ResponsePing, id)
+	defer c.stopAwaiting(v1.ResponsePing, id)
+	err := c.sendRequest(id, v1.RequestPing, []byte{})
+	if err != nil {
+		return err
+	}
+	select {
+	case <-resp:
+		return nil
+	case <-c.lost():
+		return ErrConnectionLost
+	case <-time.After(grace):
+		return ErrTimeout
+	}
+}
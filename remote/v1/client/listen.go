@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrAlreadyListening = errors.New("connection is already listening for transfers")
+var ErrConnectionClosed = errors.New("connection to the jump server closed")
+
+// ReceiveEvent reports an incoming phonon transfer handled by the local card.
+// Err is nil if the card accepted the phonons, otherwise it is the reason the transfer was refused.
+type ReceiveEvent struct {
+	CounterpartyCardID string
+	ReceivedAt         time.Time
+	Err                error
+}
+
+/*
+Listen waits for a counterparty to send phonons over this connection, which must already be
+identified with the jump server. Each incoming transfer is reported on events, if events is not nil,
+and Listen returns once a transfer has been accepted by the local card. Refused transfers are
+reported and listening continues.
+
+Listen returns ctx.Err() if ctx is done first and ErrConnectionClosed if the connection drops.
+Only one Listen may run on a connection at a time.
+*/
+func (c *RemoteConnection) Listen(ctx context.Context, events chan<- ReceiveEvent) error {
+	received := make(chan ReceiveEvent, 1)
+	c.listenerMtex.Lock()
+	if c.listener != nil {
+		c.listenerMtex.Unlock()
+		return ErrAlreadyListening
+	}
+	c.listener = received
+	c.listenerMtex.Unlock()
+	defer func() {
+		c.listenerMtex.Lock()
+		c.listener = nil
+		c.listenerMtex.Unlock()
+	}()
+
+	for {
+		select {
+		case event := <-received:
+			if events != nil {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			if event.Err == nil {
+				return nil
+			}
+		case <-c.done:
+			return ErrConnectionClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// notifyListener passes the outcome of an incoming transfer to Listen, if it is running
+func (c *RemoteConnection) notifyListener(err error) {
+	c.listenerMtex.Lock()
+	defer c.listenerMtex.Unlock()
+	if c.listener == nil {
+		return
+	}
+	event := ReceiveEvent{
+		CounterpartyCardID: c.counterpartyCardID(),
+		ReceivedAt:         time.Now(),
+		Err:                err,
+	}
+	select {
+	case c.listener <- event:
+	default:
+		c.logger.Debug("listener busy, dropping receive event")
+	}
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"time"
+
+	"github.com/GridPlus/phonon-client/metrics"
+)
+
+const (
+	handshakeServer = "server" //identifying with the jump server
+	handshakeCard   = "card"   //connecting to the counterparty card through the jump server
+
+	transferSent     = "sent"
+	transferReceived = "received"
+
+	resultOK       = "ok"
+	resultError    = "error"
+	resultAccepted = "accepted"
+	resultRejected = "rejected"
+	resultTimeout  = "timeout"
+)
+
+// observeHandshake reports how long a handshake stage took and whether it succeeded
+func observeHandshake(stage string, start time.Time, err *error) {
+	result := resultOK
+	if *err != nil {
+		result = resultError
+	}
+	metrics.ObserveSince(metrics.RemoteHandshakeDuration, start, map[string]string{"stage": stage, "result": result})
+}
+
+func countTransfer(direction string, result string) {
+	metrics.Inc(metrics.RemoteTransfers, map[string]string{"direction": direction, "result": result})
+}
+
+// observeMessage reports a message handled by process
+func observeMessage(name string, start time.Time) {
+	labels := map[string]string{"message": name}
+	metrics.Inc(metrics.RemoteMessages, labels)
+	metrics.ObserveSince(metrics.RemoteMessageDuration, start, labels)
+}
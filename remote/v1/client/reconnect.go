@@ -0,0 +1,32 @@
+package client
+
+// WithReconnect redials the jump server according to policy whenever the connection drops after Connect succeeds,
+// identifying the local card with the server again each time. The counterparty must be connected to again with
+// ConnectToCard, after which ResumePairing carries on with a recent pairing. Without it a dropped connection is closed for good.
+func WithReconnect(policy BackoffPolicy) Option {
+	return func(o *connectOptions) {
+		o.reconnect = &policy
+	}
+}
+
+// OnDisconnect sets a function to be called with the error each time the connection to the jump server drops,
+// before any attempt to reconnect. It replaces any function set before.
+func (c *RemoteConnection) OnDisconnect(f func(error)) {
+	c.onDisconnectMtex.Lock()
+	defer c.onDisconnectMtex.Unlock()
+	c.onDisconnect = f
+}
+
+// Closed returns a channel closed once the connection is lost and can't be restored
+func (c *RemoteConnection) Closed() <-chan struct{} {
+	return c.done
+}
+
+func (c *RemoteConnection) notifyDisconnect(err error) {
+	c.onDisconnectMtex.Lock()
+	f := c.onDisconnect
+	c.onDisconnectMtex.Unlock()
+	if f != nil {
+		f(err)
+	}
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GridPlus/phonon-client/model"
+	v1 "github.com/GridPlus/phonon-client/remote/v1"
+)
+
+var ErrResumptionRejected = errors.New("counterparty rejected pairing resumption")
+
+/*
+ResumePairing sends the counterparty the resumption token of the cards' last pairing once ConnectToCard has
+connected them again, so a connection that dropped carries on with that pairing instead of pairing anew.
+The counterparty checks the token against its own and answers with an empty payload if they match,
+or with the reason it refuses. Counterparties that predate resumption ignore the request, so it fails with ErrTimeout.
+*/
+func (c *RemoteConnection) ResumePairing(token []byte) error {
+	if c.pairingStatus != model.StatusConnectedToCard {
+		return ErrNotConnectedToCard
+	}
+	id := c.newRequestID()
+	resp := c.await(v1.ResponseResumePairing, id)
+	defer c.stopAwaiting(v1.ResponseResumePairing, id)
+	err := c.sendRequest(id, v1.RequestResumePairing, token)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.lost():
+		return ErrConnectionLost
+	case <-time.After(c.timeouts.withDefaults().Finalize):
+		return ErrTimeout
+	case msg := <-resp:
+		if len(msg.Payload) > 0 {
+			return fmt.Errorf("%w: %s", ErrResumptionRejected, msg.Payload)
+		}
+		c.pairingStatus = model.StatusPaired
+		c.emit(EventCardPairFinalized, nil)
+		return nil
+	}
+}
+
+func (c *RemoteConnection) processResumePairing(msg v1.Message) {
+	var payload []byte
+	err := c.requestResumePairing(msg.Payload)
+	if err != nil {
+		c.logger.Error("unable to resume card pairing: ", err)
+		payload = []byte(err.Error())
+	} else {
+		c.pairingStatus = model.StatusPaired
+		c.emit(EventCardPairFinalized, nil)
+	}
+	c.sendReply(msg, v1.ResponseResumePairing, payload)
+}
+
+func (c *RemoteConnection) requestResumePairing(token []byte) error {
+	if c.pairingStatus != model.StatusConnectedToCard {
+		return ErrNotConnectedToCard
+	}
+	req := &model.RequestResumePairing{
+		Ret:   make(chan model.ResponseResumePairing),
+		Card:  c,
+		Token: token,
+	}
+	c.logger.Debug("Requesting resume pairing")
+	err := c.toSession(req)
+	if err != nil {
+		return err
+	}
+	ret := <-req.Ret
+	return ret.Err
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GridPlus/phonon-client/model"
+	"github.com/GridPlus/phonon-client/util"
+)
+
+// PendingResponse is a request sent to the counterparty that is still waiting for its answer
+type PendingResponse struct {
+	Message string
+	Waiting time.Duration
+}
+
+/*
+ConnectionStatus is a snapshot of one active RemoteConnection, for operators diagnosing
+transfers that appear stuck. Stage is the connection's pairing status and PendingResponses
+lists the responses it is currently blocked on, longest waiting first.
+*/
+type ConnectionStatus struct {
+	CardID             string
+	CounterpartyCardID string
+	Stage              model.RemotePairingStatus
+	ConnectedFor       time.Duration
+	PendingResponses   []PendingResponse
+}
+
+// connections registers every RemoteConnection between a successful Connect and its connection closing
+var (
+	connections     = make(map[*RemoteConnection]struct{})
+	connectionsMtex sync.Mutex
+)
+
+func register(c *RemoteConnection) {
+	connectionsMtex.Lock()
+	connections[c] = struct{}{}
+	connectionsMtex.Unlock()
+}
+
+func unregister(c *RemoteConnection) {
+	connectionsMtex.Lock()
+	delete(connections, c)
+	connectionsMtex.Unlock()
+}
+
+// Status returns a snapshot of every active remote connection in this process, ordered by card ID
+func Status() []ConnectionStatus {
+	connectionsMtex.Lock()
+	active := make([]*RemoteConnection, 0, len(connections))
+	for c := range connections {
+		active = append(active, c)
+	}
+	connectionsMtex.Unlock()
+
+	now := time.Now()
+	ret := make([]ConnectionStatus, 0, len(active))
+	for _, c := range active {
+		ret = append(ret, c.status(now))
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].CardID < ret[j].CardID
+	})
+	return ret
+}
+
+func (c *RemoteConnection) status(now time.Time) ConnectionStatus {
+	status := ConnectionStatus{
+		CardID:             c.cardID,
+		CounterpartyCardID: c.counterpartyCardID(),
+		Stage:              c.pairingStatus,
+	}
+	if !c.connectedAt.IsZero() {
+		status.ConnectedFor = now.Sub(c.connectedAt)
+	}
+	c.waitersMtex.Lock()
+	for key, w := range c.waiters {
+		status.PendingResponses = append(status.PendingResponses, PendingResponse{
+			Message: key.name,
+			Waiting: now.Sub(w.since),
+		})
+	}
+	c.waitersMtex.Unlock()
+	sort.Slice(status.PendingResponses, func(i, j int) bool {
+		return status.PendingResponses[i].Waiting > status.PendingResponses[j].Waiting
+	})
+	return status
+}
+
+// counterpartyCardID returns the ID of the card this connection is paired with, if its certificate has been received
+func (c *RemoteConnection) counterpartyCardID() string {
+	remoteCert := c.remoteCertificate
+	if remoteCert == nil {
+		return ""
+	}
+	key, err := util.ParseECCPubKey(remoteCert.PubKey)
+	if err != nil {
+		return ""
+	}
+	return util.CardIDFromPubKey(key)
+}
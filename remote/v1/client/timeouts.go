@@ -0,0 +1,53 @@
+package client
+
+import "time"
+
+// Timeouts sets how long a RemoteConnection waits for each response from the jump server or counterparty.
+// A zero field uses the matching field of DefaultTimeouts.
+type Timeouts struct {
+	Identify      time.Duration //identifying with the jump server and challenging the counterparty card
+	CardPair      time.Duration //CardPair and VerifyPaired
+	Finalize      time.Duration //FinalizeCardPair
+	Certificate   time.Duration //fetching the counterparty's certificate
+	ConnectToCard time.Duration //waiting for the counterparty to join
+	PhononAck     time.Duration //the counterparty accepting a transfer or dry run
+	Invoice       time.Duration //the counterparty generating or receiving an invoice
+}
+
+// DefaultTimeouts are used by Connect unless overridden with WithTimeouts
+var DefaultTimeouts = Timeouts{
+	Identify:      10 * time.Second,
+	CardPair:      10 * time.Second,
+	Finalize:      10 * time.Second,
+	Certificate:   10 * time.Second,
+	ConnectToCard: 10 * time.Second,
+	PhononAck:     10 * time.Second,
+	Invoice:       10 * time.Second,
+}
+
+// WithTimeouts replaces the default response timeouts, for instance to allow for a high latency link
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(o *connectOptions) {
+		o.timeouts = timeouts
+	}
+}
+
+func orDefault(d time.Duration, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
+
+// withDefaults returns t with its unset fields taken from DefaultTimeouts
+func (t Timeouts) withDefaults() Timeouts {
+	return Timeouts{
+		Identify:      orDefault(t.Identify, DefaultTimeouts.Identify),
+		CardPair:      orDefault(t.CardPair, DefaultTimeouts.CardPair),
+		Finalize:      orDefault(t.Finalize, DefaultTimeouts.Finalize),
+		Certificate:   orDefault(t.Certificate, DefaultTimeouts.Certificate),
+		ConnectToCard: orDefault(t.ConnectToCard, DefaultTimeouts.ConnectToCard),
+		PhononAck:     orDefault(t.PhononAck, DefaultTimeouts.PhononAck),
+		Invoice:       orDefault(t.Invoice, DefaultTimeouts.Invoice),
+	}
+}
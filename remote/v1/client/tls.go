@@ -0,0 +1,72 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+var ErrConflictingTLSOptions = errors.New("TLS verification options given with ignoreTLS")
+var ErrServerCertMismatch = errors.New("jump server certificate does not match pinned fingerprint")
+
+// WithTLSConfig verifies the jump server, and optionally authenticates the client, with config.
+// For instance setting RootCAs to a private CA pool accepts only servers certified by that CA.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *connectOptions) {
+		o.tlsConfig = config
+	}
+}
+
+/*
+WithServerCertFingerprint pins the jump server's leaf certificate to the SHA-256 hash of its DER encoding.
+On its own the certificate chain isn't checked, so a self signed server certificate can be pinned.
+Combined with WithTLSConfig the chain is verified first and then the pin checked,
+after any VerifyPeerCertificate set in that config.
+*/
+func WithServerCertFingerprint(fingerprint []byte) Option {
+	return func(o *connectOptions) {
+		o.serverCertFingerprint = fingerprint
+	}
+}
+
+// clientTLSConfig builds the TLS configuration for dialing the jump server from the options passed to Connect
+func (o connectOptions) clientTLSConfig(ignoreTLS bool) (*tls.Config, error) {
+	if ignoreTLS {
+		if o.tlsConfig != nil || o.serverCertFingerprint != nil {
+			return nil, ErrConflictingTLSOptions
+		}
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+	config := &tls.Config{}
+	if o.tlsConfig != nil {
+		config = o.tlsConfig.Clone()
+	}
+	if o.serverCertFingerprint != nil {
+		if o.tlsConfig == nil {
+			//the pin replaces chain verification
+			config.InsecureSkipVerify = true
+		}
+		fingerprint := o.serverCertFingerprint
+		verify := config.VerifyPeerCertificate
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if verify != nil {
+				err := verify(rawCerts, verifiedChains)
+				if err != nil {
+					return err
+				}
+			}
+			if len(rawCerts) == 0 {
+				return ErrServerCertMismatch
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			if !bytes.Equal(sum[:], fingerprint) {
+				return fmt.Errorf("%w: got % X", ErrServerCertMismatch, sum)
+			}
+			return nil
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,605 @@
+package v1
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+
+	"github.com/GridPlus/phonon-client/util"
+)
+
+// Encoder writes messages to a connection. A *gob.Encoder is an Encoder.
+type Encoder interface {
+	Encode(e interface{}) error
+}
+
+// Decoder reads messages from a connection. A *gob.Decoder is a Decoder.
+type Decoder interface {
+	Decode(e interface{}) error
+}
+
+/*
+Codec is a wire format for Messages and the structured payloads they carry, such as Hello and InvoiceResult.
+Connections use gob, which only Go peers can read, unless the client names another codec in the CodecHeader
+of its request and the server echoes it in its response, in which case both sides use it from the first message.
+
+Phonon transfer packets and card pairing data are produced by the cards themselves, so they are carried as
+opaque bytes whatever the codec. Structured payloads relayed between clients using different codecs are
+converted by the jump server with TranscodePayload.
+*/
+type Codec interface {
+	Name() string
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// CodecHeader is the HTTP header a client names its codec in when connecting, and the server echoes it in to agree
+const CodecHeader = "Phonon-Codec"
+
+var (
+	// GobCodec encodes messages with encoding/gob, the default
+	GobCodec Codec = gobCodec{}
+	// CBORCodec encodes each message as a CBOR map (RFC 8949) of "name" (text), "payload" (bytes) and "id" (unsigned).
+	// Payload structs are maps keyed by field name, with big integers as bignums.
+	CBORCodec Codec = cborCodec{}
+)
+
+// CodecByName returns the codec with the given name, which can be offered in a Hello
+func CodecByName(name string) (Codec, bool) {
+	for _, codec := range []Codec{GobCodec, CBORCodec} {
+		if codec.Name() == name {
+			return codec, true
+		}
+	}
+	return nil, false
+}
+
+// ErrMalformedMessage is wrapped by the error decoding a message that was read in full but isn't a valid Message.
+// The stream is left at the start of the next message, so it can be skipped.
+var ErrMalformedMessage = errors.New("malformed message")
+
+// ErrMessageTooLarge is returned when a message is larger than a decoder accepts
+var ErrMessageTooLarge = errors.New("message exceeds maximum accepted size")
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string {
+	return "gob"
+}
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder {
+	return gob.NewEncoder(w)
+}
+
+func (gobCodec) NewDecoder(r io.Reader) Decoder {
+	return gob.NewDecoder(r)
+}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// relayedPayloads are the structured payloads the jump server relays between clients, by message name
+var relayedPayloads = map[string]func() interface{}{
+	ResponseIdentify:        func() interface{} { return &util.ECDSASignature{} },
+	MessagePhononReject:     func() interface{} { return &PhononReject{} },
+	ResponseGenerateInvoice: func() interface{} { return &InvoiceResult{} },
+	ResponseReceiveInvoice:  func() interface{} { return &InvoiceResult{} },
+}
+
+// TranscodePayload converts the payload of a message relayed from a client using one codec to a client using another.
+// Payloads of other messages are opaque bytes and returned as they are.
+func TranscodePayload(name string, payload []byte, from Codec, to Codec) ([]byte, error) {
+	newPayload, ok := relayedPayloads[name]
+	if !ok || from == to {
+		return payload, nil
+	}
+	v := newPayload()
+	err := from.Unmarshal(payload, v)
+	if err != nil {
+		return nil, err
+	}
+	return to.Marshal(v)
+}
+
+// CBOR major types used by messages
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+// CBOR simple values and tags used by payloads
+const (
+	cborFalse        = 20
+	cborTrue         = 21
+	cborNull         = 22
+	cborTagBignum    = 2
+	cborTagNegBignum = 3
+)
+
+const (
+	// maxCBORMessageSize bounds the strings and items in a single CBOR message, as for gob messages by default
+	maxCBORMessageSize = 4 * 1024 * 1024
+	// maxCBORDepth bounds the nesting of arrays and maps, which a Message never holds, being skipped
+	maxCBORDepth = 16
+)
+
+type cborCodec struct{}
+
+func (cborCodec) Name() string {
+	return "cbor"
+}
+
+func (cborCodec) NewEncoder(w io.Writer) Encoder {
+	return &cborEncoder{w: w}
+}
+
+func (cborCodec) NewDecoder(r io.Reader) Decoder {
+	return &cborDecoder{r: bufio.NewReader(r)}
+}
+
+// Marshal encodes v, a payload struct or a pointer to one, as a single CBOR data item
+func (cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return appendCBORValue(nil, reflect.ValueOf(v))
+}
+
+// Unmarshal decodes a payload encoded by Marshal into v, which must be a pointer. Unknown map keys are ignored.
+func (cborCodec) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("cbor codec can't decode into %T", v)
+	}
+	d := &cborDecoder{r: bufio.NewReader(bytes.NewReader(data)), budget: maxCBORMessageSize}
+	item, err := d.readItem(0)
+	if err != nil {
+		return err
+	}
+	if d.malformed != nil {
+		return d.malformed
+	}
+	return setCBORValue(rv.Elem(), item)
+}
+
+var bigIntType = reflect.TypeOf((*big.Int)(nil))
+
+// appendCBORValue appends v, encoding structs as maps of their exported fields keyed by name
+func appendCBORValue(buf []byte, v reflect.Value) ([]byte, error) {
+	if v.Type() == bigIntType {
+		if v.IsNil() {
+			return appendCBORHead(buf, cborSimple, cborNull), nil
+		}
+		n := v.Interface().(*big.Int)
+		tag := uint64(cborTagBignum)
+		if n.Sign() < 0 {
+			//a negative bignum holds -1-n
+			tag = cborTagNegBignum
+			n = new(big.Int).Sub(new(big.Int).Neg(n), big.NewInt(1))
+		}
+		buf = appendCBORHead(buf, cborTag, tag)
+		return append(appendCBORHead(buf, cborBytes, uint64(len(n.Bytes()))), n.Bytes()...), nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return appendCBORHead(buf, cborSimple, cborNull), nil
+		}
+		return appendCBORValue(buf, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return appendCBORHead(buf, cborSimple, cborTrue), nil
+		}
+		return appendCBORHead(buf, cborSimple, cborFalse), nil
+	case reflect.String:
+		return appendCBORText(buf, v.String()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return appendCBORHead(buf, cborUint, v.Uint()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() < 0 {
+			return appendCBORHead(buf, cborNegInt, uint64(-1-v.Int())), nil
+		}
+		return appendCBORHead(buf, cborUint, uint64(v.Int())), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		return append(appendCBORHead(buf, cborBytes, uint64(v.Len())), v.Bytes()...), nil
+	case reflect.Struct:
+		t := v.Type()
+		var fields []int
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				fields = append(fields, i)
+			}
+		}
+		buf = appendCBORHead(buf, cborMap, uint64(len(fields)))
+		for _, i := range fields {
+			buf = appendCBORText(buf, t.Field(i).Name)
+			var err error
+			buf, err = appendCBORValue(buf, v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+	return nil, fmt.Errorf("cbor codec can't encode %v", v.Type())
+}
+
+// setCBORValue stores an item returned by readItem in v, converting it to v's type
+func setCBORValue(v reflect.Value, item interface{}) error {
+	if item == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	mismatch := fmt.Errorf("%w: can't decode %T into %v", ErrMalformedMessage, item, v.Type())
+	if v.Type() == bigIntType {
+		switch n := item.(type) {
+		case *big.Int:
+			v.Set(reflect.ValueOf(n))
+		case uint64:
+			v.Set(reflect.ValueOf(new(big.Int).SetUint64(n)))
+		case cborNegative:
+			v.Set(reflect.ValueOf(n.bigInt()))
+		default:
+			return mismatch
+		}
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		err := setCBORValue(elem.Elem(), item)
+		if err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Bool:
+		b, ok := item.(bool)
+		if !ok {
+			return mismatch
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.String:
+		s, ok := item.(string)
+		if !ok {
+			return mismatch
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := item.(uint64)
+		if !ok || v.OverflowUint(n) {
+			return mismatch
+		}
+		v.SetUint(n)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := item
+		if neg, ok := item.(cborNegative); ok {
+			n = neg.bigInt()
+		} else if u, ok := item.(uint64); ok {
+			n = new(big.Int).SetUint64(u)
+		}
+		i, ok := n.(*big.Int)
+		if !ok || !i.IsInt64() || v.OverflowInt(i.Int64()) {
+			return mismatch
+		}
+		v.SetInt(i.Int64())
+		return nil
+	case reflect.Slice:
+		b, ok := item.([]byte)
+		if !ok || v.Type().Elem().Kind() != reflect.Uint8 {
+			return mismatch
+		}
+		if len(b) == 0 {
+			//as with gob, empty byte strings decode as nil
+			b = nil
+		}
+		v.SetBytes(b)
+		return nil
+	case reflect.Struct:
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return mismatch
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			value, ok := fields[t.Field(i).Name]
+			if !ok || !t.Field(i).IsExported() {
+				continue
+			}
+			err := setCBORValue(v.Field(i), value)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return mismatch
+}
+
+type cborEncoder struct {
+	w io.Writer
+}
+
+// Encode writes msg, a Message or *Message, in a single write
+func (e *cborEncoder) Encode(msg interface{}) error {
+	var m Message
+	switch v := msg.(type) {
+	case Message:
+		m = v
+	case *Message:
+		m = *v
+	default:
+		return fmt.Errorf("cbor codec can't encode %T", msg)
+	}
+	buf := make([]byte, 0, len(m.Name)+len(m.Payload)+32)
+	buf = appendCBORHead(buf, cborMap, 3)
+	buf = appendCBORText(buf, "name")
+	buf = appendCBORText(buf, m.Name)
+	buf = appendCBORText(buf, "payload")
+	buf = appendCBORHead(buf, cborBytes, uint64(len(m.Payload)))
+	buf = append(buf, m.Payload...)
+	buf = appendCBORText(buf, "id")
+	buf = appendCBORHead(buf, cborUint, m.ID)
+	_, err := e.w.Write(buf)
+	return err
+}
+
+// appendCBORHead appends the shortest head encoding major type and argument
+func appendCBORHead(buf []byte, major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return append(buf, major|byte(arg))
+	case arg <= 0xff:
+		return append(buf, major|24, byte(arg))
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(arg))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, major|27), arg)
+	}
+}
+
+func appendCBORText(buf []byte, s string) []byte {
+	return append(appendCBORHead(buf, cborText, uint64(len(s))), s...)
+}
+
+/*
+cborDecoder reads each message as one complete CBOR data item. Keys other than those of a Message are skipped,
+and an item that isn't a Message is consumed whole before ErrMalformedMessage is returned. Items that can't be
+delimited, such as indefinite length strings, or that are too large or deeply nested, leave the stream unusable.
+*/
+type cborDecoder struct {
+	r         *bufio.Reader
+	budget    uint64 //bytes the rest of the current message may claim
+	malformed error  //the first reason the current message isn't a valid Message
+}
+
+// cborSkipped stands for a value neither a Message nor a payload holds, which is read and discarded
+type cborSkipped struct{}
+
+// cborNegative is a negative integer, holding -1-n as CBOR encodes it
+type cborNegative uint64
+
+func (n cborNegative) bigInt() *big.Int {
+	i := new(big.Int).SetUint64(uint64(n))
+	return i.Neg(i.Add(i, big.NewInt(1)))
+}
+
+// Decode reads the next message into msg, which must be a *Message
+func (d *cborDecoder) Decode(msg interface{}) error {
+	m, ok := msg.(*Message)
+	if !ok {
+		return fmt.Errorf("cbor codec can't decode into %T", msg)
+	}
+	d.budget = maxCBORMessageSize
+	d.malformed = nil
+	item, err := d.readItem(0)
+	if err != nil {
+		return err
+	}
+	fields, ok := item.(map[string]interface{})
+	if !ok {
+		d.setMalformed("message is not a map")
+	}
+	var decoded Message
+	for key, value := range fields {
+		switch key {
+		case "name":
+			decoded.Name, ok = value.(string)
+		case "payload":
+			decoded.Payload, ok = value.([]byte)
+		case "id":
+			decoded.ID, ok = value.(uint64)
+		default:
+			ok = true
+		}
+		if !ok {
+			d.setMalformed(fmt.Sprintf("unexpected type for %q", key))
+		}
+	}
+	if d.malformed != nil {
+		return d.malformed
+	}
+	*m = decoded
+	return nil
+}
+
+func (d *cborDecoder) setMalformed(reason string) {
+	if d.malformed == nil {
+		d.malformed = fmt.Errorf("%w: %s", ErrMalformedMessage, reason)
+	}
+}
+
+/*
+readItem reads one complete data item. Unsigned integers, byte strings and text strings are returned as uint64,
+[]byte and string, and maps with text keys as map[string]interface{}. Negative integers are returned as cborNegative,
+booleans as bool, null as nil and bignums as *big.Int. Anything else is consumed and returned as cborSkipped.
+*/
+func (d *cborDecoder) readItem(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, errors.New("cbor items nested too deeply")
+	}
+	major, info, arg, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		return arg, nil
+	case cborNegInt:
+		return cborNegative(arg), nil
+	case cborSimple:
+		//floats share the major type, with their raw value as the argument
+		if info < 24 {
+			switch arg {
+			case cborFalse:
+				return false, nil
+			case cborTrue:
+				return true, nil
+			case cborNull:
+				return nil, nil
+			}
+		}
+		return cborSkipped{}, nil
+	case cborBytes, cborText:
+		err = d.claim(arg)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, arg)
+		_, err = io.ReadFull(d.r, buf)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if major == cborText {
+			return string(buf), nil
+		}
+		return buf, nil
+	case cborArray:
+		err = d.claim(arg)
+		if err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < arg; i++ {
+			_, err = d.readItem(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return cborSkipped{}, nil
+	case cborMap:
+		err = d.claim(2 * arg)
+		if err != nil {
+			return nil, err
+		}
+		//the count comes from the peer, so the map only grows as its entries are actually read
+		hint := arg
+		if hint > cborMapSizeHint {
+			hint = cborMapSizeHint
+		}
+		fields := make(map[string]interface{}, hint)
+		for i := uint64(0); i < arg; i++ {
+			key, err := d.readItem(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			value, err := d.readItem(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				d.setMalformed("map key is not text")
+				continue
+			}
+			fields[k] = value
+		}
+		return fields, nil
+	default: //cborTag
+		item, err := d.readItem(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		magnitude, ok := item.([]byte)
+		if !ok || (arg != cborTagBignum && arg != cborTagNegBignum) {
+			return cborSkipped{}, nil
+		}
+		n := new(big.Int).SetBytes(magnitude)
+		if arg == cborTagNegBignum {
+			n.Neg(n.Add(n, big.NewInt(1)))
+		}
+		return n, nil
+	}
+}
+
+// cborMapSizeHint bounds the space allocated for a map up front, no payload struct has more fields than this
+const cborMapSizeHint = 16
+
+// claim charges n bytes or items against the current message's budget
+func (d *cborDecoder) claim(n uint64) error {
+	if n > d.budget {
+		return ErrMessageTooLarge
+	}
+	d.budget -= n
+	return nil
+}
+
+// readHead reads an item's major type, additional information and argument.
+// For floats and simple values the argument is their raw value.
+func (d *cborDecoder) readHead() (major byte, info byte, arg uint64, err error) {
+	first, err := d.r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = first>>5, first&0x1f
+	if info < 24 {
+		return major, info, uint64(info), nil
+	}
+	if info > 27 {
+		return 0, 0, 0, fmt.Errorf("unsupported cbor additional information %d", info)
+	}
+	buf := make([]byte, 1<<(info-24))
+	_, err = io.ReadFull(d.r, buf)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	for _, b := range buf {
+		arg = arg<<8 | uint64(b)
+	}
+	return major, info, arg, nil
+}
@@ -0,0 +1,170 @@
+package v1
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/GridPlus/phonon-client/util"
+)
+
+func TestCBORCodec(t *testing.T) {
+	var buf bytes.Buffer
+	enc := CBORCodec.NewEncoder(&buf)
+	err := enc.Encode(Message{Name: "Ping", Payload: []byte{0x01}, ID: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		0xA3,
+		0x64, 'n', 'a', 'm', 'e', 0x64, 'P', 'i', 'n', 'g',
+		0x67, 'p', 'a', 'y', 'l', 'o', 'a', 'd', 0x41, 0x01,
+		0x62, 'i', 'd', 0x19, 0x01, 0xF4,
+	}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("unexpected encoding % X", buf.Bytes())
+	}
+
+	messages := []*Message{
+		{Name: RequestReceivePhonon, Payload: bytes.Repeat([]byte{0xAB}, 70000), ID: 1 << 40},
+		{Name: MessagePhononAck},
+	}
+	for _, msg := range messages {
+		err = enc.Encode(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := CBORCodec.NewDecoder(&buf)
+	var decoded Message
+	err = dec.Decode(&decoded)
+	if err != nil || decoded.Name != "Ping" || decoded.ID != 500 {
+		t.Fatalf("unable to decode the first message, got %+v, %v", decoded, err)
+	}
+	for _, msg := range messages {
+		decoded = Message{}
+		err = dec.Decode(&decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Name != msg.Name || decoded.ID != msg.ID || !bytes.Equal(decoded.Payload, msg.Payload) {
+			t.Errorf("expected %v message to survive encoding, got %v", msg.Name, decoded.Name)
+		}
+	}
+}
+
+func TestCBORDecodeMalformed(t *testing.T) {
+	stream := []byte{
+		//an array isn't a message, but can be skipped
+		0x82, 0x01, 0x62, 'h', 'i',
+		//a message with an unknown key holding a map, and an id of the wrong type
+		0xA3, 0x64, 'n', 'a', 'm', 'e', 0x61, 'a', 0x65, 'e', 'x', 't', 'r', 'a', 0xA1, 0x01, 0x02, 0x62, 'i', 'd', 0x61, 'x',
+		//a message with an unknown key, which is ignored
+		0xA2, 0x64, 'n', 'a', 'm', 'e', 0x61, 'b', 0x65, 'e', 'x', 't', 'r', 'a', 0xF5,
+		//a payload claiming more than the maximum message size
+		0xA1, 0x67, 'p', 'a', 'y', 'l', 'o', 'a', 'd', 0x5A, 0xFF, 0xFF, 0xFF, 0xFF,
+	}
+	dec := CBORCodec.NewDecoder(bytes.NewReader(stream))
+	var msg Message
+	for i := 0; i < 2; i++ {
+		err := dec.Decode(&msg)
+		if !errors.Is(err, ErrMalformedMessage) {
+			t.Errorf("expected message %v to be %v, got %v", i, ErrMalformedMessage, err)
+		}
+	}
+	err := dec.Decode(&msg)
+	if err != nil || !reflect.DeepEqual(msg, Message{Name: "b"}) {
+		t.Errorf("expected unknown keys to be ignored, got %+v, %v", msg, err)
+	}
+	err = dec.Decode(&msg)
+	if err != ErrMessageTooLarge {
+		t.Errorf("expected %v, got %v", ErrMessageTooLarge, err)
+	}
+}
+
+func TestCBORDecodeLargeMapCount(t *testing.T) {
+	//a map claiming two million entries that never arrive
+	stream := []byte{0xBA, 0x00, 0x1F, 0xFF, 0xFF}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	var msg Message
+	err := CBORCodec.NewDecoder(bytes.NewReader(stream)).Decode(&msg)
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Error("expected a truncated map to fail to decode")
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("expected the map's claimed size not to be allocated up front, allocated %v bytes", allocated)
+	}
+}
+
+func TestCBORPayloads(t *testing.T) {
+	payload, err := CBORCodec.Marshal(PhononReject{Reason: RejectReasonInvoiceMismatch, Message: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		0xA2,
+		0x66, 'R', 'e', 'a', 's', 'o', 'n', 0x03,
+		0x67, 'M', 'e', 's', 's', 'a', 'g', 'e', 0x61, 'x',
+	}
+	if !bytes.Equal(payload, expected) {
+		t.Fatalf("unexpected encoding % X", payload)
+	}
+
+	payloads := []struct {
+		value   interface{}
+		decoded interface{}
+	}{
+		{Hello{Compression: true, SchemaFingerprint: SchemaFingerprint()}, &Hello{}},
+		{InvoiceResult{Invoice: []byte{0x01, 0x02}}, &InvoiceResult{}},
+		{InvoiceResult{Error: "no such invoice"}, &InvoiceResult{}},
+		{util.ECDSASignature{R: new(big.Int).Lsh(big.NewInt(1), 255), S: big.NewInt(-300)}, &util.ECDSASignature{}},
+		{util.ECDSASignature{R: big.NewInt(7)}, &util.ECDSASignature{}},
+	}
+	for _, p := range payloads {
+		encoded, err := CBORCodec.Marshal(p.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = CBORCodec.Unmarshal(encoded, p.decoded)
+		if err != nil {
+			t.Fatalf("unable to decode %T: %v", p.value, err)
+		}
+		if !reflect.DeepEqual(reflect.ValueOf(p.decoded).Elem().Interface(), p.value) {
+			t.Errorf("expected %+v to survive encoding, got %+v", p.value, p.decoded)
+		}
+	}
+
+	var hello Hello
+	err = CBORCodec.Unmarshal([]byte{0xA1, 0x6B, 'C', 'o', 'm', 'p', 'r', 'e', 's', 's', 'i', 'o', 'n', 0x01}, &hello)
+	if !errors.Is(err, ErrMalformedMessage) {
+		t.Errorf("expected a field of the wrong type to be %v, got %v", ErrMalformedMessage, err)
+	}
+}
+
+func TestTranscodePayload(t *testing.T) {
+	sig := util.ECDSASignature{R: big.NewInt(1000), S: big.NewInt(2000)}
+	payload, err := GobCodec.Marshal(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcoded, err := TranscodePayload(ResponseIdentify, payload, GobCodec, CBORCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded util.ECDSASignature
+	err = CBORCodec.Unmarshal(transcoded, &decoded)
+	if err != nil || !reflect.DeepEqual(decoded, sig) {
+		t.Errorf("expected the signature to survive transcoding, got %+v, %v", decoded, err)
+	}
+
+	packet := []byte{0x43, 0x01, 0x02}
+	transcoded, err = TranscodePayload(RequestReceivePhonon, packet, GobCodec, CBORCodec)
+	if err != nil || !bytes.Equal(transcoded, packet) {
+		t.Errorf("expected an opaque payload to be relayed unchanged, got % X, %v", transcoded, err)
+	}
+}
@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+)
+
+/*
+Hello is sent by a client once it has identified with the jump server to advertise optional features.
+The server answers with a HelloAck carrying the subset it agreed to.
+
+If compression is agreed, every payload the server sends after its HelloAck is compressed,
+and every payload the client sends after receiving the HelloAck is compressed.
+Peers that don't know about Hello ignore it and the connection stays uncompressed.
+
+Both sides also send their SchemaFingerprint. A server with a different fingerprint agrees to no features
+and closes the connection after its HelloAck, and a client receiving a different one fails with ErrSchemaMismatch.
+An empty fingerprint comes from a peer predating the check and is accepted.
+
+Hello and HelloAck are encoded with the connection's Codec, which is agreed before either is sent, see CodecHeader.
+*/
+type Hello struct {
+	Compression       bool
+	SchemaFingerprint []byte
+}
+
+// SchemaMatches reports whether h was sent by a peer with the same message schema, or one that didn't report its schema
+func (h Hello) SchemaMatches() bool {
+	return len(h.SchemaFingerprint) == 0 || bytes.Equal(h.SchemaFingerprint, schemaFingerprint)
+}
+
+// maxDecompressedPayload bounds how much a compressed payload may expand to
+const maxDecompressedPayload = 4 * 1024 * 1024
+
+var ErrPayloadTooLarge = errors.New("decompressed payload exceeds maximum size")
+
+// CompressPayload deflates a message payload for a connection that negotiated compression
+func CompressPayload(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(payload)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecompressPayload reverses CompressPayload
+func DecompressPayload(payload []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(payload))
+	defer r.Close()
+	ret, err := io.ReadAll(io.LimitReader(r, maxDecompressedPayload+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(ret) > maxDecompressedPayload {
+		return nil, ErrPayloadTooLarge
+	}
+	return ret, nil
+}
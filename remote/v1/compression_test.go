@@ -0,0 +1,33 @@
+package v1
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressPayload(t *testing.T) {
+	payload := bytes.Repeat([]byte("phonon"), 1000)
+	compressed, err := CompressPayload(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) >= len(payload) {
+		t.Errorf("expected repetitive payload to shrink, got %d bytes from %d", len(compressed), len(payload))
+	}
+	decompressed, err := DecompressPayload(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, payload) {
+		t.Error("payload did not survive compression")
+	}
+
+	bomb, err := CompressPayload(make([]byte, maxDecompressedPayload+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = DecompressPayload(bomb)
+	if err != ErrPayloadTooLarge {
+		t.Errorf("expected %v, got %v", ErrPayloadTooLarge, err)
+	}
+}
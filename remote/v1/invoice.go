@@ -0,0 +1,19 @@
+package v1
+
+// InvoiceResult is the payload of a ResponseGenerateInvoice or ResponseReceiveInvoice.
+// Error is empty if the counterparty's card handled the invoice, otherwise it is the reason it couldn't.
+type InvoiceResult struct {
+	Invoice []byte //the generated invoice, empty in a ResponseReceiveInvoice
+	Error   string
+}
+
+// Encode encodes r with codec, the codec of the connection it is sent over
+func (r InvoiceResult) Encode(codec Codec) ([]byte, error) {
+	return codec.Marshal(r)
+}
+
+func DecodeInvoiceResult(codec Codec, payload []byte) (InvoiceResult, error) {
+	var r InvoiceResult
+	err := codec.Unmarshal(payload, &r)
+	return r, err
+}
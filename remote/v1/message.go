@@ -4,6 +4,9 @@ package v1
 type Message struct {
 	Name    string
 	Payload []byte
+	// ID correlates a response with the request it answers, responses carry the ID of their request.
+	// It is zero for unsolicited messages and for messages from peers that predate it.
+	ID uint64
 }
 
 var (
@@ -14,6 +17,8 @@ var (
 	MessagePassthruFailed       = "PassthruFailed"
 	MessageIdentifiedWithServer = "IdentifiedWithServer"
 	MessageConnectedToCard      = "connectedToCard"
+	MessageHelloAck             = "HelloAck"
+	MessageDuplicateConnection  = "DuplicateConnection"
 
 	// Client to server commands
 	RequestIdentify           = "Identify"
@@ -25,6 +30,12 @@ var (
 	RequestDisconnectFromCard = "DisconnectFromCard"
 	RequestEndSession         = "EndSession"
 	MessagePhononAck          = "AckPhonon"
+	MessagePhononReject       = "RejectPhonon"
+	MessageHello              = "Hello"
+	RequestPing               = "Ping"
+	ResponsePing              = "Pong"
+	RequestServerAuth         = "ServerAuth"
+	ResponseServerAuth        = "ServerAuthResponse"
 
 	// Client to client commands
 	RequestVerifyPaired      = "VerifyPairing"
@@ -35,6 +46,14 @@ var (
 	ResponseCardPair2        = "CardPair2Response"
 	RequestFinalizeCardPair  = "FinalizeCardPair"
 	ResponseFinalizeCardPair = "FinalizeCardPairResponse"
+	RequestDryRunTransfer    = "DryRunTransfer"
+	ResponseDryRunTransfer   = "DryRunTransferResponse"
+	RequestGenerateInvoice   = "GenerateInvoice"
+	ResponseGenerateInvoice  = "GenerateInvoiceResponse"
+	RequestReceiveInvoice    = "ReceiveInvoice"
+	ResponseReceiveInvoice   = "ReceiveInvoiceResponse"
+	RequestResumePairing     = "ResumePairing"
+	ResponseResumePairing    = "ResumePairingResponse"
 	// this one is weird because the server will cache this one
 	RequestReceivePhonon = "requestReceivePhonon"
 )
@@ -0,0 +1,41 @@
+package v1
+
+// RejectReason explains why a receiver refused a phonon transfer
+type RejectReason uint8
+
+const (
+	RejectReasonUnspecified RejectReason = iota
+	RejectReasonValidationFailed
+	RejectReasonInsufficientStorage
+	RejectReasonInvoiceMismatch
+)
+
+func (r RejectReason) String() string {
+	switch r {
+	case RejectReasonValidationFailed:
+		return "validation failed"
+	case RejectReasonInsufficientStorage:
+		return "insufficient storage"
+	case RejectReasonInvoiceMismatch:
+		return "invoice mismatch"
+	default:
+		return "unspecified"
+	}
+}
+
+// PhononReject is the payload of a MessagePhononReject
+type PhononReject struct {
+	Reason  RejectReason
+	Message string
+}
+
+// Encode encodes r with codec, the codec of the connection it is sent over
+func (r PhononReject) Encode(codec Codec) ([]byte, error) {
+	return codec.Marshal(r)
+}
+
+func DecodePhononReject(codec Codec, payload []byte) (PhononReject, error) {
+	var r PhononReject
+	err := codec.Unmarshal(payload, &r)
+	return r, err
+}
@@ -0,0 +1,109 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/GridPlus/phonon-client/util"
+)
+
+var ErrSchemaMismatch = errors.New("remote peer uses an incompatible message schema")
+
+// schemaTypes are the types exchanged as gob between clients and the jump server, either as a Message or as its payload
+var schemaTypes = []interface{}{
+	Message{},
+	Hello{},
+	PhononReject{},
+	InvoiceResult{},
+	util.ECDSASignature{},
+}
+
+var schemaFingerprint = computeSchemaFingerprint(schemaTypes)
+
+/*
+SchemaFingerprint is a hash of the shape of every type the remote protocol encodes with gob. Gob matches struct fields
+by name and decodes whatever it can, so a peer built with a renamed or retyped field would silently lose data.
+Peers exchange fingerprints in Hello so such a mismatch is caught when connecting instead.
+
+Only what gob depends on is hashed: the exported field names and their types, in name order and with integers
+of every size treated alike as gob does. Type names aren't included, so the fingerprint is the same from any build with the same schema.
+*/
+func SchemaFingerprint() []byte {
+	return append([]byte(nil), schemaFingerprint...)
+}
+
+func computeSchemaFingerprint(types []interface{}) []byte {
+	var b strings.Builder
+	for _, v := range types {
+		describeSchemaType(&b, reflect.TypeOf(v))
+		b.WriteByte('\n')
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return sum[:]
+}
+
+var (
+	gobEncoderType    = reflect.TypeOf((*gob.GobEncoder)(nil)).Elem()
+	binaryMarshalType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+)
+
+// describeSchemaType writes the wire shape of t as gob sees it
+func describeSchemaType(b *strings.Builder, t reflect.Type) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	//types encoding themselves are opaque to gob, so only their identity is part of the schema
+	if reflect.PointerTo(t).Implements(gobEncoderType) || reflect.PointerTo(t).Implements(binaryMarshalType) {
+		fmt.Fprintf(b, "encoded(%s)", t.String())
+		return
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteString("int")
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		b.WriteString("uint")
+	case reflect.Float32, reflect.Float64:
+		b.WriteString("float")
+	case reflect.Complex64, reflect.Complex128:
+		b.WriteString("complex")
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			b.WriteString("bytes")
+			return
+		}
+		b.WriteString("[]")
+		describeSchemaType(b, t.Elem())
+	case reflect.Array:
+		fmt.Fprintf(b, "[%d]", t.Len())
+		describeSchemaType(b, t.Elem())
+	case reflect.Map:
+		b.WriteString("map[")
+		describeSchemaType(b, t.Key())
+		b.WriteString("]")
+		describeSchemaType(b, t.Elem())
+	case reflect.Struct:
+		//fields are matched by name, so their order doesn't matter
+		var fields []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() || f.Type.Kind() == reflect.Chan || f.Type.Kind() == reflect.Func {
+				//gob skips these fields
+				continue
+			}
+			var field strings.Builder
+			fmt.Fprintf(&field, "%s ", f.Name)
+			describeSchemaType(&field, f.Type)
+			fields = append(fields, field.String())
+		}
+		sort.Strings(fields)
+		fmt.Fprintf(b, "struct{%s}", strings.Join(fields, ";"))
+	default:
+		b.WriteString(t.Kind().String())
+	}
+}
@@ -0,0 +1,49 @@
+package v1
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSchemaFingerprint(t *testing.T) {
+	type original struct {
+		Name    string
+		Payload []byte
+		Count   int64
+	}
+	type reordered struct {
+		Count   int32
+		Payload []byte
+		Name    string
+		private bool
+	}
+	type renamed struct {
+		Label   string
+		Payload []byte
+		Count   int64
+	}
+	type retyped struct {
+		Name    string
+		Payload []string
+		Count   int64
+	}
+	fingerprint := computeSchemaFingerprint([]interface{}{original{}})
+	if !bytes.Equal(fingerprint, computeSchemaFingerprint([]interface{}{original{}})) {
+		t.Error("expected the fingerprint to be stable")
+	}
+	if !bytes.Equal(fingerprint, computeSchemaFingerprint([]interface{}{reordered{}})) {
+		t.Error("expected field order, integer size and unexported fields not to change the fingerprint")
+	}
+	for _, changed := range []interface{}{renamed{}, retyped{}} {
+		if bytes.Equal(fingerprint, computeSchemaFingerprint([]interface{}{changed})) {
+			t.Errorf("expected %T to change the fingerprint", changed)
+		}
+	}
+
+	if !(Hello{}).SchemaMatches() || !(Hello{SchemaFingerprint: SchemaFingerprint()}).SchemaMatches() {
+		t.Error("expected a missing or identical fingerprint to match")
+	}
+	if (Hello{SchemaFingerprint: fingerprint}).SchemaMatches() {
+		t.Error("expected a different fingerprint not to match")
+	}
+}
@@ -1,11 +1,11 @@
 package server
 
 import (
-	"bytes"
 	"crypto/ecdsa"
-	"crypto/rand"
-	"encoding/gob"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"reflect"
 	"strings"
@@ -33,15 +33,27 @@ type clientSession struct {
 	Name           string
 	certificate    cert.CardCertificate
 	underlyingConn *h2conn.Conn
-	out            *gob.Encoder
-	in             *gob.Decoder
+	out            v1.Encoder
+	in             v1.Decoder
+	codec          v1.Codec //encodes messages and payloads in both directions, agreed when the client connected
 	validated      bool
 	Counterparty   *clientSession
+	compression    bool //payloads are compressed in both directions once a HelloAck agreeing to it is sent
+	tlsState       *tls.ConnectionState
 	// the same name that goes in the lookup value of the clientSession map
 }
 
 var clientSessions map[string]*clientSession
 
+// identityKey signs client challenges in RequestServerAuth so clients can authenticate the server, if set
+var identityKey *ecdsa.PrivateKey
+
+// SetIdentityKey sets the key the server proves its identity with to clients configured with its public key.
+// It must be called before StartServer.
+func SetIdentityKey(key *ecdsa.PrivateKey) {
+	identityKey = key
+}
+
 func index(w http.ResponseWriter, _ *http.Request) {
 	w.Write([]byte("hello there"))
 }
@@ -52,6 +64,12 @@ func listConnected(w http.ResponseWriter, _ *http.Request) {
 }
 
 func handle(w http.ResponseWriter, r *http.Request) {
+	//agree to the client's codec by echoing it before the response starts
+	codec := v1.GobCodec
+	if offered, ok := v1.CodecByName(r.Header.Get(v1.CodecHeader)); ok {
+		codec = offered
+		w.Header().Set(v1.CodecHeader, codec.Name())
+	}
 	conn, err := h2conn.Accept(w, r)
 	if err != nil {
 		log.Error("Unable to establish http2 duplex connection with ", r.RemoteAddr)
@@ -59,8 +77,8 @@ func handle(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	cmdEncoder := gob.NewEncoder(conn)
-	cmdDecoder := gob.NewDecoder(conn)
+	cmdEncoder := codec.NewEncoder(conn)
+	cmdDecoder := codec.NewDecoder(conn)
 	//generate session
 	session := clientSession{
 		Name:           "",
@@ -68,8 +86,10 @@ func handle(w http.ResponseWriter, r *http.Request) {
 		underlyingConn: conn,
 		out:            cmdEncoder,
 		in:             cmdDecoder,
+		codec:          codec,
 		validated:      false,
 		Counterparty:   nil,
+		tlsState:       r.TLS,
 	}
 
 	valid, err := session.ValidateClient()
@@ -103,8 +123,19 @@ func handle(w http.ResponseWriter, r *http.Request) {
 			log.Error("failed receiving message: ", err)
 			return
 		}
+		if session.compression {
+			msg.Payload, err = v1.DecompressPayload(msg.Payload)
+			if err != nil {
+				log.Error("failed decompressing message payload: ", err)
+				return
+			}
+		}
 		log.Debugf("received %v message with payload: % X\n", msg.Name, msg.Payload)
 		err = session.process(msg)
+		if errors.Is(err, v1.ErrSchemaMismatch) {
+			log.Error("closing connection from client with an incompatible schema: ", err)
+			return
+		}
 		if err != nil {
 			log.Errorf("failed to process incoming %v msg. err: %v", msg.Name, err)
 			log.Errorf("msg payload: % X", msg.Payload)
@@ -122,10 +153,14 @@ func (c *clientSession) process(msg v1.Message) error {
 		c.endSession(msg)
 	case v1.RequestNoOp:
 		c.noop(msg)
-	case v1.RequestIdentify, v1.ResponseIdentify, v1.RequestCardPair1, v1.ResponseCardPair1, v1.RequestCardPair2, v1.ResponseCardPair2, v1.RequestFinalizeCardPair, v1.ResponseFinalizeCardPair, v1.RequestReceivePhonon, v1.MessagePhononAck, v1.RequestVerifyPaired, v1.ResponseVerifyPaired:
+	case v1.RequestPing:
+		c.send(v1.Message{Name: v1.ResponsePing, ID: msg.ID})
+	case v1.MessageHello:
+		return c.hello(msg)
+	case v1.RequestIdentify, v1.ResponseIdentify, v1.RequestCardPair1, v1.ResponseCardPair1, v1.RequestCardPair2, v1.ResponseCardPair2, v1.RequestFinalizeCardPair, v1.ResponseFinalizeCardPair, v1.RequestReceivePhonon, v1.MessagePhononAck, v1.MessagePhononReject, v1.RequestVerifyPaired, v1.ResponseVerifyPaired, v1.RequestDryRunTransfer, v1.ResponseDryRunTransfer, v1.RequestGenerateInvoice, v1.ResponseGenerateInvoice, v1.RequestReceiveInvoice, v1.ResponseReceiveInvoice, v1.RequestResumePairing, v1.ResponseResumePairing:
 		c.passthrough(msg)
 	case v1.RequestCertificate:
-		c.provideCertificate()
+		c.provideCertificate(msg)
 	}
 	//TODO: provide actual errors, or ensure all the cases handle errors themselves
 	return nil
@@ -133,13 +168,26 @@ func (c *clientSession) process(msg v1.Message) error {
 
 func (c *clientSession) ValidateClient() (bool, error) {
 	log.Info("validating client connection")
-	//Read client certificate
 	var in v1.Message
 	err := c.in.Decode(&in)
 	if err != nil {
 		log.Error("unable to decode raw client certificate bytes: ", err)
 		return false, err
 	}
+	//a client authenticating the server does so before identifying its card
+	if in.Name == v1.RequestServerAuth {
+		err = c.authenticate(in)
+		if err != nil {
+			return false, err
+		}
+		in = v1.Message{}
+		err = c.in.Decode(&in)
+		if err != nil {
+			log.Error("unable to decode raw client certificate bytes: ", err)
+			return false, err
+		}
+	}
+	//Read client certificate
 	log.Info("past first Decode:")
 	c.certificate, err = cert.ParseRawCardCertificate(in.Payload)
 	if err != nil {
@@ -176,6 +224,10 @@ func (c *clientSession) ValidateClient() (bool, error) {
 	c.validated = true
 	name := util.CardIDFromPubKey(key)
 	c.Name = strings.ToLower(name)
+	if existing, ok := clientSessions[name]; ok && existing != c {
+		log.Infof("card %s connected again, replacing its previous connection", name)
+		existing.supersede()
+	}
 	clientSessions[name] = c
 	c.out.Encode(v1.Message{
 		Name:    v1.MessageIdentifiedWithServer,
@@ -186,9 +238,32 @@ func (c *clientSession) ValidateClient() (bool, error) {
 	return true, nil
 }
 
+// authenticate signs the client's challenge, bound to the TLS connection, with the server's identity key.
+// A server without an identity key answers with no signature.
+func (c *clientSession) authenticate(req v1.Message) error {
+	var sig []byte
+	if identityKey != nil {
+		binding, err := v1.TLSBinding(c.tlsState)
+		if err != nil {
+			log.Error("unable to bind server signature to the connection: ", err)
+			return err
+		}
+		sig, err = v1.SignServerChallenge(identityKey, req.Payload, binding)
+		if err != nil {
+			log.Error("unable to sign server auth challenge: ", err)
+			return err
+		}
+	}
+	err := c.out.Encode(v1.Message{Name: v1.ResponseServerAuth, Payload: sig, ID: req.ID})
+	if err != nil {
+		log.Error("unable to send server auth response: ", err)
+	}
+	return err
+}
+
 func (c *clientSession) RequestIdentify() (challengeNonce []byte, err error) {
 	challengeNonce = make([]byte, 32)
-	_, err = rand.Reader.Read(challengeNonce)
+	err = util.ReadRandom(challengeNonce)
 	if err != nil {
 		log.Error("unable to generate challenge nonce. err: ", err)
 		return nil, err
@@ -211,9 +286,7 @@ func (c *clientSession) ReceiveIdentifyResponse() (*util.ECDSASignature, error)
 	}
 	log.Infof("received identify response: %+v\n", identifyResp)
 	if identifyResp.Name == v1.ResponseIdentify {
-		buf := bytes.NewBuffer(identifyResp.Payload)
-		decoder := gob.NewDecoder(buf)
-		err := decoder.Decode(&sig)
+		err := c.codec.Unmarshal(identifyResp.Payload, &sig)
 		if err != nil {
 			log.Error("unable to decode sig. err: ", err)
 			return nil, err
@@ -223,16 +296,61 @@ func (c *clientSession) ReceiveIdentifyResponse() (*util.ECDSASignature, error)
 	return &sig, nil
 }
 
-func (c *clientSession) provideCertificate() {
+// hello answers a client's feature offer, agreeing to every feature the server supports.
+// A client with a different message schema is sent the server's fingerprint alone and ErrSchemaMismatch is returned.
+func (c *clientSession) hello(msg v1.Message) error {
+	var offered v1.Hello
+	err := c.codec.Unmarshal(msg.Payload, &offered)
+	if err != nil {
+		log.Error("unable to decode hello: ", err)
+		return err
+	}
+	agreed := v1.Hello{
+		SchemaFingerprint: v1.SchemaFingerprint(),
+	}
+	matches := offered.SchemaMatches()
+	if matches {
+		agreed.Compression = offered.Compression
+	}
+	payload, err := c.codec.Marshal(agreed)
+	if err != nil {
+		log.Error("unable to encode hello ack: ", err)
+		return err
+	}
+	err = c.send(v1.Message{Name: v1.MessageHelloAck, Payload: payload})
+	if err != nil {
+		log.Error("unable to send hello ack: ", err)
+		return err
+	}
+	if !matches {
+		return fmt.Errorf("%w: client fingerprint % X", v1.ErrSchemaMismatch, offered.SchemaFingerprint)
+	}
+	c.compression = agreed.Compression
+	return nil
+}
+
+// send encodes a message to the client, compressing the payload if negotiated
+func (c *clientSession) send(msg v1.Message) error {
+	if c.compression {
+		payload, err := v1.CompressPayload(msg.Payload)
+		if err != nil {
+			return err
+		}
+		msg.Payload = payload
+	}
+	return c.out.Encode(msg)
+}
+
+func (c *clientSession) provideCertificate(req v1.Message) {
 	if c.Counterparty == nil {
-		c.out.Encode(v1.Message{
+		c.send(v1.Message{
 			Name:    v1.MessageError,
 			Payload: []byte("no counterparty connected. Cannot get certificate"),
 		})
 		return
 	}
 	if reflect.DeepEqual(c.Counterparty.certificate, cert.CardCertificate{}) {
-		c.out.Encode(v1.Message{
+		c.send(v1.Message{
 			Name:    v1.MessageError,
 			Payload: []byte("failed to retrieve cached counterparty certificate"),
 		})
@@ -242,8 +360,9 @@ func (c *clientSession) provideCertificate() {
 	msg := v1.Message{
 		Name:    v1.ResponseCertificate,
 		Payload: c.Counterparty.certificate.Serialize(),
+		ID:      req.ID,
 	}
-	err := c.out.Encode(msg)
+	err := c.send(msg)
 	if err != nil {
 		log.Error("error encoding provideCertificate reply: ", err)
 		return
@@ -286,7 +405,7 @@ func (c *clientSession) ConnectCard2Card(msg v1.Message) {
 	log.Infof("attempting to connect card %s to card %s\n", c.Name, string(msg.Payload))
 	counterparty, ok := clientSessions[strings.ToLower(string(msg.Payload))]
 	if !ok {
-		c.out.Encode(v1.Message{
+		c.send(v1.Message{
 			Name:    v1.MessageError,
 			Payload: []byte("No connected card"),
 		})
@@ -295,11 +414,12 @@ func (c *clientSession) ConnectCard2Card(msg v1.Message) {
 	} else if counterparty.Counterparty == nil && c.Counterparty == nil {
 		counterparty.Counterparty = c
 		c.Counterparty = counterparty
-		c.out.Encode(v1.Message{
+		c.send(v1.Message{
 			Name:    v1.MessageConnectedToCard,
 			Payload: c.Counterparty.certificate.Serialize(),
+			ID:      msg.ID,
 		})
-		c.Counterparty.out.Encode(v1.Message{
+		c.Counterparty.send(v1.Message{
 			Name:    v1.MessageConnectedToCard,
 			Payload: c.certificate.Serialize(),
 		})
@@ -307,7 +427,7 @@ func (c *clientSession) ConnectCard2Card(msg v1.Message) {
 	} else if c.Counterparty == counterparty && counterparty.Counterparty == c {
 		//do nothing
 	} else {
-		c.out.Encode(v1.Message{
+		c.send(v1.Message{
 			Name:    v1.MessageError,
 			Payload: []byte("Unable to connect. Connection already satisfied"),
 		})
@@ -320,10 +440,10 @@ func (c *clientSession) disconnectFromCard(msg v1.Message) {
 	}
 	// encode can fail, so it needs to be checked. Not sure how to handle that
 	if c.Counterparty != nil && c.Counterparty.out != nil {
-		c.Counterparty.out.Encode(out)
+		c.Counterparty.send(out)
 	}
 	if c.out != nil {
-		c.out.Encode(out)
+		c.send(out)
 	}
 	if c.Counterparty != nil {
 		c.Counterparty.Counterparty = nil
@@ -333,7 +453,25 @@ func (c *clientSession) disconnectFromCard(msg v1.Message) {
 
 func (c *clientSession) endSession(msg v1.Message) {
 	c.disconnectFromCard(msg)
-	delete(clientSessions, c.Name)
+	//the slot may already belong to a newer connection for the same card
+	if clientSessions[c.Name] == c {
+		delete(clientSessions, c.Name)
+	}
+	if c.underlyingConn != nil {
+		c.underlyingConn.Close()
+	}
+}
+
+// supersede hands this card's slot to a newer connection, telling the old client why it is being dropped
+func (c *clientSession) supersede() {
+	c.disconnectFromCard(v1.Message{})
+	err := c.send(v1.Message{
+		Name:    v1.MessageDuplicateConnection,
+		Payload: []byte("card connected from another session"),
+	})
+	if err != nil {
+		log.Error("unable to notify superseded connection: ", err)
+	}
 	if c.underlyingConn != nil {
 		c.underlyingConn.Close()
 	}
@@ -350,10 +488,18 @@ func (c *clientSession) passthrough(msg v1.Message) {
 		ret := v1.Message{
 			Name: v1.MessagePassthruFailed,
 		}
-		c.out.Encode(ret)
-	} else {
-		c.Counterparty.out.Encode(msg)
+		c.send(ret)
+		return
+	}
+	//the counterparty may have connected with another codec
+	payload, err := v1.TranscodePayload(msg.Name, msg.Payload, c.codec, c.Counterparty.codec)
+	if err != nil {
+		log.Errorf("unable to convert %v payload from %v to %v: %v", msg.Name, c.codec.Name(), c.Counterparty.codec.Name(), err)
+		c.send(v1.Message{Name: v1.MessagePassthruFailed})
+		return
 	}
+	msg.Payload = payload
+	c.Counterparty.send(msg)
 }
 
 func (c *clientSession) RequestSendPhonon(msg v1.Message) {
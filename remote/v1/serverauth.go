@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+var ErrServerAuthFailed = errors.New("jump server did not prove its identity")
+var ErrNoTLSBinding = errors.New("connection has no TLS state to bind the server's signature to")
+
+// ServerChallengeSize is the length of the challenge a client sends for the jump server to sign
+const ServerChallengeSize = 32
+
+// serverChallengeDomain is prefixed to every signed challenge so the server's key can't be used to sign anything else
+const serverChallengeDomain = "phonon jump server hello:"
+
+// tlsBindingLabel is the exporter label both sides derive the TLS binding of a server signature with
+const tlsBindingLabel = "EXPORTER-phonon-jump-server-auth"
+
+/*
+TLSBinding derives keying material unique to the TLS connection in state, as RFC 5705 exports it.
+A client and server only derive the same binding when they share one TLS connection, so a signature over it
+can't be relayed by a machine in the middle terminating TLS separately with each side.
+*/
+func TLSBinding(state *tls.ConnectionState) ([]byte, error) {
+	if state == nil {
+		return nil, ErrNoTLSBinding
+	}
+	return state.ExportKeyingMaterial(tlsBindingLabel, nil, sha256.Size)
+}
+
+func serverChallengeDigest(challenge []byte, binding []byte) []byte {
+	msg := append([]byte(serverChallengeDomain), challenge...)
+	digest := sha256.Sum256(append(msg, binding...))
+	return digest[:]
+}
+
+/*
+SignServerChallenge signs a client's RequestServerAuth challenge and the connection's TLSBinding with the
+jump server's secp256k1 identity key. TLS alone doesn't identify the server to a client skipping certificate
+verification, so a client configured with the server's public key checks the signature with VerifyServerChallenge
+before identifying its card to the server.
+*/
+func SignServerChallenge(key *ecdsa.PrivateKey, challenge []byte, binding []byte) ([]byte, error) {
+	return ethcrypto.Sign(serverChallengeDigest(challenge, binding), key)
+}
+
+// VerifyServerChallenge returns ErrServerAuthFailed unless sig is the signature of challenge and binding by the server's key
+func VerifyServerChallenge(pubKey *ecdsa.PublicKey, challenge []byte, binding []byte, sig []byte) error {
+	if len(sig) != ethcrypto.SignatureLength {
+		return ErrServerAuthFailed
+	}
+	//drop the recovery id, the expected key is already known
+	if !ethcrypto.VerifySignature(ethcrypto.FromECDSAPub(pubKey), serverChallengeDigest(challenge, binding), sig[:ethcrypto.RecoveryIDOffset]) {
+		return ErrServerAuthFailed
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestServerChallenge(t *testing.T) {
+	key, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rogue, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge := make([]byte, ServerChallengeSize)
+	challenge[0] = 0x01
+	binding := []byte("keying material of the client's TLS connection")
+	sig, err := SignServerChallenge(key, challenge, binding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = VerifyServerChallenge(&key.PublicKey, challenge, binding, sig)
+	if err != nil {
+		t.Errorf("expected the signature to verify, got %v", err)
+	}
+	err = VerifyServerChallenge(&rogue.PublicKey, challenge, binding, sig)
+	if err != ErrServerAuthFailed {
+		t.Errorf("expected %v for another key, got %v", ErrServerAuthFailed, err)
+	}
+	err = VerifyServerChallenge(&key.PublicKey, make([]byte, ServerChallengeSize), binding, sig)
+	if err != ErrServerAuthFailed {
+		t.Errorf("expected %v for another challenge, got %v", ErrServerAuthFailed, err)
+	}
+	//a signature relayed from the server's own TLS connection doesn't match the client's
+	err = VerifyServerChallenge(&key.PublicKey, challenge, []byte("keying material of the relay's TLS connection"), sig)
+	if err != ErrServerAuthFailed {
+		t.Errorf("expected %v for another TLS connection, got %v", ErrServerAuthFailed, err)
+	}
+	err = VerifyServerChallenge(&key.PublicKey, challenge, binding, nil)
+	if err != ErrServerAuthFailed {
+		t.Errorf("expected %v without a signature, got %v", ErrServerAuthFailed, err)
+	}
+}
+
+func TestTLSBinding(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	clientConfig := &tls.Config{InsecureSkipVerify: true}
+
+	handshake := func() (client *tls.Conn, server *tls.Conn) {
+		clientConn, serverConn := net.Pipe()
+		client, server = tls.Client(clientConn, clientConfig), tls.Server(serverConn, serverConfig)
+		errs := make(chan error, 1)
+		go func() { errs <- server.Handshake() }()
+		err := client.Handshake()
+		if err == nil {
+			err = <-errs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client, server
+	}
+	client, server := handshake()
+	clientState, serverState := client.ConnectionState(), server.ConnectionState()
+	clientBinding, err := TLSBinding(&clientState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverBinding, err := TLSBinding(&serverState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(clientBinding, serverBinding) {
+		t.Error("expected both ends of a connection to derive the same binding")
+	}
+	other, _ := handshake()
+	otherState := other.ConnectionState()
+	otherBinding, err := TLSBinding(&otherState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(clientBinding, otherBinding) {
+		t.Error("expected another connection to derive another binding")
+	}
+	_, err = TLSBinding(nil)
+	if err != ErrNoTLSBinding {
+		t.Errorf("expected %v without TLS, got %v", ErrNoTLSBinding, err)
+	}
+}
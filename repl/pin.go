@@ -45,10 +45,12 @@ func changeCardPIN(c *ishell.Context) {
 	if ready := checkActiveCard(c); !ready {
 		return
 	}
+	c.Println("please enter current PIN")
+	oldPIN := c.ReadPassword()
 	c.Println("please enter new numeric 6 digit PIN")
 	pin := c.ReadPassword()
-	err := activeCard.ChangePIN(pin)
+	err := activeCard.ChangePIN(oldPIN, pin)
 	if err != nil {
-		c.Println()
+		c.Err(fmt.Errorf("unable to change card PIN %s", err.Error()))
 	}
 }
@@ -0,0 +1,125 @@
+/*
+Package secure provides passphrase based encryption for data exported from the client, such as pairing state and phonon backups.
+
+Encrypted blobs are laid out as
+
+	magic (4) | version (1) | argon2 time (4) | argon2 memory KiB (4) | argon2 threads (1) | salt (16) | nonce (12) | ciphertext
+
+The key is derived from the passphrase with argon2id and the payload is sealed with AES-256-GCM.
+Everything before the ciphertext is authenticated as additional data, so any modification of the header is detected on decryption.
+*/
+package secure
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	Version uint8 = 1
+
+	saltLength   = 16
+	nonceLength  = 12
+	keyLength    = 32
+	headerLength = len(magic) + 1 + 4 + 4 + 1 + saltLength + nonceLength
+
+	defaultTime    uint32 = 3
+	defaultMemory  uint32 = 64 * 1024
+	defaultThreads uint8  = 4
+
+	// upper bounds on the stored KDF parameters so a crafted header can't make decryption exhaust resources
+	maxTime   uint32 = 16
+	maxMemory uint32 = 1024 * 1024
+)
+
+const magic = "PHSE"
+
+var (
+	ErrInvalidFormat      = errors.New("data is not in the encrypted export format")
+	ErrUnsupportedVersion = errors.New("unsupported encrypted export version")
+	ErrDecryptionFailed   = errors.New("unable to decrypt, passphrase incorrect or data tampered with")
+	ErrEmptyPassphrase    = errors.New("passphrase cannot be empty")
+)
+
+// Encrypt seals plaintext with a key derived from passphrase
+func Encrypt(passphrase []byte, plaintext []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrEmptyPassphrase
+	}
+	header := make([]byte, 0, headerLength)
+	header = append(header, magic...)
+	header = append(header, Version)
+	header = binary.BigEndian.AppendUint32(header, defaultTime)
+	header = binary.BigEndian.AppendUint32(header, defaultMemory)
+	header = append(header, defaultThreads)
+
+	salt := make([]byte, saltLength)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceLength)
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	header = append(header, salt...)
+	header = append(header, nonce...)
+
+	aead, err := newAEAD(passphrase, salt, defaultTime, defaultMemory, defaultThreads)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(header, nonce, plaintext, header), nil
+}
+
+// Decrypt opens data produced by Encrypt, returning ErrDecryptionFailed if the passphrase is wrong or the data was modified
+func Decrypt(passphrase []byte, data []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrEmptyPassphrase
+	}
+	if len(data) < headerLength || !bytes.Equal(data[:len(magic)], []byte(magic)) {
+		return nil, ErrInvalidFormat
+	}
+	header := data[:headerLength]
+	r := header[len(magic):]
+	if r[0] != Version {
+		return nil, ErrUnsupportedVersion
+	}
+	r = r[1:]
+	iterations := binary.BigEndian.Uint32(r[0:4])
+	memory := binary.BigEndian.Uint32(r[4:8])
+	threads := r[8]
+	r = r[9:]
+	if iterations == 0 || iterations > maxTime || memory == 0 || memory > maxMemory || threads == 0 {
+		return nil, ErrInvalidFormat
+	}
+	salt := r[:saltLength]
+	nonce := r[saltLength : saltLength+nonceLength]
+
+	aead, err := newAEAD(passphrase, salt, iterations, memory, threads)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, data[headerLength:], header)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
+
+func newAEAD(passphrase []byte, salt []byte, iterations uint32, memory uint32, threads uint8) (cipher.AEAD, error) {
+	key := argon2.IDKey(passphrase, salt, iterations, memory, threads, keyLength)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
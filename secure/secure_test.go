@@ -0,0 +1,64 @@
+package secure
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	plaintext := []byte("pairing state")
+	data, err := Encrypt([]byte("correct horse"), plaintext)
+	if err != nil {
+		t.Fatal("unable to encrypt: ", err)
+	}
+	if bytes.Contains(data, plaintext) {
+		t.Error("encrypted data contains the plaintext")
+	}
+	res, err := Decrypt([]byte("correct horse"), data)
+	if err != nil {
+		t.Fatal("unable to decrypt: ", err)
+	}
+	if !bytes.Equal(res, plaintext) {
+		t.Errorf("expected %q, got %q", plaintext, res)
+	}
+}
+
+func TestDecryptWrongPassphrase(t *testing.T) {
+	data, err := Encrypt([]byte("correct horse"), []byte("backup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Decrypt([]byte("battery staple"), data)
+	if err != ErrDecryptionFailed {
+		t.Errorf("expected %v, got %v", ErrDecryptionFailed, err)
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	data, err := Encrypt([]byte("correct horse"), []byte("backup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// flip a bit in the salt, which is authenticated as part of the header, and in the ciphertext
+	for _, i := range []int{len(magic) + 10, len(data) - 1} {
+		tampered := append([]byte{}, data...)
+		tampered[i] ^= 0x01
+		_, err = Decrypt([]byte("correct horse"), tampered)
+		if err != ErrDecryptionFailed {
+			t.Errorf("expected tampering at byte %d to fail decryption, got %v", i, err)
+		}
+	}
+
+	truncated := data[:headerLength-1]
+	_, err = Decrypt([]byte("correct horse"), truncated)
+	if err != ErrInvalidFormat {
+		t.Errorf("expected %v for truncated data, got %v", ErrInvalidFormat, err)
+	}
+
+	wrongVersion := append([]byte{}, data...)
+	wrongVersion[len(magic)] = Version + 1
+	_, err = Decrypt([]byte("correct horse"), wrongVersion)
+	if err != ErrUnsupportedVersion {
+		t.Errorf("expected %v, got %v", ErrUnsupportedVersion, err)
+	}
+}
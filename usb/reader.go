@@ -1,29 +1,119 @@
 package usb
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/ebfe/scard"
 	log "github.com/sirupsen/logrus"
 )
 
 var ErrReaderNotFound = errors.New("card reader not found")
+var ErrReaderTimeout = errors.New("timed out waiting for the PC/SC service")
+var ErrNoCard = errors.New("no card in reader")
 
-func ConnectAllUSBReaders() (cards []*scard.Card, err error) {
-	ctx, err := scard.EstablishContext()
+// ReaderError reports a reader that couldn't be connected to along with the attached readers, so another can be picked
+type ReaderError struct {
+	Reader  string   //the reader asked for, empty when chosen by an index with no reader
+	Readers []string //every attached reader
+	Err     error    //ErrReaderNotFound or ErrNoCard
+}
+
+func (e *ReaderError) Error() string {
+	if e.Reader == "" {
+		return fmt.Sprintf("%v, available readers: %q", e.Err, e.Readers)
+	}
+	return fmt.Sprintf("%v %q, available readers: %q", e.Err, e.Reader, e.Readers)
+}
+
+func (e *ReaderError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultReaderTimeout bounds reader enumeration for the functions that don't take a context.
+// Establishing a context can hang indefinitely on Linux when pcscd isn't running.
+const DefaultReaderTimeout = 10 * time.Second
+
+type readerContext struct {
+	ctx     *scard.Context
+	readers []string
+	err     error
+}
+
+/*
+establishContext establishes a PC/SC context and lists the attached readers, giving up when ctx is done.
+Having no readers attached isn't an error, the list is just empty. PC/SC calls can't be interrupted, so a call that outlives ctx keeps running in the background
+and releases its context whenever it finally returns.
+*/
+func establishContext(ctx context.Context) (*scard.Context, []string, error) {
+	done := make(chan readerContext, 1)
+	abandoned := make(chan struct{})
+	go func() {
+		var ret readerContext
+		ret.ctx, ret.err = scard.EstablishContext()
+		if ret.err == nil {
+			ret.readers, ret.err = ret.ctx.ListReaders()
+			if ret.err == scard.ErrNoReadersAvailable {
+				ret.readers, ret.err = nil, nil
+			}
+		}
+		select {
+		case done <- ret:
+		case <-abandoned:
+			if ret.ctx != nil {
+				ret.ctx.Release()
+			}
+		}
+	}()
+	select {
+	case ret := <-done:
+		if ret.err != nil {
+			if ret.ctx != nil {
+				ret.ctx.Release()
+			}
+			return nil, nil, ret.err
+		}
+		log.Debugf("readers: %v", ret.readers)
+		return ret.ctx, ret.readers, nil
+	case <-ctx.Done():
+		close(abandoned)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, nil, ErrReaderTimeout
+		}
+		return nil, nil, ctx.Err()
+	}
+}
+
+// ListReaders returns the names of the attached card readers, or ErrReaderTimeout if PC/SC doesn't answer before ctx's deadline
+func ListReaders(ctx context.Context) ([]string, error) {
+	scardCtx, readers, err := establishContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	readers, err := ctx.ListReaders()
+	scardCtx.Release()
+	return readers, nil
+}
+
+func ConnectAllUSBReaders() (cards []*scard.Card, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultReaderTimeout)
+	defer cancel()
+	return ConnectAllUSBReadersContext(ctx)
+}
+
+// ConnectAllUSBReadersContext is ConnectAllUSBReaders with reader enumeration bounded by ctx
+func ConnectAllUSBReadersContext(ctx context.Context) (cards []*scard.Card, err error) {
+	scardCtx, readers, err := establishContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("readers: %v", readers)
 	if len(readers) == 0 {
+		scardCtx.Release()
 		return nil, ErrReaderNotFound
 	}
 	for _, reader := range readers {
-		c, err := ctx.Connect(reader, scard.ShareShared, scard.ProtocolAny)
+		c, err := scardCtx.Connect(reader, scard.ShareShared, scard.ProtocolAny)
 		if err == nil {
 			cards = append(cards, c)
 		} else {
@@ -34,20 +124,55 @@ func ConnectAllUSBReaders() (cards []*scard.Card, err error) {
 }
 
 func ConnectUSBReader(i int) (*scard.Card, error) {
-	ctx, err := scard.EstablishContext()
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultReaderTimeout)
+	defer cancel()
+	return ConnectUSBReaderContext(ctx, i)
+}
+
+// ConnectUSBReaderContext is ConnectUSBReader with reader enumeration bounded by ctx
+func ConnectUSBReaderContext(ctx context.Context, i int) (*scard.Card, error) {
+	scardCtx, readers, err := establishContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	readers, err := ctx.ListReaders()
+	if i < 0 || i >= len(readers) {
+		scardCtx.Release()
+		return nil, &ReaderError{Readers: readers, Err: ErrReaderNotFound}
+	}
+	return connectReader(scardCtx, readers[i], readers)
+}
+
+// ConnectUSBReaderByName connects to the card in the reader with the given name, as returned by ListReaders
+func ConnectUSBReaderByName(name string) (*scard.Card, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultReaderTimeout)
+	defer cancel()
+	return ConnectUSBReaderByNameContext(ctx, name)
+}
+
+// ConnectUSBReaderByNameContext is ConnectUSBReaderByName with reader enumeration bounded by ctx
+func ConnectUSBReaderByNameContext(ctx context.Context, name string) (*scard.Card, error) {
+	scardCtx, readers, err := establishContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	log.Debugf("readers: %v", readers)
-	if len(readers) < (i + 1) {
-		return nil, ErrReaderNotFound
+	for _, reader := range readers {
+		if reader == name {
+			return connectReader(scardCtx, reader, readers)
+		}
+	}
+	scardCtx.Release()
+	return nil, &ReaderError{Reader: name, Readers: readers, Err: ErrReaderNotFound}
+}
+
+// connectReader connects to the card in reader, releasing scardCtx if it can't
+func connectReader(scardCtx *scard.Context, reader string, readers []string) (*scard.Card, error) {
+	card, err := scardCtx.Connect(reader, scard.ShareShared, scard.ProtocolAny)
+	if err == scard.ErrNoSmartcard || err == scard.ErrRemovedCard {
+		scardCtx.Release()
+		return nil, &ReaderError{Reader: reader, Readers: readers, Err: ErrNoCard}
 	}
-	card, err := ctx.Connect(readers[i], scard.ShareShared, scard.ProtocolAny)
 	if err != nil {
+		scardCtx.Release()
 		return nil, err
 	}
 	return card, nil
@@ -0,0 +1,201 @@
+package usb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ebfe/scard"
+	log "github.com/sirupsen/logrus"
+)
+
+// CardEventType is the kind of change reported by WatchReaders
+type CardEventType int
+
+const (
+	ReaderAdded   CardEventType = iota //a reader was attached
+	ReaderRemoved                      //a reader was detached, after CardRemoved if it held a card
+	CardInserted                       //a card was put in a reader
+	CardRemoved                        //a card was taken out of a reader
+)
+
+func (t CardEventType) String() string {
+	switch t {
+	case ReaderAdded:
+		return "reader added"
+	case ReaderRemoved:
+		return "reader removed"
+	case CardInserted:
+		return "card inserted"
+	case CardRemoved:
+		return "card removed"
+	default:
+		return fmt.Sprintf("CardEventType(%d)", int(t))
+	}
+}
+
+// CardEvent is a change to a reader or the card in it
+type CardEvent struct {
+	Type   CardEventType
+	Reader string
+}
+
+// pnpNotification is the PC/SC pseudo reader whose state changes whenever a reader is attached or detached
+const pnpNotification = `\\?PnP?\Notification`
+
+// watchTimeout bounds each wait for a status change, so readers are relisted where PnP notification isn't supported
+const watchTimeout = time.Second
+
+/*
+WatchReaders reports readers being attached and detached and cards being inserted and removed, waiting on
+PC/SC status changes rather than polling for cards. The readers and cards attached when it's called are
+reported first as ReaderAdded and CardInserted events, so a caller can connect to a card already present.
+
+The channel is closed once ctx is done or PC/SC fails. Events must be received promptly, as the watcher waits
+for each one to be taken before looking for further changes.
+*/
+func WatchReaders(ctx context.Context) (<-chan CardEvent, error) {
+	scardCtx, _, err := establishContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan CardEvent)
+	go watchReaders(ctx, scardCtx, events)
+	return events, nil
+}
+
+func watchReaders(ctx context.Context, scardCtx *scard.Context, events chan<- CardEvent) {
+	//cancel any status change being waited for once ctx is done, so the watcher can exit
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-ctx.Done():
+			scardCtx.Cancel()
+		case <-stop:
+		}
+	}()
+	defer func() {
+		close(stop)
+		wg.Wait()
+		scardCtx.Release()
+		close(events)
+	}()
+
+	send := func(t CardEventType, reader string) bool {
+		select {
+		case events <- CardEvent{Type: t, Reader: reader}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	known := make(map[string]scard.StateFlag) //last state of each attached reader
+	pnpState := scard.StateUnaware
+	usePnP := true
+	for {
+		readers, err := scardCtx.ListReaders()
+		if err == scard.ErrNoReadersAvailable {
+			readers, err = nil, nil
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Error("unable to list readers: ", err)
+			return
+		}
+		for _, event := range readerChanges(known, readers) {
+			if !send(event.Type, event.Reader) {
+				return
+			}
+		}
+
+		var states []scard.ReaderState
+		if usePnP {
+			states = append(states, scard.ReaderState{Reader: pnpNotification, CurrentState: pnpState})
+		}
+		for _, reader := range readers {
+			states = append(states, scard.ReaderState{Reader: reader, CurrentState: known[reader]})
+		}
+		if len(states) == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchTimeout):
+				continue
+			}
+		}
+		err = scardCtx.GetStatusChange(states, watchTimeout)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == scard.ErrUnknownReader {
+			//either a reader was detached while waiting, which relisting handles, or PnP notification isn't supported.
+			//Readers are relisted after every timeout regardless, so stop relying on PnP rather than retrying it.
+			usePnP = false
+			continue
+		}
+		if err == scard.ErrTimeout {
+			continue
+		}
+		if err != nil {
+			log.Error("unable to watch readers: ", err)
+			return
+		}
+		if usePnP {
+			pnpState = states[0].EventState &^ scard.StateChanged
+			states = states[1:]
+		}
+		for _, state := range states {
+			event, changed := cardChange(known, state)
+			if changed && !send(event.Type, event.Reader) {
+				return
+			}
+		}
+	}
+}
+
+// readerChanges updates known to the attached readers, returning events for the readers detached and attached since it was last updated
+func readerChanges(known map[string]scard.StateFlag, readers []string) []CardEvent {
+	var events []CardEvent
+	attached := make(map[string]bool, len(readers))
+	for _, reader := range readers {
+		attached[reader] = true
+	}
+	for reader, state := range known {
+		if attached[reader] {
+			continue
+		}
+		delete(known, reader)
+		if state&scard.StatePresent != 0 {
+			events = append(events, CardEvent{Type: CardRemoved, Reader: reader})
+		}
+		events = append(events, CardEvent{Type: ReaderRemoved, Reader: reader})
+	}
+	for _, reader := range readers {
+		if _, ok := known[reader]; !ok {
+			known[reader] = scard.StateUnaware
+			events = append(events, CardEvent{Type: ReaderAdded, Reader: reader})
+		}
+	}
+	return events
+}
+
+// cardChange records the reader state reported by GetStatusChange in known, returning an event if a card was inserted or removed
+func cardChange(known map[string]scard.StateFlag, state scard.ReaderState) (CardEvent, bool) {
+	previous := known[state.Reader]
+	current := state.EventState &^ scard.StateChanged
+	known[state.Reader] = current
+	if previous&scard.StatePresent == 0 && current&scard.StatePresent != 0 {
+		return CardEvent{Type: CardInserted, Reader: state.Reader}, true
+	}
+	if previous&scard.StatePresent != 0 && current&scard.StatePresent == 0 {
+		return CardEvent{Type: CardRemoved, Reader: state.Reader}, true
+	}
+	return CardEvent{}, false
+}
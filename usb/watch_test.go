@@ -0,0 +1,61 @@
+package usb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ebfe/scard"
+)
+
+func TestReaderChanges(t *testing.T) {
+	known := make(map[string]scard.StateFlag)
+	events := readerChanges(known, []string{"a", "b"})
+	expected := []CardEvent{{ReaderAdded, "a"}, {ReaderAdded, "b"}}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+	if events := readerChanges(known, []string{"a", "b"}); len(events) != 0 {
+		t.Errorf("expected no events for unchanged readers, got %v", events)
+	}
+
+	known["a"] = scard.StatePresent
+	events = readerChanges(known, []string{"b"})
+	expected = []CardEvent{{CardRemoved, "a"}, {ReaderRemoved, "a"}}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected a reader detached with a card in it to report both, got %v", events)
+	}
+	if _, ok := known["a"]; ok {
+		t.Error("expected a detached reader to be forgotten")
+	}
+	events = readerChanges(known, nil)
+	expected = []CardEvent{{ReaderRemoved, "b"}}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+}
+
+func TestCardChange(t *testing.T) {
+	known := map[string]scard.StateFlag{"a": scard.StateUnaware}
+	steps := []struct {
+		state    scard.StateFlag
+		expected []CardEvent
+	}{
+		{scard.StateEmpty | scard.StateChanged, nil},
+		{scard.StatePresent | scard.StateChanged, []CardEvent{{CardInserted, "a"}}},
+		{scard.StatePresent | scard.StateInuse | scard.StateChanged, nil},
+		{scard.StateEmpty | scard.StateChanged, []CardEvent{{CardRemoved, "a"}}},
+	}
+	for i, step := range steps {
+		var events []CardEvent
+		event, changed := cardChange(known, scard.ReaderState{Reader: "a", EventState: step.state})
+		if changed {
+			events = append(events, event)
+		}
+		if !reflect.DeepEqual(events, step.expected) {
+			t.Errorf("step %d: expected %v, got %v", i, step.expected, events)
+		}
+		if known["a"]&scard.StateChanged != 0 {
+			t.Errorf("step %d: expected the changed flag to be cleared from the recorded state", i)
+		}
+	}
+}
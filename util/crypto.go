@@ -75,6 +75,13 @@ func ParseECCPrivKey(privKey []byte) (*ecdsa.PrivateKey, error) {
 	return eccPrivKey, nil
 }
 
+// ZeroBytes overwrites b so key material doesn't linger in memory once it's no longer needed
+func ZeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
 func CardIDFromPubKey(pubKey *ecdsa.PublicKey) string {
 	return ECCPubKeyToHexString(pubKey)[:16]
 }
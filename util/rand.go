@@ -0,0 +1,45 @@
+package util
+
+import (
+	"crypto/rand"
+	"io"
+	"sync/atomic"
+)
+
+// randHolder keeps the stored type constant, which atomic.Value requires
+type randHolder struct {
+	r io.Reader
+}
+
+var randSource atomic.Value
+
+func init() {
+	randSource.Store(randHolder{rand.Reader})
+}
+
+// Rand returns the source of randomness for the challenges, salts and keys generated by this client.
+// It is crypto/rand.Reader unless a test has replaced it with SetRandForTesting.
+func Rand() io.Reader {
+	return randSource.Load().(randHolder).r
+}
+
+/*
+SetRandForTesting replaces the source returned by Rand with r, so tests can run challenge/response,
+pairing and key generation flows with known values. It returns a function restoring the previous source.
+
+This is for tests only. Any source other than crypto/rand.Reader makes every secret the client generates
+predictable. Go's ecdsa package may mix in system randomness, so signatures are not guaranteed reproducible.
+*/
+func SetRandForTesting(r io.Reader) (restore func()) {
+	previous := randSource.Load()
+	randSource.Store(randHolder{r})
+	return func() {
+		randSource.Store(previous)
+	}
+}
+
+// ReadRandom fills b from Rand, returning an error rather than leaving b partially filled
+func ReadRandom(b []byte) error {
+	_, err := io.ReadFull(Rand(), b)
+	return err
+}
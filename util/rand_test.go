@@ -0,0 +1,39 @@
+package util
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("entropy unavailable")
+}
+
+func TestSetRandForTesting(t *testing.T) {
+	seed := bytes.Repeat([]byte{0xAB}, 64)
+	restore := SetRandForTesting(bytes.NewReader(seed))
+	key := RandomKey(32)
+	if !bytes.Equal(key, seed[:32]) {
+		t.Errorf("expected key read from the test source, got % X", key)
+	}
+	err := ReadRandom(make([]byte, 64))
+	if err == nil {
+		t.Error("expected an error reading past the end of the test source")
+	}
+	restore()
+
+	if Rand() != rand.Reader {
+		t.Error("expected restore to reinstate crypto/rand.Reader")
+	}
+
+	restore = SetRandForTesting(failingReader{})
+	defer restore()
+	err = ReadRandom(make([]byte, 16))
+	if err == nil {
+		t.Error("expected ReadRandom to report a failing source")
+	}
+}
@@ -2,16 +2,20 @@ package util
 
 import (
 	"bytes"
-	"crypto/rand"
 	"encoding/binary"
 	"fmt"
 
 	"github.com/manifoldco/promptui"
 )
 
+// RandomKey returns length bytes read from Rand, panicking if no randomness is available
+// since carrying on with a predictable key would be worse
 func RandomKey(length int) []byte {
 	key := make([]byte, length)
-	rand.Read(key)
+	err := ReadRandom(key)
+	if err != nil {
+		panic(fmt.Sprintf("unable to read random bytes: %v", err))
+	}
 	return key
 }
 
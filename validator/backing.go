@@ -0,0 +1,83 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+// BalanceReporter is implemented by validators that can report how much is held by a phonon's addresses,
+// in the base units of its currency
+type BalanceReporter interface {
+	Balance(phonon *model.Phonon) (*big.Int, error)
+}
+
+/*
+Backing is the on chain backing found for a phonon by CheckBackingAll. Shortfall is how much less than the
+phonon's denomination is backed, and is zero for a fully backed phonon. Balance is nil when the validator
+can't report balances, in which case a phonon failing validation is short its whole denomination.
+*/
+type Backing struct {
+	Phonon    *model.Phonon
+	Balance   *big.Int
+	Shortfall *big.Int
+	Err       error
+}
+
+// Backed reports whether the phonon was checked and is fully backed
+func (b Backing) Backed() bool {
+	return b.Err == nil && b.Shortfall.Sign() == 0
+}
+
+/*
+CheckBackingAll checks how much backs each phonon, batching by currency as ValidateAll does.
+Validators implementing BalanceReporter are asked for the balance, which is compared with the denomination,
+while other validators only decide between fully backed and not backed at all.
+A phonon whose key has been used to spend, so is reported ErrPhononCompromised, has no backing.
+*/
+func CheckBackingAll(ctx context.Context, phonons []*model.Phonon, maxConcurrent int) []Backing {
+	backings := make([]Backing, len(phonons))
+	forEachByCurrency(ctx, phonons, maxConcurrent, func(v Validator, i int, err error) {
+		backings[i].Phonon = phonons[i]
+		if err != nil {
+			backings[i].Err = err
+			return
+		}
+		backings[i] = checkBacking(v, phonons[i])
+	})
+	return backings
+}
+
+func checkBacking(v Validator, phonon *model.Phonon) Backing {
+	backing := Backing{Phonon: phonon}
+	denomination := phonon.Denomination.Value()
+	reporter, ok := v.(BalanceReporter)
+	if !ok {
+		valid, err := v.Validate(phonon)
+		if err != nil {
+			backing.Err = err
+			return backing
+		}
+		backing.Shortfall = big.NewInt(0)
+		if !valid {
+			backing.Shortfall = denomination
+		}
+		return backing
+	}
+	balance, err := reporter.Balance(phonon)
+	if errors.Is(err, ErrPhononCompromised) {
+		balance, err = big.NewInt(0), nil
+	}
+	if err != nil {
+		backing.Err = err
+		return backing
+	}
+	backing.Balance = balance
+	backing.Shortfall = new(big.Int).Sub(denomination, balance)
+	if backing.Shortfall.Sign() < 0 {
+		backing.Shortfall.SetInt64(0)
+	}
+	return backing
+}
@@ -0,0 +1,49 @@
+package validator
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+func TestCheckBackingAll(t *testing.T) {
+	denomination, err := model.NewDenomination(big.NewInt(5000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	balances := make(map[string]int64)
+	var phonons []*model.Phonon
+	for _, balance := range []int64{5000, 3000} {
+		p := newTestPhonon(t, model.Bitcoin)
+		p.Denomination = denomination
+		addresses, err := pubKeyToAddresses(p.PubKey.(*model.ECCPubKey).PubKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		balances[addresses[0]] = balance
+		phonons = append(phonons, p)
+	}
+	invalid := newTestPhonon(t, model.Ethereum)
+	invalid.Denomination = denomination
+	phonons = append(phonons, invalid)
+
+	eth := NewMockValidator()
+	eth.Script(invalid.PubKey, MockResult{Valid: false})
+	Register(model.Bitcoin, NewOfflineValidator(balances))
+	Register(model.Ethereum, eth)
+	defer Unregister(model.Bitcoin)
+	defer Unregister(model.Ethereum)
+
+	backings := CheckBackingAll(context.Background(), phonons, 0)
+	if !backings[0].Backed() || backings[0].Balance.Int64() != 5000 {
+		t.Errorf("expected the fully funded phonon to be backed, got %+v", backings[0])
+	}
+	if backings[1].Backed() || backings[1].Shortfall.Int64() != 2000 {
+		t.Errorf("expected a shortfall of 2000, got %+v", backings[1])
+	}
+	if backings[2].Backed() || backings[2].Balance != nil || backings[2].Shortfall.Int64() != 5000 {
+		t.Errorf("expected an invalid phonon without a balance to be short its denomination, got %+v", backings[2])
+	}
+}
@@ -6,9 +6,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/big"
 	"net/http"
+	"time"
 
+	"github.com/GridPlus/phonon-client/metrics"
 	"github.com/GridPlus/phonon-client/model"
 	"github.com/GridPlus/phonon-client/util"
 	"github.com/btcsuite/btcd/btcec"
@@ -20,9 +24,12 @@ import (
 )
 
 var ErrPhononCompromised error = errors.New("transaction with phonon as sender detected")
+var ErrInvalidHeight = errors.New("block height must not be negative")
 
 type BTCValidator struct {
-	bclient *bcoinClient
+	bclient          *bcoinClient
+	minConfirmations int64
+	params           *chaincfg.Params //network the phonons' addresses are generated for
 }
 
 const transactionRequestLimit int = 100
@@ -30,54 +37,228 @@ const transactionRequestLimit int = 100
 type bcoinClient struct {
 	url       string
 	authtoken string
-	client    http.Client
+	client    *http.Client
+	cache     *transactionCache
+}
+
+// ClientOption configures a bcoin client made with NewClient
+type ClientOption func(*bcoinClient)
+
+// WithHTTPClient makes requests to bcoin with c, for setting a timeout, TLS configuration, proxy or test transport.
+// A nil c keeps the default client.
+func WithHTTPClient(c *http.Client) ClientOption {
+	return func(bc *bcoinClient) {
+		if c != nil {
+			bc.client = c
+		}
+	}
 }
 
 func NewBTCValidator(c *bcoinClient) *BTCValidator {
+	return NewBTCValidatorForNetwork(c, &chaincfg.MainNetParams)
+}
+
+// NewBTCValidatorForNetwork returns a validator generating addresses for the given network, such as
+// chaincfg.TestNet3Params or chaincfg.RegressionNetParams for a local regtest node. A nil params selects mainnet.
+func NewBTCValidatorForNetwork(c *bcoinClient, params *chaincfg.Params) *BTCValidator {
+	if params == nil {
+		params = &chaincfg.MainNetParams
+	}
 	return &BTCValidator{
 		bclient: c,
+		params:  params,
 	}
 }
 
-func NewClient(url string, authToken string) *bcoinClient {
-	return &bcoinClient{
+func NewClient(url string, authToken string, opts ...ClientOption) *bcoinClient {
+	bc := &bcoinClient{
 		url:       url,
 		authtoken: authToken,
-		client:    http.Client{},
+		client:    &http.Client{},
+	}
+	for _, opt := range opts {
+		opt(bc)
 	}
+	return bc
+}
+
+// ValidationReport details the on chain state backing a phonon
+type ValidationReport struct {
+	Valid   bool
+	Balance int64
+	// Funding lists every transaction output paying one of the phonon's addresses
+	Funding []FundingOutput
+}
+
+// FundingOutput is a single transaction output that funded a phonon
+type FundingOutput struct {
+	TxID    string
+	Address string
+	Value   int64
+}
+
+// Outpoint is an unspent transaction output paying one of a phonon's addresses
+type Outpoint struct {
+	TxID    string
+	Vout    uint32
+	Value   int64
+	Address string
+}
+
+func (o Outpoint) String() string {
+	return fmt.Sprintf("%s:%d", o.TxID, o.Vout)
 }
 
 // Validate returns true if the balance associated with the public key
 // on the bitcoin phonon is greater than or equal to the balance stated in
 // the phonon using as many known address generation functions as reasonable.
-// Currently: P2SH script and P2PKH addresses.
+// Currently: P2SH script and P2PKH addresses. A phonon with no balance is never valid.
 func (b *BTCValidator) Validate(phonon *model.Phonon) (bool, error) {
+	return b.ValidateWithContext(context.Background(), phonon)
+}
+
+// ValidateWithContext performs the same check as Validate, abandoning any requests to bcoin once ctx is done
+func (b *BTCValidator) ValidateWithContext(ctx context.Context, phonon *model.Phonon) (bool, error) {
+	report, err := b.validateReport(ctx, phonon, chainTip)
+	if err != nil {
+		return false, err
+	}
+	return report.Valid, nil
+}
+
+// ValidateReport performs the same check as Validate, additionally returning the
+// balance and the funding transactions found for the phonon
+func (b *BTCValidator) ValidateReport(phonon *model.Phonon) (*ValidationReport, error) {
+	return b.validateReport(context.Background(), phonon, chainTip)
+}
+
+// GetBalance returns the satoshis held by the phonon's addresses, or ErrPhononCompromised if any have been spent from.
+// Unconfirmed deposits are counted unless excluded with SetMinConfirmations.
+func (b *BTCValidator) GetBalance(phonon *model.Phonon) (int64, error) {
+	report, err := b.ValidateReport(phonon)
+	if err != nil {
+		return 0, err
+	}
+	return report.Balance, nil
+}
+
+// Balance returns the satoshis held by the phonon's addresses, or ErrPhononCompromised if any have been spent from
+func (b *BTCValidator) Balance(phonon *model.Phonon) (*big.Int, error) {
+	balance, err := b.GetBalance(phonon)
+	if err != nil {
+		return nil, err
+	}
+	return big.NewInt(balance), nil
+}
+
+// ValidateReportAtHeight reports the phonon's backing as it stood at the given block height,
+// counting only transactions confirmed at or below it. Later deposits and spends are ignored,
+// so a phonon spent since can still be shown to have been backed at that height.
+func (b *BTCValidator) ValidateReportAtHeight(phonon *model.Phonon, height int64) (*ValidationReport, error) {
+	if height < 0 {
+		return nil, ErrInvalidHeight
+	}
+	return b.validateReport(context.Background(), phonon, height)
+}
+
+/*
+SetMinConfirmations makes Validate and ValidateReport count deposits only once they have at least n confirmations.
+Spends from the phonon's addresses are counted however recent they are, so an unconfirmed spend still marks
+the phonon compromised. The default of 0 counts every known transaction, including unconfirmed ones.
+*/
+func (b *BTCValidator) SetMinConfirmations(n int64) {
+	b.minConfirmations = n
+}
+
+// chainTip selects every known transaction, including unconfirmed ones, when passed as a height
+const chainTip int64 = -1
+
+func (b *BTCValidator) validateReport(ctx context.Context, phonon *model.Phonon, height int64) (*ValidationReport, error) {
+	if !b.Configured() {
+		return nil, ErrBackendUnavailable
+	}
 	// get the public key of the phonon
 	key, err := util.ParseECCPubKey(phonon.PubKey.Bytes())
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	// turn it into an address
-	addresses, err := pubKeyToAddresses(key)
+	addresses, err := pubKeyToNetworkAddresses(key, b.params)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	// get balance of address
-	balance, err := b.getBalance(addresses)
+	balance, funding, err := b.getBalance(ctx, addresses, height)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+
+	return &ValidationReport{
+		Valid:   balance != 0 && big.NewInt(balance).Cmp(phonon.Denomination.Value()) >= 0,
+		Balance: balance,
+		Funding: funding,
+	}, nil
+}
+
+// FundingOutpoints returns the unspent outputs currently backing the phonon, so a wallet can
+// record exactly which coins fund it and notice if any are spent
+func (b *BTCValidator) FundingOutpoints(phonon *model.Phonon) ([]Outpoint, error) {
+	if !b.Configured() {
+		return nil, ErrBackendUnavailable
+	}
+	if phonon.PubKey == nil {
+		return nil, ErrMissingPubKey
+	}
+	key, err := util.ParseECCPubKey(phonon.PubKey.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := pubKeyToNetworkAddresses(key, b.params)
+	if err != nil {
+		return nil, err
 	}
+	return b.bclient.GetCoins(context.Background(), addresses)
+}
+
+// Configured reports whether a bcoin backend has been supplied to the validator
+func (b *BTCValidator) Configured() bool {
+	return b.bclient != nil && b.bclient.url != ""
+}
 
-	if balance == 0 {
-		return false, nil
+// Ping checks that the configured bcoin backend is reachable
+func (b *BTCValidator) Ping(ctx context.Context) error {
+	if !b.Configured() {
+		return ErrBackendUnavailable
 	}
+	return b.bclient.ping(ctx)
+}
 
-	return true, nil
+// network returns the bitcoin network the validator derives addresses for
+func (b *BTCValidator) network() *chaincfg.Params {
+	return b.params
 }
 
+// networkValidator is implemented by validators deriving the addresses of a configurable bitcoin network
+type networkValidator interface {
+	network() *chaincfg.Params
+}
+
+// validatorNetwork returns the network v derives addresses for, mainnet unless it is a networkValidator
+func validatorNetwork(v Validator) *chaincfg.Params {
+	if n, ok := v.(networkValidator); ok {
+		return n.network()
+	}
+	return &chaincfg.MainNetParams
+}
+
+// pubKeyToAddresses returns the mainnet addresses a bitcoin phonon's key may have been funded at
 func pubKeyToAddresses(key *ecdsa.PublicKey) ([]string, error) {
+	return pubKeyToNetworkAddresses(key, &chaincfg.MainNetParams)
+}
+
+func pubKeyToNetworkAddresses(key *ecdsa.PublicKey, params *chaincfg.Params) ([]string, error) {
 	btcpubkey := btcec.PublicKey{
 		Curve: key.Curve,
 		X:     key.X,
@@ -92,14 +273,14 @@ func pubKeyToAddresses(key *ecdsa.PublicKey) ([]string, error) {
 	}
 
 	for _, x := range serializationFunctions {
-		k, err := btcutil.NewAddressPubKey(x(), &chaincfg.MainNetParams)
+		k, err := btcutil.NewAddressPubKey(x(), params)
 		if err != nil {
 			log.Debug("Error Generating Address From Public Key")
 			return []string{}, err
 		}
 		ret = append(ret, k.EncodeAddress())
 
-		witnessKeyHash, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(x()), &chaincfg.MainNetParams)
+		witnessKeyHash, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(x()), params)
 		if err != nil {
 			log.Debug("Error Generating Address Witness From Public Key")
 			return []string{}, err
@@ -111,7 +292,7 @@ func pubKeyToAddresses(key *ecdsa.PublicKey) ([]string, error) {
 			return []string{}, err
 
 		}
-		addrScriptHash, err := btcutil.NewAddressScriptHash(script, &chaincfg.MainNetParams)
+		addrScriptHash, err := btcutil.NewAddressScriptHash(script, params)
 		if err != nil {
 			log.Debug("Error Generating Address From PayToAddressScript")
 			return []string{}, err
@@ -123,28 +304,43 @@ func pubKeyToAddresses(key *ecdsa.PublicKey) ([]string, error) {
 	return ret, nil
 }
 
-func (b *BTCValidator) getBalance(addresses []string) (int64, error) {
+func (b *BTCValidator) getBalance(ctx context.Context, addresses []string, height int64) (int64, []FundingOutput, error) {
 	//get transactions
-	transactions, err := b.bclient.GetTransactions(context.Background(), addresses)
+	transactions, err := b.bclient.GetTransactions(ctx, addresses)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
+	}
+	if height != chainTip {
+		transactions = transactions.confirmedAt(height)
+	} else if b.minConfirmations > 0 {
+		transactions, err = b.bclient.withMinConfirmations(ctx, transactions, addresses, b.minConfirmations)
+		if err != nil {
+			return 0, nil, err
+		}
 	}
 	//aggregate transactions into a running balance
-	balance, err := aggregateTransactions(transactions, addresses)
+	balance, funding, err := aggregateFunding(transactions, addresses)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 	log.Debug("Balance retrieved:", balance)
-	return balance, nil
+	return balance, funding, nil
 }
 
 func aggregateTransactions(txl transactionList, addresses []string) (int64, error) {
+	balance, _, err := aggregateFunding(txl, addresses)
+	return balance, err
+}
+
+// aggregateFunding sums the outputs paying any of the addresses, keeping track of each funding output along the way
+func aggregateFunding(txl transactionList, addresses []string) (int64, []FundingOutput, error) {
 	var runningTotal int64 = 0
+	var funding []FundingOutput
 	for _, transaction := range txl {
 		for _, input := range transaction.Inputs {
 			for _, address := range addresses {
 				if input.Coin.Address == address {
-					return 0, ErrPhononCompromised
+					return 0, nil, ErrPhononCompromised
 				}
 			}
 		}
@@ -152,43 +348,132 @@ func aggregateTransactions(txl transactionList, addresses []string) (int64, erro
 			for _, address := range addresses {
 				if output.Address == address {
 					runningTotal += output.Value
+					funding = append(funding, FundingOutput{
+						TxID:    transaction.Hash,
+						Address: output.Address,
+						Value:   output.Value,
+					})
 				}
 			}
 		}
 	}
-	return runningTotal, nil
+	return runningTotal, funding, nil
 }
 
 func (bc *bcoinClient) GetTransactions(ctx context.Context, addresses []string) (transactionList, error) {
 	var ret transactionList
 	for _, address := range addresses {
-		url := fmt.Sprintf("%s/tx/address/%s?limit=%d", bc.url, address, transactionRequestLimit)
-		var listPart transactionList
-		listPart, err := bc.getTransactionList(ctx, url)
+		txl, ok := bc.cache.get(address)
+		if !ok {
+			var err error
+			txl, err = bc.getAddressTransactions(ctx, address)
+			if err != nil {
+				return nil, err
+			}
+			bc.cache.put(address, txl)
+		}
+		ret = append(ret, txl...)
+	}
+	return ret, nil
+}
+
+// getAddressTransactions fetches every page of transactions involving address
+func (bc *bcoinClient) getAddressTransactions(ctx context.Context, address string) (transactionList, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	url := fmt.Sprintf("%s/tx/address/%s?limit=%d", bc.url, address, transactionRequestLimit)
+	ret, err := bc.getTransactionList(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	listPart := ret
+	// As long as we are getting a full list, keep checking for more and adding them to the list
+	for len(listPart) == transactionRequestLimit {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// Add limit parameters to url
+		url := fmt.Sprintf("%s/tx/address/%s?limit=%d&after=%s", bc.url, address, transactionRequestLimit, ret[len(ret)-1].Hash)
+		listPart, err = bc.getTransactionList(ctx, url)
 		if err != nil {
 			return nil, err
 		}
 		ret = append(ret, listPart...)
-		// As long as we are getting a full list, keep checking for more and adding them to the list
-		for len(listPart) == transactionRequestLimit {
-			// Add limit parameters to url
-			url := fmt.Sprintf("%s/tx/address/%s?limit=%d&after=%s", bc.url, address, transactionRequestLimit, ret[len(ret)-1].Hash)
-			listPart, err = bc.getTransactionList(ctx, url)
-			if err != nil {
-				return nil, err
-			}
-			newret := append(ret, listPart...)
-			ret = newret
+	}
+	return ret, nil
+}
+
+// withMinConfirmations drops the deposits with fewer than min confirmations. bcoin's confirmations field is used
+// when every transaction carries it, otherwise confirmations are counted from the chain tip and each block height.
+func (bc *bcoinClient) withMinConfirmations(ctx context.Context, txl transactionList, addresses []string, min int64) (transactionList, error) {
+	if !txl.confirmationsKnown() {
+		tip, err := bc.tipHeight(ctx)
+		if err != nil {
+			return nil, err
+		}
+		txl.countConfirmations(tip)
+	}
+	ret := transactionList{}
+	for _, transaction := range txl {
+		if transaction.Confirmations >= min || transaction.Inputs.spendFrom(addresses) {
+			ret = append(ret, transaction)
 		}
 	}
 	return ret, nil
 }
 
+// tipHeight returns the height of the best block known to bcoin
+func (bc *bcoinClient) tipHeight(ctx context.Context) (int64, error) {
+	var info struct {
+		Chain struct {
+			Height int64 `json:"height"`
+		} `json:"chain"`
+	}
+	err := bc.getJSON(ctx, bc.url, &info)
+	if err != nil {
+		return 0, err
+	}
+	return info.Chain.Height, nil
+}
+
 func (bc *bcoinClient) getTransactionList(ctx context.Context, url string) (transactionList, error) {
+	var ret = transactionList{}
+	err := bc.getJSON(ctx, url, &ret)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// GetCoins lists the unspent outputs paying any of the addresses
+func (bc *bcoinClient) GetCoins(ctx context.Context, addresses []string) ([]Outpoint, error) {
+	var ret []Outpoint
+	for _, address := range addresses {
+		url := fmt.Sprintf("%s/coin/address/%s", bc.url, address)
+		var coins []coin
+		err := bc.getJSON(ctx, url, &coins)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range coins {
+			ret = append(ret, Outpoint{
+				TxID:    c.Hash,
+				Vout:    c.Index,
+				Value:   c.Value,
+				Address: c.Address,
+			})
+		}
+	}
+	return ret, nil
+}
+
+func (bc *bcoinClient) getJSON(ctx context.Context, url string, v interface{}) (err error) {
+	defer observeBcoinRequest(time.Now(), &err)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		log.Debug("Unable to create request to bcoin api")
-		return nil, err
+		return err
 	}
 
 	if bc.authtoken != "" {
@@ -198,33 +483,124 @@ func (bc *bcoinClient) getTransactionList(ctx context.Context, url string) (tran
 	resp, err := bc.client.Do(req)
 	if err != nil {
 		log.Debug("Error making request to bcoin")
-		return nil, err
+		return err
+	}
+	defer closeBody(resp)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		log.Debug("bcoin api returned status ", resp.StatusCode)
+		return ErrBackendUnavailable
 	}
-	var ret = transactionList{}
 	retBytes, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		log.Debug("Unable to read response from bcoin api")
-		return nil, err
+		return err
 	}
 
-	err = json.Unmarshal(retBytes, &ret)
+	err = json.Unmarshal(retBytes, v)
 	if err != nil {
 		log.Debug("Unable to unmarshal Json response from bcoin")
-		return nil, err
+		return err
 	}
-	return ret, nil
+	return nil
+}
+
+func (bc *bcoinClient) ping(ctx context.Context) (err error) {
+	defer observeBcoinRequest(time.Now(), &err)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bc.url, nil)
+	if err != nil {
+		return err
+	}
+	if bc.authtoken != "" {
+		req.SetBasicAuth("x", bc.authtoken)
+	}
+	resp, err := bc.client.Do(req)
+	if err != nil {
+		log.Debug("unable to reach bcoin api: ", err)
+		return ErrBackendUnavailable
+	}
+	defer closeBody(resp)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return ErrBackendUnavailable
+	}
+	return nil
+}
+
+var bcoinLabels = map[string]string{"backend": "bcoin"}
+
+// observeBcoinRequest reports a finished bcoin request and its outcome to the installed metrics
+func observeBcoinRequest(start time.Time, err *error) {
+	metrics.Inc(metrics.ValidatorRequests, bcoinLabels)
+	metrics.ObserveSince(metrics.ValidatorRequestDuration, start, bcoinLabels)
+	if *err != nil {
+		metrics.Inc(metrics.ValidatorRequestErrors, bcoinLabels)
+	}
+}
+
+// closeBody drains and closes a response body so the connection can be reused for the next request.
+// Validating a phonon makes a request per candidate address, so without reuse a long validation run
+// opens a new connection for every request.
+func closeBody(resp *http.Response) {
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
 }
 
 type transactionList []struct {
-	Hash    string  `json:"hash"`
-	Inputs  Inputs  `json:"inputs"`
-	Outputs Outputs `json:"outputs"`
+	Hash          string  `json:"hash"`
+	Height        int64   `json:"height"`        //-1 while unconfirmed
+	Confirmations int64   `json:"confirmations"` //only returned by some bcoin configurations
+	Inputs        Inputs  `json:"inputs"`
+	Outputs       Outputs `json:"outputs"`
+}
+
+// confirmationsKnown reports whether bcoin returned confirmations for every transaction.
+// A mined transaction has at least one, so a zero alongside a block height means the field was left out.
+func (txl transactionList) confirmationsKnown() bool {
+	for _, transaction := range txl {
+		if transaction.Height >= 0 && transaction.Confirmations <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// countConfirmations fills in each transaction's confirmations from the height of the chain tip
+func (txl transactionList) countConfirmations(tip int64) {
+	for i := range txl {
+		if txl[i].Height < 0 {
+			txl[i].Confirmations = 0
+			continue
+		}
+		txl[i].Confirmations = tip - txl[i].Height + 1
+	}
+}
+
+// confirmedAt returns the transactions confirmed in a block at or below height
+func (txl transactionList) confirmedAt(height int64) transactionList {
+	ret := transactionList{}
+	for _, transaction := range txl {
+		if transaction.Height >= 0 && transaction.Height <= height {
+			ret = append(ret, transaction)
+		}
+	}
+	return ret
 }
 
 type Inputs []struct {
 	Coin Coin `json:"coin"`
 }
 
+// spendFrom reports whether any of the inputs spends a coin paying one of the addresses
+func (inputs Inputs) spendFrom(addresses []string) bool {
+	for _, input := range inputs {
+		for _, address := range addresses {
+			if input.Coin.Address == address {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 type Outputs []output
 
 type output struct {
@@ -236,3 +612,11 @@ type Coin struct {
 	Value   int64  `json:"value"`
 	Address string `json:"address"`
 }
+
+// coin is an unspent output as returned by the bcoin coin endpoints
+type coin struct {
+	Hash    string `json:"hash"`
+	Index   uint32 `json:"index"`
+	Value   int64  `json:"value"`
+	Address string `json:"address"`
+}
@@ -0,0 +1,84 @@
+package validator
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"testing"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+func benchmarkKeys(b *testing.B, n int) []*ecdsa.PublicKey {
+	keys := make([]*ecdsa.PublicKey, n)
+	for i := range keys {
+		priv, err := ethcrypto.GenerateKey()
+		if err != nil {
+			b.Fatal("unable to generate key: ", err)
+		}
+		keys[i] = &priv.PublicKey
+	}
+	return keys
+}
+
+// benchmarkTransactions builds a list of n transactions each paying a mix of unrelated addresses and the given address
+func benchmarkTransactions(n int, address string) transactionList {
+	txl := make(transactionList, n)
+	for i := range txl {
+		txl[i].Hash = fmt.Sprintf("tx%d", i)
+		txl[i].Inputs = Inputs{{Coin: Coin{Value: 100, Address: fmt.Sprintf("sender%d", i)}}}
+		txl[i].Outputs = Outputs{
+			{Value: 60, Address: fmt.Sprintf("change%d", i)},
+			{Value: 40, Address: address},
+		}
+	}
+	return txl
+}
+
+func BenchmarkPubKeyToAddresses(b *testing.B) {
+	key := benchmarkKeys(b, 1)[0]
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := pubKeyToAddresses(key)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAggregateTransactions(b *testing.B) {
+	for _, n := range []int{10, 100, 1000, 10000} {
+		b.Run(fmt.Sprintf("%d", n), func(b *testing.B) {
+			txl := benchmarkTransactions(n, "phononAddress")
+			addresses := []string{"a1", "a2", "a3", "a4", "a5", "phononAddress"}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := aggregateTransactions(txl, addresses)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkValidate1000Phonons measures the local cost of validating 1,000 phonons,
+// excluding the bcoin requests, with a handful of funding transactions each
+func BenchmarkValidate1000Phonons(b *testing.B) {
+	keys := benchmarkKeys(b, 1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, key := range keys {
+			addresses, err := pubKeyToAddresses(key)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.StopTimer()
+			txl := benchmarkTransactions(5, addresses[0])
+			b.StartTimer()
+			_, err = aggregateTransactions(txl, addresses)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
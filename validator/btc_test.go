@@ -1,12 +1,23 @@
 package validator
 
 import (
+	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/GridPlus/phonon-client/model"
 	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 )
 
 // Helper type for table testing
@@ -179,3 +190,408 @@ var list3 transactionList = transactionList{
 		},
 	},
 }
+
+func TestAggregateFunding(t *testing.T) {
+	balance, funding, err := aggregateFunding(list3, []string{"phononAddress", "phononAddress2"})
+	if err != nil {
+		t.Fatal("unable to aggregate funding: ", err)
+	}
+	if balance != 149 {
+		t.Errorf("expected balance of 149, got %d", balance)
+	}
+	expected := []FundingOutput{
+		{TxID: "NoNeedHere", Address: "phononAddress", Value: 49},
+		{TxID: "NoNeedHere", Address: "phononAddress2", Value: 51},
+		{TxID: "NoNeedHere", Address: "phononAddress", Value: 49},
+	}
+	if !reflect.DeepEqual(funding, expected) {
+		t.Errorf("expected funding outputs %+v, got %+v", expected, funding)
+	}
+}
+
+func TestFundingOutpoints(t *testing.T) {
+	priv, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses, err := pubKeyToAddresses(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	funded := addresses[1]
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/coin/address/"+funded {
+			w.Write([]byte("[]"))
+			return
+		}
+		fmt.Fprintf(w, `[{"hash":"aa11","index":1,"value":5000,"address":"%s"},{"hash":"bb22","index":0,"value":700,"address":"%s"}]`, funded, funded)
+	}))
+	defer srv.Close()
+
+	phonon := &model.Phonon{PubKey: &model.ECCPubKey{PubKey: &priv.PublicKey}}
+	outpoints, err := NewBTCValidator(NewClient(srv.URL, "")).FundingOutpoints(phonon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Outpoint{
+		{TxID: "aa11", Vout: 1, Value: 5000, Address: funded},
+		{TxID: "bb22", Vout: 0, Value: 700, Address: funded},
+	}
+	if !reflect.DeepEqual(outpoints, expected) {
+		t.Errorf("expected outpoints %v, got %v", expected, outpoints)
+	}
+	if outpoints[0].String() != "aa11:1" {
+		t.Errorf("unexpected outpoint string %s", outpoints[0])
+	}
+
+	_, err = NewBTCValidator(NewClient("", "")).FundingOutpoints(phonon)
+	if err != ErrBackendUnavailable {
+		t.Errorf("expected %v without a backend, got %v", ErrBackendUnavailable, err)
+	}
+}
+
+func TestValidateSurfacesBackendErrors(t *testing.T) {
+	priv, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	phonon := &model.Phonon{PubKey: &model.ECCPubKey{PubKey: &priv.PublicKey}}
+
+	valid, err := NewBTCValidator(NewClient(srv.URL, "")).Validate(phonon)
+	if err != ErrBackendUnavailable || valid {
+		t.Errorf("expected a failed fetch to return %v rather than an invalid phonon, got %v, %v", ErrBackendUnavailable, valid, err)
+	}
+}
+
+func TestValidateComparesDenomination(t *testing.T) {
+	priv, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses, err := pubKeyToAddresses(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tx/address/" + addresses[0]:
+			fmt.Fprintf(w, `[{"hash":"deposit","height":100,"inputs":[],"outputs":[{"value":3000,"address":"%[1]s"}]},`+
+				`{"hash":"pending","height":-1,"inputs":[],"outputs":[{"value":2000,"address":"%[1]s"}]}]`, addresses[0])
+		case "/":
+			w.Write([]byte(`{"chain":{"height":100}}`))
+		default:
+			w.Write([]byte("[]"))
+		}
+	}))
+	defer srv.Close()
+	phonon := &model.Phonon{
+		PubKey:       &model.ECCPubKey{PubKey: &priv.PublicKey},
+		Denomination: model.Denomination{Base: 5, Exponent: 3},
+	}
+	v := NewBTCValidator(NewClient(srv.URL, ""))
+
+	balance, err := v.GetBalance(phonon)
+	if err != nil || balance != 5000 {
+		t.Errorf("expected a balance of 5000 including the unconfirmed deposit, got %v, %v", balance, err)
+	}
+	valid, err := v.Validate(phonon)
+	if err != nil || !valid {
+		t.Errorf("expected the phonon to be fully backed, got %v, %v", valid, err)
+	}
+
+	v.SetMinConfirmations(1)
+	balance, err = v.GetBalance(phonon)
+	if err != nil || balance != 3000 {
+		t.Errorf("expected a balance of 3000 without the unconfirmed deposit, got %v, %v", balance, err)
+	}
+	valid, err = v.Validate(phonon)
+	if err != nil || valid {
+		t.Errorf("expected a balance below the denomination to be invalid, got %v, %v", valid, err)
+	}
+}
+
+func TestValidateOnRegtest(t *testing.T) {
+	priv, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses, err := pubKeyToNetworkAddresses(&priv.PublicKey, &chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tx/address/"+addresses[1] {
+			w.Write([]byte("[]"))
+			return
+		}
+		fmt.Fprintf(w, `[{"hash":"deposit","height":100,"inputs":[],"outputs":[{"value":5000,"address":"%s"}]}]`, addresses[1])
+	}))
+	defer srv.Close()
+	phonon := &model.Phonon{PubKey: &model.ECCPubKey{PubKey: &priv.PublicKey}}
+
+	valid, err := NewBTCValidatorForNetwork(NewClient(srv.URL, ""), &chaincfg.RegressionNetParams).Validate(phonon)
+	if err != nil || !valid {
+		t.Errorf("expected the regtest deposit to validate the phonon, got %v, %v", valid, err)
+	}
+	valid, err = NewBTCValidator(NewClient(srv.URL, "")).Validate(phonon)
+	if err != nil || valid {
+		t.Errorf("expected a mainnet validator not to find the regtest deposit, got %v, %v", valid, err)
+	}
+}
+
+func TestValidateWithContextStopsPaging(t *testing.T) {
+	priv, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := make([]string, transactionRequestLimit)
+	for i := range page {
+		page[i] = fmt.Sprintf(`{"hash":"tx%d","height":100,"inputs":[],"outputs":[]}`, i)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//every page is full, so only cancellation ends the listing
+		requests++
+		cancel()
+		fmt.Fprintf(w, "[%s]", strings.Join(page, ","))
+	}))
+	defer srv.Close()
+	phonon := &model.Phonon{PubKey: &model.ECCPubKey{PubKey: &priv.PublicKey}}
+
+	_, err = NewBTCValidator(NewClient(srv.URL, "")).ValidateWithContext(ctx, phonon)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if requests != 1 {
+		t.Errorf("expected no pages to be requested after cancellation, got %d requests", requests)
+	}
+}
+
+func TestValidateReportAtHeight(t *testing.T) {
+	priv, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses, err := pubKeyToAddresses(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	funded := addresses[0]
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tx/address/"+funded {
+			w.Write([]byte("[]"))
+			return
+		}
+		fmt.Fprintf(w, `[
+			{"hash":"deposit","height":100,"inputs":[],"outputs":[{"value":5000,"address":"%[1]s"}]},
+			{"hash":"topup","height":150,"inputs":[],"outputs":[{"value":700,"address":"%[1]s"}]},
+			{"hash":"spend","height":200,"inputs":[{"coin":{"value":5700,"address":"%[1]s"}}],"outputs":[]},
+			{"hash":"pending","height":-1,"inputs":[],"outputs":[{"value":1,"address":"%[1]s"}]}
+		]`, funded)
+	}))
+	defer srv.Close()
+	v := NewBTCValidator(NewClient(srv.URL, ""))
+	phonon := &model.Phonon{PubKey: &model.ECCPubKey{PubKey: &priv.PublicKey}}
+
+	heights := []struct {
+		height  int64
+		balance int64
+	}{
+		{99, 0},
+		{100, 5000},
+		{199, 5700},
+	}
+	for _, h := range heights {
+		report, err := v.ValidateReportAtHeight(phonon, h.height)
+		if err != nil {
+			t.Fatalf("height %d: %v", h.height, err)
+		}
+		if report.Balance != h.balance || report.Valid != (h.balance != 0) {
+			t.Errorf("height %d: expected balance %d, got %+v", h.height, h.balance, report)
+		}
+	}
+	_, err = v.ValidateReportAtHeight(phonon, 200)
+	if err != ErrPhononCompromised {
+		t.Errorf("expected spend at height 200 to be detected, got %v", err)
+	}
+	_, err = v.ValidateReport(phonon)
+	if err != ErrPhononCompromised {
+		t.Errorf("expected spend to be detected at the tip, got %v", err)
+	}
+	_, err = v.ValidateReportAtHeight(phonon, -5)
+	if err != ErrInvalidHeight {
+		t.Errorf("expected %v, got %v", ErrInvalidHeight, err)
+	}
+}
+
+func TestMinConfirmations(t *testing.T) {
+	priv, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses, err := pubKeyToAddresses(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	funded := addresses[0]
+	phonon := &model.Phonon{PubKey: &model.ECCPubKey{PubKey: &priv.PublicKey}}
+
+	//the same history at a tip of 105, with and without bcoin's confirmations field
+	withConfirmations := `[
+		{"hash":"deposit","height":100,"confirmations":6,"inputs":[],"outputs":[{"value":5000,"address":"%[1]s"}]},
+		{"hash":"topup","height":104,"confirmations":2,"inputs":[],"outputs":[{"value":700,"address":"%[1]s"}]},
+		{"hash":"pending","height":-1,"confirmations":0,"inputs":[],"outputs":[{"value":1,"address":"%[1]s"}]}
+	]`
+	withoutConfirmations := `[
+		{"hash":"deposit","height":100,"inputs":[],"outputs":[{"value":5000,"address":"%[1]s"}]},
+		{"hash":"topup","height":104,"inputs":[],"outputs":[{"value":700,"address":"%[1]s"}]},
+		{"hash":"pending","height":-1,"inputs":[],"outputs":[{"value":1,"address":"%[1]s"}]}
+	]`
+	for _, fixture := range []struct {
+		name         string
+		transactions string
+		tipRequests  int
+	}{
+		{"confirmations field", withConfirmations, 0},
+		{"tip height", withoutConfirmations, 3},
+	} {
+		tipRequests := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/":
+				tipRequests++
+				w.Write([]byte(`{"chain":{"height":105}}`))
+			case "/tx/address/" + funded:
+				fmt.Fprintf(w, fixture.transactions, funded)
+			default:
+				w.Write([]byte("[]"))
+			}
+		}))
+		v := NewBTCValidator(NewClient(srv.URL, ""))
+
+		confirmations := []struct {
+			min     int64
+			balance int64
+		}{
+			{0, 5701},
+			{1, 5700},
+			{3, 5000},
+			{7, 0},
+		}
+		for _, c := range confirmations {
+			v.SetMinConfirmations(c.min)
+			report, err := v.ValidateReport(phonon)
+			if err != nil {
+				t.Fatalf("%s, %d confirmations: %v", fixture.name, c.min, err)
+			}
+			if report.Balance != c.balance || report.Valid != (c.balance != 0) {
+				t.Errorf("%s, %d confirmations: expected balance %d, got %+v", fixture.name, c.min, c.balance, report)
+			}
+		}
+		srv.Close()
+		//the tip is only looked up when a minimum is set and the field is missing
+		if tipRequests != fixture.tipRequests {
+			t.Errorf("%s: expected %d chain tip requests, got %d", fixture.name, fixture.tipRequests, tipRequests)
+		}
+	}
+}
+
+func TestMinConfirmationsCountsUnconfirmedSpends(t *testing.T) {
+	txl := transactionList{
+		{Hash: "deposit", Height: 100, Confirmations: 6, Outputs: Outputs{{Value: 5000, Address: "phonon"}}},
+		{Hash: "spend", Height: -1, Inputs: Inputs{{Coin: Coin{Value: 5000, Address: "phonon"}}}},
+	}
+	filtered, err := NewClient("", "").withMinConfirmations(context.Background(), txl, []string{"phonon"}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = aggregateTransactions(filtered, []string{"phonon"})
+	if err != ErrPhononCompromised {
+		t.Errorf("expected an unconfirmed spend to be counted, got %v", err)
+	}
+}
+
+func TestBcoinClientReusesConnections(t *testing.T) {
+	var connMtex sync.Mutex
+	connections := 0
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Write([]byte(`{"network":"main"}`))
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connMtex.Lock()
+			connections++
+			connMtex.Unlock()
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	v := NewBTCValidator(NewClient(srv.URL, ""))
+	for i := 0; i < 20; i++ {
+		priv, err := ethcrypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = v.Validate(&model.Phonon{PubKey: &model.ECCPubKey{PubKey: &priv.PublicKey}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = v.Ping(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	connMtex.Lock()
+	defer connMtex.Unlock()
+	if connections > 2 {
+		t.Errorf("expected requests to reuse connections, %d were opened", connections)
+	}
+}
+
+// roundTripFunc serves requests without a network connection
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	priv, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses, err := pubKeyToAddresses(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		body := "[]"
+		if r.URL.Path == "/tx/address/"+addresses[0] {
+			body = fmt.Sprintf(`[{"hash":"deposit","height":100,"inputs":[],"outputs":[{"value":5000,"address":"%s"}]}]`, addresses[0])
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Header:     make(http.Header),
+		}, nil
+	})
+	client := NewClient("http://bcoin.invalid", "", WithHTTPClient(&http.Client{Transport: transport}))
+	balance, err := NewBTCValidator(client).GetBalance(&model.Phonon{PubKey: &model.ECCPubKey{PubKey: &priv.PublicKey}})
+	if err != nil || balance != 5000 {
+		t.Errorf("expected the injected transport to serve a balance of 5000, got %v, %v", balance, err)
+	}
+	if NewClient("http://bcoin.invalid", "", WithHTTPClient(nil)).client == nil {
+		t.Error("expected a nil client to keep the default")
+	}
+}
@@ -0,0 +1,71 @@
+package validator
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+NewClientWithCache returns a bcoin client that reuses the transactions fetched for an address for ttl.
+Phonons whose keys share addresses, or a validation run repeated within the window, then don't fetch
+the same paginated history again. A deposit or spend made within ttl of a lookup may not be seen until it expires.
+*/
+func NewClientWithCache(url string, authToken string, ttl time.Duration, opts ...ClientOption) *bcoinClient {
+	c := NewClient(url, authToken, opts...)
+	c.cache = newTransactionCache(ttl)
+	return c
+}
+
+// transactionCache holds the transactions fetched for each address until they are older than ttl.
+// A nil cache holds nothing, so clients without one always fetch.
+type transactionCache struct {
+	ttl     time.Duration
+	mtex    sync.RWMutex
+	entries map[string]cachedTransactions
+	now     func() time.Time
+}
+
+type cachedTransactions struct {
+	txl     transactionList
+	fetched time.Time
+}
+
+func newTransactionCache(ttl time.Duration) *transactionCache {
+	return &transactionCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedTransactions),
+		now:     time.Now,
+	}
+}
+
+// get returns the transactions cached for address, evicting them if they have expired
+func (c *transactionCache) get(address string) (transactionList, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mtex.RLock()
+	entry, ok := c.entries[address]
+	c.mtex.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if c.now().Sub(entry.fetched) >= c.ttl {
+		c.mtex.Lock()
+		//another lookup may have refreshed the entry since it was read
+		if current, ok := c.entries[address]; ok && current.fetched.Equal(entry.fetched) {
+			delete(c.entries, address)
+		}
+		c.mtex.Unlock()
+		return nil, false
+	}
+	return entry.txl, true
+}
+
+func (c *transactionCache) put(address string, txl transactionList) {
+	if c == nil {
+		return
+	}
+	c.mtex.Lock()
+	c.entries[address] = cachedTransactions{txl: txl, fetched: c.now()}
+	c.mtex.Unlock()
+}
@@ -0,0 +1,61 @@
+package validator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientWithCache(t *testing.T) {
+	var mtex sync.Mutex
+	requests := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtex.Lock()
+		requests[r.URL.Path]++
+		mtex.Unlock()
+		w.Write([]byte(`[{"hash":"deposit","height":100,"inputs":[],"outputs":[{"value":5000,"address":"a"}]}]`))
+	}))
+	defer srv.Close()
+
+	c := NewClientWithCache(srv.URL, "", time.Minute)
+	now := time.Now()
+	c.cache.now = func() time.Time { return now }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			txl, err := c.GetTransactions(context.Background(), []string{"a", "b"})
+			if err != nil || len(txl) != 2 {
+				t.Errorf("expected a transaction for each address, got %v, %v", txl, err)
+			}
+		}()
+	}
+	wg.Wait()
+	_, err := c.GetTransactions(context.Background(), []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//concurrent lookups of an uncached address may each fetch it, but later lookups are served from the cache
+	fetched := requests["/tx/address/a"]
+	if fetched == 0 || fetched > 4 {
+		t.Fatalf("expected the address to be fetched by at most the concurrent lookups, got %d requests", fetched)
+	}
+
+	now = now.Add(time.Minute)
+	_, err = c.GetTransactions(context.Background(), []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests["/tx/address/a"] != fetched+1 {
+		t.Errorf("expected an expired entry to be fetched again, got %d requests", requests["/tx/address/a"]-fetched)
+	}
+	_, ok := c.cache.get("b")
+	if _, kept := c.cache.entries["b"]; ok || kept {
+		t.Error("expected an expired entry to be evicted when accessed")
+	}
+}
@@ -0,0 +1,97 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+var ErrNoValidator = errors.New("no validator registered for phonon currency")
+
+// Result is the outcome of validating a single phonon with ValidateAll
+type Result struct {
+	Phonon *model.Phonon
+	Valid  bool
+	Err    error
+}
+
+/*
+ValidateAll validates a mixed currency list of phonons with the registered validators, for
+wallet dashboards that need every phonon checked at once.
+
+Each currency is validated in its own goroutine since the backends are independent, with at most
+maxConcurrent currencies in flight at a time, or no limit if maxConcurrent is 0. Within a currency
+phonons are validated in order by that currency's validator. A failing backend only fails the
+results for its own currency. Once ctx is done no further phonons are validated and their results
+carry ctx.Err(). Results are returned in the same order as phonons.
+*/
+func ValidateAll(ctx context.Context, phonons []*model.Phonon, maxConcurrent int) []Result {
+	results := make([]Result, len(phonons))
+	for i, p := range phonons {
+		results[i].Phonon = p
+	}
+	forEachByCurrency(ctx, phonons, maxConcurrent, func(v Validator, i int, err error) {
+		if err != nil {
+			results[i].Err = err
+			return
+		}
+		results[i].Valid, results[i].Err = v.Validate(phonons[i])
+	})
+	return results
+}
+
+/*
+forEachByCurrency calls check with the registered validator for each phonon, batching phonons by currency as
+described for ValidateAll. check is instead passed ErrNoValidator for a phonon without a validator, or ctx.Err()
+once ctx is done. Calls for the same currency are made in order from one goroutine.
+*/
+func forEachByCurrency(ctx context.Context, phonons []*model.Phonon, maxConcurrent int, check func(v Validator, i int, err error)) {
+	batches := make(map[model.CurrencyType][]int)
+	for i, p := range phonons {
+		batches[p.CurrencyType] = append(batches[p.CurrencyType], i)
+	}
+
+	registryMtex.RLock()
+	validators := make(map[model.CurrencyType]Validator, len(batches))
+	for currencyType := range batches {
+		validators[currencyType] = registry[currencyType]
+	}
+	registryMtex.RUnlock()
+
+	var sem chan struct{}
+	if maxConcurrent > 0 {
+		sem = make(chan struct{}, maxConcurrent)
+	}
+	var wg sync.WaitGroup
+	for currencyType, batch := range batches {
+		v := validators[currencyType]
+		if v == nil {
+			for _, i := range batch {
+				check(nil, i, ErrNoValidator)
+			}
+			continue
+		}
+		wg.Add(1)
+		go func(v Validator, batch []int) {
+			defer wg.Done()
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-ctx.Done():
+					for _, i := range batch {
+						check(v, i, ctx.Err())
+					}
+					return
+				}
+			}
+			//each goroutine only checks the phonons of its own batch
+			for _, i := range batch {
+				check(v, i, ctx.Err())
+			}
+		}(v, batch)
+	}
+	wg.Wait()
+}
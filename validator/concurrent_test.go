@@ -0,0 +1,103 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GridPlus/phonon-client/model"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// countingValidator tracks how many validations are running at once across every validator sharing it
+type countingValidator struct {
+	mtex    sync.Mutex
+	active  int
+	maxSeen int
+}
+
+func (c *countingValidator) Validate(phonon *model.Phonon) (bool, error) {
+	c.mtex.Lock()
+	c.active++
+	if c.active > c.maxSeen {
+		c.maxSeen = c.active
+	}
+	c.mtex.Unlock()
+	time.Sleep(time.Millisecond)
+	c.mtex.Lock()
+	c.active--
+	c.mtex.Unlock()
+	return true, nil
+}
+
+func newTestPhonon(t *testing.T, currencyType model.CurrencyType) *model.Phonon {
+	priv, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &model.Phonon{CurrencyType: currencyType, PubKey: &model.ECCPubKey{PubKey: &priv.PublicKey}}
+}
+
+func TestValidateAll(t *testing.T) {
+	btc := NewMockValidator()
+	eth := NewMockValidator()
+	Register(model.Bitcoin, btc)
+	Register(model.Ethereum, eth)
+	defer Unregister(model.Bitcoin)
+	defer Unregister(model.Ethereum)
+
+	backendDown := errors.New("backend down")
+	phonons := []*model.Phonon{
+		newTestPhonon(t, model.Bitcoin),
+		newTestPhonon(t, model.Ethereum),
+		newTestPhonon(t, model.Bitcoin),
+		newTestPhonon(t, model.CurrencyType(99)),
+	}
+	btc.Script(phonons[0].PubKey, MockResult{Valid: true})
+	btc.Script(phonons[2].PubKey, MockResult{Valid: false})
+	eth.Script(phonons[1].PubKey, MockResult{Err: backendDown})
+
+	results := ValidateAll(context.Background(), phonons, 1)
+	if len(results) != len(phonons) {
+		t.Fatalf("expected %d results, got %d", len(phonons), len(results))
+	}
+	for i, r := range results {
+		if r.Phonon != phonons[i] {
+			t.Errorf("result %d is for the wrong phonon", i)
+		}
+	}
+	if !results[0].Valid || results[0].Err != nil || results[2].Valid || results[2].Err != nil {
+		t.Errorf("bitcoin results affected by ethereum backend failure: %+v, %+v", results[0], results[2])
+	}
+	if results[1].Err != backendDown {
+		t.Errorf("expected ethereum backend error, got %v", results[1].Err)
+	}
+	if results[3].Err != ErrNoValidator {
+		t.Errorf("expected %v for unregistered currency, got %v", ErrNoValidator, results[3].Err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range ValidateAll(ctx, phonons[:3], 0) {
+		if r.Err != context.Canceled {
+			t.Errorf("expected cancelled validation to report %v, got %v", context.Canceled, r.Err)
+		}
+	}
+}
+
+func TestValidateAllConcurrencyCap(t *testing.T) {
+	counter := &countingValidator{}
+	var phonons []*model.Phonon
+	for _, c := range []model.CurrencyType{model.Bitcoin, model.Ethereum, model.CurrencyType(50)} {
+		Register(c, counter)
+		defer Unregister(c)
+		phonons = append(phonons, newTestPhonon(t, c), newTestPhonon(t, c))
+	}
+
+	ValidateAll(context.Background(), phonons, 1)
+	if counter.maxSeen != 1 {
+		t.Errorf("expected at most 1 currency validating at a time, saw %d", counter.maxSeen)
+	}
+}
@@ -0,0 +1,155 @@
+package validator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/GridPlus/phonon-client/metrics"
+	"github.com/GridPlus/phonon-client/model"
+	"github.com/GridPlus/phonon-client/util"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ETHValidator checks ether phonons against the balance of their address from an Ethereum JSON-RPC node
+type ETHValidator struct {
+	url       string
+	authtoken string
+	client    http.Client
+}
+
+// NewETHValidator returns a validator querying the JSON-RPC endpoint at url, sending authToken as a bearer token if set
+func NewETHValidator(url string, authToken string) *ETHValidator {
+	return &ETHValidator{
+		url:       url,
+		authtoken: authToken,
+		client:    http.Client{},
+	}
+}
+
+// Validate returns true if the phonon's address holds at least the phonon's denomination in wei.
+// An address with no balance is reported as invalid rather than as an error.
+func (e *ETHValidator) Validate(phonon *model.Phonon) (bool, error) {
+	balance, err := e.Balance(phonon)
+	if err != nil {
+		return false, err
+	}
+	if balance.Sign() == 0 {
+		return false, nil
+	}
+	return balance.Cmp(phonon.Denomination.Value()) >= 0, nil
+}
+
+// Balance returns the wei held by the phonon's address at the latest block
+func (e *ETHValidator) Balance(phonon *model.Phonon) (*big.Int, error) {
+	if !e.Configured() {
+		return nil, ErrBackendUnavailable
+	}
+	if phonon.PubKey == nil {
+		return nil, ErrMissingPubKey
+	}
+	key, err := util.ParseECCPubKey(phonon.PubKey.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	//the address is the last 20 bytes of the keccak256 hash of the uncompressed public key
+	address := ethcrypto.PubkeyToAddress(*key)
+	var balance hexutil.Big
+	err = e.call(context.Background(), "eth_getBalance", []interface{}{address.Hex(), "latest"}, &balance)
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("Balance retrieved:", balance.ToInt())
+	return balance.ToInt(), nil
+}
+
+// Configured reports whether a JSON-RPC endpoint has been supplied to the validator
+func (e *ETHValidator) Configured() bool {
+	return e.url != ""
+}
+
+// Ping checks that the configured JSON-RPC endpoint answers requests
+func (e *ETHValidator) Ping(ctx context.Context) error {
+	if !e.Configured() {
+		return ErrBackendUnavailable
+	}
+	var blockNumber hexutil.Uint64
+	return e.call(ctx, "eth_blockNumber", []interface{}{}, &blockNumber)
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// call makes a JSON-RPC request, decoding its result into v
+func (e *ETHValidator) call(ctx context.Context, method string, params []interface{}, v interface{}) (err error) {
+	defer observeETHRequest(time.Now(), &err)
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		log.Debug("Unable to create request to ethereum rpc")
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.authtoken != "" {
+		req.Header.Set("Authorization", "Bearer "+e.authtoken)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		log.Debug("Error making request to ethereum rpc")
+		return err
+	}
+	defer closeBody(resp)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		log.Debug("ethereum rpc returned status ", resp.StatusCode)
+		return ErrBackendUnavailable
+	}
+	retBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Debug("Unable to read response from ethereum rpc")
+		return err
+	}
+	var rpcResp rpcResponse
+	err = json.Unmarshal(retBytes, &rpcResp)
+	if err != nil {
+		log.Debug("Unable to unmarshal Json response from ethereum rpc")
+		return err
+	}
+	if rpcResp.Error != nil {
+		return errors.New(rpcResp.Error.Message)
+	}
+	return json.Unmarshal(rpcResp.Result, v)
+}
+
+var ethLabels = map[string]string{"backend": "ethrpc"}
+
+// observeETHRequest reports a finished JSON-RPC request and its outcome to the installed metrics
+func observeETHRequest(start time.Time, err *error) {
+	metrics.Inc(metrics.ValidatorRequests, ethLabels)
+	metrics.ObserveSince(metrics.ValidatorRequestDuration, start, ethLabels)
+	if *err != nil {
+		metrics.Inc(metrics.ValidatorRequestErrors, ethLabels)
+	}
+}
@@ -0,0 +1,60 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GridPlus/phonon-client/model"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestETHValidator(t *testing.T) {
+	phonon := newTestPhonon(t, model.Ethereum)
+	phonon.Denomination = model.Denomination{Base: 5, Exponent: 3}
+	address := ethcrypto.PubkeyToAddress(*phonon.PubKey.(*model.ECCPubKey).PubKey).Hex()
+
+	var balance string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req rpcRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.Method != "eth_getBalance" || !strings.EqualFold(req.Params[0].(string), address) {
+			fmt.Fprint(w, `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid params"}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"%s"}`, balance)
+	}))
+	defer server.Close()
+	v := NewETHValidator(server.URL, "token")
+
+	for _, test := range []struct {
+		balance string
+		valid   bool
+	}{
+		{"0x0", false},
+		{"0x1000", false},
+		{"0x1388", true},
+		{"0xde0b6b3a7640000", true},
+	} {
+		balance = test.balance
+		valid, err := v.Validate(phonon)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if valid != test.valid {
+			t.Errorf("expected a balance of %s to be valid: %v, got %v", test.balance, test.valid, valid)
+		}
+	}
+
+	_, err := NewETHValidator("", "").Validate(phonon)
+	if err != ErrBackendUnavailable {
+		t.Errorf("expected %v from an unconfigured validator, got %v", ErrBackendUnavailable, err)
+	}
+}
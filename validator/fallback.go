@@ -0,0 +1,82 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+/*
+IsTransient reports whether err means a validator's backend couldn't be reached or didn't answer in time,
+rather than the backend reaching a verdict on the phonon. Asking another backend may succeed after a
+transient error, while a definitive one such as ErrPhononCompromised would be the same from any backend.
+*/
+func IsTransient(err error) bool {
+	if errors.Is(err, ErrBackendUnavailable) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+/*
+FallbackValidator validates with the first of an ordered list of validators that can reach its backend,
+such as a user's own node followed by a public explorer. Each validator is tried in turn until one returns
+a result or a definitive error, which is returned as is. If every validator fails transiently the last error is returned.
+
+Unlike a quorum only one backend has to answer, so a fallback backend is trusted as much as the primary.
+*/
+type FallbackValidator struct {
+	validators []Validator
+}
+
+// NewFallbackValidator returns a FallbackValidator trying validators in the order given
+func NewFallbackValidator(validators ...Validator) *FallbackValidator {
+	return &FallbackValidator{
+		validators: validators,
+	}
+}
+
+func (f *FallbackValidator) Validate(phonon *model.Phonon) (bool, error) {
+	err := ErrBackendUnavailable
+	for _, v := range f.validators {
+		var valid bool
+		valid, err = v.Validate(phonon)
+		if err == nil || !IsTransient(err) {
+			return valid, err
+		}
+	}
+	return false, err
+}
+
+// Configured reports whether any of the validators can be used
+func (f *FallbackValidator) Configured() bool {
+	for _, v := range f.validators {
+		b, ok := v.(backend)
+		if !ok || b.Configured() {
+			return true
+		}
+	}
+	return false
+}
+
+// Ping succeeds if any of the validators' backends is reachable, otherwise returning the last error
+func (f *FallbackValidator) Ping(ctx context.Context) error {
+	err := ErrBackendUnavailable
+	for _, v := range f.validators {
+		b, ok := v.(backend)
+		if !ok {
+			return nil
+		}
+		if !b.Configured() {
+			continue
+		}
+		err = b.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package validator
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GridPlus/phonon-client/model"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestFallbackValidator(t *testing.T) {
+	priv, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses, err := pubKeyToAddresses(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phonon := &model.Phonon{PubKey: &model.ECCPubKey{PubKey: &priv.PublicKey}}
+
+	//serve the phonon's history, or an error status in place of any response
+	newBackend := func(status int, history string, requests *int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*requests++
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+			if r.URL.Path != "/tx/address/"+addresses[0] {
+				w.Write([]byte("[]"))
+				return
+			}
+			fmt.Fprintf(w, history, addresses[0])
+		}))
+	}
+	funded := `[{"hash":"deposit","height":100,"inputs":[],"outputs":[{"value":5000,"address":"%[1]s"}]}]`
+	spent := `[{"hash":"spend","height":101,"inputs":[{"coin":{"value":5000,"address":"%[1]s"}}],"outputs":[]}]`
+
+	var downRequests, spentRequests, fundedRequests int
+	down := newBackend(http.StatusServiceUnavailable, "", &downRequests)
+	defer down.Close()
+	spentNode := newBackend(http.StatusOK, spent, &spentRequests)
+	defer spentNode.Close()
+	fundedNode := newBackend(http.StatusOK, funded, &fundedRequests)
+	defer fundedNode.Close()
+
+	unconfigured := NewBTCValidator(NewClient("", ""))
+	primary := NewBTCValidator(NewClient(down.URL, ""))
+	explorer := NewBTCValidator(NewClient(fundedNode.URL, ""))
+
+	//an unconfigured and an unavailable backend are skipped
+	valid, err := NewFallbackValidator(unconfigured, primary, explorer).Validate(phonon)
+	if err != nil || !valid {
+		t.Errorf("expected the explorer to validate the phonon, got %v, %v", valid, err)
+	}
+	if downRequests != 1 || fundedRequests == 0 {
+		t.Errorf("expected one failed request to the primary before the explorer, got %d and %d", downRequests, fundedRequests)
+	}
+
+	//a definitive answer stops the fallback
+	fundedRequests = 0
+	_, err = NewFallbackValidator(NewBTCValidator(NewClient(spentNode.URL, "")), explorer).Validate(phonon)
+	if err != ErrPhononCompromised {
+		t.Errorf("expected %v from the first backend, got %v", ErrPhononCompromised, err)
+	}
+	if fundedRequests != 0 {
+		t.Errorf("expected the explorer not to be asked after a definitive answer, got %d requests", fundedRequests)
+	}
+
+	_, err = NewFallbackValidator(unconfigured, primary).Validate(phonon)
+	if !IsTransient(err) {
+		t.Errorf("expected the last transient error when every backend fails, got %v", err)
+	}
+	f := NewFallbackValidator(unconfigured)
+	if f.Configured() {
+		t.Error("expected a fallback of only unconfigured backends to be unconfigured")
+	}
+}
@@ -0,0 +1,61 @@
+package validator
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+var ErrUnscriptedPhonon = errors.New("mock validator has no result scripted for phonon")
+
+// MockResult is the scripted outcome of validating a phonon with MockValidator
+type MockResult struct {
+	Valid bool
+	Err   error
+}
+
+// MockValidator returns scripted results keyed by phonon public key so tests
+// of validation dependent flows are reproducible and make no network requests
+type MockValidator struct {
+	results   map[string]MockResult
+	validated []*model.Phonon
+	mtex      sync.Mutex
+}
+
+func NewMockValidator() *MockValidator {
+	return &MockValidator{
+		results: make(map[string]MockResult),
+	}
+}
+
+// Script sets the result returned for phonons with the given public key
+func (m *MockValidator) Script(pubKey model.PhononPubKey, result MockResult) {
+	m.mtex.Lock()
+	defer m.mtex.Unlock()
+	m.results[pubKey.String()] = result
+}
+
+// Validate records the phonon and returns its scripted result, or ErrUnscriptedPhonon if none was set
+func (m *MockValidator) Validate(phonon *model.Phonon) (bool, error) {
+	m.mtex.Lock()
+	defer m.mtex.Unlock()
+	m.validated = append(m.validated, phonon)
+	if phonon.PubKey == nil {
+		return false, ErrMissingPubKey
+	}
+	result, ok := m.results[phonon.PubKey.String()]
+	if !ok {
+		return false, ErrUnscriptedPhonon
+	}
+	return result.Valid, result.Err
+}
+
+// Validated returns every phonon passed to Validate, in order
+func (m *MockValidator) Validated() []*model.Phonon {
+	m.mtex.Lock()
+	defer m.mtex.Unlock()
+	ret := make([]*model.Phonon, len(m.validated))
+	copy(ret, m.validated)
+	return ret
+}
@@ -0,0 +1,42 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/GridPlus/phonon-client/model"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestMockValidator(t *testing.T) {
+	var phonons []*model.Phonon
+	for i := 0; i < 4; i++ {
+		priv, err := ethcrypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		phonons = append(phonons, &model.Phonon{PubKey: &model.ECCPubKey{PubKey: &priv.PublicKey}})
+	}
+	errBackend := errors.New("backend down")
+	m := NewMockValidator()
+	m.Script(phonons[0].PubKey, MockResult{Valid: true})
+	m.Script(phonons[1].PubKey, MockResult{Valid: false})
+	m.Script(phonons[2].PubKey, MockResult{Err: errBackend})
+
+	expected := []MockResult{{Valid: true}, {Valid: false}, {Err: errBackend}, {Err: ErrUnscriptedPhonon}}
+	for i, p := range phonons {
+		valid, err := m.Validate(p)
+		if valid != expected[i].Valid || err != expected[i].Err {
+			t.Errorf("phonon %d: expected %v, %v, got %v, %v", i, expected[i].Valid, expected[i].Err, valid, err)
+		}
+	}
+	validated := m.Validated()
+	if len(validated) != len(phonons) {
+		t.Fatalf("expected %d validated phonons, got %d", len(phonons), len(validated))
+	}
+	for i := range phonons {
+		if validated[i] != phonons[i] {
+			t.Errorf("validation %d recorded the wrong phonon", i)
+		}
+	}
+}
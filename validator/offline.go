@@ -0,0 +1,90 @@
+package validator
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/GridPlus/phonon-client/model"
+	"github.com/GridPlus/phonon-client/util"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+var ErrBalanceUnknown = errors.New("no balance supplied for any of the phonon's addresses")
+
+// OfflineValidator validates bitcoin phonons against balances fetched out of band,
+// for air gapped use where the client must not make network requests
+type OfflineValidator struct {
+	balances map[string]int64
+	params   *chaincfg.Params //network the balances' addresses are for
+}
+
+// NewOfflineValidator takes a map of mainnet bitcoin address to balance in satoshis
+func NewOfflineValidator(balances map[string]int64) *OfflineValidator {
+	return NewOfflineValidatorForNetwork(balances, &chaincfg.MainNetParams)
+}
+
+// NewOfflineValidatorForNetwork takes a map of address to balance in satoshis for the given network,
+// as NewBTCValidatorForNetwork does. A nil params selects mainnet.
+func NewOfflineValidatorForNetwork(balances map[string]int64, params *chaincfg.Params) *OfflineValidator {
+	if params == nil {
+		params = &chaincfg.MainNetParams
+	}
+	return &OfflineValidator{
+		balances: balances,
+		params:   params,
+	}
+}
+
+// Validate derives the same addresses as BTCValidator and checks them against the supplied balances,
+// which like BTCValidator's must be nonzero and at least the phonon's denomination.
+// An address missing from the map is not assumed to be empty, so if none of the phonon's
+// addresses are known ErrBalanceUnknown is returned.
+func (o *OfflineValidator) Validate(phonon *model.Phonon) (bool, error) {
+	balance, err := o.balance(phonon)
+	if err != nil {
+		return false, err
+	}
+	return balance != 0 && big.NewInt(balance).Cmp(phonon.Denomination.Value()) >= 0, nil
+}
+
+// Balance returns the total supplied for the phonon's addresses, or ErrBalanceUnknown as Validate does
+func (o *OfflineValidator) Balance(phonon *model.Phonon) (*big.Int, error) {
+	balance, err := o.balance(phonon)
+	if err != nil {
+		return nil, err
+	}
+	return big.NewInt(balance), nil
+}
+
+// network returns the bitcoin network the validator derives addresses for
+func (o *OfflineValidator) network() *chaincfg.Params {
+	return o.params
+}
+
+func (o *OfflineValidator) balance(phonon *model.Phonon) (int64, error) {
+	if phonon.PubKey == nil {
+		return 0, ErrMissingPubKey
+	}
+	key, err := util.ParseECCPubKey(phonon.PubKey.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	addresses, err := pubKeyToNetworkAddresses(key, o.params)
+	if err != nil {
+		return 0, err
+	}
+
+	var balance int64
+	var known bool
+	for _, address := range addresses {
+		b, ok := o.balances[address]
+		if ok {
+			known = true
+			balance += b
+		}
+	}
+	if !known {
+		return 0, ErrBalanceUnknown
+	}
+	return balance, nil
+}
@@ -0,0 +1,54 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/GridPlus/phonon-client/model"
+	"github.com/btcsuite/btcd/chaincfg"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestOfflineValidator(t *testing.T) {
+	priv, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	phonon := &model.Phonon{
+		CurrencyType: model.Bitcoin,
+		PubKey:       &model.ECCPubKey{PubKey: &priv.PublicKey},
+		Denomination: model.Denomination{Base: 5, Exponent: 3},
+	}
+	addresses, err := pubKeyToAddresses(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewOfflineValidator(map[string]int64{"unrelated": 100}).Validate(phonon)
+	if err != ErrBalanceUnknown {
+		t.Errorf("expected %v for unknown addresses, got %v", ErrBalanceUnknown, err)
+	}
+
+	valid, err := NewOfflineValidator(map[string]int64{addresses[0]: 0}).Validate(phonon)
+	if err != nil || valid {
+		t.Errorf("expected known empty address to be invalid, got %v, %v", valid, err)
+	}
+
+	valid, err = NewOfflineValidator(map[string]int64{addresses[0]: 4999}).Validate(phonon)
+	if err != nil || valid {
+		t.Errorf("expected address holding less than the denomination to be invalid, got %v, %v", valid, err)
+	}
+
+	valid, err = NewOfflineValidator(map[string]int64{addresses[0]: 0, addresses[len(addresses)-1]: 5000}).Validate(phonon)
+	if err != nil || !valid {
+		t.Errorf("expected funded address to be valid, got %v, %v", valid, err)
+	}
+
+	testnetAddresses, err := pubKeyToNetworkAddresses(&priv.PublicKey, &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err = NewOfflineValidatorForNetwork(map[string]int64{testnetAddresses[0]: 5000}, &chaincfg.TestNet3Params).Validate(phonon)
+	if err != nil || !valid {
+		t.Errorf("expected funded testnet address to be valid, got %v, %v", valid, err)
+	}
+}
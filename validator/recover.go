@@ -0,0 +1,88 @@
+package validator
+
+import (
+	"crypto/ecdsa"
+	"errors"
+
+	"github.com/GridPlus/phonon-client/model"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+var ErrInvalidSignatureLength = errors.New("signature must be 64 bytes (r || s) or 65 bytes (compact with recovery id)")
+var ErrAmbiguousRecovery = errors.New("more than one public key recovered, an expected address is required")
+var ErrNoMatchingKey = errors.New("no recovered public key matches the expected address")
+
+/*
+RecoverPubKeys recovers the secp256k1 public keys that could have produced sig over hash.
+A 65 byte compact signature carries its recovery id and yields a single key.
+A 64 byte r || s signature does not, so both recovery ids are tried and each valid candidate returned.
+*/
+func RecoverPubKeys(sig []byte, hash []byte) ([]*ecdsa.PublicKey, error) {
+	var compactSigs [][]byte
+	switch len(sig) {
+	case 65:
+		compactSigs = [][]byte{sig}
+	case 64:
+		for recoveryID := byte(0); recoveryID < 2; recoveryID++ {
+			compactSigs = append(compactSigs, append([]byte{27 + recoveryID}, sig...))
+		}
+	default:
+		return nil, ErrInvalidSignatureLength
+	}
+
+	var ret []*ecdsa.PublicKey
+	var err error
+	for _, compactSig := range compactSigs {
+		var key *btcec.PublicKey
+		key, _, err = btcec.RecoverCompact(btcec.S256(), compactSig, hash)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, key.ToECDSA())
+	}
+	if len(ret) == 0 {
+		return nil, err
+	}
+	return ret, nil
+}
+
+/*
+ValidateRecovered validates a phonon known only by a signature over hash made with its key.
+If expectedAddress is set, the recovered key whose bitcoin addresses on v's network include it is used,
+otherwise recovery must be unambiguous.
+*/
+func ValidateRecovered(v Validator, phonon *model.Phonon, sig []byte, hash []byte, expectedAddress string) (bool, error) {
+	candidates, err := RecoverPubKeys(sig, hash)
+	if err != nil {
+		return false, err
+	}
+	key, err := selectRecoveredKey(candidates, expectedAddress, validatorNetwork(v))
+	if err != nil {
+		return false, err
+	}
+	recovered := *phonon
+	recovered.PubKey = &model.ECCPubKey{PubKey: key}
+	return v.Validate(&recovered)
+}
+
+func selectRecoveredKey(candidates []*ecdsa.PublicKey, expectedAddress string, params *chaincfg.Params) (*ecdsa.PublicKey, error) {
+	if expectedAddress == "" {
+		if len(candidates) != 1 {
+			return nil, ErrAmbiguousRecovery
+		}
+		return candidates[0], nil
+	}
+	for _, key := range candidates {
+		addresses, err := pubKeyToNetworkAddresses(key, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, address := range addresses {
+			if address == expectedAddress {
+				return key, nil
+			}
+		}
+	}
+	return nil, ErrNoMatchingKey
+}
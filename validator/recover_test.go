@@ -0,0 +1,61 @@
+package validator
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/GridPlus/phonon-client/model"
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func TestValidateRecovered(t *testing.T) {
+	priv, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte("proof of ownership"))
+	compact, err := btcec.SignCompact(btcec.S256(), priv, hash[:], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addresses, err := pubKeyToAddresses(priv.PubKey().ToECDSA())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMockValidator()
+	m.Script(&model.ECCPubKey{PubKey: priv.PubKey().ToECDSA()}, MockResult{Valid: true})
+
+	valid, err := ValidateRecovered(m, &model.Phonon{}, compact, hash[:], "")
+	if err != nil || !valid {
+		t.Errorf("compact signature: expected valid, got %v, %v", valid, err)
+	}
+
+	rs := compact[1:]
+	_, err = ValidateRecovered(m, &model.Phonon{}, rs, hash[:], "")
+	if err != ErrAmbiguousRecovery {
+		t.Errorf("expected %v without an expected address, got %v", ErrAmbiguousRecovery, err)
+	}
+	valid, err = ValidateRecovered(m, &model.Phonon{}, rs, hash[:], addresses[0])
+	if err != nil || !valid {
+		t.Errorf("r || s signature with expected address: expected valid, got %v, %v", valid, err)
+	}
+	_, err = ValidateRecovered(m, &model.Phonon{}, rs, hash[:], "1BoatSLRHtKNngkdXEeobR76b53LETtpyT")
+	if err != ErrNoMatchingKey {
+		t.Errorf("expected %v, got %v", ErrNoMatchingKey, err)
+	}
+
+	//the expected address is matched on the validator's network
+	testnetAddresses, err := pubKeyToNetworkAddresses(priv.PubKey().ToECDSA(), &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates, err := RecoverPubKeys(rs, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := selectRecoveredKey(candidates, testnetAddresses[0], validatorNetwork(NewBTCValidatorForNetwork(nil, &chaincfg.TestNet3Params)))
+	if err != nil || !key.Equal(priv.PubKey().ToECDSA()) {
+		t.Errorf("expected the key matching a testnet address, got %v", err)
+	}
+}
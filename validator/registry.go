@@ -0,0 +1,129 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+var ErrBackendUnavailable = errors.New("validator backend unavailable")
+
+// backend is optionally implemented by validators that depend on an external service
+// so callers can tell an unconfigured or unreachable backend apart from an invalid phonon
+type backend interface {
+	Configured() bool
+	Ping(ctx context.Context) error
+}
+
+// CurrencyStatus describes a registered validator and the state of its backend
+type CurrencyStatus struct {
+	CurrencyType model.CurrencyType
+	Configured   bool
+	Checked      bool //whether a reachability check was run
+	Reachable    bool
+	Err          error
+}
+
+var (
+	registryMtex sync.RWMutex
+	registry     = make(map[model.CurrencyType]Validator)
+)
+
+// Register makes a validator available for the given currency type, replacing any previously registered one
+func Register(currencyType model.CurrencyType, v Validator) {
+	registryMtex.Lock()
+	defer registryMtex.Unlock()
+	registry[currencyType] = v
+}
+
+// Unregister removes the validator registered for the given currency type
+func Unregister(currencyType model.CurrencyType) {
+	registryMtex.Lock()
+	defer registryMtex.Unlock()
+	delete(registry, currencyType)
+}
+
+// ValidatorFor returns the validator registered for currencyType, or an error wrapping ErrNoValidator if there is none
+func ValidatorFor(currencyType model.CurrencyType) (Validator, error) {
+	registryMtex.RLock()
+	defer registryMtex.RUnlock()
+	v, ok := registry[currencyType]
+	if !ok {
+		return nil, fmt.Errorf("%w: currency type %d", ErrNoValidator, currencyType)
+	}
+	return v, nil
+}
+
+// ValidateForCurrency validates the phonon with the validator registered for its currency
+func ValidateForCurrency(phonon *model.Phonon) (bool, error) {
+	v, err := ValidatorFor(phonon.CurrencyType)
+	if err != nil {
+		return false, err
+	}
+	return v.Validate(phonon)
+}
+
+// SupportedCurrencies lists every registered currency along with whether its backend is configured.
+// No network requests are made, see CheckSupportedCurrencies for reachability.
+func SupportedCurrencies() []CurrencyStatus {
+	return supportedCurrencies(nil)
+}
+
+// CheckSupportedCurrencies behaves like SupportedCurrencies but additionally pings each configured backend
+func CheckSupportedCurrencies(ctx context.Context) []CurrencyStatus {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return supportedCurrencies(ctx)
+}
+
+func supportedCurrencies(ctx context.Context) []CurrencyStatus {
+	//copy the registry so backends are pinged without blocking Register
+	registryMtex.RLock()
+	validators := make(map[model.CurrencyType]Validator, len(registry))
+	for currencyType, v := range registry {
+		validators[currencyType] = v
+	}
+	registryMtex.RUnlock()
+
+	ret := make([]CurrencyStatus, len(validators))
+	var wg sync.WaitGroup
+	i := 0
+	for currencyType, v := range validators {
+		status := &ret[i]
+		i++
+		status.CurrencyType = currencyType
+		status.Configured = true
+		b, ok := v.(backend)
+		if ok {
+			status.Configured = b.Configured()
+		}
+		if ctx == nil {
+			continue
+		}
+		status.Checked = true
+		switch {
+		case !status.Configured:
+			status.Err = ErrBackendUnavailable
+		case ok:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				status.Err = b.Ping(ctx)
+				status.Reachable = status.Err == nil
+			}()
+		default:
+			//validators without a backend are always reachable
+			status.Reachable = true
+		}
+	}
+	wg.Wait()
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].CurrencyType < ret[j].CurrencyType
+	})
+	return ret
+}
@@ -0,0 +1,121 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GridPlus/phonon-client/model"
+)
+
+func TestSupportedCurrencies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	Register(model.Bitcoin, NewBTCValidator(NewClient(srv.URL, "")))
+	Register(model.Ethereum, NewBTCValidator(NewClient("", "")))
+	defer Unregister(model.Bitcoin)
+	defer Unregister(model.Ethereum)
+
+	statuses := SupportedCurrencies()
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 supported currencies, got %d", len(statuses))
+	}
+	if statuses[0].CurrencyType != model.Bitcoin || !statuses[0].Configured || statuses[0].Checked {
+		t.Errorf("unexpected bitcoin status: %+v", statuses[0])
+	}
+	if statuses[1].Configured {
+		t.Errorf("expected backend without url to be unconfigured: %+v", statuses[1])
+	}
+
+	statuses = CheckSupportedCurrencies(context.Background())
+	if !statuses[0].Reachable || statuses[0].Err != nil {
+		t.Errorf("expected bitcoin backend to be reachable: %+v", statuses[0])
+	}
+	if statuses[1].Reachable || statuses[1].Err != ErrBackendUnavailable {
+		t.Errorf("expected unconfigured backend to be unavailable: %+v", statuses[1])
+	}
+}
+
+func TestValidateForCurrency(t *testing.T) {
+	eth := NewMockValidator()
+	Register(model.Ethereum, eth)
+	defer Unregister(model.Ethereum)
+
+	ether := newTestPhonon(t, model.Ethereum)
+	eth.Script(ether.PubKey, MockResult{Valid: true})
+	valid, err := ValidateForCurrency(ether)
+	if err != nil || !valid {
+		t.Errorf("expected the ethereum validator to accept the phonon, got %v, %v", valid, err)
+	}
+	_, err = ValidateForCurrency(newTestPhonon(t, model.Bitcoin))
+	if !errors.Is(err, ErrNoValidator) {
+		t.Errorf("expected %v for a currency without a validator, got %v", ErrNoValidator, err)
+	}
+}
+
+// slowBackend is a validator whose backend answers pings once released
+type slowBackend struct {
+	pinged  chan struct{}
+	release chan struct{}
+}
+
+func (s *slowBackend) Validate(phonon *model.Phonon) (bool, error) {
+	return false, nil
+}
+
+func (s *slowBackend) Configured() bool {
+	return true
+}
+
+func (s *slowBackend) Ping(ctx context.Context) error {
+	s.pinged <- struct{}{}
+	<-s.release
+	return nil
+}
+
+func TestCheckSupportedCurrenciesDoesNotBlockRegister(t *testing.T) {
+	slow := &slowBackend{pinged: make(chan struct{}, 2), release: make(chan struct{})}
+	Register(model.Bitcoin, slow)
+	Register(model.Ethereum, slow)
+	defer Unregister(model.Bitcoin)
+	defer Unregister(model.Ethereum)
+	var release sync.Once
+	defer release.Do(func() { close(slow.release) })
+
+	checked := make(chan []CurrencyStatus)
+	go func() {
+		checked <- CheckSupportedCurrencies(context.Background())
+	}()
+	//both backends are pinged at once
+	for i := 0; i < 2; i++ {
+		select {
+		case <-slow.pinged:
+		case <-time.After(time.Second):
+			t.Fatal("expected the backends to be pinged concurrently")
+		}
+	}
+
+	registered := make(chan struct{})
+	go func() {
+		Register(model.Ethereum, NewMockValidator())
+		close(registered)
+	}()
+	select {
+	case <-registered:
+	case <-time.After(time.Second):
+		t.Error("expected Register not to wait for backends to be pinged")
+	}
+
+	release.Do(func() { close(slow.release) })
+	statuses := <-checked
+	if len(statuses) != 2 || !statuses[0].Reachable || !statuses[1].Reachable {
+		t.Errorf("expected both backends to be reachable, got %+v", statuses)
+	}
+}
@@ -0,0 +1,127 @@
+package validator
+
+import (
+	"context"
+	"errors"
+
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+var ErrInvalidGapLimit = errors.New("gap limit must be positive")
+var ErrWrongNetwork = errors.New("extended key is for another network than the validator's")
+
+// DefaultGapLimit is the number of consecutive unused addresses most wallets scan before stopping
+const DefaultGapLimit = 20
+
+// AddressDeriver returns the address at index of an account
+type AddressDeriver func(index uint32) (string, error)
+
+// FundedAddress is a scanned address holding unspent outputs
+type FundedAddress struct {
+	Index   uint32
+	Address string
+	Balance int64
+}
+
+/*
+XPubAddressDeriver derives the P2PKH addresses on the external chain (m/0/i) of an extended public key,
+encoded for the validator's network. A key for another network fails with ErrWrongNetwork.
+A private extended key is accepted but only its public half is used.
+*/
+func (b *BTCValidator) XPubAddressDeriver(xpub string) (AddressDeriver, error) {
+	key, err := hdkeychain.NewKeyFromString(xpub)
+	if err != nil {
+		return nil, err
+	}
+	if !key.IsForNet(b.params) {
+		return nil, ErrWrongNetwork
+	}
+	key, err = key.Neuter()
+	if err != nil {
+		return nil, err
+	}
+	external, err := key.Derive(0)
+	if err != nil {
+		return nil, err
+	}
+	return func(index uint32) (string, error) {
+		child, err := external.Derive(index)
+		if err != nil {
+			return "", err
+		}
+		address, err := child.Address(b.params)
+		if err != nil {
+			return "", err
+		}
+		return address.EncodeAddress(), nil
+	}, nil
+}
+
+/*
+ScanAddresses checks the addresses produced by derive in order, stopping once gapLimit consecutive
+addresses have never been used, and returns the ones currently holding funds.
+An address counts as used once it has any transaction history, so a spent address doesn't end the gap
+early and a deposit to any of the next gapLimit unused addresses is still found.
+*/
+func (b *BTCValidator) ScanAddresses(ctx context.Context, derive AddressDeriver, gapLimit int) ([]FundedAddress, error) {
+	if !b.Configured() {
+		return nil, ErrBackendUnavailable
+	}
+	return scanAddresses(ctx, derive, gapLimit, b.addressActivity)
+}
+
+// addressActivity reports whether an address has ever been used and its unspent balance
+func (b *BTCValidator) addressActivity(ctx context.Context, address string) (bool, int64, error) {
+	transactions, err := b.bclient.GetTransactions(ctx, []string{address})
+	if err != nil {
+		return false, 0, err
+	}
+	if len(transactions) == 0 {
+		return false, 0, nil
+	}
+	coins, err := b.bclient.GetCoins(ctx, []string{address})
+	if err != nil {
+		return false, 0, err
+	}
+	var balance int64
+	for _, c := range coins {
+		balance += c.Value
+	}
+	return true, balance, nil
+}
+
+func scanAddresses(ctx context.Context, derive AddressDeriver, gapLimit int,
+	activity func(ctx context.Context, address string) (used bool, balance int64, err error)) ([]FundedAddress, error) {
+	if gapLimit <= 0 {
+		return nil, ErrInvalidGapLimit
+	}
+	var funded []FundedAddress
+	gap := 0
+	for index := uint32(0); gap < gapLimit; index++ {
+		err := ctx.Err()
+		if err != nil {
+			return funded, err
+		}
+		address, err := derive(index)
+		if err != nil {
+			return funded, err
+		}
+		used, balance, err := activity(ctx, address)
+		if err != nil {
+			return funded, err
+		}
+		if !used {
+			gap++
+			continue
+		}
+		gap = 0
+		if balance > 0 {
+			funded = append(funded, FundedAddress{
+				Index:   index,
+				Address: address,
+				Balance: balance,
+			})
+		}
+	}
+	return funded, nil
+}
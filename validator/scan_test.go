@@ -0,0 +1,106 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+func TestScanAddressesStopsAtGapLimit(t *testing.T) {
+	derive := func(index uint32) (string, error) {
+		return fmt.Sprintf("addr%d", index), nil
+	}
+	//addr1 was spent, addr3 and addr6 hold funds
+	history := map[string]int64{
+		"addr1": 0,
+		"addr3": 5000,
+		"addr6": 7000,
+	}
+	var scanned []string
+	activity := func(ctx context.Context, address string) (bool, int64, error) {
+		scanned = append(scanned, address)
+		balance, used := history[address]
+		return used, balance, nil
+	}
+
+	funded, err := scanAddresses(context.Background(), derive, 3, activity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []FundedAddress{
+		{Index: 3, Address: "addr3", Balance: 5000},
+		{Index: 6, Address: "addr6", Balance: 7000},
+	}
+	if !reflect.DeepEqual(funded, expected) {
+		t.Errorf("unexpected funded addresses: %+v", funded)
+	}
+	if len(scanned) != 10 {
+		t.Errorf("expected scan to stop after 3 unused addresses following addr6, scanned %v", scanned)
+	}
+
+	//a smaller gap misses the deposit to addr6
+	scanned = nil
+	funded, err = scanAddresses(context.Background(), derive, 2, activity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(funded) != 1 || len(scanned) != 6 {
+		t.Errorf("unexpected scan with gap limit 2: funded %+v, scanned %v", funded, scanned)
+	}
+
+	_, err = scanAddresses(context.Background(), derive, 0, activity)
+	if err != ErrInvalidGapLimit {
+		t.Errorf("expected ErrInvalidGapLimit, got %v", err)
+	}
+}
+
+func TestXPubAddressDeriver(t *testing.T) {
+	seed := make([]byte, hdkeychain.RecommendedSeedLen)
+	master, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xpub, err := master.Neuter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	validator := NewBTCValidator(nil)
+	derive, err := validator.XPubAddressDeriver(xpub.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	external, err := master.Derive(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index := uint32(0); index < 3; index++ {
+		child, err := external.Derive(index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := child.Address(&chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		address, err := derive(index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if address != expected.EncodeAddress() {
+			t.Errorf("address %d: expected %s, got %s", index, expected.EncodeAddress(), address)
+		}
+	}
+
+	_, err = validator.XPubAddressDeriver("not an xpub")
+	if err == nil {
+		t.Error("expected an invalid extended key to be rejected")
+	}
+	_, err = NewBTCValidatorForNetwork(nil, &chaincfg.TestNet3Params).XPubAddressDeriver(xpub.String())
+	if err != ErrWrongNetwork {
+		t.Errorf("expected %v for a mainnet key on testnet, got %v", ErrWrongNetwork, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 )
 
 var ErrMissingPubKey = errors.New("phonon missing public key")
+var ErrValidationFailed = errors.New("phonon value not backed on chain by its public key")
 
 //Validates that a phonon's presented public key represents an actual crypto asset
 type Validator interface {